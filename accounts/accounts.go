@@ -1,14 +1,17 @@
 package accounts
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"os"
+	"io"
+	"path/filepath"
 	"sort"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/src-bin/substrate/awscfg"
 	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/fileutil"
 	"github.com/src-bin/substrate/naming"
 	"github.com/src-bin/substrate/roles"
 	"github.com/src-bin/substrate/table"
@@ -27,19 +30,75 @@ const (
 	CheatSheetFilename = awscfg.AccountsFilename
 )
 
+// CheatSheet writes substrate.accounts.txt, a listing of every AWS account
+// and the role to assume to access it, to the root of the Substrate
+// repository. It also rewrites substrate.accounts.md and substrate.accounts.csv
+// there if they exist, which is how to opt into those formats.
 func CheatSheet(ctx context.Context, cfg *awscfg.Config) error {
+	sheet, err := newCheatSheet(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	dirname := CheatSheetDirname()
+	for _, format := range []CheatSheetFormat{CheatSheetText, CheatSheetMarkdown, CheatSheetCSV} {
+		pathname := filepath.Join(dirname, cheatSheetFilenames[format])
+		if format != CheatSheetText && !fileutil.Exists(pathname) {
+			continue
+		}
+		b := &bytes.Buffer{}
+		if err := sheet.write(b, format); err != nil {
+			return err
+		}
+		if err := fileutil.WriteFileAtomic(pathname, b.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// TODO If we run `substrate account list` in any directory except exactly the
-	// Substrate repository, this litters extra substrate.accounts.txt files
-	// all over the place. We should either stop writing this file entirely
-	// (which might require making `substrate account list` a lot faster) or use
-	// PathnameInParents to ensure we write to the correct directory.
-	f, err := os.Create(CheatSheetFilename)
+// CheatSheetDirname returns the root of the Substrate repository, where
+// substrate.prefix is, or the current working directory if it can't be found,
+// as is the case very early in `substrate setup`.
+func CheatSheetDirname() string {
+	pathname, err := fileutil.PathnameInParents(naming.PrefixFilename)
+	if err != nil {
+		return "."
+	}
+	return filepath.Dir(pathname)
+}
+
+type CheatSheetFormat string
+
+const (
+	CheatSheetCSV      CheatSheetFormat = "csv"
+	CheatSheetMarkdown CheatSheetFormat = "markdown"
+	CheatSheetText     CheatSheetFormat = "text"
+
+	CheatSheetCSVFilename      = "substrate.accounts.csv"
+	CheatSheetMarkdownFilename = "substrate.accounts.md"
+)
+
+var cheatSheetFilenames = map[CheatSheetFormat]string{
+	CheatSheetCSV:      CheatSheetCSVFilename,
+	CheatSheetMarkdown: CheatSheetMarkdownFilename,
+	CheatSheetText:     CheatSheetFilename,
+}
+
+// FcheatSheet writes the cheat sheet in the given format to w instead of to
+// a file in the root of the Substrate repository.
+func FcheatSheet(ctx context.Context, cfg *awscfg.Config, w io.Writer, format CheatSheetFormat) error {
+	sheet, err := newCheatSheet(ctx, cfg)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	return sheet.write(w, format)
+}
+
+type cheatSheet struct {
+	adminAccountsCells, serviceAccountsCells, specialAccountsCells [][]string
+}
 
+func newCheatSheet(ctx context.Context, cfg *awscfg.Config) (*cheatSheet, error) {
 	adminAccountsCells := table.MakeCells(5, 1)
 	adminAccountsCells[0][0] = "Quality"
 	adminAccountsCells[0][1] = "Account Number"
@@ -63,7 +122,7 @@ func CheatSheet(ctx context.Context, cfg *awscfg.Config) error {
 
 	adminAccounts, serviceAccounts, substrateAccount, auditAccount, deployAccount, managementAccount, networkAccount, err := Grouped(ctx, cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	specialAccountsCells[1][0] = Management
@@ -132,26 +191,64 @@ func CheatSheet(ctx context.Context, cfg *awscfg.Config) error {
 		})
 	}
 
-	fmt.Fprint(f, "Welcome to your Substrate-managed AWS organization!\n")
-	fmt.Fprint(f, "\n")
-	fmt.Fprint(f, "You can find the Substrate documentation at <https://docs.substrate.tools/substrate/>.\n")
-	fmt.Fprint(f, "\n")
-	fmt.Fprint(f, "You're likely to want to use the AWS CLI or Console to explore and manipulate\n")
-	fmt.Fprint(f, "your Organization.  Here are the account numbers and roles you'll need for the\n")
-	fmt.Fprint(f, "special accounts that Substrate manages:\n")
-	fmt.Fprint(f, "\n")
-	table.Ftable(f, specialAccountsCells)
-
-	fmt.Fprint(f, "\n")
-	fmt.Fprint(f, "And here are the account numbers and roles for your service accounts:\n")
-	fmt.Fprint(f, "\n")
-	table.Ftable(f, serviceAccountsCells)
-
-	if len(adminAccounts) > 0 {
-		fmt.Fprint(f, "\n")
-		fmt.Fprint(f, "Finally, here are the account numbers and roles for your admin accounts:\n")
-		fmt.Fprint(f, "\n")
-		table.Ftable(f, adminAccountsCells)
+	return &cheatSheet{
+		adminAccountsCells:   adminAccountsCells,
+		serviceAccountsCells: serviceAccountsCells,
+		specialAccountsCells: specialAccountsCells,
+	}, nil
+}
+
+// csvCells merges all three tables into one, with a column to say which each
+// row came from, because spreadsheets are more useful that way.
+func (sheet *cheatSheet) csvCells() [][]string {
+	cells := [][]string{{"Type", "Account Name", "Domain", "Environment", "Quality", "Account Number", "Role Name", "Role ARN", "Version"}}
+	for _, row := range sheet.specialAccountsCells[1:] {
+		cells = append(cells, []string{"special", row[0], "", "", "", row[1], row[2], row[3], row[4]})
+	}
+	for _, row := range sheet.serviceAccountsCells[1:] {
+		cells = append(cells, []string{Service, "", row[0], row[1], row[2], row[3], row[4], row[5], row[6]})
+	}
+	for _, row := range sheet.adminAccountsCells[1:] {
+		cells = append(cells, []string{Admin, "", Admin, Admin, row[0], row[1], row[2], row[3], row[4]})
+	}
+	return cells
+}
+
+func (sheet *cheatSheet) write(w io.Writer, format CheatSheetFormat) error {
+	var ftable func(io.Writer, [][]string)
+	switch format {
+	case CheatSheetCSV:
+		return table.Fcsv(w, sheet.csvCells())
+	case CheatSheetMarkdown:
+		ftable = table.Fmarkdown
+		fmt.Fprint(w, "# AWS accounts\n")
+		fmt.Fprint(w, "\n")
+	case CheatSheetText:
+		ftable = table.Ftable
+	default:
+		return fmt.Errorf("cheat sheet format %q not supported", format)
+	}
+
+	fmt.Fprint(w, "Welcome to your Substrate-managed AWS organization!\n")
+	fmt.Fprint(w, "\n")
+	fmt.Fprint(w, "You can find the Substrate documentation at <https://docs.substrate.tools/substrate/>.\n")
+	fmt.Fprint(w, "\n")
+	fmt.Fprint(w, "You're likely to want to use the AWS CLI or Console to explore and manipulate\n")
+	fmt.Fprint(w, "your Organization.  Here are the account numbers and roles you'll need for the\n")
+	fmt.Fprint(w, "special accounts that Substrate manages:\n")
+	fmt.Fprint(w, "\n")
+	ftable(w, sheet.specialAccountsCells)
+
+	fmt.Fprint(w, "\n")
+	fmt.Fprint(w, "And here are the account numbers and roles for your service accounts:\n")
+	fmt.Fprint(w, "\n")
+	ftable(w, sheet.serviceAccountsCells)
+
+	if len(sheet.adminAccountsCells) > 1 {
+		fmt.Fprint(w, "\n")
+		fmt.Fprint(w, "Finally, here are the account numbers and roles for your admin accounts:\n")
+		fmt.Fprint(w, "\n")
+		ftable(w, sheet.adminAccountsCells)
 	}
 
 	return nil
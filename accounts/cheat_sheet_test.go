@@ -0,0 +1,69 @@
+package accounts
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testCheatSheet() *cheatSheet {
+	return &cheatSheet{
+		adminAccountsCells: [][]string{
+			{"Quality", "Account Number", "Role Name", "Role ARN", "Version"},
+		},
+		serviceAccountsCells: [][]string{
+			{"Domain", "Environment", "Quality", "Account Number", "Role Name", "Role ARN", "Version"},
+			{"www", "production", "default", "234567890123", "Administrator", "arn:aws:iam::234567890123:role/Administrator", "2024.01"},
+		},
+		specialAccountsCells: [][]string{
+			{"Account Name", "Account Number", "Role Name", "Role ARN", "Version"},
+			{"management", "123456789012", "OrganizationAdministrator", "arn:aws:iam::123456789012:role/OrganizationAdministrator", "2024.01"},
+		},
+	}
+}
+
+func TestCheatSheetCSV(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := testCheatSheet().write(b, CheatSheetCSV); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Type,Account Name,Domain,Environment,Quality,Account Number,Role Name,Role ARN,Version",
+		"special,management,,,,123456789012,OrganizationAdministrator,arn:aws:iam::123456789012:role/OrganizationAdministrator,2024.01",
+		"service,,www,production,default,234567890123,Administrator,arn:aws:iam::234567890123:role/Administrator,2024.01",
+	}
+	if actual := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("actual: %q, expected: %q", actual, expected)
+	}
+}
+
+func TestCheatSheetMarkdown(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := testCheatSheet().write(b, CheatSheetMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	s := b.String()
+	for _, expected := range []string{
+		"# AWS accounts\n",
+		"| Account Name | Account Number | Role Name | Role ARN | Version |\n| --- | --- | --- | --- | --- |\n",
+		"| www | production | default | 234567890123 | Administrator | arn:aws:iam::234567890123:role/Administrator | 2024.01 |\n",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("%q doesn't contain %q", s, expected)
+		}
+	}
+	if strings.Contains(s, "admin accounts") {
+		t.Errorf("%q mentions admin accounts but there aren't any", s)
+	}
+}
+
+func TestCheatSheetText(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := testCheatSheet().write(b, CheatSheetText); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); !strings.HasPrefix(s, "Welcome to your Substrate-managed AWS organization!\n") || !strings.Contains(s, "| www    | production  |") {
+		t.Errorf("%q", s)
+	}
+}
@@ -35,6 +35,18 @@ func SetupIAM(
 
 	ui.Must(CheatSheet(ctx, awscfg.Must(mgmtCfg.OrganizationReader(ctx))))
 
+	// Keep the account in the organizational unit for its environment (and
+	// maybe quality), if so configured, so that service control policies
+	// and tag policies may be attached there instead of to each account.
+	if byEnvironment, _, err := OrganizationalUnits(); err != nil {
+		ui.Fatal(err)
+	} else if byEnvironment {
+		ui.Spin("placing the account in its organizational unit")
+		_, parentId, err := PlaceAccount(ctx, mgmtCfg, accountCfg.MustAccountId(ctx), environment, quality)
+		ui.Must(err)
+		ui.Stop(parentId)
+	}
+
 	ui.Spin("configuring IAM")
 	ui.Must2(humans.EnsureAdministratorRole(ctx, mgmtCfg, accountCfg))
 	ui.Must2(humans.EnsureAuditorRole(ctx, mgmtCfg, accountCfg))
@@ -52,7 +64,7 @@ func SetupIAM(
 	const cloudwatchRoleName = "CloudWatch-CrossAccountSharingRole"
 	orgAssumeRolePolicy, err := awsorgs.OrgAssumeRolePolicy(ctx, mgmtCfg)
 	ui.Must(err)
-	cloudwatchRole, err := awsiam.EnsureRole(ctx, accountCfg, cloudwatchRoleName, orgAssumeRolePolicy)
+	cloudwatchRole, err := awsiam.EnsureRole(ctx, accountCfg, cloudwatchRoleName, orgAssumeRolePolicy, nil)
 	ui.Must(err)
 	ui.Must(awsiam.AttachRolePolicy(ctx, accountCfg, cloudwatchRole.Name, "arn:aws:iam::aws:policy/job-function/ViewOnlyAccess"))
 	ui.Must(awsiam.AttachRolePolicy(ctx, accountCfg, cloudwatchRole.Name, "arn:aws:iam::aws:policy/AWSXrayReadOnlyAccess"))
@@ -0,0 +1,97 @@
+package accounts
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/tagging"
+	"github.com/src-bin/substrate/ui"
+	"github.com/src-bin/substrate/version"
+)
+
+const (
+	OrganizationalUnitsFilename        = "substrate.organizational-units"
+	QualityOrganizationalUnitsFilename = "substrate.quality-organizational-units"
+)
+
+// OrganizationalUnits reads substrate.organizational-units and
+// substrate.quality-organizational-units to determine whether service
+// accounts should be kept in organizational units named after their
+// environment and, further, whether those should contain organizational
+// units named after each quality. Both default to false, meaning service
+// accounts live directly in the organization's root as they always have.
+func OrganizationalUnits() (byEnvironment, byQuality bool, err error) {
+	if byEnvironment, err = ui.ConfirmFile(OrganizationalUnitsFilename); err != nil || !byEnvironment {
+		return
+	}
+	byQuality, err = ui.ConfirmFile(QualityOrganizationalUnitsFilename)
+	return
+}
+
+// EnsureOrganizationalUnit finds or creates the organizational unit where
+// service accounts in the given environment and quality belong and returns
+// its ID. If organizational units aren't enabled, it returns the ID of the
+// root. The *Config must be in the management account.
+func EnsureOrganizationalUnit(
+	ctx context.Context,
+	mgmtCfg *awscfg.Config,
+	environment, quality string,
+) (string, error) {
+	byEnvironment, byQuality, err := OrganizationalUnits()
+	if err != nil {
+		return "", err
+	}
+	root, err := awsorgs.DescribeRoot(ctx, mgmtCfg)
+	if err != nil {
+		return "", err
+	}
+	parentId := aws.ToString(root.Id)
+	if !byEnvironment {
+		return parentId, nil
+	}
+
+	ou, err := awsorgs.EnsureOrganizationalUnit(ctx, mgmtCfg, parentId, environment, tagging.Map{
+		tagging.Environment:      environment,
+		tagging.SubstrateVersion: version.Version,
+	})
+	if err != nil {
+		return "", err
+	}
+	parentId = aws.ToString(ou.Id)
+	if !byQuality {
+		return parentId, nil
+	}
+
+	ou, err = awsorgs.EnsureOrganizationalUnit(ctx, mgmtCfg, parentId, quality, tagging.Map{
+		tagging.Environment:      environment,
+		tagging.Quality:          quality,
+		tagging.SubstrateVersion: version.Version,
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(ou.Id), nil
+}
+
+// PlaceAccount moves an account into the organizational unit where service
+// accounts in the given environment and quality belong, creating that
+// organizational unit if necessary. It returns the IDs of the parent the
+// account was found in and the one it's now in, which are equal if the
+// account was already in the right place. The *Config must be in the
+// management account.
+func PlaceAccount(
+	ctx context.Context,
+	mgmtCfg *awscfg.Config,
+	accountId, environment, quality string,
+) (sourceParentId, destinationParentId string, err error) {
+	if sourceParentId, err = awsorgs.DescribeParent(ctx, mgmtCfg, accountId); err != nil {
+		return
+	}
+	if destinationParentId, err = EnsureOrganizationalUnit(ctx, mgmtCfg, environment, quality); err != nil {
+		return
+	}
+	err = awsorgs.MoveAccount(ctx, mgmtCfg, accountId, sourceParentId, destinationParentId)
+	return
+}
@@ -326,8 +326,9 @@ func (s *Selection) String() string {
 	return strings.Join(s.Arguments(), " ")
 }
 
-func (s *Selection) Validate() error {
-	if !s.AllDomains &&
+// Empty returns true if no account selection flags were given.
+func (s *Selection) Empty() bool {
+	return !s.AllDomains &&
 		len(s.Domains) == 0 &&
 		!s.AllEnvironments &&
 		len(s.Environments) == 0 &&
@@ -337,7 +338,11 @@ func (s *Selection) Validate() error {
 		!s.Humans &&
 		!s.Management &&
 		len(s.Specials) == 0 &&
-		len(s.Numbers) == 0 {
+		len(s.Numbers) == 0
+}
+
+func (s *Selection) Validate() error {
+	if s.Empty() {
 		return SelectionError("at least one account selection flag is required")
 	}
 
@@ -33,9 +33,15 @@ func TestSelectionAllService(t *testing.T) {
 
 func TestSelectionEmpty(t *testing.T) {
 	selection := Selection{}
+	if !selection.Empty() {
+		t.Fatal("Selection{}.Empty() is false")
+	}
 	if err := selection.Validate(); err == nil {
 		t.Fatal(err)
 	}
+	if (&Selection{Domains: []string{"foo"}}).Empty() {
+		t.Fatal("Selection{Domains: []string{\"foo\"}}.Empty() is true")
+	}
 }
 
 func TestSelectionFoo(t *testing.T) {
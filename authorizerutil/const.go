@@ -5,7 +5,9 @@ const (
 	AccessToken = "AccessToken"
 	IDToken     = "IDToken"
 	PrincipalId = "principalId" // lowercase because that's how it was in API Gateway v1
-	RoleName    = "RoleName"
+	RoleName    = "RoleName"    // the default of RoleNames
+	RoleNames   = "RoleNames"   // JSON-encoded because context values must be scalars
+	SetCookies  = "SetCookies"  // JSON-encoded Set-Cookie headers for the handler to send, since the authorizer can't
 
 	Error = "Error"
 
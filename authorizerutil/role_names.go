@@ -0,0 +1,52 @@
+package authorizerutil
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// AllowedRoleNames returns all the role names the authorizer allowed, given
+// event.RequestContext.Authorizer.Lambda, which will be just RoleName if
+// group-based role mapping isn't configured.
+func AllowedRoleNames(authorizer map[string]interface{}) []string {
+	var roleNames []string
+	if s, ok := authorizer[RoleNames].(string); ok {
+		if err := json.Unmarshal([]byte(s), &roleNames); err == nil && len(roleNames) > 0 {
+			return roleNames
+		}
+	}
+	if roleName, ok := authorizer[RoleName].(string); ok && roleName != "" {
+		return []string{roleName}
+	}
+	return nil
+}
+
+// MarshalRoleNames encodes role names as the authorizer must to put them in
+// its context.
+func MarshalRoleNames(roleNames []string) string {
+	b, err := json.Marshal(roleNames)
+	if err != nil {
+		panic(err) // unreachable because roleNames is only strings
+	}
+	return string(b)
+}
+
+// SelectRoleName returns the requested role name if the authorizer allowed
+// it and RoleName, the default, if requested is empty.
+func SelectRoleName(authorizer map[string]interface{}, requested string) (string, error) {
+	if requested == "" {
+		return fmt.Sprint(authorizer[RoleName]), nil
+	}
+	for _, roleName := range AllowedRoleNames(authorizer) {
+		if roleName == requested {
+			return roleName, nil
+		}
+	}
+	return "", RoleNotAllowedError(requested)
+}
+
+type RoleNotAllowedError string
+
+func (err RoleNotAllowedError) Error() string {
+	return fmt.Sprintf("RoleNotAllowedError: your identity provider doesn't allow you to use the %s role", string(err))
+}
@@ -0,0 +1,45 @@
+package authorizerutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectRoleName(t *testing.T) {
+	authorizer := map[string]interface{}{
+		RoleName:  "Administrator",
+		RoleNames: MarshalRoleNames([]string{"Administrator", "Developer"}),
+	}
+	if actual, expected := AllowedRoleNames(authorizer), []string{"Administrator", "Developer"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("actual: %v, expected: %v", actual, expected)
+	}
+	for requested, expected := range map[string]string{
+		"":              "Administrator",
+		"Administrator": "Administrator",
+		"Developer":     "Developer",
+	} {
+		actual, err := SelectRoleName(authorizer, requested)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("SelectRoleName(%q): actual: %s, expected: %s", requested, actual, expected)
+		}
+	}
+	if _, err := SelectRoleName(authorizer, "Auditor"); err == nil {
+		t.Fatal("expected RoleNotAllowedError")
+	}
+}
+
+func TestSelectRoleNameWithoutRoleNames(t *testing.T) {
+	authorizer := map[string]interface{}{RoleName: "Auditor"} // from before group-based role mapping
+	if actual, expected := AllowedRoleNames(authorizer), []string{"Auditor"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("actual: %v, expected: %v", actual, expected)
+	}
+	if actual, err := SelectRoleName(authorizer, "Auditor"); err != nil || actual != "Auditor" {
+		t.Fatal(actual, err)
+	}
+	if _, err := SelectRoleName(authorizer, "Administrator"); err == nil {
+		t.Fatal("expected RoleNotAllowedError")
+	}
+}
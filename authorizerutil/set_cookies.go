@@ -0,0 +1,24 @@
+package authorizerutil
+
+import "encoding/json"
+
+// MarshalSetCookies encodes Set-Cookie headers as the authorizer must to put
+// them in its context.
+func MarshalSetCookies(setCookies []string) string {
+	b, err := json.Marshal(setCookies)
+	if err != nil {
+		panic(err) // unreachable because setCookies is only strings
+	}
+	return string(b)
+}
+
+// UnmarshalSetCookies returns the Set-Cookie headers the authorizer asked the
+// handler to send, given event.RequestContext.Authorizer.Lambda, e.g. because
+// it renewed the user's tokens.
+func UnmarshalSetCookies(authorizer map[string]interface{}) []string {
+	var setCookies []string
+	if s, ok := authorizer[SetCookies].(string); ok {
+		json.Unmarshal([]byte(s), &setCookies)
+	}
+	return setCookies
+}
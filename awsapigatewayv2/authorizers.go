@@ -74,10 +74,11 @@ func EnsureAuthorizer(
 		return
 	}
 
-	// Use this authorizer for every route except GET /credential-factory/fetch
-	// and {GET,POST} /login. Yes, this is a leak in the abstraction to pretend
-	// this is a generalized AWS API Gateway management client but, hey, it's
-	// not and that doesn't matter to Substrate (yet).
+	// Use this authorizer for every route except GET /credential-factory/fetch,
+	// {GET,POST} /login, and {GET,POST} /logout, which only revokes sessions
+	// on POST with a CSRF token. Yes, this is a leak in the abstraction to
+	// pretend this is a generalized AWS API Gateway management client but,
+	// hey, it's not and that doesn't matter to Substrate (yet).
 	var integration *types.Integration
 	if integration, err = getIntegrationByFunctionARN(ctx, cfg, apiId, functionARN); err != nil {
 		ui.StopErr(err)
@@ -92,6 +93,10 @@ func EnsureAuthorizer(
 		ui.StopErr(err)
 		return
 	}
+	if err = EnsureRoute(ctx, cfg, apiId, []string{"GET", "POST"}, "/logout", "", target); err != nil {
+		ui.StopErr(err)
+		return
+	}
 	if err = UpdateRoute(ctx, cfg, apiId, Default, authorizerId, target); err != nil {
 		ui.StopErr(err)
 		return
@@ -106,6 +106,7 @@ func (c *Config) AssumeRole(
 	accountId string,
 	roleName string,
 	duration time.Duration, // AWS-enforced maximum when crossing accounts per <https://aws.amazon.com/premiumsupport/knowledge-center/iam-role-chaining-limit/> is 1 hour
+	optFns ...func(*stscreds.AssumeRoleOptions), // e.g. to set an external ID or override the session name
 ) (*Config, error) {
 	//ui.Printf("assuming %s in %s", roleName, accountId)
 	if roleName != roles.OrganizationReader {
@@ -158,6 +159,7 @@ func (c *Config) AssumeRole(
 		cfg:               c.cfg.Copy(),
 		deferredTelemetry: c.deferredTelemetry, // better twice than not at all
 		event:             c.event,
+		plan:              c.plan,
 		wd:                c.wd,
 	}
 
@@ -167,6 +169,9 @@ func (c *Config) AssumeRole(
 		func(options *stscreds.AssumeRoleOptions) {
 			options.Duration = duration
 			options.RoleSessionName = roleSessionName
+			for _, f := range optFns {
+				f(options)
+			}
 		},
 	))
 
@@ -189,13 +194,18 @@ func (c *Config) AssumeRole(
 	// assume OrganizationAccountAccessRole, too, which will succeed during
 	// account creation before the Substrate-managed role exists.
 	if err != nil && (roleName == roles.Administrator || roleName == roles.DeployAdministrator || roleName == roles.NetworkAdministrator) {
-		return c.AssumeRole(ctx, accountId, roles.OrganizationAccountAccessRole, duration)
+		return c.AssumeRole(ctx, accountId, roles.OrganizationAccountAccessRole, duration, optFns...)
 	}
 
 	return cfg, err
 }
 
-func (c *Config) AssumeRoleARN(ctx context.Context, roleARN string, duration time.Duration) (*Config, error) {
+func (c *Config) AssumeRoleARN(
+	ctx context.Context,
+	roleARN string,
+	duration time.Duration,
+	optFns ...func(*stscreds.AssumeRoleOptions),
+) (*Config, error) {
 	parsed, err := arn.Parse(roleARN)
 	if err != nil {
 		return nil, err
@@ -203,9 +213,47 @@ func (c *Config) AssumeRoleARN(ctx context.Context, roleARN string, duration tim
 	if !strings.HasPrefix(parsed.Resource, "role/") {
 		return nil, roles.ARNError(roleARN)
 	}
-	return c.AssumeRole(ctx, parsed.AccountID, strings.TrimPrefix(parsed.Resource, "role/"), duration)
+	return c.AssumeRole(ctx, parsed.AccountID, strings.TrimPrefix(parsed.Resource, "role/"), duration, optFns...)
 }
 
+// RoleChainHop is one role to assume on the way to another, perhaps in an
+// AWS account outside the organization that requires an external ID.
+type RoleChainHop struct {
+	ARN, ExternalId, SessionName string
+}
+
+// AssumeRoleChain assumes each role in hops in turn, starting with the
+// receiver, and returns a *Config with the last one. Every hop after the
+// first is role chaining so duration can't exceed one hour.
+func (c *Config) AssumeRoleChain(ctx context.Context, hops []RoleChainHop, duration time.Duration) (cfg *Config, err error) {
+	cfg = c
+	for _, hop := range hops {
+		hop := hop
+		if cfg, err = cfg.AssumeRoleARN(ctx, hop.ARN, duration, func(options *stscreds.AssumeRoleOptions) {
+			if hop.ExternalId != "" {
+				options.ExternalID = aws.String(hop.ExternalId)
+			}
+			if hop.SessionName != "" {
+				options.RoleSessionName = hop.SessionName
+			}
+		}); err != nil {
+			return nil, RoleChainError{hop.ARN, err}
+		}
+	}
+	return cfg, nil
+}
+
+type RoleChainError struct {
+	ARN string
+	Err error
+}
+
+func (err RoleChainError) Error() string {
+	return fmt.Sprintf("RoleChainError: couldn't assume %s: %v", err.ARN, err.Err)
+}
+
+func (err RoleChainError) Unwrap() error { return err.Err }
+
 // AssumeServiceRole assumes the given role in the service account identified
 // by the given domain, environment, and quality. It can be called on any
 // *Config but is most often (and most effectively) called on one with the
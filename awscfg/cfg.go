@@ -41,6 +41,7 @@ type Config struct {
 	event                   *telemetry.Event
 	getCallerIdentityOutput *sts.GetCallerIdentityOutput // cache
 	organization            *Organization                // cache
+	plan                    *Plan                        // nil unless planning
 	wd                      string                       // detect os.Chdir to bust cache
 }
 
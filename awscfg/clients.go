@@ -24,81 +24,81 @@ import (
 )
 
 func (c *Config) ACM() *acm.Client {
-	return acm.NewFromConfig(c.cfg) // TODO memoize regionally
+	return acm.NewFromConfig(c.awsConfig((*acm.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) APIGateway() *apigateway.Client {
-	return apigateway.NewFromConfig(c.cfg) // TODO memoize regionally
+	return apigateway.NewFromConfig(c.awsConfig((*apigateway.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) APIGatewayV2() *apigatewayv2.Client {
-	return apigatewayv2.NewFromConfig(c.cfg) // TODO memoize regionally
+	return apigatewayv2.NewFromConfig(c.awsConfig((*apigatewayv2.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) CloudFront() *cloudfront.Client {
-	return cloudfront.NewFromConfig(c.cfg) // TODO memoize
+	return cloudfront.NewFromConfig(c.awsConfig((*cloudfront.Client)(nil))) // TODO memoize
 }
 
 func (c *Config) CloudTrail() *cloudtrail.Client {
-	return cloudtrail.NewFromConfig(c.cfg) // TODO memoize regionally
+	return cloudtrail.NewFromConfig(c.awsConfig((*cloudtrail.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) CloudWatchLogs() *cloudwatchlogs.Client {
-	return cloudwatchlogs.NewFromConfig(c.cfg) // TODO memoize regionally
+	return cloudwatchlogs.NewFromConfig(c.awsConfig((*cloudwatchlogs.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) DynamoDB() *dynamodb.Client {
-	return dynamodb.NewFromConfig(c.cfg) // TODO memoize regionally
+	return dynamodb.NewFromConfig(c.awsConfig((*dynamodb.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) EC2() *ec2.Client {
-	return ec2.NewFromConfig(c.cfg) // TODO memoize regionally
+	return ec2.NewFromConfig(c.awsConfig((*ec2.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) IAM() *iam.Client {
-	return iam.NewFromConfig(c.cfg) // TODO memoize
+	return iam.NewFromConfig(c.awsConfig((*iam.Client)(nil))) // TODO memoize
 }
 
 func (c *Config) IdentityStore() *identitystore.Client {
-	return identitystore.NewFromConfig(c.cfg) // TODO memoize regionally
+	return identitystore.NewFromConfig(c.awsConfig((*identitystore.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) Lambda() *lambda.Client {
-	return lambda.NewFromConfig(c.cfg) // TODO memoize regionally
+	return lambda.NewFromConfig(c.awsConfig((*lambda.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) Organizations() *organizations.Client {
-	return organizations.NewFromConfig(c.cfg) // TODO memoize
+	return organizations.NewFromConfig(c.awsConfig((*organizations.Client)(nil))) // TODO memoize
 }
 
 func (c *Config) RAM() *ram.Client {
-	return ram.NewFromConfig(c.cfg) // TODO memoize (regionally?)
+	return ram.NewFromConfig(c.awsConfig((*ram.Client)(nil))) // TODO memoize (regionally?)
 }
 
 func (c *Config) Route53() *route53.Client {
-	return route53.NewFromConfig(c.cfg) // TODO memoize
+	return route53.NewFromConfig(c.awsConfig((*route53.Client)(nil))) // TODO memoize
 }
 
 func (c *Config) S3() *s3.Client {
-	return s3.NewFromConfig(c.cfg) // TODO memoize regionally
+	return s3.NewFromConfig(c.awsConfig((*s3.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) SSO() *sso.Client {
-	return sso.NewFromConfig(c.cfg) // TODO memoize regionally
+	return sso.NewFromConfig(c.awsConfig((*sso.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) SSOAdmin() *ssoadmin.Client {
-	return ssoadmin.NewFromConfig(c.cfg) // TODO memoize regionally
+	return ssoadmin.NewFromConfig(c.awsConfig((*ssoadmin.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) STS() *sts.Client {
-	return sts.NewFromConfig(c.cfg) // TODO memoize
+	return sts.NewFromConfig(c.awsConfig((*sts.Client)(nil))) // TODO memoize
 }
 
 func (c *Config) SecretsManager() *secretsmanager.Client {
-	return secretsmanager.NewFromConfig(c.cfg) // TODO memoize regionally
+	return secretsmanager.NewFromConfig(c.awsConfig((*secretsmanager.Client)(nil))) // TODO memoize regionally
 }
 
 func (c *Config) ServiceQuotas() *servicequotas.Client {
-	return servicequotas.NewFromConfig(c.cfg) // TODO memoize regionally
+	return servicequotas.NewFromConfig(c.awsConfig((*servicequotas.Client)(nil))) // TODO memoize regionally
 }
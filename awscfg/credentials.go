@@ -139,6 +139,23 @@ func (c *Config) Retrieve(ctx context.Context) (aws.Credentials, error) {
 	return c.cfg.Credentials.Retrieve(ctx)
 }
 
+// RetrieveFresh is like Retrieve but, if the cached credentials expire
+// within window, it invalidates them and retrieves new ones so that they'll
+// last at least that long. For credentials from assuming a role, that means
+// assuming the role again, re-minting any roles it was chained from that
+// have expired, too.
+func (c *Config) RetrieveFresh(ctx context.Context, window time.Duration) (aws.Credentials, error) {
+	creds, err := c.Retrieve(ctx)
+	if err != nil || !creds.CanExpire || time.Until(creds.Expires) > window {
+		return creds, err
+	}
+	if cache, ok := c.cfg.Credentials.(*aws.CredentialsCache); ok {
+		cache.Invalidate()
+		return c.Retrieve(ctx)
+	}
+	return creds, nil
+}
+
 // SetCredentials reconfigures the receiver to use the given credentials
 // (whether root, user, or session credentials) and waits until they begin
 // working (which concerns mostly user credentials). It returns the caller
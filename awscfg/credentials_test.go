@@ -0,0 +1,51 @@
+package awscfg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+type countingProvider struct {
+	lifetime time.Duration
+	n        int
+}
+
+func (p *countingProvider) Retrieve(context.Context) (aws.Credentials, error) {
+	p.n++
+	return aws.Credentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+		CanExpire:       true,
+		Expires:         time.Now().Add(p.lifetime),
+	}, nil
+}
+
+func TestRetrieveFresh(t *testing.T) {
+	ctx := context.Background()
+
+	p := &countingProvider{lifetime: time.Hour}
+	c := &Config{cfg: aws.Config{Credentials: aws.NewCredentialsCache(p)}}
+	for i := 0; i < 2; i++ {
+		if _, err := c.RetrieveFresh(ctx, 15*time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.n != 1 {
+		t.Fatalf("retrieved %d times; should have used cached credentials that don't expire for an hour", p.n)
+	}
+
+	p = &countingProvider{lifetime: 10 * time.Minute}
+	c = &Config{cfg: aws.Config{Credentials: aws.NewCredentialsCache(p)}}
+	if _, err := c.Retrieve(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.RetrieveFresh(ctx, 15*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if p.n != 2 {
+		t.Fatalf("retrieved %d times; should have re-minted credentials that expire within the window", p.n)
+	}
+}
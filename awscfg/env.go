@@ -14,10 +14,15 @@ const (
 	SUBSTRATE_CREDENTIALS_EXPIRATION = "SUBSTRATE_CREDENTIALS_EXPIRATION"
 )
 
+// Getenv returns the credentials in the environment, including when they
+// expire if Setenv or `eval $(substrate credentials)` put that there, too.
 func Getenv() (creds aws.Credentials) {
 	creds.AccessKeyID = os.Getenv(AWS_ACCESS_KEY_ID)
 	creds.SecretAccessKey = os.Getenv(AWS_SECRET_ACCESS_KEY)
 	creds.SessionToken = os.Getenv(AWS_SESSION_TOKEN)
+	if expires, err := time.Parse(time.RFC3339, os.Getenv(SUBSTRATE_CREDENTIALS_EXPIRATION)); err == nil {
+		creds.CanExpire, creds.Expires = true, expires
+	}
 	return
 }
 
@@ -3,7 +3,6 @@ package awscfg
 import (
 	"context"
 	"fmt"
-	"io/fs"
 	"path/filepath"
 	"time"
 
@@ -20,16 +19,29 @@ import (
 const (
 	AccountsFilename       = "substrate.accounts.txt"
 	CachedAccountsFilename = ".substrate.accounts.json" // cached on disk (obviously)
+	CachedAccountsTTL      = 24 * time.Hour             // cached on disk
 	MemoizedAccountsTTL    = time.Hour                  // memoized in memory
 )
 
+// ClearCachedAccounts forgets the accounts memoized in memory and removes
+// the cache on disk. Functions in awsorgs that create, close, or tag accounts
+// call this automatically.
 func (c *Config) ClearCachedAccounts() error {
 	c.accounts = nil
 	c.accountsExpiry = time.Time{}
-	pathname, err := fileutil.PathnameInParents(CachedAccountsFilename)
-	if err != nil && err != fs.ErrNotExist {
+
+	// Wait for any other process that's refreshing the cache, even if the
+	// cache doesn't exist yet, lest it write what it fetched before whatever
+	// change prompted this.
+	pathname := cachedAccountsPathname()
+	if pathname == "" {
+		return nil
+	}
+	unlock, err := fileutil.Lock(pathname + ".lock")
+	if err != nil {
 		return err
 	}
+	defer unlock()
 	return fileutil.Remove(pathname)
 }
 
@@ -152,7 +164,23 @@ func (c *Config) ListAccounts(ctx context.Context) (accounts []*Account, err err
 	if accounts, err = c.listCachedAccounts(); accounts != nil || err != nil {
 		return
 	}
+
+	// Only one process at a time refreshes the cache. Any others wait here
+	// and then use what it fetched.
+	cachePathname := cachedAccountsPathname()
+	if cachePathname != "" {
+		var unlock func() error
+		if unlock, err = fileutil.Lock(cachePathname + ".lock"); err != nil {
+			return
+		}
+		defer unlock()
+		if accounts, err = c.listCachedAccounts(); accounts != nil || err != nil {
+			return
+		}
+	}
+
 	ui.Spin("fetching a list of all your AWS accounts and their tags")
+	fetchedAt := time.Now()
 
 	var cfg *Config
 	if cfg, err = c.OrganizationReader(ctx); err != nil {
@@ -205,9 +233,12 @@ func (c *Config) ListAccounts(ctx context.Context) (accounts []*Account, err err
 
 	// Cache the full ListAccounts and ListTagsForResource amalgamation. Do not
 	// treat an error here as fatal, since all it would do is slow us down.
-	if pathname, err := fileutil.PathnameInParents(AccountsFilename); err == nil {
-		pathname = filepath.Join(filepath.Dir(pathname), CachedAccountsFilename)
-		if err := jsonutil.Write(accounts, pathname); err != nil {
+	if cachePathname != "" {
+		cache := &cachedAccounts{FetchedAt: fetchedAt, Accounts: accounts}
+		if org, err := cfg.DescribeOrganization(ctx); err == nil {
+			cache.OrganizationId = aws.ToString(org.Id)
+		}
+		if err := jsonutil.WriteAtomic(cache, cachePathname); err != nil {
 			ui.Print(err)
 		}
 	}
@@ -225,16 +256,49 @@ func (c *Config) listCachedAccounts() ([]*Account, error) {
 	}
 
 	if pathname, err := fileutil.PathnameInParents(CachedAccountsFilename); err == nil {
-		var accounts []*Account
-		if err := jsonutil.Read(pathname, &accounts); err == nil {
-			c.memoizeAccounts(accounts)
-			return accounts, nil
+		var cache cachedAccounts
+		if err := jsonutil.Read(pathname, &cache); err == nil && cache.fresh(c.organization, time.Now()) {
+			c.memoizeAccounts(cache.Accounts)
+			return cache.Accounts, nil
 		}
 	}
 
 	return nil, nil // cache miss
 }
 
+// cachedAccounts is the document in CachedAccountsFilename. The list of
+// accounts it contains is stale if it's older than CachedAccountsTTL or if it
+// was fetched from a different organization.
+type cachedAccounts struct {
+	OrganizationId string
+	FetchedAt      time.Time
+	Accounts       []*Account
+}
+
+// fresh returns true if the cache may be used at the given time. org may be
+// nil, in which case the organization isn't checked, because it's not worth
+// calling DescribeOrganization to avoid calling ListAccounts.
+func (cache *cachedAccounts) fresh(org *Organization, now time.Time) bool {
+	if cache.Accounts == nil || now.Sub(cache.FetchedAt) > CachedAccountsTTL {
+		return false
+	}
+	if org != nil && cache.OrganizationId != aws.ToString(org.Id) {
+		return false
+	}
+	return true
+}
+
+// cachedAccountsPathname returns the pathname where ListAccounts caches
+// accounts, beside substrate.accounts.txt, or the empty string if that file
+// doesn't exist, e.g. in Lambda.
+func cachedAccountsPathname() string {
+	pathname, err := fileutil.PathnameInParents(AccountsFilename)
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(pathname), CachedAccountsFilename)
+}
+
 func (c *Config) memoizeAccounts(accounts []*Account) {
 	c.accounts = accounts
 	c.accountsExpiry = time.Now().Add(MemoizedAccountsTTL)
@@ -0,0 +1,28 @@
+package awscfg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestCachedAccountsFresh(t *testing.T) {
+	now := time.Now()
+	org := &Organization{Id: aws.String("o-abcdefghij")}
+	for i, test := range []struct {
+		cache *cachedAccounts
+		org   *Organization
+		fresh bool
+	}{
+		{&cachedAccounts{OrganizationId: "o-abcdefghij", FetchedAt: now.Add(-time.Hour), Accounts: []*Account{}}, org, true},
+		{&cachedAccounts{OrganizationId: "o-abcdefghij", FetchedAt: now.Add(-time.Hour), Accounts: []*Account{}}, nil, true},
+		{&cachedAccounts{OrganizationId: "o-klmnopqrst", FetchedAt: now.Add(-time.Hour), Accounts: []*Account{}}, org, false},
+		{&cachedAccounts{OrganizationId: "o-abcdefghij", FetchedAt: now.Add(-CachedAccountsTTL - time.Second), Accounts: []*Account{}}, org, false},
+		{&cachedAccounts{}, org, false}, // e.g. the old format, which was just an array
+	} {
+		if fresh := test.cache.fresh(test.org, now); fresh != test.fresh {
+			t.Errorf("test %d: fresh: %v, expected %v", i, fresh, test.fresh)
+		}
+	}
+}
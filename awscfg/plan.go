@@ -0,0 +1,287 @@
+package awscfg
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/src-bin/substrate/ui"
+)
+
+const (
+	PlanCreate = "create"
+	PlanDelete = "delete"
+	PlanTag    = "tag"
+	PlanUpdate = "update"
+
+	Planned = "(planned)" // placeholder for identifiers AWS would have assigned
+)
+
+// Change is one AWS API request that would have mutated the organization
+// had we not been planning.
+type Change struct {
+	AccountId, Region  string
+	Action             string // PlanCreate, PlanDelete, PlanTag, or PlanUpdate
+	Service, Operation string
+	Resource           string // a best-effort summary of the names, IDs, and ARNs in Input
+	Input              interface{}
+}
+
+func (change Change) String() string {
+	s := fmt.Sprintf("%-6s %s:%s", change.Action, change.Service, change.Operation)
+	if change.Resource != "" {
+		s += " " + change.Resource
+	}
+	return s
+}
+
+// Plan records the changes that would have been made by every Config that
+// shares it. Read-only AWS API requests are sent as usual so that discovery
+// works but everything else is intercepted, recorded, and answered with a
+// placeholder response so that callers can carry on.
+type Plan struct {
+	changes []Change
+	mu      sync.Mutex
+}
+
+// Changes returns every change recorded so far, grouped by account and
+// region but otherwise in the order they were recorded.
+func (p *Plan) Changes() []Change {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changes := make([]Change, len(p.changes))
+	copy(changes, p.changes)
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].AccountId != changes[j].AccountId {
+			return changes[i].AccountId < changes[j].AccountId
+		}
+		return changes[i].Region < changes[j].Region
+	})
+	return changes
+}
+
+// Print prints every change recorded so far grouped by account and region
+// followed by a count of each kind of change.
+func (p *Plan) Print() {
+	changes := p.Changes()
+	if len(changes) == 0 {
+		ui.Print("no changes planned")
+		return
+	}
+	counts := make(map[string]int)
+	var accountId, region string
+	for i, change := range changes {
+		if i == 0 || change.AccountId != accountId || change.Region != region {
+			accountId, region = change.AccountId, change.Region
+			ui.Printf("account %s, region %s:", accountId, region)
+		}
+		ui.Printf("\t%s", change)
+		counts[change.Action]++
+	}
+	ui.Printf(
+		"%d to create, %d to update, %d to tag, %d to delete",
+		counts[PlanCreate],
+		counts[PlanUpdate],
+		counts[PlanTag],
+		counts[PlanDelete],
+	)
+}
+
+func (p *Plan) apiOption(c *Config, client interface{}) func(*middleware.Stack) error {
+	clientType := reflect.TypeOf(client)
+	service := path.Base(clientType.Elem().PkgPath())
+	return func(stack *middleware.Stack) error {
+		operation := stack.ID()
+		if readOnly(service, operation) {
+			return nil
+		}
+		method, ok := clientType.MethodByName(operation)
+		if !ok {
+			return fmt.Errorf("can't plan %s:%s because %v has no such method", service, operation, clientType)
+		}
+		outputType := method.Type.Out(0).Elem()
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc(
+			"SubstratePlan",
+			func(ctx context.Context, in middleware.InitializeInput, _ middleware.InitializeHandler) (
+				out middleware.InitializeOutput,
+				metadata middleware.Metadata,
+				err error,
+			) {
+				key := service + ":" + operation
+
+				// Let Ensure* functions that create first and ask questions
+				// later see the same error they would if we weren't planning.
+				if f, ok := planExistenceChecks[key]; ok {
+					var code string
+					if code, err = f(ctx, c, in.Parameters); err != nil {
+						return
+					}
+					if code != "" {
+						err = &smithy.GenericAPIError{Code: code, Message: "already exists"}
+						return
+					}
+				}
+
+				out.Result = placeholder(reflect.New(outputType), reflect.ValueOf(in.Parameters), 0).Interface()
+
+				// Don't bother recording updates that wouldn't change anything.
+				if f, ok := planUnchangedChecks[key]; ok {
+					var unchanged bool
+					if unchanged, err = f(ctx, c, in.Parameters); err != nil || unchanged {
+						return
+					}
+				}
+
+				accountId, err := c.AccountId(ctx)
+				if err != nil {
+					return
+				}
+				change := Change{
+					AccountId: accountId,
+					Region:    c.Region(),
+					Action:    action(operation),
+					Service:   service,
+					Operation: operation,
+					Resource:  resource(in.Parameters),
+					Input:     in.Parameters,
+				}
+				p.mu.Lock()
+				p.changes = append(p.changes, change)
+				p.mu.Unlock()
+				ui.Printf("planned to %s in account %s", change, accountId) // so there's something to review if we can't finish
+				return
+			},
+		), middleware.Before)
+	}
+}
+
+// Plan returns the Plan shared by this Config and every Config derived from
+// it or nil if we're not planning.
+func (c *Config) Plan() *Plan {
+	return c.plan
+}
+
+// Planning returns true if this Config is recording mutating AWS API requests
+// instead of sending them.
+func (c *Config) Planning() bool {
+	return c.plan != nil
+}
+
+// StartPlan causes every client subsequently constructed from this Config or
+// any Config derived from it (by Copy, Regional, AssumeRole, etc.) to record
+// mutating AWS API requests in the returned Plan instead of sending them.
+func (c *Config) StartPlan() *Plan {
+	if c.plan == nil {
+		c.plan = &Plan{}
+	}
+	return c.plan
+}
+
+// awsConfig returns the aws.Config clients should be constructed from,
+// which, when we're planning, intercepts mutating requests on behalf of the
+// given (probably nil) client.
+func (c *Config) awsConfig(client interface{}) aws.Config {
+	if c.plan == nil {
+		return c.cfg
+	}
+	cfg := c.cfg.Copy()
+	cfg.APIOptions = append(
+		cfg.APIOptions[:len(cfg.APIOptions):len(cfg.APIOptions)], // don't clobber c.cfg.APIOptions
+		c.plan.apiOption(c, client),
+	)
+	return cfg
+}
+
+func action(operation string) string {
+	for _, prefix := range []string{"Delete", "Deregister", "Detach", "Disable", "Disassociate", "Remove"} {
+		if strings.HasPrefix(operation, prefix) {
+			return PlanDelete
+		}
+	}
+	if strings.HasPrefix(operation, "Tag") || strings.HasPrefix(operation, "Untag") {
+		return PlanTag
+	}
+	for _, prefix := range []string{"Allocate", "Associate", "Attach", "Create", "Invite", "Register", "Run"} {
+		if strings.HasPrefix(operation, prefix) {
+			return PlanCreate
+		}
+	}
+	return PlanUpdate
+}
+
+// placeholder fills in every string pointer in the struct v points to with
+// the value of the field by the same name in in, if there is one, or with
+// Planned otherwise, recursing into struct pointers so that callers that
+// dereference e.g. CreateRoleOutput.Role.Arn don't panic.
+func placeholder(v, in reflect.Value, depth int) reflect.Value {
+	if depth > 3 {
+		return v
+	}
+	for in.Kind() == reflect.Pointer && !in.IsNil() {
+		in = in.Elem()
+	}
+	elem := v.Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		field := elem.Field(i)
+		if !field.CanSet() || field.Kind() != reflect.Pointer {
+			continue
+		}
+		name := elem.Type().Field(i).Name
+		switch field.Type().Elem().Kind() {
+		case reflect.String:
+			s := Planned
+			if in.Kind() == reflect.Struct {
+				if inField := in.FieldByName(name); inField.IsValid() && inField.Type() == field.Type() && !inField.IsNil() {
+					s = inField.Elem().String()
+				}
+			}
+			ptr := reflect.New(field.Type().Elem())
+			ptr.Elem().SetString(s)
+			field.Set(ptr)
+		case reflect.Struct:
+			field.Set(placeholder(reflect.New(field.Type().Elem()), in, depth+1))
+		}
+	}
+	return v
+}
+
+func readOnly(service, operation string) bool {
+	switch service + ":" + operation {
+	case "sts:AssumeRole", "sts:AssumeRoleWithSAML", "sts:AssumeRoleWithWebIdentity":
+		return true
+	}
+	for _, prefix := range []string{"BatchGet", "Describe", "Get", "Head", "List", "Lookup", "Query", "Scan", "Search", "Simulate"} {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func resource(params interface{}) string {
+	v := reflect.ValueOf(params)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	var ss []string
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if !strings.HasSuffix(name, "Name") && !strings.HasSuffix(name, "Id") && !strings.HasSuffix(name, "Arn") && !strings.HasSuffix(name, "ARN") {
+			continue
+		}
+		if field := v.Field(i); field.Kind() == reflect.Pointer && !field.IsNil() && field.Elem().Kind() == reflect.String {
+			ss = append(ss, fmt.Sprintf("%s=%s", name, field.Elem().String()))
+		}
+	}
+	return strings.Join(ss, " ")
+}
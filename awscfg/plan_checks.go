@@ -0,0 +1,463 @@
+package awscfg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/src-bin/substrate/awsutil"
+)
+
+// planExistenceChecks map service:operation to functions that determine
+// whether the resource a create operation would create already exists and,
+// if so, return the error code AWS would have responded with. Many Ensure*
+// functions create first and update when they see that error so it's
+// important to mimic it exactly.
+var planExistenceChecks map[string]func(context.Context, *Config, interface{}) (string, error)
+
+// planUnchangedChecks map service:operation to functions that determine
+// whether an update operation would leave the resource exactly as it is so
+// that the plan only contains changes worth reviewing. These cover the
+// updates `substrate setup` makes to every IAM role, IAM user, and AWS
+// account every time it runs.
+var planUnchangedChecks map[string]func(context.Context, *Config, interface{}) (bool, error)
+
+func init() { // these refer (indirectly) to themselves so can't be initialized statically
+	planExistenceChecks = map[string]func(context.Context, *Config, interface{}) (string, error){
+
+		"dynamodb:CreateTable": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			_, err := c.DynamoDB().DescribeTable(ctx, &dynamodb.DescribeTableInput{
+				TableName: params.(*dynamodb.CreateTableInput).TableName,
+			})
+			return existence(err, "ResourceInUseException", "ResourceNotFoundException")
+		},
+
+		"iam:CreateInstanceProfile": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			_, err := c.IAM().GetInstanceProfile(ctx, &iam.GetInstanceProfileInput{
+				InstanceProfileName: params.(*iam.CreateInstanceProfileInput).InstanceProfileName,
+			})
+			return existence(err, "EntityAlreadyExists", "NoSuchEntity")
+		},
+
+		"iam:CreateOpenIDConnectProvider": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			accountId, err := c.AccountId(ctx)
+			if err != nil {
+				return "", err
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(params.(*iam.CreateOpenIDConnectProviderInput).Url), "https://"), "/")
+			_, err = c.IAM().GetOpenIDConnectProvider(ctx, &iam.GetOpenIDConnectProviderInput{
+				OpenIDConnectProviderArn: aws.String(fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", accountId, name)),
+			})
+			return existence(err, "EntityAlreadyExists", "NoSuchEntity")
+		},
+
+		"iam:CreatePolicy": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			in := params.(*iam.CreatePolicyInput)
+			accountId, err := c.AccountId(ctx)
+			if err != nil {
+				return "", err
+			}
+			policyPath := aws.ToString(in.Path)
+			if policyPath == "" {
+				policyPath = "/"
+			}
+			_, err = c.IAM().GetPolicy(ctx, &iam.GetPolicyInput{
+				PolicyArn: aws.String(fmt.Sprintf("arn:aws:iam::%s:policy%s%s", accountId, policyPath, aws.ToString(in.PolicyName))),
+			})
+			return existence(err, "EntityAlreadyExists", "NoSuchEntity")
+		},
+
+		"iam:CreateRole": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			_, err := c.IAM().GetRole(ctx, &iam.GetRoleInput{
+				RoleName: params.(*iam.CreateRoleInput).RoleName,
+			})
+			return existence(err, "EntityAlreadyExists", "NoSuchEntity")
+		},
+
+		"iam:CreateServiceLinkedRole": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			out, err := c.IAM().ListRoles(ctx, &iam.ListRolesInput{
+				PathPrefix: aws.String(fmt.Sprintf(
+					"/aws-service-role/%s/",
+					aws.ToString(params.(*iam.CreateServiceLinkedRoleInput).AWSServiceName),
+				)),
+			})
+			if err != nil {
+				return "", err
+			}
+			if len(out.Roles) > 0 {
+				return "InvalidInput", nil
+			}
+			return "", nil
+		},
+
+		"iam:CreateUser": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			_, err := c.IAM().GetUser(ctx, &iam.GetUserInput{
+				UserName: params.(*iam.CreateUserInput).UserName,
+			})
+			return existence(err, "EntityAlreadyExists", "NoSuchEntity")
+		},
+
+		"lambda:CreateFunction": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			_, err := c.Lambda().GetFunction(ctx, &lambda.GetFunctionInput{
+				FunctionName: params.(*lambda.CreateFunctionInput).FunctionName,
+			})
+			return existence(err, "ResourceConflictException", "ResourceNotFoundException")
+		},
+
+		"organizations:CreatePolicy": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			in := params.(*organizations.CreatePolicyInput)
+			var nextToken *string
+			for {
+				out, err := c.Organizations().ListPolicies(ctx, &organizations.ListPoliciesInput{
+					Filter:    in.Type,
+					NextToken: nextToken,
+				})
+				if err != nil {
+					return "", err
+				}
+				for _, summary := range out.Policies {
+					if aws.ToString(summary.Name) == aws.ToString(in.Name) {
+						return "DuplicatePolicyException", nil
+					}
+				}
+				if nextToken = out.NextToken; nextToken == nil {
+					return "", nil
+				}
+			}
+		},
+
+		"s3:CreateBucket": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			_, err := c.S3().HeadBucket(ctx, &s3.HeadBucketInput{
+				Bucket: params.(*s3.CreateBucketInput).Bucket,
+			})
+			if err == nil {
+				return "BucketAlreadyOwnedByYou", nil
+			}
+			if awsutil.ErrorCodeIs(err, "NotFound") {
+				return "", nil
+			}
+			return "BucketAlreadyExists", nil
+		},
+
+		"secretsmanager:CreateSecret": func(ctx context.Context, c *Config, params interface{}) (string, error) {
+			_, err := c.SecretsManager().DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+				SecretId: params.(*secretsmanager.CreateSecretInput).Name,
+			})
+			return existence(err, "ResourceExistsException", "ResourceNotFoundException")
+		},
+	}
+
+	planUnchangedChecks = map[string]func(context.Context, *Config, interface{}) (bool, error){
+
+		"iam:AttachRolePolicy": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*iam.AttachRolePolicyInput)
+			var marker *string
+			for {
+				out, err := c.IAM().ListAttachedRolePolicies(ctx, &iam.ListAttachedRolePoliciesInput{
+					Marker:   marker,
+					RoleName: in.RoleName,
+				})
+				if err != nil {
+					return false, ignoreNoSuchEntity(err)
+				}
+				for _, policy := range out.AttachedPolicies {
+					if aws.ToString(policy.PolicyArn) == aws.ToString(in.PolicyArn) {
+						return true, nil
+					}
+				}
+				if marker = out.Marker; !out.IsTruncated {
+					return false, nil
+				}
+			}
+		},
+
+		"iam:AttachUserPolicy": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*iam.AttachUserPolicyInput)
+			var marker *string
+			for {
+				out, err := c.IAM().ListAttachedUserPolicies(ctx, &iam.ListAttachedUserPoliciesInput{
+					Marker:   marker,
+					UserName: in.UserName,
+				})
+				if err != nil {
+					return false, ignoreNoSuchEntity(err)
+				}
+				for _, policy := range out.AttachedPolicies {
+					if aws.ToString(policy.PolicyArn) == aws.ToString(in.PolicyArn) {
+						return true, nil
+					}
+				}
+				if marker = out.Marker; !out.IsTruncated {
+					return false, nil
+				}
+			}
+		},
+
+		"iam:DeleteRolePermissionsBoundary": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*iam.DeleteRolePermissionsBoundaryInput)
+			out, err := c.IAM().GetRole(ctx, &iam.GetRoleInput{RoleName: in.RoleName})
+			if err != nil {
+				return false, ignoreNoSuchEntity(err)
+			}
+			return out.Role.PermissionsBoundary == nil, nil
+		},
+
+		"iam:PutRolePermissionsBoundary": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*iam.PutRolePermissionsBoundaryInput)
+			out, err := c.IAM().GetRole(ctx, &iam.GetRoleInput{RoleName: in.RoleName})
+			if err != nil {
+				return false, ignoreNoSuchEntity(err)
+			}
+			return out.Role.PermissionsBoundary != nil && aws.ToString(out.Role.PermissionsBoundary.PermissionsBoundaryArn) == aws.ToString(in.PermissionsBoundary), nil
+		},
+
+		"iam:PutRolePolicy": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*iam.PutRolePolicyInput)
+			out, err := c.IAM().GetRolePolicy(ctx, &iam.GetRolePolicyInput{
+				PolicyName: in.PolicyName,
+				RoleName:   in.RoleName,
+			})
+			if err != nil {
+				return false, ignoreNoSuchEntity(err)
+			}
+			return policiesEqual(aws.ToString(out.PolicyDocument), aws.ToString(in.PolicyDocument)), nil
+		},
+
+		"iam:TagRole": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*iam.TagRoleInput)
+			tags, err := iamRoleTags(ctx, c, in.RoleName)
+			if err != nil {
+				return false, ignoreNoSuchEntity(err)
+			}
+			return iamTagsInclude(tags, in.Tags), nil
+		},
+
+		"iam:TagUser": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*iam.TagUserInput)
+			var (
+				marker *string
+				tags   []iamtypes.Tag
+			)
+			for {
+				out, err := c.IAM().ListUserTags(ctx, &iam.ListUserTagsInput{
+					Marker:   marker,
+					UserName: in.UserName,
+				})
+				if err != nil {
+					return false, ignoreNoSuchEntity(err)
+				}
+				tags = append(tags, out.Tags...)
+				if marker = out.Marker; !out.IsTruncated {
+					break
+				}
+			}
+			return iamTagsInclude(tags, in.Tags), nil
+		},
+
+		"iam:UntagRole": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*iam.UntagRoleInput)
+			tags, err := iamRoleTags(ctx, c, in.RoleName)
+			if err != nil {
+				return false, ignoreNoSuchEntity(err)
+			}
+			for _, tag := range tags {
+				for _, key := range in.TagKeys {
+					if aws.ToString(tag.Key) == key {
+						return false, nil
+					}
+				}
+			}
+			return true, nil
+		},
+
+		"iam:UpdateAssumeRolePolicy": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*iam.UpdateAssumeRolePolicyInput)
+			out, err := c.IAM().GetRole(ctx, &iam.GetRoleInput{RoleName: in.RoleName})
+			if err != nil {
+				return false, ignoreNoSuchEntity(err)
+			}
+			return policiesEqual(aws.ToString(out.Role.AssumeRolePolicyDocument), aws.ToString(in.PolicyDocument)), nil
+		},
+
+		"iam:UpdateRole": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*iam.UpdateRoleInput)
+			out, err := c.IAM().GetRole(ctx, &iam.GetRoleInput{RoleName: in.RoleName})
+			if err != nil {
+				return false, ignoreNoSuchEntity(err)
+			}
+			if in.Description != nil && aws.ToString(in.Description) != aws.ToString(out.Role.Description) {
+				return false, nil
+			}
+			if in.MaxSessionDuration != nil && aws.ToInt32(in.MaxSessionDuration) != aws.ToInt32(out.Role.MaxSessionDuration) {
+				return false, nil
+			}
+			return true, nil
+		},
+
+		"organizations:AttachPolicy": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*organizations.AttachPolicyInput)
+			var nextToken *string
+			for {
+				out, err := c.Organizations().ListTargetsForPolicy(ctx, &organizations.ListTargetsForPolicyInput{
+					NextToken: nextToken,
+					PolicyId:  in.PolicyId,
+				})
+				if err != nil {
+					return false, ignorePolicyNotFound(err)
+				}
+				for _, target := range out.Targets {
+					if aws.ToString(target.TargetId) == aws.ToString(in.TargetId) {
+						return true, nil
+					}
+				}
+				if nextToken = out.NextToken; nextToken == nil {
+					return false, nil
+				}
+			}
+		},
+
+		"organizations:TagResource": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*organizations.TagResourceInput)
+			var (
+				nextToken *string
+				tags      []orgtypes.Tag
+			)
+			for {
+				out, err := c.Organizations().ListTagsForResource(ctx, &organizations.ListTagsForResourceInput{
+					NextToken:  nextToken,
+					ResourceId: in.ResourceId,
+				})
+				if err != nil {
+					return false, ignorePolicyNotFound(err)
+				}
+				tags = append(tags, out.Tags...)
+				if nextToken = out.NextToken; nextToken == nil {
+					break
+				}
+			}
+			have := make(map[string]string)
+			for _, tag := range tags {
+				have[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+			for _, tag := range in.Tags {
+				if value, ok := have[aws.ToString(tag.Key)]; !ok || value != aws.ToString(tag.Value) {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+
+		"organizations:UpdatePolicy": func(ctx context.Context, c *Config, params interface{}) (bool, error) {
+			in := params.(*organizations.UpdatePolicyInput)
+			out, err := c.Organizations().DescribePolicy(ctx, &organizations.DescribePolicyInput{
+				PolicyId: in.PolicyId,
+			})
+			if err != nil {
+				return false, ignorePolicyNotFound(err)
+			}
+			if in.Name != nil && aws.ToString(in.Name) != aws.ToString(out.Policy.PolicySummary.Name) {
+				return false, nil
+			}
+			if in.Description != nil && aws.ToString(in.Description) != aws.ToString(out.Policy.PolicySummary.Description) {
+				return false, nil
+			}
+			return in.Content == nil || policiesEqual(aws.ToString(out.Policy.Content), aws.ToString(in.Content)), nil
+		},
+	}
+}
+
+// existence translates the error from a read-only request into the error
+// code the corresponding create request would have returned, which is
+// existsCode if the read succeeded and empty if it failed with
+// notFoundCode.
+func existence(err error, existsCode, notFoundCode string) (string, error) {
+	if err == nil {
+		return existsCode, nil
+	}
+	if awsutil.ErrorCodeIs(err, notFoundCode) {
+		return "", nil
+	}
+	return "", err
+}
+
+func iamRoleTags(ctx context.Context, c *Config, roleName *string) ([]iamtypes.Tag, error) {
+	var (
+		marker *string
+		tags   []iamtypes.Tag
+	)
+	for {
+		out, err := c.IAM().ListRoleTags(ctx, &iam.ListRoleTagsInput{
+			Marker:   marker,
+			RoleName: roleName,
+		})
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, out.Tags...)
+		if marker = out.Marker; !out.IsTruncated {
+			break
+		}
+	}
+	return tags, nil
+}
+
+func iamTagsInclude(have, want []iamtypes.Tag) bool {
+	m := make(map[string]string)
+	for _, tag := range have {
+		m[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	for _, tag := range want {
+		if value, ok := m[aws.ToString(tag.Key)]; !ok || value != aws.ToString(tag.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// ignoreNoSuchEntity and ignorePolicyNotFound accept that the resource being
+// updated may itself only exist in the plan, in which case the update is
+// definitely a change.
+func ignoreNoSuchEntity(err error) error {
+	if awsutil.ErrorCodeIs(err, "NoSuchEntity") {
+		return nil
+	}
+	return err
+}
+
+func ignorePolicyNotFound(err error) error {
+	if awsutil.ErrorCodeIs(err, "PolicyNotFoundException") || awsutil.ErrorCodeIs(err, "TargetNotFoundException") || awsutil.ErrorCodeIs(err, "InvalidInputException") {
+		return nil
+	}
+	return err
+}
+
+// policiesEqual compares two JSON policy documents, either of which may be
+// URL-encoded as IAM returns them, for semantic equality.
+func policiesEqual(a, b string) bool {
+	if s, err := url.PathUnescape(a); err == nil {
+		a = s
+	}
+	if s, err := url.PathUnescape(b); err == nil {
+		b = s
+	}
+	var aDoc, bDoc interface{}
+	if err := json.Unmarshal([]byte(a), &aDoc); err != nil {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}
+	if err := json.Unmarshal([]byte(b), &bDoc); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(aDoc, bDoc)
+}
@@ -0,0 +1,125 @@
+package awscfg
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+func TestAction(t *testing.T) {
+	for operation, expected := range map[string]string{
+		"AttachRolePolicy":       PlanCreate,
+		"CreateRole":             PlanCreate,
+		"DeleteRole":             PlanDelete,
+		"DetachPolicy":           PlanDelete,
+		"EnablePolicyType":       PlanUpdate,
+		"PutRolePolicy":          PlanUpdate,
+		"TagResource":            PlanTag,
+		"UntagResource":          PlanTag,
+		"UpdateAssumeRolePolicy": PlanUpdate,
+	} {
+		if actual := action(operation); actual != expected {
+			t.Errorf("action(%q) = %q, expected %q", operation, actual, expected)
+		}
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	in := &iam.CreateRoleInput{RoleName: aws.String("Example")}
+	out := placeholder(reflect.New(reflect.TypeOf(iam.CreateRoleOutput{})), reflect.ValueOf(in), 0).Interface().(*iam.CreateRoleOutput)
+	if out.Role == nil {
+		t.Fatal("Role is nil")
+	}
+	if name := aws.ToString(out.Role.RoleName); name != "Example" {
+		t.Errorf(`RoleName = %q, expected "Example"`, name)
+	}
+	if arn := aws.ToString(out.Role.Arn); arn != Planned {
+		t.Errorf("Arn = %q, expected %q", arn, Planned)
+	}
+}
+
+func TestPlaceholderNested(t *testing.T) {
+	in := &organizations.UpdatePolicyInput{PolicyId: aws.String("p-example")}
+	out := placeholder(reflect.New(reflect.TypeOf(organizations.UpdatePolicyOutput{})), reflect.ValueOf(in), 0).Interface().(*organizations.UpdatePolicyOutput)
+	if id := aws.ToString(out.Policy.PolicySummary.Id); id != Planned {
+		t.Errorf("Id = %q, expected %q", id, Planned) // PolicyId != Id
+	}
+}
+
+func TestPoliciesEqual(t *testing.T) {
+	if !policiesEqual(
+		`%7B%22Version%22%3A%222012-10-17%22%2C%22Statement%22%3A%5B%5D%7D`,
+		`{"Statement": [], "Version": "2012-10-17"}`,
+	) {
+		t.Error("URL-encoded and reordered policies should be equal")
+	}
+	if policiesEqual(`{"Version":"2012-10-17"}`, `{"Version":"2008-10-17"}`) {
+		t.Error("different policies should not be equal")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	for _, tc := range []struct {
+		service, operation string
+		expected           bool
+	}{
+		{"iam", "GetRole", true},
+		{"iam", "CreateRole", false},
+		{"organizations", "ListAccounts", true},
+		{"organizations", "TagResource", false},
+		{"s3", "HeadBucket", true},
+		{"sts", "AssumeRole", true},
+		{"sts", "GetCallerIdentity", true},
+	} {
+		if actual := readOnly(tc.service, tc.operation); actual != tc.expected {
+			t.Errorf("readOnly(%q, %q) = %v, expected %v", tc.service, tc.operation, actual, tc.expected)
+		}
+	}
+}
+
+func TestPlanIntercepts(t *testing.T) {
+	ctx := context.Background()
+	c := &Config{
+		cfg: aws.Config{
+			Credentials: credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "example", ""),
+			Region:      "us-west-2",
+		},
+		getCallerIdentityOutput: &sts.GetCallerIdentityOutput{Account: aws.String("123456789012")},
+	}
+	p := c.StartPlan()
+	out, err := c.Regional("us-east-2").IAM().UpdateRoleDescription(ctx, &iam.UpdateRoleDescriptionInput{
+		Description: aws.String("example"),
+		RoleName:    aws.String("Example"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := aws.ToString(out.Role.RoleName); name != "Example" {
+		t.Errorf(`RoleName = %q, expected "Example"`, name)
+	}
+	changes := p.Changes()
+	if len(changes) != 1 {
+		t.Fatal(changes)
+	}
+	if s := changes[0].String(); changes[0].AccountId != "123456789012" || changes[0].Region != "us-east-2" || s != "update iam:UpdateRoleDescription RoleName=Example" {
+		t.Errorf("%+v %q", changes[0], s)
+	}
+
+	// `substrate setup --plan --plan-format json` marshals the whole change,
+	// including its input, for programs to review.
+	b, err := json.Marshal(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"Input":{"Description":"example","RoleName":"Example"}`) {
+		t.Error(string(b))
+	}
+}
@@ -0,0 +1,146 @@
+package awsdynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/src-bin/substrate/awscfg"
+)
+
+const ConditionalCheckFailedException = "ConditionalCheckFailedException"
+
+type (
+	AttributeValue        = types.AttributeValue
+	AttributeValueMemberB = types.AttributeValueMemberB
+	AttributeValueMemberN = types.AttributeValueMemberN
+	AttributeValueMemberS = types.AttributeValueMemberS
+)
+
+func DeleteItem(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	name string,
+	key map[string]AttributeValue,
+) error {
+	_, err := cfg.DynamoDB().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		Key:       key,
+		TableName: aws.String(name),
+	})
+	return err
+}
+
+// GetItem returns the item with the given key, read consistently, or
+// ItemNotFoundError if there's no such item.
+func GetItem(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	name string,
+	key map[string]AttributeValue,
+) (map[string]AttributeValue, error) {
+	out, err := cfg.DynamoDB().GetItem(ctx, &dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key:            key,
+		TableName:      aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, ItemNotFoundError(name)
+	}
+	return out.Item, nil
+}
+
+func PutItem(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	name string,
+	item map[string]AttributeValue,
+) error {
+	_, err := cfg.DynamoDB().PutItem(ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(name),
+	})
+	return err
+}
+
+// PutItemIf is PutItem but only if the condition expression, in which names
+// and values may be substituted, is true of the item being replaced. It
+// returns an error with code ConditionalCheckFailedException if not.
+func PutItemIf(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	name string,
+	item map[string]AttributeValue,
+	condition string,
+	names map[string]string,
+	values map[string]AttributeValue,
+) error {
+	_, err := cfg.DynamoDB().PutItem(ctx, &dynamodb.PutItemInput{
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		Item:                      item,
+		TableName:                 aws.String(name),
+	})
+	return err
+}
+
+// Scan returns every item in the table, which is only reasonable for small
+// tables.
+func Scan(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	name string,
+) (items []map[string]AttributeValue, err error) {
+	client := cfg.DynamoDB()
+	var exclusiveStartKey map[string]AttributeValue
+	for {
+		out, err := client.Scan(ctx, &dynamodb.ScanInput{
+			ExclusiveStartKey: exclusiveStartKey,
+			TableName:         aws.String(name),
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, out.Items...)
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		exclusiveStartKey = out.LastEvaluatedKey
+	}
+	return
+}
+
+// UpdateItemIf applies the update expression to the item with the given key
+// but only if the condition expression is true of it. Names and values may be
+// substituted in both expressions. It returns an error with code
+// ConditionalCheckFailedException if the condition is false.
+func UpdateItemIf(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	name string,
+	key map[string]AttributeValue,
+	update, condition string,
+	names map[string]string,
+	values map[string]AttributeValue,
+) error {
+	_, err := cfg.DynamoDB().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		Key:                       key,
+		TableName:                 aws.String(name),
+		UpdateExpression:          aws.String(update),
+	})
+	return err
+}
+
+type ItemNotFoundError string
+
+func (err ItemNotFoundError) Error() string {
+	return fmt.Sprintf("ItemNotFoundError: item not found in DynamoDB table %s", string(err))
+}
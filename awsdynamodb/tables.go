@@ -83,3 +83,30 @@ func EnsureTable(
 	//log.Printf("%+v", out)
 	return out.TableDescription, nil
 }
+
+// EnsureTimeToLive arranges for DynamoDB to delete items from the named table
+// once the time in attrName, in seconds since the epoch, passes.
+func EnsureTimeToLive(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	name, attrName string,
+) error {
+	client := cfg.DynamoDB()
+	out, err := client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(name),
+	})
+	if err != nil {
+		return err
+	}
+	if desc := out.TimeToLiveDescription; desc != nil && aws.ToString(desc.AttributeName) == attrName && (desc.TimeToLiveStatus == types.TimeToLiveStatusEnabled || desc.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+		return nil
+	}
+	_, err = client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(name),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attrName),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
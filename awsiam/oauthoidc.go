@@ -2,8 +2,16 @@ package awsiam
 
 import (
 	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
@@ -15,10 +23,19 @@ import (
 )
 
 const (
-	GitHubActionsOAuthOIDCThumbprint = "ffffffffffffffffffffffffffffffffffffffff" // <https://github.com/aws-actions/configure-aws-credentials/issues/357>
+	GitHubActionsOAuthOIDCThumbprint = PlaceholderOAuthOIDCThumbprint // <https://github.com/aws-actions/configure-aws-credentials/issues/357>
 	GitHubActionsOAuthOIDCURL        = "https://token.actions.githubusercontent.com"
+
+	// PlaceholderOAuthOIDCThumbprint satisfies IAM's requirement for at least
+	// one thumbprint for issuers whose certificates IAM verifies using its own
+	// library of trusted root CAs, in which case it ignores thumbprints.
+	PlaceholderOAuthOIDCThumbprint = "ffffffffffffffffffffffffffffffffffffffff"
 )
 
+// EnsureOpenIDConnectProvider creates an OpenID Connect provider for the
+// given issuer URL or, if one already exists, ensures it accepts tokens for
+// every one of the given clients (also known as audiences). IAM requires at
+// least one thumbprint; see OpenIDConnectProviderThumbprints.
 func EnsureOpenIDConnectProvider(
 	ctx context.Context,
 	cfg *awscfg.Config,
@@ -29,27 +46,118 @@ func EnsureOpenIDConnectProvider(
 	if err != nil {
 		return "", err
 	}
-	out, err := cfg.IAM().CreateOpenIDConnectProvider(ctx, &iam.CreateOpenIDConnectProviderInput{
-		ClientIDList: clients,
-		Tags: []types.Tag{
-			{Key: aws.String(tagging.Manager), Value: aws.String(tagging.Substrate)},
-			{Key: aws.String(tagging.SubstrateVersion), Value: aws.String(version.Version)},
-		},
-		ThumbprintList: thumbprints,
-		Url:            aws.String(u.String()),
-	})
+	name, err := OpenIDConnectProviderName(urlString)
+	if err != nil {
+		return "", err
+	}
+	out, err := cfg.IAM().CreateOpenIDConnectProvider(ctx, createOpenIDConnectProviderInput(clients, thumbprints, u))
 	if awsutil.ErrorCodeIs(err, EntityAlreadyExists) {
 		callerIdentity, err := cfg.GetCallerIdentity(ctx)
 		if err != nil {
 			return "", err
 		}
-		return fmt.Sprintf(
-			"arn:aws:iam::%s:oidc-provider/%s",
-			aws.ToString(callerIdentity.Account),
-			u.Host,
-		), nil
+		arn := OpenIDConnectProviderARN(aws.ToString(callerIdentity.Account), name)
+		for _, client := range clients { // idempotent so there's no need to check first
+			if _, err := cfg.IAM().AddClientIDToOpenIDConnectProvider(ctx, &iam.AddClientIDToOpenIDConnectProviderInput{
+				ClientID:                 aws.String(client),
+				OpenIDConnectProviderArn: aws.String(arn),
+			}); err != nil {
+				return "", err
+			}
+		}
+		return arn, nil
 	} else if err != nil {
 		return "", err
 	}
 	return aws.ToString(out.OpenIDConnectProviderArn), nil
 }
+
+// OpenIDConnectProviderThumbprints returns the thumbprint of the certificate
+// IAM will see at the top of the chain when it fetches the issuer's JSON Web
+// Key Set, as AWS documents at <https://docs.aws.amazon.com/IAM/latest/UserGuide/id_roles_providers_create_oidc_verify-thumbprint.html>.
+// If that can't be determined, it returns PlaceholderOAuthOIDCThumbprint so
+// that IAM still accepts the list, which is enough for issuers whose
+// certificates are signed by a CA IAM trusts.
+func OpenIDConnectProviderThumbprints(urlString string) ([]string, error) {
+	thumbprint, err := openIDConnectProviderThumbprint(urlString)
+	if err != nil {
+		return []string{PlaceholderOAuthOIDCThumbprint}, err
+	}
+	return []string{thumbprint}, nil
+}
+
+// OpenIDConnectProviderARN returns the ARN of the OpenID Connect provider by
+// the given name (see OpenIDConnectProviderName) in the given account, which
+// is deterministic, so that it may be referenced without (or before)
+// creating the provider.
+func OpenIDConnectProviderARN(accountId, name string) string {
+	return fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", accountId, name)
+}
+
+// OpenIDConnectProviderName returns the issuer URL's host and path, which is
+// how IAM identifies OpenID Connect providers in ARNs and condition keys.
+func OpenIDConnectProviderName(urlString string) (string, error) {
+	u, err := url.Parse(urlString)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("OpenID Connect issuer %q must be an https:// URL", urlString)
+	}
+	return strings.TrimSuffix(u.Host+u.Path, "/"), nil
+}
+
+func createOpenIDConnectProviderInput(clients, thumbprints []string, u *url.URL) *iam.CreateOpenIDConnectProviderInput {
+	return &iam.CreateOpenIDConnectProviderInput{
+		ClientIDList: clients,
+		Tags: []types.Tag{
+			{Key: aws.String(tagging.Manager), Value: aws.String(tagging.Substrate)},
+			{Key: aws.String(tagging.SubstrateVersion), Value: aws.String(version.Version)},
+		},
+		ThumbprintList: thumbprints,
+		Url:            aws.String(u.String()),
+	}
+}
+
+func openIDConnectProviderThumbprint(urlString string) (string, error) {
+	if _, err := OpenIDConnectProviderName(urlString); err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(urlString, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s/.well-known/openid-configuration: %s", urlString, resp.Status)
+	}
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(doc.JWKSURI)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%s/.well-known/openid-configuration has no jwks_uri", urlString)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", fmt.Errorf("%s presented no certificates", host)
+	}
+	sum := sha1.Sum(certs[len(certs)-1].Raw) // the top of the chain, as IAM wants
+	return hex.EncodeToString(sum[:]), nil
+}
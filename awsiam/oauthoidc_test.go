@@ -0,0 +1,67 @@
+package awsiam
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/smithy-go"
+)
+
+var errSent = errors.New("request passed validation and was sent")
+
+type unsentHTTPClient struct{}
+
+func (unsentHTTPClient) Do(*http.Request) (*http.Response, error) { return nil, errSent }
+
+// validate runs in through the IAM client's request validator, returning nil
+// if it would've been sent to IAM.
+func validate(in *iam.CreateOpenIDConnectProviderInput) error {
+	client := iam.New(iam.Options{
+		Credentials: credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", ""),
+		HTTPClient:  unsentHTTPClient{},
+		Region:      "us-east-1",
+		Retryer:     aws.NopRetryer{},
+	})
+	_, err := client.CreateOpenIDConnectProvider(context.Background(), in)
+	if errors.Is(err, errSent) {
+		return nil
+	}
+	return err
+}
+
+func TestCreateOpenIDConnectProviderInputGeneric(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.NotFoundHandler())
+	s.Config.ErrorLog = log.New(io.Discard, "", 0) // it's expected that we don't trust its certificate
+	s.StartTLS()
+	defer s.Close()
+	issuer := s.URL
+	thumbprints, err := OpenIDConnectProviderThumbprints(issuer)
+	if err == nil {
+		t.Fatal("expected an error finding the thumbprint of an issuer with no discovery document")
+	}
+	if len(thumbprints) != 1 || thumbprints[0] != PlaceholderOAuthOIDCThumbprint {
+		t.Fatalf("actual: %v, expected: [%s]", thumbprints, PlaceholderOAuthOIDCThumbprint)
+	}
+	u, _ := url.Parse(issuer)
+	if err := validate(createOpenIDConnectProviderInput([]string{"sts.amazonaws.com"}, thumbprints, u)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateOpenIDConnectProviderInputNilThumbprints(t *testing.T) {
+	u, _ := url.Parse("https://oidc.example.invalid")
+	err := validate(createOpenIDConnectProviderInput([]string{"sts.amazonaws.com"}, nil, u))
+	var invalidParamsErr smithy.InvalidParamsError
+	if !errors.As(err, &invalidParamsErr) {
+		t.Fatalf("expected smithy.InvalidParamsError, got %v", err)
+	}
+}
@@ -13,8 +13,10 @@ import (
 	"github.com/src-bin/substrate/awsorgs"
 	"github.com/src-bin/substrate/awsutil"
 	"github.com/src-bin/substrate/policies"
+	"github.com/src-bin/substrate/roles"
 	"github.com/src-bin/substrate/tagging"
 	"github.com/src-bin/substrate/ui"
+	"github.com/src-bin/substrate/version"
 )
 
 func AttachRolePolicy(
@@ -39,19 +41,22 @@ func CreateRole(
 	cfg *awscfg.Config,
 	roleName string,
 	assumeRolePolicyDoc *policies.Document,
-	// TODO permissionsBoundaryPolicyARN,
+	options *RoleOptions, // may be nil
 ) (*Role, error) {
 	docJSON, err := assumeRolePolicyDoc.Marshal()
 	if err != nil {
 		return nil, err
 	}
-	out, err := cfg.IAM().CreateRole(ctx, &iam.CreateRoleInput{
+	in := &iam.CreateRoleInput{
 		AssumeRolePolicyDocument: aws.String(docJSON),
-		MaxSessionDuration:       aws.Int32(43200),
-		// TODO permissionsBoundaryPolicyARN,
-		RoleName: aws.String(roleName),
-		Tags:     tagsFor(roleName),
-	})
+		MaxSessionDuration:       aws.Int32(options.maxSessionDurationSeconds()),
+		RoleName:                 aws.String(roleName),
+		Tags:                     tagsFor(roleName),
+	}
+	if arn := options.permissionsBoundary(); arn != "" {
+		in.PermissionsBoundary = aws.String(arn)
+	}
+	out, err := cfg.IAM().CreateRole(ctx, in)
 	if err != nil {
 		return nil, err
 	}
@@ -64,6 +69,14 @@ func CreateRole(
 			docJSON,
 		)
 	}
+	if cfg.Planning() { // the role only exists in the plan so there's nothing to read back
+		return &Role{
+			ARN:              roles.ARN(cfg.MustAccountId(ctx), roleName),
+			AssumeRolePolicy: assumeRolePolicyDoc,
+			Name:             roleName,
+			Tags:             tagging.Map{tagging.Manager: tagging.Substrate, tagging.SubstrateVersion: version.Version},
+		}, nil
+	}
 	time.Sleep(10e9) // give IAM time to become consistent (TODO do it gracefully)
 	return roleFromAPI(ctx, cfg, out.Role)
 }
@@ -80,6 +93,9 @@ func CreateServiceLinkedRole(
 		return nil, err
 	}
 	//log.Printf("%+v", out)
+	if cfg.Planning() { // the role only exists in the plan so there's nothing to read back
+		return &Role{ARN: aws.ToString(out.Role.Arn), Name: aws.ToString(out.Role.RoleName)}, nil
+	}
 	time.Sleep(10e9) // give IAM time to become consistent (TODO do it gracefully)
 	return roleFromAPI(ctx, cfg, out.Role)
 }
@@ -193,12 +209,16 @@ func DetachRolePolicy(
 	return ui.StopErr(err)
 }
 
+// EnsureRole creates or updates a role with the given assume-role policy.
+// If options is nil, the role has a 12-hour maximum session duration and its
+// permissions boundary, if any, is left alone; if not, its permissions
+// boundary is set or removed to match options.
 func EnsureRole(
 	ctx context.Context,
 	cfg *awscfg.Config,
 	roleName string,
 	assumeRolePolicyDoc *policies.Document,
-	// TODO permissionsBoundaryPolicyARN,
+	options *RoleOptions, // may be nil
 ) (*Role, error) {
 	ui.Spinf("creating the %s IAM role", roleName)
 	defer time.Sleep(1e9) // avoid Throttling: Rate exceeded
@@ -218,21 +238,41 @@ func EnsureRole(
 			Service: []string{"sts.amazonaws.com"},
 		}),
 
-		// TODO permissionsBoundaryPolicyARN,
+		options,
 	)
 	if awsutil.ErrorCodeIs(err, EntityAlreadyExists) {
 		ui.Stop("already exists")
 		ui.Spinf("updating the %s IAM role", roleName)
 
 		// There was a time when Substrate created roles with the default
-		// 1-hour maximum session duration. Lengthen that to 12 hours.
+		// 1-hour maximum session duration. Lengthen that to 12 hours unless
+		// we've been given a different maximum.
 		if _, err := client.UpdateRole(ctx, &iam.UpdateRoleInput{
-			MaxSessionDuration: aws.Int32(43200),
+			MaxSessionDuration: aws.Int32(options.maxSessionDurationSeconds()),
 			RoleName:           aws.String(roleName),
 		}); err != nil {
 			return nil, ui.StopErr(err)
 		}
 
+		if options != nil {
+			if arn := options.permissionsBoundary(); arn != "" {
+				_, err = client.PutRolePermissionsBoundary(ctx, &iam.PutRolePermissionsBoundaryInput{
+					PermissionsBoundary: aws.String(arn),
+					RoleName:            aws.String(roleName),
+				})
+			} else {
+				_, err = client.DeleteRolePermissionsBoundary(ctx, &iam.DeleteRolePermissionsBoundaryInput{
+					RoleName: aws.String(roleName),
+				})
+				if awsutil.ErrorCodeIs(err, NoSuchEntity) {
+					err = nil
+				}
+			}
+			if err != nil {
+				return nil, ui.StopErr(err)
+			}
+		}
+
 		role, err = GetRole(ctx, cfg, roleName)
 	}
 	if err != nil {
@@ -274,12 +314,12 @@ func EnsureRoleWithPolicy(
 	cfg *awscfg.Config,
 	roleName string,
 	assumeRolePolicyDoc *policies.Document,
-	// TODO permissionsBoundaryPolicyARN,
+	options *RoleOptions, // may be nil
 	doc *policies.Document,
 ) (*Role, error) {
 	defer time.Sleep(1e9) // avoid Throttling: Rate exceeded
 
-	role, err := EnsureRole(ctx, cfg, roleName, assumeRolePolicyDoc)
+	role, err := EnsureRole(ctx, cfg, roleName, assumeRolePolicyDoc, options)
 	if err != nil {
 		return nil, err
 	}
@@ -327,6 +367,25 @@ func GetRole(ctx context.Context, cfg *awscfg.Config, roleName string) (*Role, e
 	return roleFromAPI(ctx, cfg, out.Role)
 }
 
+func GetRolePolicy(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	roleName, policyName string,
+) (*policies.Document, error) {
+	out, err := cfg.IAM().GetRolePolicy(ctx, &iam.GetRolePolicyInput{
+		PolicyName: aws.String(policyName),
+		RoleName:   aws.String(roleName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s, err := url.PathUnescape(aws.ToString(out.PolicyDocument))
+	if err != nil {
+		return nil, err
+	}
+	return policies.UnmarshalString(s)
+}
+
 func ListAttachedRolePolicies(
 	ctx context.Context,
 	cfg *awscfg.Config,
@@ -427,6 +486,47 @@ func PutRolePolicy(
 	return err
 }
 
+// UpdateAssumeRolePolicy replaces only the assume-role policy of an existing
+// role.
+func UpdateAssumeRolePolicy(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	roleName string,
+	doc *policies.Document,
+) error {
+	docJSON, err := doc.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = cfg.IAM().UpdateAssumeRolePolicy(ctx, &iam.UpdateAssumeRolePolicyInput{
+		PolicyDocument: aws.String(docJSON),
+		RoleName:       aws.String(roleName),
+	})
+	return err
+}
+
+// RoleOptions customizes a role beyond its assume-role policy. A nil
+// *RoleOptions is valid and means the 12-hour maximum session duration and
+// no permissions boundary.
+type RoleOptions struct {
+	MaxSessionDuration           time.Duration // zero means 12 hours
+	PermissionsBoundaryPolicyARN string        // empty means none
+}
+
+func (o *RoleOptions) maxSessionDurationSeconds() int32 {
+	if o == nil || o.MaxSessionDuration == 0 {
+		return 43200
+	}
+	return int32(o.MaxSessionDuration / time.Second)
+}
+
+func (o *RoleOptions) permissionsBoundary() string {
+	if o == nil {
+		return ""
+	}
+	return o.PermissionsBoundaryPolicyARN
+}
+
 type Role struct {
 	ARN              string
 	AssumeRolePolicy *policies.Document
@@ -89,6 +89,19 @@ func TagUser(
 	return err
 }
 
+func UntagRole(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	roleName string,
+	keys []string,
+) error {
+	_, err := cfg.IAM().UntagRole(ctx, &iam.UntagRoleInput{
+		RoleName: aws.String(roleName),
+		TagKeys:  keys,
+	})
+	return err
+}
+
 func UntagUser(
 	ctx context.Context,
 	cfg *awscfg.Config,
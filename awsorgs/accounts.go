@@ -43,11 +43,15 @@ func (err AccountNotFound) Error() string {
 
 type CreateAccountStatus = types.CreateAccountStatus
 
+// CloseAccount closes an AWS account and clears the cached list of accounts.
+// The *Config must be in the management account.
 func CloseAccount(ctx context.Context, cfg *awscfg.Config, accountId string) error {
-	_, err := cfg.Organizations().CloseAccount(ctx, &organizations.CloseAccountInput{
+	if _, err := cfg.Organizations().CloseAccount(ctx, &organizations.CloseAccountInput{
 		AccountId: aws.String(accountId),
-	})
-	return err
+	}); err != nil {
+		return err
+	}
+	return cfg.ClearCachedAccounts()
 }
 
 // DescribeAccount fetches an account from the AWS Organizations API and
@@ -128,11 +132,13 @@ func NameFor(domain, environment, quality string) string {
 	return fmt.Sprintf("%s-%s-%s", domain, environment, quality)
 }
 
-// Tag tags an AWS account. The *Config must be in the management account.
+// Tag tags an AWS account, organizational unit, policy, or root and, if it's
+// an account, clears the cached list of accounts and their tags. The *Config
+// must be in the management account.
 func Tag(
 	ctx context.Context,
 	cfg *awscfg.Config,
-	accountId string,
+	resourceId string,
 	tags tagging.Map,
 ) error {
 	tagStructs := make([]types.Tag, 0, len(tags))
@@ -142,11 +148,16 @@ func Tag(
 			Value: aws.String(value),
 		})
 	}
-	_, err := cfg.Organizations().TagResource(ctx, &organizations.TagResourceInput{
-		ResourceId: aws.String(accountId),
+	if _, err := cfg.Organizations().TagResource(ctx, &organizations.TagResourceInput{
+		ResourceId: aws.String(resourceId),
 		Tags:       tagStructs,
-	})
-	return err
+	}); err != nil {
+		return err
+	}
+	if isAccountId(resourceId) {
+		return cfg.ClearCachedAccounts()
+	}
+	return nil
 }
 
 func createAccount(
@@ -203,6 +214,9 @@ func createAccount(
 	//log.Printf("%+v", out)
 
 	status := out.CreateAccountStatus
+	if cfg.Planning() {
+		return status, nil // it's never going to succeed
+	}
 	for {
 		out, err := client.DescribeCreateAccountStatus(ctx, &organizations.DescribeCreateAccountStatusInput{
 			CreateAccountRequestId: status.Id,
@@ -321,14 +335,14 @@ func ensureAccount(
 	return DescribeAccount(ctx, cfg, accountId)
 }
 
-func listTagsForResource(ctx context.Context, cfg *awscfg.Config, accountId string) (tagging.Map, error) {
+func listTagsForResource(ctx context.Context, cfg *awscfg.Config, resourceId string) (tagging.Map, error) {
 	client := cfg.Organizations()
 	var nextToken *string
 	tags := make(tagging.Map)
 	for {
 		out, err := client.ListTagsForResource(ctx, &organizations.ListTagsForResourceInput{
 			NextToken:  nextToken,
-			ResourceId: aws.String(accountId),
+			ResourceId: aws.String(resourceId),
 		})
 		if err != nil {
 			return nil, err
@@ -359,3 +373,18 @@ func tagsEqual(a, b tagging.Map) bool {
 	}
 	return a[tagging.Domain] == b[tagging.Domain] && a[tagging.Environment] == b[tagging.Environment] && a[tagging.Quality] == b[tagging.Quality]
 }
+
+// isAccountId returns true if resourceId is a 12-digit AWS account number as
+// opposed to an organizational unit, policy, or root ID, which all start with
+// letters.
+func isAccountId(resourceId string) bool {
+	if len(resourceId) != 12 {
+		return false
+	}
+	for _, r := range resourceId {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package awsorgs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsutil"
+	"github.com/src-bin/substrate/tagging"
+)
+
+const (
+	DuplicateAccountException            = "DuplicateAccountException"
+	DuplicateOrganizationalUnitException = "DuplicateOrganizationalUnitException"
+)
+
+type OrganizationalUnit = types.OrganizationalUnit
+
+type OrganizationalUnitNotManaged string
+
+func (err OrganizationalUnitNotManaged) Error() string {
+	return fmt.Sprintf(
+		"organizational unit not managed by Substrate: %s; tag it %s=%s for Substrate to adopt it or rename it",
+		string(err),
+		tagging.Manager,
+		tagging.Substrate,
+	)
+}
+
+// DescribeParent returns the ID of the root or organizational unit that
+// immediately contains the given account or organizational unit. The
+// *Config must be in the management account.
+func DescribeParent(ctx context.Context, cfg *awscfg.Config, childId string) (string, error) { // DescribeParent is a made-up name
+	out, err := cfg.Organizations().ListParents(ctx, &organizations.ListParentsInput{
+		ChildId: aws.String(childId),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(out.Parents) != 1 {
+		return "", errors.New("ListParents responded with other than one Parent which AWS says is impossible")
+	}
+	return aws.ToString(out.Parents[0].Id), nil
+}
+
+// EnsureOrganizationalUnit finds or creates an organizational unit by the
+// given name in the given parent, which may be the root or another
+// organizational unit, and tags it. It returns OrganizationalUnitNotManaged
+// rather than adopting an existing organizational unit by that name that
+// isn't tagged as managed by Substrate. The *Config must be in the
+// management account.
+func EnsureOrganizationalUnit(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	parentId, name string,
+	tags tagging.Map,
+) (*OrganizationalUnit, error) {
+	ou, err := FindOrganizationalUnit(ctx, cfg, parentId, name)
+	if err != nil {
+		return nil, err
+	}
+	if ou != nil {
+		tags, err := ListOrganizationalUnitTags(ctx, cfg, aws.ToString(ou.Id))
+		if err != nil {
+			return nil, err
+		}
+		if tags[tagging.Manager] != tagging.Substrate {
+			return nil, OrganizationalUnitNotManaged(fmt.Sprintf("%s (%s)", aws.ToString(ou.Id), name))
+		}
+	} else {
+		out, err := cfg.Organizations().CreateOrganizationalUnit(ctx, &organizations.CreateOrganizationalUnitInput{
+			Name:     aws.String(name),
+			ParentId: aws.String(parentId),
+		})
+		if awsutil.ErrorCodeIs(err, DuplicateOrganizationalUnitException) {
+			ou, err = FindOrganizationalUnit(ctx, cfg, parentId, name) // lost a race to another Substrate; find the winner
+		} else if err == nil {
+			ou = out.OrganizationalUnit
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := Tag(ctx, cfg, aws.ToString(ou.Id), tagging.Merge(
+		tags,
+		tagging.Map{tagging.Manager: tagging.Substrate},
+	)); err != nil {
+		return nil, err
+	}
+	return ou, nil
+}
+
+// FindOrganizationalUnit returns the organizational unit by the given name in
+// the given parent or nil if there's no such organizational unit.
+func FindOrganizationalUnit(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	parentId, name string,
+) (*OrganizationalUnit, error) {
+	ous, err := ListOrganizationalUnitsForParent(ctx, cfg, parentId)
+	if err != nil {
+		return nil, err
+	}
+	for _, ou := range ous {
+		if aws.ToString(ou.Name) == name {
+			return &ou, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListAccountIdsForParent returns the IDs of the accounts immediately
+// contained by the given root or organizational unit.
+func ListAccountIdsForParent(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	parentId string,
+) (accountIds []string, err error) {
+	var nextToken *string
+	for {
+		out, err := cfg.Organizations().ListAccountsForParent(ctx, &organizations.ListAccountsForParentInput{
+			NextToken: nextToken,
+			ParentId:  aws.String(parentId),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range out.Accounts {
+			accountIds = append(accountIds, aws.ToString(account.Id))
+		}
+		if nextToken = out.NextToken; nextToken == nil {
+			break
+		}
+	}
+	return
+}
+
+func ListOrganizationalUnitsForParent(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	parentId string,
+) (ous []OrganizationalUnit, err error) {
+	var nextToken *string
+	for {
+		out, err := cfg.Organizations().ListOrganizationalUnitsForParent(ctx, &organizations.ListOrganizationalUnitsForParentInput{
+			NextToken: nextToken,
+			ParentId:  aws.String(parentId),
+		})
+		if err != nil {
+			return nil, err
+		}
+		ous = append(ous, out.OrganizationalUnits...)
+		if nextToken = out.NextToken; nextToken == nil {
+			break
+		}
+	}
+	return
+}
+
+// ListOrganizationalUnitTags returns the tags on the given organizational
+// unit.
+func ListOrganizationalUnitTags(ctx context.Context, cfg *awscfg.Config, ouId string) (tagging.Map, error) {
+	return listTagsForResource(ctx, cfg, ouId)
+}
+
+// MoveAccount moves an account from one parent, which may be the root or an
+// organizational unit, to another. It's not an error to move an account to
+// the parent it's already in. The *Config must be in the management account.
+func MoveAccount(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	accountId, sourceParentId, destinationParentId string,
+) error {
+	if sourceParentId == destinationParentId {
+		return nil
+	}
+	_, err := cfg.Organizations().MoveAccount(ctx, &organizations.MoveAccountInput{
+		AccountId:           aws.String(accountId),
+		DestinationParentId: aws.String(destinationParentId),
+		SourceParentId:      aws.String(sourceParentId),
+	})
+	if awsutil.ErrorCodeIs(err, DuplicateAccountException) {
+		err = nil
+	}
+	return err
+}
@@ -2,6 +2,7 @@ package awsorgs
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -11,18 +12,23 @@ import (
 	"github.com/src-bin/substrate/awscfg"
 	"github.com/src-bin/substrate/awsutil"
 	"github.com/src-bin/substrate/policies"
+	"github.com/src-bin/substrate/tagging"
+	"github.com/src-bin/substrate/version"
 )
 
 type (
-	Policy        = types.Policy
-	PolicySummary = types.PolicySummary
-	PolicyType    = types.PolicyType
+	Policy              = types.Policy
+	PolicySummary       = types.PolicySummary
+	PolicyTargetSummary = types.PolicyTargetSummary
+	PolicyType          = types.PolicyType
 )
 
 const (
 	ConcurrentModificationException    = "ConcurrentModificationException"
 	DuplicatePolicyAttachmentException = "DuplicatePolicyAttachmentException"
 	DuplicatePolicyException           = "DuplicatePolicyException"
+	PolicyNotAttachedException         = "PolicyNotAttachedException"
+	PolicyNotFoundException            = "PolicyNotFoundException"
 	PolicyTypeAlreadyEnabledException  = "PolicyTypeAlreadyEnabledException"
 
 	AISERVICES_OPT_OUT_POLICY PolicyType = "AISERVICES_OPT_OUT_POLICY"
@@ -31,6 +37,43 @@ const (
 	TAG_POLICY                PolicyType = "TAG_POLICY"
 )
 
+type PolicyNotFound string
+
+func (err PolicyNotFound) Error() string {
+	return fmt.Sprintf("policy not found: %s", string(err))
+}
+
+// AttachPolicy attaches a policy to a target, which may be the root, an
+// organizational unit, or an account. It's not an error to attach a policy
+// that's already attached.
+func AttachPolicy(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	policyId, targetId string,
+) error {
+	_, err := cfg.Organizations().AttachPolicy(ctx, &organizations.AttachPolicyInput{
+		PolicyId: aws.String(policyId),
+		TargetId: aws.String(targetId),
+	})
+	if awsutil.ErrorCodeIs(err, DuplicatePolicyAttachmentException) {
+		err = nil
+	}
+	return err
+}
+
+// DeletePolicy deletes a policy, which must first be detached from every
+// target.
+func DeletePolicy(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	policyId string,
+) error {
+	_, err := cfg.Organizations().DeletePolicy(ctx, &organizations.DeletePolicyInput{
+		PolicyId: aws.String(policyId),
+	})
+	return err
+}
+
 func DescribePolicy(
 	ctx context.Context,
 	cfg *awscfg.Config,
@@ -45,6 +88,23 @@ func DescribePolicy(
 	return out.Policy, nil
 }
 
+// DetachPolicy detaches a policy from a target. It's not an error to detach
+// a policy that isn't attached.
+func DetachPolicy(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	policyId, targetId string,
+) error {
+	_, err := cfg.Organizations().DetachPolicy(ctx, &organizations.DetachPolicyInput{
+		PolicyId: aws.String(policyId),
+		TargetId: aws.String(targetId),
+	})
+	if awsutil.ErrorCodeIs(err, PolicyNotAttachedException) {
+		err = nil
+	}
+	return err
+}
+
 func EnablePolicyType(
 	ctx context.Context,
 	cfg *awscfg.Config,
@@ -68,13 +128,17 @@ func EnablePolicyType(
 		}); err != nil && !awsutil.ErrorCodeIs(err, ConcurrentModificationException) {
 			return err
 		}
+		if cfg.Planning() {
+			return nil // it's never going to become enabled
+		}
 		time.Sleep(5e9) // TODO exponential backoff
 	}
 }
 
 // EnsurePolicy makes potentially several AWS API requests to ensure,
 // regardless of initial state, that a policy by a given name and type exists,
-// has the desired content, and is attached to the specified root.
+// has the desired content, is tagged as being managed by Substrate, and is
+// attached to the specified root.
 //
 // A curiosity:  Though DescribeOrganization alludes to the ability to attach
 // service control policies to the management account, that does not appear to be
@@ -99,39 +163,73 @@ func EnsurePolicy(
 		return err
 	}
 
+	policy, err := EnsurePolicyDocument(ctx, cfg, name, policyType, doc, nil)
+	if err != nil {
+		return err
+	}
+	//log.Printf("%+v", policy)
+
+	return AttachPolicy(ctx, cfg, aws.ToString(policy.PolicySummary.Id), aws.ToString(root.Id))
+}
+
+// EnsurePolicyDocument creates or updates a policy by a given name and type
+// so that it has the desired content and is tagged as being managed by
+// Substrate plus any additional tags given. It does not attach the policy to
+// any targets; see AttachPolicy for that.
+func EnsurePolicyDocument(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	name string,
+	policyType PolicyType,
+	doc *policies.Document,
+	tags tagging.Map,
+) (*Policy, error) {
 	policy, err := createPolicy(ctx, cfg, name, policyType, doc)
 	if awsutil.ErrorCodeIs(err, DuplicatePolicyException) {
-		err = nil
-
-		summaries, err := ListPolicies(ctx, cfg, policyType)
-		if err != nil {
-			return err
+		var summary *PolicySummary
+		if summary, err = FindPolicy(ctx, cfg, name, policyType); err != nil {
+			return nil, err
 		}
-		for _, summary := range summaries {
-			if aws.ToString(summary.Name) != name {
-				continue
-			}
-
-			policy, err = updatePolicy(ctx, cfg, aws.ToString(summary.Id), name, doc)
-			if err != nil {
-				return err
-			}
+		if summary == nil {
+			return nil, PolicyNotFound(name)
 		}
-
+		policy, err = updatePolicy(ctx, cfg, aws.ToString(summary.Id), name, doc)
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	//log.Printf("%+v", policy)
-
-	// TODO tag the policy, which isn't a supported operation; get clever
 
-	err = attachPolicy(ctx, cfg, aws.ToString(policy.PolicySummary.Id), aws.ToString(root.Id))
-	if awsutil.ErrorCodeIs(err, DuplicatePolicyAttachmentException) {
-		err = nil
+	if err := Tag(ctx, cfg, aws.ToString(policy.PolicySummary.Id), tagging.Merge(
+		tags,
+		tagging.Map{
+			tagging.Manager:          tagging.Substrate,
+			tagging.SubstrateVersion: version.Version,
+		},
+	)); err != nil {
+		return nil, err
 	}
 
-	return err
+	return policy, nil
+}
+
+// FindPolicy returns the summary of the policy of the given type with the
+// given name or nil if there's no such policy.
+func FindPolicy(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	name string,
+	policyType PolicyType,
+) (*PolicySummary, error) {
+	summaries, err := ListPolicies(ctx, cfg, policyType)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range summaries {
+		if aws.ToString(summary.Name) == name {
+			return &summary, nil
+		}
+	}
+	return nil, nil
 }
 
 func ListPolicies(
@@ -156,6 +254,35 @@ func ListPolicies(
 	return
 }
 
+// ListPolicyTags returns the tags on the given policy.
+func ListPolicyTags(ctx context.Context, cfg *awscfg.Config, policyId string) (tagging.Map, error) {
+	return listTagsForResource(ctx, cfg, policyId)
+}
+
+// ListTargetsForPolicy returns the roots, organizational units, and accounts
+// to which the given policy is attached.
+func ListTargetsForPolicy(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	policyId string,
+) (targets []PolicyTargetSummary, err error) {
+	var nextToken *string
+	for {
+		out, err := cfg.Organizations().ListTargetsForPolicy(ctx, &organizations.ListTargetsForPolicyInput{
+			NextToken: nextToken,
+			PolicyId:  aws.String(policyId),
+		})
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, out.Targets...)
+		if nextToken = out.NextToken; nextToken == nil {
+			break
+		}
+	}
+	return
+}
+
 // OrgAssumeRolePolicy returns an assume-role policy that will allow any
 // principal in this organization to assume a role, which is useful for
 // roles that don't necessarily know the account numbers and/or role names
@@ -201,18 +328,6 @@ func PutResourcePolicy(ctx context.Context, cfg *awscfg.Config, doc *policies.Do
 	return err
 }
 
-func attachPolicy(
-	ctx context.Context,
-	cfg *awscfg.Config,
-	policyId, rootId string,
-) error {
-	_, err := cfg.Organizations().AttachPolicy(ctx, &organizations.AttachPolicyInput{
-		PolicyId: aws.String(policyId),
-		TargetId: aws.String(rootId),
-	})
-	return err
-}
-
 func createPolicy(
 	ctx context.Context,
 	cfg *awscfg.Config,
@@ -135,6 +135,10 @@ func EnsureServiceQuota(
 		//log.Printf("%+v", req)
 	}
 
+	if cfg.Planning() {
+		return nil // don't wait for a request that was only planned
+	}
+
 	var zero time.Time
 	for {
 
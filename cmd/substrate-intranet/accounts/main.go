@@ -40,6 +40,17 @@ func Main(
 ) (*events.APIGatewayV2HTTPResponse, error) {
 	var err error
 
+	// Users allowed more than one role by their group memberships choose
+	// which one to start from with the as query string parameter.
+	as, err := authorizerutil.SelectRoleName(
+		event.RequestContext.Authorizer.Lambda,
+		event.QueryStringParameters["as"],
+	)
+	if err != nil {
+		return lambdautil.ErrorResponse2(err)
+	}
+	roleNames := authorizerutil.AllowedRoleNames(event.RequestContext.Authorizer.Lambda)
+
 	accountId := event.QueryStringParameters["number"]
 	roleName := event.QueryStringParameters["role"]
 	if accountId != "" && roleName != "" {
@@ -50,7 +61,7 @@ func Main(
 		if userCfg, err = cfg.AssumeRole(
 			ctx,
 			event.RequestContext.AccountID,
-			fmt.Sprint(event.RequestContext.Authorizer.Lambda[authorizerutil.RoleName]),
+			as,
 			time.Hour,
 		); err != nil {
 			return lambdautil.ErrorResponse2(err)
@@ -127,11 +138,13 @@ func Main(
 		SubstrateAccount                                               *awsorgs.Account
 		AuditAccount, DeployAccount, ManagementAccount, NetworkAccount *awsorgs.Account
 		RoleName                                                       string
+		RoleNames                                                      []string
 	}{
 		adminAccounts, serviceAccounts,
 		substrateAccount,
 		auditAccount, deployAccount, managementAccount, networkAccount,
-		fmt.Sprint(event.RequestContext.Authorizer.Lambda[authorizerutil.RoleName]),
+		as,
+		roleNames,
 	})
 	if err != nil {
 		return nil, err
@@ -4,17 +4,24 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/src-bin/substrate/authorizerutil"
 	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsutil"
 	"github.com/src-bin/substrate/contextutil"
 	"github.com/src-bin/substrate/lambdautil"
 	"github.com/src-bin/substrate/oauthoidc"
 	"github.com/src-bin/substrate/policies"
+	"github.com/src-bin/substrate/sessions"
 	"github.com/src-bin/substrate/ui"
 )
 
+// renewalLease is how long a request may take to renew a session's tokens
+// before another request may try.
+const renewalLease = 5 * time.Second
+
 func authorizer(
 	cfg *awscfg.Config,
 	oc *oauthoidc.Client,
@@ -40,10 +47,13 @@ func authorizer(
 		}
 
 		idToken := &oauthoidc.IDToken{}
+		var sessionCookie string
 		for _, cookie := range lambdautil.Cookies2(event.Cookies) {
 			switch cookie.Name {
 			case "a":
 				authContext[authorizerutil.AccessToken] = cookie.Value
+			case sessions.CookieName:
+				sessionCookie = cookie.Value
 			case "id":
 				_, err := oauthoidc.ParseAndVerifyJWT(cookie.Value, oc, idToken)
 				if err != nil {
@@ -60,16 +70,70 @@ func authorizer(
 			}
 		}
 
+		// Every request must belong to a session that hasn't been revoked and
+		// belongs to the same user as the ID token. If the ID token's missing,
+		// invalid, or expired but the session has a refresh token, use it to
+		// get new tokens from the IdP without sending the browser through the
+		// login flow.
+		session, err := sessions.Get(ctx, cfg, sessionCookie)
+		if err != nil {
+			authContext[authorizerutil.Error] = err
+			ui.PrintWithCaller(err)
+			idToken = &oauthoidc.IDToken{} // revert to zero-value and thus to denying access
+		} else if idToken.Email != "" && idToken.Email != session.PrincipalId {
+			err := oauthoidc.VerificationError{
+				Field:    "email",
+				Actual:   idToken.Email,
+				Expected: session.PrincipalId,
+			}
+			authContext[authorizerutil.Error] = err
+			ui.PrintWithCaller(err)
+			idToken = &oauthoidc.IDToken{}
+		} else if idToken.Email == "" && session.RefreshToken != "" {
+			if doc, renewed, err := renew(ctx, cfg, oc, session); err == nil {
+				idToken = renewed
+				authContext[authorizerutil.AccessToken] = doc.AccessToken
+				ctx = context.WithValue(ctx, contextutil.Username, idToken.Email)
+				if authContext[authorizerutil.IDToken], err = idToken.JSONString(); err != nil {
+					ui.PrintWithCaller(err)
+					return nil, err
+				}
+				authContext[authorizerutil.SetCookies] = authorizerutil.MarshalSetCookies(
+					sessions.SetTokenCookies(doc.AccessToken, doc.IDToken, idToken.Expires),
+				)
+				delete(authContext, authorizerutil.Error)
+			} else {
+				authContext[authorizerutil.Error] = err
+				ui.PrintWithCaller(err)
+			}
+		}
+
 		effect := policies.Deny
 		if idToken.Email != "" {
 			authContext[authorizerutil.PrincipalId] = idToken.Email // would be overkill except see the comment on PrincipalID below
+
+			// The role name the IdP defines for this user, if any, is the
+			// default and any others granted by their group memberships may
+			// be chosen instead.
 			roleName, err := oc.WithAccessToken(fmt.Sprint(authContext[authorizerutil.AccessToken])).RoleNameFromIdP(idToken.Email)
+			groupRoleNames := oc.GroupRoles.RoleNames(idToken)
+			var roleNames []string
 			if err == nil {
-				authContext[authorizerutil.RoleName] = roleName
+				roleNames = append(roleNames, roleName)
+			} else if len(groupRoleNames) == 0 { // not worth logging for users whose roles all come from groups
+				ui.PrintWithCaller(err)
+			}
+			for _, groupRoleName := range groupRoleNames {
+				if groupRoleName != roleName {
+					roleNames = append(roleNames, groupRoleName)
+				}
+			}
+			if len(roleNames) > 0 {
+				authContext[authorizerutil.RoleName] = roleNames[0]
+				authContext[authorizerutil.RoleNames] = authorizerutil.MarshalRoleNames(roleNames)
 				effect = policies.Allow
 			} else {
 				authContext[authorizerutil.Error] = err
-				ui.PrintWithCaller(err)
 			}
 		}
 
@@ -88,3 +152,146 @@ func authorizer(
 		}, nil
 	}
 }
+
+// renew exchanges the refresh token stored in the session for new tokens and
+// verifies the new ID token. Browsers send many requests at once and every
+// one of them finds the same expired ID token so only the request that takes
+// the session's lease actually spends the refresh token; the rest wait for it
+// to store the new tokens in the session and reuse those.
+func renew(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	oc *oauthoidc.Client,
+	s *sessions.Session,
+) (*oauthoidc.TokenResponse, *oauthoidc.IDToken, error) {
+	return renewWith(dynamoDBRenewals{ctx, cfg}, oauthOIDCRenewals{oc}, s)
+}
+
+// renewalIdP is the part of the IdP that renew needs, separated from
+// oauthoidc.Client so renewWith can be tested without an IdP.
+type renewalIdP interface {
+	Refresh(refreshToken string) (*oauthoidc.TokenResponse, error)
+	Verify(s *sessions.Session, jwt string) (*oauthoidc.IDToken, error)
+}
+
+// renewalStore is the part of the sessions table that renew needs, separated
+// from package sessions so renewWith can be tested without DynamoDB.
+type renewalStore interface {
+	Get(cookie string) (*sessions.Session, error)
+	Lease(s *sessions.Session, d time.Duration) error
+	Release(s *sessions.Session) error
+	Renew(s *sessions.Session, accessToken, idToken, refreshToken string) error
+}
+
+// renewWith implements renew. If the refresh or verification fails after
+// taking the lease, it releases the lease so the other requests waiting on it
+// fail fast instead of waiting out renewalLease.
+func renewWith(
+	store renewalStore,
+	idp renewalIdP,
+	s *sessions.Session,
+) (*oauthoidc.TokenResponse, *oauthoidc.IDToken, error) {
+	if doc, idToken, err := renewed(idp, s); err == nil {
+		return doc, idToken, nil
+	}
+
+	if err := store.Lease(s, renewalLease); err != nil {
+		if _, ok := err.(sessions.LeaseError); !ok {
+			return nil, nil, err
+		}
+		deadline := time.Now().Add(renewalLease)
+		for range awsutil.JitteredExponentialBackoff(100*time.Millisecond, time.Second) {
+			if s, err := store.Get(s.Cookie()); err != nil {
+				return nil, nil, err
+			} else if doc, idToken, err := renewed(idp, s); err == nil {
+				return doc, idToken, nil
+			}
+			if time.Now().After(deadline) {
+				break
+			}
+		}
+		return nil, nil, err
+	}
+
+	doc, err := idp.Refresh(s.RefreshToken)
+	if err != nil {
+		release(store, s)
+		return nil, nil, err
+	}
+	idToken, err := idp.Verify(s, doc.IDToken)
+	if err != nil {
+		release(store, s)
+		return nil, nil, err
+	}
+	refreshToken := s.RefreshToken
+	if doc.RefreshToken != "" { // the IdP rotated the refresh token
+		refreshToken = doc.RefreshToken
+	}
+	if err := store.Renew(s, doc.AccessToken, doc.IDToken, refreshToken); err != nil {
+		return nil, nil, err
+	}
+	return doc, idToken, nil
+}
+
+// renewed returns the tokens stored in the session by the most recent renewal
+// if they're still valid.
+func renewed(idp renewalIdP, s *sessions.Session) (*oauthoidc.TokenResponse, *oauthoidc.IDToken, error) {
+	if s.IDToken == "" {
+		return nil, nil, oauthoidc.InvalidJWTError("session has never been renewed")
+	}
+	idToken, err := idp.Verify(s, s.IDToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &oauthoidc.TokenResponse{AccessToken: s.AccessToken, IDToken: s.IDToken}, idToken, nil
+}
+
+// release releases the session's lease, only logging any error because the
+// lease expires on its own, anyway.
+func release(store renewalStore, s *sessions.Session) {
+	if err := store.Release(s); err != nil {
+		ui.PrintWithCaller(err)
+	}
+}
+
+type dynamoDBRenewals struct {
+	ctx context.Context
+	cfg *awscfg.Config
+}
+
+func (r dynamoDBRenewals) Get(cookie string) (*sessions.Session, error) {
+	return sessions.Get(r.ctx, r.cfg, cookie)
+}
+
+func (r dynamoDBRenewals) Lease(s *sessions.Session, d time.Duration) error {
+	return s.Lease(r.ctx, r.cfg, d)
+}
+
+func (r dynamoDBRenewals) Release(s *sessions.Session) error {
+	return s.Release(r.ctx, r.cfg)
+}
+
+func (r dynamoDBRenewals) Renew(s *sessions.Session, accessToken, idToken, refreshToken string) error {
+	return s.Renew(r.ctx, r.cfg, accessToken, idToken, refreshToken)
+}
+
+type oauthOIDCRenewals struct {
+	*oauthoidc.Client
+}
+
+// Verify parses and verifies an ID token from a renewal and ensures it
+// belongs to the session's principal.
+func (r oauthOIDCRenewals) Verify(s *sessions.Session, jwt string) (*oauthoidc.IDToken, error) {
+	idToken := &oauthoidc.IDToken{}
+	if _, err := oauthoidc.ParseAndVerifyJWT(jwt, r.Client, idToken); err != nil {
+		return nil, err
+	}
+	if idToken.Email != s.PrincipalId {
+		return nil, oauthoidc.VerificationError{
+			Field:    "email",
+			Actual:   idToken.Email,
+			Expected: s.PrincipalId,
+		}
+	}
+	return idToken, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/src-bin/substrate/oauthoidc"
+	"github.com/src-bin/substrate/sessions"
+)
+
+func TestRenewConcurrently(t *testing.T) {
+	store := newFakeRenewalStore()
+	idp := &fakeRenewalIdP{}
+	const n = 10
+	idTokens := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := store.Get("")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			doc, _, err := renewWith(store, idp, s)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			idTokens[i] = doc.IDToken
+		}(i)
+	}
+	wg.Wait()
+	if idp.refreshes != 1 {
+		t.Fatalf("refreshed %d times; expected 1", idp.refreshes)
+	}
+	for i, idToken := range idTokens {
+		if idToken != "id-1" {
+			t.Errorf("request %d got ID token %q; expected %q", i, idToken, "id-1")
+		}
+	}
+}
+
+func TestRenewReleasesLeaseOnFailure(t *testing.T) {
+	store := newFakeRenewalStore()
+	idp := &fakeRenewalIdP{err: errors.New("invalid_grant")}
+	s, err := store.Get("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := renewWith(store, idp, s); err == nil {
+		t.Fatal("renewal succeeded despite the IdP refusing the refresh token")
+	}
+	if !store.lease.IsZero() {
+		t.Fatal("lease not released after a failed renewal")
+	}
+
+	idp.err = nil
+	start := time.Now()
+	doc, _, err := renewWith(store, idp, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d >= renewalLease {
+		t.Errorf("renewal waited %v for the lease", d)
+	}
+	if doc.IDToken != "id-2" {
+		t.Errorf("got ID token %q; expected %q", doc.IDToken, "id-2")
+	}
+}
+
+// fakeRenewalIdP issues numbered tokens and accepts any ID token it issued.
+type fakeRenewalIdP struct {
+	err       error
+	mu        sync.Mutex
+	refreshes int
+}
+
+func (idp *fakeRenewalIdP) Refresh(refreshToken string) (*oauthoidc.TokenResponse, error) {
+	time.Sleep(50 * time.Millisecond) // give the other requests time to contend
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.refreshes++
+	if idp.err != nil {
+		return nil, idp.err
+	}
+	return &oauthoidc.TokenResponse{
+		AccessToken: fmt.Sprint("access-", idp.refreshes),
+		IDToken:     fmt.Sprint("id-", idp.refreshes),
+	}, nil
+}
+
+func (idp *fakeRenewalIdP) Verify(s *sessions.Session, jwt string) (*oauthoidc.IDToken, error) {
+	if jwt == "" || jwt == "expired" {
+		return nil, oauthoidc.InvalidJWTError(jwt)
+	}
+	return &oauthoidc.IDToken{Email: s.PrincipalId}, nil
+}
+
+// fakeRenewalStore holds one session and enforces the same lease and version
+// conditions as the sessions table.
+type fakeRenewalStore struct {
+	lease time.Time
+	mu    sync.Mutex
+	s     sessions.Session
+}
+
+func newFakeRenewalStore() *fakeRenewalStore {
+	return &fakeRenewalStore{s: sessions.Session{
+		AccessToken:  "access-0",
+		IDToken:      "expired",
+		Id:           "id",
+		PrincipalId:  "user@example.com",
+		RefreshToken: "refresh",
+		Version:      1,
+	}}
+}
+
+func (store *fakeRenewalStore) Get(string) (*sessions.Session, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	s := store.s
+	return &s, nil
+}
+
+func (store *fakeRenewalStore) Lease(s *sessions.Session, d time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if s.Version != store.s.Version || time.Now().Before(store.lease) {
+		return sessions.LeaseError(s.Id)
+	}
+	store.lease = time.Now().Add(d)
+	return nil
+}
+
+func (store *fakeRenewalStore) Release(s *sessions.Session) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if s.Version == store.s.Version {
+		store.lease = time.Time{}
+	}
+	return nil
+}
+
+func (store *fakeRenewalStore) Renew(s *sessions.Session, accessToken, idToken, refreshToken string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if s.Version != store.s.Version {
+		return sessions.LeaseError(s.Id)
+	}
+	store.s.AccessToken = accessToken
+	store.s.IDToken = idToken
+	store.s.RefreshToken = refreshToken
+	store.s.Version++
+	store.lease = time.Time{}
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/src-bin/substrate/authorizerutil"
 	"github.com/src-bin/substrate/awscfg"
 	"github.com/src-bin/substrate/awsiam"
@@ -123,20 +124,28 @@ func authorize(
 	if len(token) < MinTokenLength {
 		return lambdautil.ErrorResponse2(fmt.Errorf("token must be at least %d characters long", MinTokenLength))
 	}
+	roleName, err := authorizerutil.SelectRoleName(
+		event.RequestContext.Authorizer.Lambda,
+		event.QueryStringParameters["role"],
+	)
+	if err != nil {
+		return lambdautil.ErrorResponse2(err)
+	}
 	if err := awsiam.TagUser(
 		ctx,
 		cfg,
 		users.Substrate,
 		tagging.Map{TagKeyPrefix + token: NewTagValue(
 			fmt.Sprint(event.RequestContext.Authorizer.Lambda[authorizerutil.PrincipalId]),
-			fmt.Sprint(event.RequestContext.Authorizer.Lambda[authorizerutil.RoleName]),
+			roleName,
 		).String()},
 	); err != nil {
 		return nil, err
 	}
 	ui.PrintfWithCaller(
-		"authorized a token exchange for %s",
+		"authorized a token exchange for %s as %s",
 		fmt.Sprint(event.RequestContext.Authorizer.Lambda[authorizerutil.PrincipalId]),
+		roleName,
 	)
 
 	// Garbage collect expired tags asynchronously if we're not very close to
@@ -281,17 +290,32 @@ func index(
 	if err != nil {
 		return lambdautil.ErrorResponse2(err)
 	}
+	roleName, err := authorizerutil.SelectRoleName(
+		event.RequestContext.Authorizer.Lambda,
+		event.QueryStringParameters["role"],
+	)
+	if err != nil {
+		return lambdautil.ErrorResponse2(err)
+	}
 	creds, err := awsiam.AllDayCredentials(
 		ctx,
 		cfg,
 		accountId,
-		fmt.Sprint(event.RequestContext.Authorizer.Lambda[authorizerutil.RoleName]),
+		roleName,
 	)
 	if err != nil {
 		return lambdautil.ErrorResponse2(err)
 	}
 
-	body, err := lambdautil.RenderHTML(html, creds)
+	body, err := lambdautil.RenderHTML(html, struct {
+		aws.Credentials
+		RoleName  string
+		RoleNames []string
+	}{
+		creds,
+		roleName,
+		authorizerutil.AllowedRoleNames(event.RequestContext.Authorizer.Lambda),
+	})
 	if err != nil {
 		return nil, err
 	}
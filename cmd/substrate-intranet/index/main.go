@@ -46,7 +46,7 @@ func Main(
 		} else {
 			continue // unlists "$default", which is most of the Substrate-managed Intranet
 		}
-		if path == "/credential-factory/fetch" || path == "/favicon.ico" || path == "/login" {
+		if path == "/credential-factory/fetch" || path == "/favicon.ico" || path == "/login" || path == "/logout" {
 			continue // unlists the bits of the Substrate-managed Intranet that don't require auth[nz]
 		}
 		if strings.Contains(path, "{") {
@@ -13,13 +13,16 @@ import (
 	"github.com/src-bin/substrate/lambdautil"
 	"github.com/src-bin/substrate/oauthoidc"
 	"github.com/src-bin/substrate/randutil"
+	"github.com/src-bin/substrate/sessions"
 	"github.com/src-bin/substrate/ui"
 )
 
 //go:generate go run ../../../tools/template/main.go -name loginTemplate -package login login.html
 //go:generate go run ../../../tools/template/main.go -name redirectTemplate -package login redirect.html
 
-const maxAge = 43200 // 12 hours, in seconds for the Max-Age modifier in the Set-Cookie header
+// pkceCookieName names the cookie that holds the PKCE code verifier between
+// redirecting to the IdP and handling its redirect back here.
+const pkceCookieName = "pkce"
 
 func errorResponse(err error, extras ...interface{}) *events.APIGatewayV2HTTPResponse {
 	ui.PrintWithCaller(err) // log the error to CloudWatch but not the extras, which may be sensitive
@@ -68,6 +71,9 @@ func Main(
 		v.Add("code", code)
 		v.Add("grant_type", "authorization_code")
 		v.Add("redirect_uri", redirectURI.String())
+		if cookie := lambdautil.Cookie2(event.Cookies, pkceCookieName); cookie != nil {
+			v.Add("code_verifier", cookie.Value)
+		}
 		doc := &oauthoidc.TokenResponse{}
 		resp, tokenBody, err := oc.Post(oauthoidc.Token, v, doc)
 		if err != nil {
@@ -90,11 +96,32 @@ func Main(
 			Location string
 		}
 		bodyV.IDToken = idToken
-		setCookies := []string{
-			fmt.Sprintf("a=%s; HttpOnly; Max-Age=%d; Secure", doc.AccessToken, maxAge),
-			fmt.Sprintf("exp=%d; HttpOnly; Max-Age=%d; Secure", idToken.Expires, maxAge),
-			fmt.Sprintf("id=%s; HttpOnly; Max-Age=%d; Secure", doc.IDToken, maxAge),
+		maxAge := int(sessions.Lifetime().Seconds())
+		setCookies := append(
+			sessions.SetTokenCookies(doc.AccessToken, doc.IDToken, idToken.Expires),
 			fmt.Sprintf("csrf=%s; HttpOnly; Max-Age=%d; Secure", randutil.String(), maxAge),
+			fmt.Sprintf("%s=; HttpOnly; Max-Age=0; Path=/login; Secure", pkceCookieName),
+		)
+
+		// Start a session, without which the authorizer won't allow any
+		// requests, so that it can be revoked. If the IdP granted offline
+		// access, store the refresh token, too, so the authorizer can renew
+		// the ID and access tokens when they expire rather than sending the
+		// user back through the IdP.
+		session, err := sessions.New(idToken.Email, doc.RefreshToken, sessions.Lifetime())
+		if err != nil {
+			return nil, err
+		}
+		if err := session.Put(ctx, cfg); err != nil {
+			return nil, err
+		}
+		setCookies = append(setCookies, session.SetCookie())
+		if cookie := lambdautil.Cookie2(event.Cookies, sessions.CookieName); cookie != nil {
+			if old, err := sessions.Get(ctx, cfg, cookie.Value); err == nil {
+				if err := sessions.Revoke(ctx, cfg, old.Id); err != nil {
+					ui.PrintWithCaller(err) // it'll expire eventually
+				}
+			}
 		}
 		if i := strings.LastIndexByte(idToken.Email, '@'); i != -1 && oc.IsGoogle() {
 			setCookies = append(setCookies, fmt.Sprintf(
@@ -132,18 +159,31 @@ func Main(
 	q.Add("nonce", nonce)
 	q.Add("redirect_uri", redirectURI.String())
 	q.Add("response_type", "code")
+	verifier, err := oauthoidc.PKCEVerifier()
+	if err != nil {
+		return nil, err
+	}
+	q.Add("code_challenge", oauthoidc.PKCEChallenge(verifier))
+	q.Add("code_challenge_method", oauthoidc.PKCEChallengeMethod)
 	scope := "openid email profile"
 	if oc.IsAzureAD() {
-		scope += " CustomSecAttributeAssignment.Read.All User.Read"
+		scope += " offline_access CustomSecAttributeAssignment.Read.All User.Read"
+	}
+	if oc.IsGeneric() {
+		scope += " offline_access"
 	}
 	if oc.IsGoogle() {
 		scope += " https://www.googleapis.com/auth/admin.directory.user.readonly"
+		q.Add("access_type", "offline") // Google's way of asking for a refresh token
 		if hd := lambdautil.Cookie2(event.Cookies, "hd"); hd != nil {
 			q.Add("hd", hd.Value)
 		}
 	}
 	if oc.IsOkta() {
-		scope += " okta.users.read.self"
+		scope += " offline_access okta.users.read.self"
+		if oc.GroupRoles != nil {
+			scope += " groups" // Okta only includes the groups claim when asked
+		}
 	}
 	q.Add("scope", scope)
 	state = &oauthoidc.State{
@@ -167,7 +207,13 @@ func Main(
 	}
 
 	return &events.APIGatewayV2HTTPResponse{
-		Body:       body,
+		Body: body,
+		Cookies: []string{fmt.Sprintf(
+			"%s=%s; HttpOnly; Max-Age=%d; Path=/login; Secure",
+			pkceCookieName,
+			verifier,
+			600, // 10 minutes to authenticate with the IdP
+		)},
 		Headers:    headers,
 		StatusCode: statusCode,
 	}, nil
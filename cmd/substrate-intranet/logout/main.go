@@ -0,0 +1,92 @@
+package logout
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/lambdautil"
+	"github.com/src-bin/substrate/oauthoidc"
+	"github.com/src-bin/substrate/sessions"
+	"github.com/src-bin/substrate/ui"
+)
+
+// Main revokes the browser's session and clears all its cookies. It's served
+// without the authorizer so that it works even when the session's already
+// expired or been revoked. It still requires the session cookie, key and all,
+// so no one can revoke anyone else's session with just its ID, and only
+// revokes sessions when POSTed a form with the CSRF token so that links from
+// other sites can't log anyone out; GET asks for confirmation instead.
+func Main(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	oc *oauthoidc.Client,
+	event *events.APIGatewayV2HTTPRequest,
+) (*events.APIGatewayV2HTTPResponse, error) {
+	cookie := lambdautil.Cookie2(event.Cookies, sessions.CookieName)
+	csrf := lambdautil.CSRFCookie2(event)
+
+	if event.RequestContext.HTTP.Method == "POST" {
+		body, err := lambdautil.EventBody2(event)
+		if err != nil {
+			return lambdautil.ErrorResponse2(err)
+		}
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return lambdautil.ErrorResponse2(err)
+		}
+		if err := lambdautil.PreventCSRF2(values, event); err != nil {
+			return lambdautil.ErrorResponse2(err)
+		}
+	} else if cookie != nil && csrf != "" {
+		return render(nil, struct {
+			CSRF, PrincipalId string
+			Confirm           bool
+		}{
+			CSRF:    csrf,
+			Confirm: true,
+		})
+	}
+
+	// Without a CSRF token there's no way to tell whether this request came
+	// from the Intranet so don't revoke anything, though clearing the cookies
+	// is harmless.
+	var principalId string
+	if cookie != nil && csrf != "" {
+		if s, err := sessions.Get(ctx, cfg, cookie.Value); err == nil {
+			if err := sessions.Revoke(ctx, cfg, s.Id); err != nil {
+				return lambdautil.ErrorResponse2(err)
+			}
+			principalId = s.PrincipalId
+			ui.Printf("revoked session %s for %s", s.Id, principalId)
+		} else {
+			ui.PrintWithCaller(err) // nothing to revoke but still clear the cookies
+		}
+	}
+
+	return render(sessions.ClearCookies(), struct {
+		CSRF, PrincipalId string
+		Confirm           bool
+	}{
+		PrincipalId: principalId,
+	})
+}
+
+func render(setCookies []string, v interface{}) (*events.APIGatewayV2HTTPResponse, error) {
+	body, err := lambdautil.RenderHTML(html, v)
+	if err != nil {
+		return nil, err
+	}
+	return &events.APIGatewayV2HTTPResponse{
+		Body:       body,
+		Cookies:    setCookies,
+		Headers:    map[string]string{"Content-Type": "text/html; charset=utf-8"},
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+//go:embed logout.html
+var html string
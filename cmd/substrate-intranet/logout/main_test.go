@@ -0,0 +1,42 @@
+package logout
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/src-bin/substrate/sessions"
+)
+
+func TestLogoutGETConfirms(t *testing.T) {
+	resp, err := Main(context.Background(), nil /* cfg */, nil /* oc */, request("GET", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Cookies) != 0 {
+		t.Errorf("GET cleared cookies: %v", resp.Cookies)
+	}
+	if !strings.Contains(resp.Body, `<form method="POST">`) || !strings.Contains(resp.Body, `value="csrf-token"`) {
+		t.Fatal(resp.Body)
+	}
+}
+
+func TestLogoutPOSTWithoutCSRF(t *testing.T) {
+	resp, err := Main(context.Background(), nil /* cfg */, nil /* oc */, request("POST", "csrf=wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Cookies) != 0 || !strings.Contains(resp.Body, "CSRF") {
+		t.Fatal(resp.Body)
+	}
+}
+
+func request(method, body string) *events.APIGatewayV2HTTPRequest {
+	event := &events.APIGatewayV2HTTPRequest{
+		Body:    body,
+		Cookies: []string{"csrf=csrf-token", sessions.CookieName + "=id.key"},
+	}
+	event.RequestContext.HTTP.Method = method
+	return event
+}
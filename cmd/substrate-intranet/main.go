@@ -7,11 +7,13 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/src-bin/substrate/authorizerutil"
 	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsutil"
 	"github.com/src-bin/substrate/contextutil"
 	"github.com/src-bin/substrate/oauthoidc"
 	"github.com/src-bin/substrate/ui"
@@ -20,6 +22,11 @@ import (
 //go:generate go run ../../tools/dispatch-map/main.go -function JavaScript -o dispatch-map-js.go .
 //go:generate go run ../../tools/dispatch-map/main.go -function Main -o dispatch-map-main.go .
 
+// discoveryTimeout is how long to retry fetching a generic OpenID Connect
+// IdP's discovery document, which must be well within the 10 seconds Lambda
+// allows for initialization.
+const discoveryTimeout = 5 * time.Second
+
 func main() {
 	ctx := contextutil.WithValues(context.Background(), "substrate-intranet", "", "")
 
@@ -29,10 +36,17 @@ func main() {
 	}
 
 	clientId := os.Getenv(oauthoidc.OAuthOIDCClientId)
+	provider := oauthoidc.IdPName(clientId, os.Getenv(oauthoidc.OIDCIssuer))
 	var pathQualifier oauthoidc.PathQualifier
-	switch oauthoidc.IdPName(clientId) {
+	switch provider {
 	case oauthoidc.AzureAD:
 		pathQualifier = oauthoidc.AzureADPathQualifier(os.Getenv(oauthoidc.AzureADTenantId))
+	case oauthoidc.Generic:
+		doc, err := discover(os.Getenv(oauthoidc.OIDCIssuer))
+		if err != nil {
+			ui.Fatal(err)
+		}
+		pathQualifier = oauthoidc.DiscoveryPathQualifier(doc)
 	case oauthoidc.Google:
 		pathQualifier = oauthoidc.GooglePathQualifier()
 	case oauthoidc.Okta:
@@ -43,44 +57,95 @@ func main() {
 		cfg,
 		clientId,
 		os.Getenv(oauthoidc.OAuthOIDCClientSecretTimestamp),
+		provider,
 		pathQualifier,
 	)
 	if err != nil {
 		ui.Fatal(err)
 	}
+	oc.RoleNameClaim = os.Getenv(oauthoidc.OIDCRoleNameClaim)
+	if s := os.Getenv(oauthoidc.GroupRolesJSON); s != "" {
+		if oc.GroupRoles, err = oauthoidc.ParseGroupRoles([]byte(s)); err != nil {
+			ui.Fatal(err)
+		}
+	}
 
 	lambda.Start(&Mux{
 		Authorizer: authorizer(cfg, oc),
 		Handler: func(ctx context.Context, event *events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTPResponse, error) {
-			var principalId string
+			var (
+				principalId string
+				setCookies  []string
+			)
 			if event.RequestContext.Authorizer != nil {
 				principalId = fmt.Sprint(event.RequestContext.Authorizer.Lambda[authorizerutil.PrincipalId])
+				setCookies = authorizerutil.UnmarshalSetCookies(event.RequestContext.Authorizer.Lambda)
 			}
 			ctx = contextutil.WithValues(ctx, "substrate-intranet", event.RawPath, principalId)
 			ui.Printf("%s %s %s", event.RequestContext.HTTP.Method, event.RawPath, principalId)
 
-			if event.RawPath == "/favicon.ico" {
-				return &events.APIGatewayV2HTTPResponse{StatusCode: http.StatusNoContent}, nil
-			} else if path.Dir(event.RawPath) == "/js" && path.Ext(event.RawPath) == ".js" {
-				k := strings.TrimSuffix(path.Base(event.RawPath), ".js")
-				if m, ok := DispatchMapJavaScript.Map[k]; ok && m.Func != nil {
-					return m.Func(ctx, cfg, oc.Copy(), event)
-				}
-			} else {
-				k := strings.SplitN(event.RawPath, "/", 3)[1] // safe because there's always at least the leading '/'
-				if k == "" {
-					k = "index"
-				}
-				if m, ok := DispatchMapMain.Map[k]; ok && m.Func != nil { // TODO handle nested routes here, too, if you want to
-					return m.Func(ctx, cfg, oc.Copy(), event)
+			// If the authorizer renewed the user's tokens, store the new ones
+			// in the browser alongside whatever this response sets.
+			if len(setCookies) > 0 {
+				resp, err := route(ctx, cfg, oc, event)
+				if resp != nil {
+					resp.Cookies = append(setCookies, resp.Cookies...)
 				}
+				return resp, err
 			}
-
-			return &events.APIGatewayV2HTTPResponse{
-				Body:       fmt.Sprintf("%s not found\n", event.RawPath),
-				Headers:    map[string]string{"Content-Type": "text/plain"},
-				StatusCode: http.StatusNotFound,
-			}, nil
+			return route(ctx, cfg, oc, event)
 		},
 	})
 }
+
+// discover is oauthoidc.Discover retried with backoff for up to
+// discoveryTimeout so that a transient failure at the IdP doesn't fail this
+// Lambda function's initialization and, with it, every route, even those
+// that don't involve the IdP. If it fails for longer than that, Lambda will
+// try initializing again on the next request.
+func discover(issuer string) (doc *oauthoidc.DiscoveryDocument, err error) {
+	deadline := time.Now().Add(discoveryTimeout)
+	for range awsutil.JitteredExponentialBackoff(100*time.Millisecond, time.Second) {
+		if doc, err = oauthoidc.Discover(issuer); err == nil {
+			return
+		}
+		if _, ok := err.(oauthoidc.VerificationError); ok {
+			return // the issuer's misconfigured, which retrying won't fix
+		}
+		if time.Now().After(deadline) {
+			return
+		}
+		ui.Print(err)
+	}
+	return
+}
+
+func route(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	oc *oauthoidc.Client,
+	event *events.APIGatewayV2HTTPRequest,
+) (*events.APIGatewayV2HTTPResponse, error) {
+	if event.RawPath == "/favicon.ico" {
+		return &events.APIGatewayV2HTTPResponse{StatusCode: http.StatusNoContent}, nil
+	} else if path.Dir(event.RawPath) == "/js" && path.Ext(event.RawPath) == ".js" {
+		k := strings.TrimSuffix(path.Base(event.RawPath), ".js")
+		if m, ok := DispatchMapJavaScript.Map[k]; ok && m.Func != nil {
+			return m.Func(ctx, cfg, oc.Copy(), event)
+		}
+	} else {
+		k := strings.SplitN(event.RawPath, "/", 3)[1] // safe because there's always at least the leading '/'
+		if k == "" {
+			k = "index"
+		}
+		if m, ok := DispatchMapMain.Map[k]; ok && m.Func != nil { // TODO handle nested routes here, too, if you want to
+			return m.Func(ctx, cfg, oc.Copy(), event)
+		}
+	}
+
+	return &events.APIGatewayV2HTTPResponse{
+		Body:       fmt.Sprintf("%s not found\n", event.RawPath),
+		Headers:    map[string]string{"Content-Type": "text/plain"},
+		StatusCode: http.StatusNotFound,
+	}, nil
+}
@@ -0,0 +1,122 @@
+package sessions
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/src-bin/substrate/authorizerutil"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/lambdautil"
+	"github.com/src-bin/substrate/oauthoidc"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/sessions"
+	"github.com/src-bin/substrate/ui"
+)
+
+// Main lists every Intranet session and lets administrators revoke them, one
+// at a time or all of a user's at once, e.g. when offboarding them.
+func Main(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	oc *oauthoidc.Client,
+	event *events.APIGatewayV2HTTPRequest,
+) (*events.APIGatewayV2HTTPResponse, error) {
+
+	if !isAdministrator(event.RequestContext.Authorizer.Lambda) {
+		return lambdautil.ErrorResponse2(NotAdministratorError(fmt.Sprint(
+			event.RequestContext.Authorizer.Lambda[authorizerutil.PrincipalId],
+		)))
+	}
+	principalId := fmt.Sprint(event.RequestContext.Authorizer.Lambda[authorizerutil.PrincipalId])
+
+	if event.RequestContext.HTTP.Method == "POST" {
+		body, err := lambdautil.EventBody2(event)
+		if err != nil {
+			return lambdautil.ErrorResponse2(err)
+		}
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return lambdautil.ErrorResponse2(err)
+		}
+		if err := lambdautil.PreventCSRF2(values, event); err != nil {
+			return lambdautil.ErrorResponse2(err)
+		}
+		var revoked int
+		if id := values.Get("revoke"); id != "" {
+			if err := sessions.Revoke(ctx, cfg, id); err != nil {
+				return lambdautil.ErrorResponse2(err)
+			}
+			revoked = 1
+			ui.Printf("%s revoked session %s", principalId, id)
+		} else if revokeAll := values.Get("revoke_all"); revokeAll != "" {
+			if revoked, err = sessions.RevokeAll(ctx, cfg, revokeAll); err != nil {
+				return lambdautil.ErrorResponse2(err)
+			}
+			ui.Printf("%s revoked all %d of %s's sessions", principalId, revoked, revokeAll)
+		}
+		return &events.APIGatewayV2HTTPResponse{
+			Body: fmt.Sprintf("revoked %d session(s)", revoked),
+			Headers: map[string]string{
+				"Content-Type": "text/plain",
+				"Location": lambdautil.Location(
+					event,
+					url.Values{"revoked": []string{strconv.Itoa(revoked)}},
+				),
+			},
+			StatusCode: http.StatusFound,
+		}, nil
+	}
+
+	v := struct {
+		CSRF      string
+		Current   string
+		Error     error
+		Revoked   string
+		Sessions  []*sessions.Session
+		TableName string
+	}{
+		CSRF:      lambdautil.CSRFCookie2(event),
+		Revoked:   event.QueryStringParameters["revoked"],
+		TableName: sessions.TableName,
+	}
+	if cookie := lambdautil.Cookie2(event.Cookies, sessions.CookieName); cookie != nil {
+		if s, err := sessions.Get(ctx, cfg, cookie.Value); err == nil {
+			v.Current = s.Id
+		}
+	}
+	v.Sessions, v.Error = sessions.List(ctx, cfg)
+	body, err := lambdautil.RenderHTML(html, v)
+	if err != nil {
+		return nil, err
+	}
+	return &events.APIGatewayV2HTTPResponse{
+		Body:       body,
+		Headers:    map[string]string{"Content-Type": "text/html; charset=utf-8"},
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// isAdministrator returns true if the authorizer allowed the Administrator
+// role, whether or not it's the one currently selected.
+func isAdministrator(authorizer map[string]interface{}) bool {
+	for _, roleName := range authorizerutil.AllowedRoleNames(authorizer) {
+		if roleName == roles.Administrator {
+			return true
+		}
+	}
+	return false
+}
+
+//go:embed sessions.html
+var html string
+
+type NotAdministratorError string
+
+func (err NotAdministratorError) Error() string {
+	return fmt.Sprintf("NotAdministratorError: %s isn't allowed to use the %s role and so can't manage Intranet sessions", string(err), roles.Administrator)
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/src-bin/substrate/awscfg"
 	"github.com/src-bin/substrate/awsorgs"
 	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/customroles"
+	"github.com/src-bin/substrate/profiles"
 	"github.com/src-bin/substrate/roles"
 	"github.com/src-bin/substrate/ui"
 	"github.com/src-bin/substrate/veqp"
@@ -105,16 +107,33 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 		return
 	}
 
-	ui.Spinf("closing account number %s", accountCfg.MustAccountId(ctx))
+	accountId := accountCfg.MustAccountId(ctx)
+	ui.Spinf("closing account number %s", accountId)
 	time.Sleep(5e9) // give them a chance to ^C
 	ui.Must(awsorgs.CloseAccount(
 		ctx,
 		mgmtCfg,
-		accountCfg.MustAccountId(ctx),
+		accountId,
 	))
-	ui.Must(mgmtCfg.ClearCachedAccounts())
 	ui.Stop("ok")
 
+	// Custom roles that trust accounts like this one must no longer trust it.
+	if err := customroles.DistrustAccount(
+		ctx,
+		cfg,
+		accountId,
+		identity.Tags.Domain,
+		identity.Tags.Environment,
+		identity.Tags.Quality,
+	); err != nil {
+		ui.Print(err)
+	}
+
+	// Keep the Substrate-managed profiles in ~/.aws/config, if any, current.
+	if err := profiles.Update(ctx, mgmtCfg, nil); err != nil {
+		ui.Print(err)
+	}
+
 	go mgmtCfg.Telemetry().Post(ctx) // post earlier, finish earlier
 	defer mgmtCfg.Telemetry().Wait(ctx)
 
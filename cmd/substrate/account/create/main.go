@@ -13,6 +13,8 @@ import (
 	"github.com/src-bin/substrate/awscfg"
 	"github.com/src-bin/substrate/awsorgs"
 	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/customroles"
+	"github.com/src-bin/substrate/profiles"
 	"github.com/src-bin/substrate/roles"
 	"github.com/src-bin/substrate/ui"
 	"github.com/src-bin/substrate/veqp"
@@ -118,6 +120,17 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 
 	accounts.SetupIAM(ctx, mgmtCfg, networkCfg, substrateCfg, accountCfg, *domain, *environment, *quality)
 
+	// Custom roles that trust accounts like this one must now trust this one.
+	if err := customroles.TrustAccount(ctx, cfg, *domain, *environment, *quality); err != nil {
+		ui.Print(err) // `substrate role create` will fix this later
+	}
+
+	// Keep the Substrate-managed profiles in ~/.aws/config, if any, current
+	// but don't let a problem with them stop us from setting up Terraform.
+	if err := profiles.Update(ctx, cfg, nil); err != nil {
+		ui.Print(err)
+	}
+
 	// TODO delete the default VPC in every region using accountCfg
 
 	accounts.SetupTerraform(ctx, mgmtCfg, networkCfg, accountCfg, *domain, *environment, *quality)
@@ -1,10 +1,12 @@
 package list
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/spf13/cobra"
@@ -14,6 +16,7 @@ import (
 	"github.com/src-bin/substrate/cmd/substrate/setup/cloudtrail"
 	"github.com/src-bin/substrate/cmdutil"
 	"github.com/src-bin/substrate/jsonutil"
+	"github.com/src-bin/substrate/profiles"
 	"github.com/src-bin/substrate/tagging"
 	"github.com/src-bin/substrate/ui"
 	"github.com/src-bin/substrate/versionutil"
@@ -22,7 +25,7 @@ import (
 var (
 	format, formatFlag, formatCompletionFunc = cmdutil.FormatFlag(
 		cmdutil.FormatText,
-		[]cmdutil.Format{cmdutil.FormatJSON, cmdutil.FormatShell, cmdutil.FormatText},
+		[]cmdutil.Format{cmdutil.FormatAWSConfig, cmdutil.FormatCSV, cmdutil.FormatJSON, cmdutil.FormatMarkdown, cmdutil.FormatShell, cmdutil.FormatText},
 	)
 	number                                                  = new(string)
 	onlyTags                                                = new(bool)
@@ -82,6 +85,16 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	ui.Must(err)
 	switch *format {
 
+	case cmdutil.FormatAWSConfig:
+		ui.Spin("inspecting all the roles in all your AWS accounts")
+		b := &bytes.Buffer{}
+		ui.Must(profiles.FawsConfig(ctx, cfg, b))
+		ui.Stop("ok")
+		io.Copy(os.Stdout, b)
+
+	case cmdutil.FormatCSV:
+		ui.Must(accounts.FcheatSheet(ctx, cfg, os.Stdout, accounts.CheatSheetCSV))
+
 	case cmdutil.FormatJSON:
 
 		// Maybe only print one account.
@@ -171,8 +184,11 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 			)
 		}
 
+	case cmdutil.FormatMarkdown:
+		ui.Must(accounts.FcheatSheet(ctx, cfg, os.Stdout, accounts.CheatSheetMarkdown))
+
 	case cmdutil.FormatText:
-		f, err := os.Open(accounts.CheatSheetFilename)
+		f, err := os.Open(filepath.Join(accounts.CheatSheetDirname(), accounts.CheatSheetFilename))
 		if err != nil {
 			ui.Fatal(err)
 		}
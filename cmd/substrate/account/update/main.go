@@ -50,7 +50,7 @@ func Command() *cobra.Command {
 	cmd.RegisterFlagCompletionFunc(qualityFlag.Name, qualityCompletionFunc)
 	cmd.Flags().BoolVar(runTerraform, "terraform", false, "initialize and plan or apply Terraform in the account")
 	cmd.Flags().BoolVar(autoApprove, "auto-approve", false, "with --terraform, apply Terraform changes without waiting for confirmation")
-	cmd.Flags().BoolVar(noApply, "no-apply", false, "with --terraform, plan but do not apply Terraform changes")
+	cmd.Flags().BoolVar(noApply, "no-apply", false, "with --terraform, plan but do not apply Terraform changes, saving the plans for `substrate terraform --format json show`")
 	cmd.Flags().BoolVar(providersLock, "providers-lock", false, "with --terraform, run terraform providers lock during Terraform initialization")
 	return cmd
 }
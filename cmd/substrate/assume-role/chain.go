@@ -0,0 +1,56 @@
+package assumerole
+
+import (
+	"fmt"
+
+	"github.com/src-bin/substrate/awscfg"
+)
+
+// chain is the roles given by --arn, in order, each with the --external-id
+// and --session-name that follow it.
+var chain []awscfg.RoleChainHop
+
+type arnValue struct{ chain *[]awscfg.RoleChainHop }
+
+func (v arnValue) Set(s string) error {
+	*v.chain = append(*v.chain, awscfg.RoleChainHop{ARN: s})
+	return nil
+}
+
+func (arnValue) String() string { return "" }
+
+func (arnValue) Type() string { return "string" }
+
+type externalIdValue struct{ chain *[]awscfg.RoleChainHop }
+
+func (v externalIdValue) Set(s string) error {
+	if len(*v.chain) == 0 {
+		return ChainFlagError("--external-id must follow --arn")
+	}
+	(*v.chain)[len(*v.chain)-1].ExternalId = s
+	return nil
+}
+
+func (externalIdValue) String() string { return "" }
+
+func (externalIdValue) Type() string { return "string" }
+
+type sessionNameValue struct{ chain *[]awscfg.RoleChainHop }
+
+func (v sessionNameValue) Set(s string) error {
+	if len(*v.chain) == 0 {
+		return ChainFlagError("--session-name must follow --arn")
+	}
+	(*v.chain)[len(*v.chain)-1].SessionName = s
+	return nil
+}
+
+func (sessionNameValue) String() string { return "" }
+
+func (sessionNameValue) Type() string { return "string" }
+
+type ChainFlagError string
+
+func (err ChainFlagError) Error() string {
+	return fmt.Sprintf("ChainFlagError: %s", string(err))
+}
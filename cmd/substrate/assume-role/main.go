@@ -19,6 +19,10 @@ import (
 	"github.com/src-bin/substrate/versionutil"
 )
 
+// refreshWindow is how long before they expire that --serve re-mints
+// credentials, which is longer than AWS SDKs wait to refresh them.
+const refreshWindow = 15 * time.Minute
+
 var (
 	domain, domainFlag, domainCompletionFunc                = cmdutil.DomainFlag("domain of an AWS account in which to assume a role")
 	environment, environmentFlag, environmentCompletionFunc = cmdutil.EnvironmentFlag("environment of an AWS account in which to assume a role")
@@ -27,20 +31,21 @@ var (
 	special                                                 = new(string)
 	substrate                                               = new(bool)
 	number                                                  = new(string)
-	roleName, roleARN                                       = new(string), new(string)
+	roleName                                                = new(string)
 	console                                                 = new(bool)
+	serve                                                   = new(bool)
 	format, formatFlag, formatCompletionFunc                = cmdutil.FormatFlag(
 		cmdutil.FormatExportWithHistory,
-		[]cmdutil.Format{cmdutil.FormatEnv, cmdutil.FormatExport, cmdutil.FormatExportWithHistory, cmdutil.FormatJSON},
+		[]cmdutil.Format{cmdutil.FormatCredentialProcess, cmdutil.FormatEnv, cmdutil.FormatExport, cmdutil.FormatExportWithHistory, cmdutil.FormatJSON},
 	)
 )
 
 func Command() *cobra.Command {
 	cmd := &cobra.Command{
-		Use: `assume-role --domain <domain> --environment <environment> [--quality <quality>] [--role <role-name>] [--console] [--format <format>] [--quiet] [<command> [<argument> [...]]]
+		Use: `assume-role --domain <domain> --environment <environment> [--quality <quality>] [--role <role-name>] [--console] [--format <format>] [--quiet] [[--serve] <command> [<argument> [...]]]
   substrate assume-role --management|--special <special>|--substrate [--role <role-name>] [--console] [--format <format>] [--quiet] [<command> [<argument> [...]]]
   substrate assume-role --number <number> --role <role-name> [--console] [--format <format>] [--quiet] [<command> [<argument> [...]]]
-  substrate assume-role --arn <role-arn> [--console] [--format <format>] [--quiet] [<command> [<argument> [...]]]`,
+  substrate assume-role [<account selection flags>] --arn <role-arn> [--external-id <external-id>] [--session-name <session-name>] [--arn <role-arn> [...]] [--console] [--format <format>] [--quiet] [<command> [<argument> [...]]]`,
 		Short: "assume a role in another AWS account",
 		Long:  ``,
 		Args:  cobra.ArbitraryArgs,
@@ -53,8 +58,9 @@ func Command() *cobra.Command {
 				"--domain", "--environment", "--quality",
 				"--management", "--special", "--substrate",
 				"--number",
-				"--role", "--arn",
+				"--role", "--arn", "--external-id", "--session-name",
 				"--console",
+				"--serve",
 				"--format",
 				"--quiet",
 			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
@@ -76,9 +82,14 @@ func Command() *cobra.Command {
 	cmd.RegisterFlagCompletionFunc("number", cmdutil.NoCompletionFunc)
 	cmd.Flags().StringVar(roleName, "role", "", "name of the IAM role to assume")
 	cmd.RegisterFlagCompletionFunc("role", cmdutil.NoCompletionFunc)
-	cmd.Flags().StringVar(roleARN, "arn", "", "ARN of the IAM role to assume")
+	cmd.Flags().Var(arnValue{&chain}, "arn", "ARN of an IAM role to assume, after the role selected by other flags, if any (may be repeated to assume each role in turn)")
 	cmd.RegisterFlagCompletionFunc("arn", cmdutil.NoCompletionFunc)
+	cmd.Flags().Var(externalIdValue{&chain}, "external-id", "external ID to provide when assuming the role given by the preceding --arn")
+	cmd.RegisterFlagCompletionFunc("external-id", cmdutil.NoCompletionFunc)
+	cmd.Flags().Var(sessionNameValue{&chain}, "session-name", "role session name to use when assuming the role given by the preceding --arn (default the same as for roles Substrate assumes)")
+	cmd.RegisterFlagCompletionFunc("session-name", cmdutil.NoCompletionFunc)
 	cmd.Flags().BoolVar(console, "console", false, "open the AWS Console to assume a role instead of generating an access key")
+	cmd.Flags().BoolVar(serve, "serve", false, "with a command, serve it credentials on localhost that are re-minted as they near expiration for as long as it runs instead of putting credentials that last an hour in its environment")
 	cmd.Flags().AddFlag(formatFlag)
 	cmd.RegisterFlagCompletionFunc(formatFlag.Name, formatCompletionFunc)
 	cmd.Flags().AddFlag(cmdutil.QuietFlag())
@@ -90,9 +101,12 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, args []stri
 	if *environment != "" && *quality == "" {
 		*quality = cmdutil.QualityForEnvironment(*environment)
 	}
-	if (*domain == "" || *environment == "" || *quality == "") && *special == "" && !*substrate && !*management && *number == "" && *roleARN == "" {
+	if (*domain == "" || *environment == "" || *quality == "") && *special == "" && !*substrate && !*management && *number == "" && len(chain) == 0 {
 		ui.Fatal(`one of --domain "..." --environment "..." --quality "..." or --management or --special "..." or --substrate or --number "..." or --arn "..." is required`)
 	}
+	if (*domain != "" || *environment != "") && (*domain == "" || *environment == "" || *quality == "") {
+		ui.Fatal(`--domain "..." --environment "..." --quality "..." must be given together`)
+	}
 	if (*domain != "" || *environment != "" /* || *quality != "" */) && *management {
 		ui.Fatal(`can't mix --domain "..." --environment "..." --quality "..." with --management`)
 	}
@@ -105,9 +119,6 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, args []stri
 	if (*domain != "" || *environment != "" /* || *quality != "" */) && *number != "" {
 		ui.Fatal(`can't mix --domain "..." --environment "..." --quality "..." with --number "..."`)
 	}
-	if (*domain != "" || *environment != "" /* || *quality != "" */) && *roleARN != "" {
-		ui.Fatal(`can't mix --domain "..." --environment "..." --quality "..." with --arn "..."`)
-	}
 	if *management && *special != "" {
 		ui.Fatal(`can't mix --management with --special "..."`)
 	}
@@ -117,23 +128,20 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, args []stri
 	if *management && *number != "" {
 		ui.Fatal(`can't mix --management with --number "..."`)
 	}
-	if *management && *roleARN != "" {
-		ui.Fatal(`can't mix --management with --arn "..."`)
-	}
 	if *special != "" && *substrate {
 		ui.Fatal(`can't mix --special "..." with --substrate`)
 	}
 	if *special != "" && *number != "" {
 		ui.Fatal(`can't mix --special "..." with --number "..."`)
 	}
-	if *special != "" && *roleARN != "" {
-		ui.Fatal(`can't mix --special "..." with --arn "..."`)
-	}
 	if *substrate && *number != "" {
 		ui.Fatal(`can't mix --substrate with --number "..."`)
 	}
-	if *substrate && *roleARN != "" {
-		ui.Fatal(`can't mix --substrate with --arn "..."`)
+	if *serve && len(args) == 0 {
+		ui.Fatal(`--serve requires a command to serve credentials to`)
+	}
+	if *serve && *console {
+		ui.Fatal(`can't mix --serve with --console`)
 	}
 
 	callerIdentity := cfg.MustGetCallerIdentity(ctx)
@@ -162,8 +170,12 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, args []stri
 		ci, err := cfg.GetCallerIdentity(ctx)
 	*/
 
-	if *roleARN != "" {
-		cfg, err = cfg.AssumeRoleARN(ctx, *roleARN, duration)
+	// Assume the role selected by domain, environment, and quality or any of
+	// the other account selection flags, if given, and then every role given
+	// by --arn in turn, if any.
+	selected := *domain != "" || *special != "" || *substrate || *management || *number != ""
+	if !selected {
+		// nothing to do until we follow the chain below
 	} else if *number != "" {
 		if *roleName == "" {
 			ui.Fatal(`--role "..." is required with --number "..."`)
@@ -206,6 +218,9 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, args []stri
 		}
 		cfg, err = cfg.AssumeServiceRole(ctx, *domain, *environment, *quality, *roleName, duration)
 	}
+	if err == nil && len(chain) > 0 {
+		cfg, err = cfg.AssumeRoleChain(ctx, chain, duration)
+	}
 	if err != nil {
 		ui.Print(err)
 		if os.Getenv("OLD_AWS_ACCESS_KEY_ID") != "" {
@@ -238,8 +253,20 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, args []stri
 	// Execute a command with the credentials in its environment.  We use
 	// os.Setenv instead of exec.Cmd.Env because we also want to preserve
 	// other environment variables in case they're relevant to the command.
+	// With --serve, though, the command instead gets credentials from a
+	// server that assumes the role again (and any roles it's chained from)
+	// as they near expiration so commands can run for longer than an hour.
 	if len(args) > 0 {
-		ui.Must(awscfg.Setenv(creds))
+		var env []string // nil means the command inherits our environment
+		if *serve {
+			s, err := cmdutil.ServeCredentials(0, func(ctx context.Context) (aws.Credentials, error) {
+				return cfg.RetrieveFresh(ctx, refreshWindow)
+			})
+			ui.Must(err)
+			env = append(environWithoutCredentials(os.Environ()), s.Environ()...)
+		} else {
+			ui.Must(awscfg.Setenv(creds))
+		}
 
 		// Switch back to the original working directory before looking for the
 		// program to execute.
@@ -252,6 +279,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, args []stri
 		ui.Must(err)
 
 		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Env = env
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
@@ -269,3 +297,24 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, args []stri
 	cmdutil.PrintCredentials(*format, creds)
 
 }
+
+// environWithoutCredentials returns environ without any AWS credentials,
+// which AWS SDKs would prefer over those from --serve.
+func environWithoutCredentials(environ []string) []string {
+	var filtered []string
+	for _, kv := range environ {
+		switch k, _, _ := strings.Cut(kv, "="); k {
+		case
+			awscfg.AWS_ACCESS_KEY_ID,
+			awscfg.AWS_SECRET_ACCESS_KEY,
+			awscfg.AWS_SESSION_TOKEN,
+			awscfg.SUBSTRATE_CREDENTIALS_EXPIRATION,
+			cmdutil.AWS_CONTAINER_AUTHORIZATION_TOKEN,
+			cmdutil.AWS_CONTAINER_CREDENTIALS_FULL_URI,
+			"AWS_PROFILE":
+		default:
+			filtered = append(filtered, kv)
+		}
+	}
+	return filtered
+}
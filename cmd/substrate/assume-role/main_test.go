@@ -0,0 +1,67 @@
+package assumerole
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/src-bin/substrate/awscfg"
+)
+
+func TestEnvironWithoutCredentials(t *testing.T) {
+	actual := environWithoutCredentials([]string{
+		"AWS_ACCESS_KEY_ID=AKIAEXAMPLE",
+		"AWS_REGION=us-west-2",
+		"AWS_SECRET_ACCESS_KEY=secret",
+		"AWS_SESSION_TOKEN=token",
+		"HOME=/home/me",
+		"SUBSTRATE_CREDENTIALS_EXPIRATION=2024-01-02T03:04:05Z",
+		"SUBSTRATE_ROOT=/home/me/substrate",
+	})
+	expected := []string{
+		"AWS_REGION=us-west-2",
+		"HOME=/home/me",
+		"SUBSTRATE_ROOT=/home/me/substrate",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("actual: %v, expected: %v", actual, expected)
+	}
+}
+
+func TestChainFlags(t *testing.T) {
+	var chain []awscfg.RoleChainHop
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Var(arnValue{&chain}, "arn", "")
+	flags.Var(externalIdValue{&chain}, "external-id", "")
+	flags.Var(sessionNameValue{&chain}, "session-name", "")
+	if err := flags.Parse([]string{
+		"--arn", "arn:aws:iam::123456789012:role/Hop1",
+		"--session-name", "first",
+		"--arn", "arn:aws:iam::210987654321:role/Hop2",
+		"--external-id", "secret",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []awscfg.RoleChainHop{
+		{ARN: "arn:aws:iam::123456789012:role/Hop1", SessionName: "first"},
+		{ARN: "arn:aws:iam::210987654321:role/Hop2", ExternalId: "secret"},
+	}
+	if !reflect.DeepEqual(chain, expected) {
+		t.Fatalf("actual: %+v, expected: %+v", chain, expected)
+	}
+}
+
+func TestChainFlagsOutOfOrder(t *testing.T) {
+	var chain []awscfg.RoleChainHop
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Var(arnValue{&chain}, "arn", "")
+	flags.Var(externalIdValue{&chain}, "external-id", "")
+	if err := flags.Parse([]string{
+		"--external-id", "secret",
+		"--arn", "arn:aws:iam::123456789012:role/Hop1",
+	}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -25,14 +25,18 @@ import (
 var (
 	format, formatFlag, formatCompletionFunc = cmdutil.FormatFlag(
 		cmdutil.FormatExport,
-		[]cmdutil.Format{cmdutil.FormatEnv, cmdutil.FormatExport, cmdutil.FormatJSON},
+		[]cmdutil.Format{cmdutil.FormatCredentialProcess, cmdutil.FormatEnv, cmdutil.FormatExport, cmdutil.FormatJSON},
 	)
 	force, noOpen = new(bool), new(bool)
+	roleName      = new(string)
+	serve         = new(bool)
+	port          = new(int)
 )
 
 func Command() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "credentials [--format <format>] [--force] [--no-open] [--quiet]",
+		Use: `credentials [--role <role>] [--format <format>] [--force] [--no-open] [--quiet]
+  substrate credentials --serve [--port <port>] [--role <role>] [--format env|export] [--no-open] [--quiet]`,
 		Short: "mint temporary AWS credentials with the help of your IdP",
 		Long:  ``,
 		Args:  cobra.NoArgs,
@@ -45,6 +49,8 @@ func Command() *cobra.Command {
 				"--format",
 				"--force",
 				"--no-open",
+				"--role",
+				"--serve", "--port",
 				"--quiet",
 			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
 		},
@@ -53,13 +59,24 @@ func Command() *cobra.Command {
 	cmd.RegisterFlagCompletionFunc(formatFlag.Name, formatCompletionFunc)
 	cmd.Flags().BoolVar(force, "force", false, "force minting new credentials even if there are valid credentials in the environment")
 	cmd.Flags().BoolVar(noOpen, "no-open", false, "do not try to open your web browser (so that you can copy the URL and open it yourself)")
+	cmd.Flags().StringVar(roleName, "role", "", "name of an IAM role your IdP group memberships allow you to use (default the role your IdP assigns you)")
+	cmd.RegisterFlagCompletionFunc("role", cmdutil.NoCompletionFunc)
+	cmd.Flags().BoolVar(serve, "serve", false, "serve credentials to AWS SDKs on localhost using the ECS container credentials protocol and mint new ones before they expire until interrupted")
+	cmd.Flags().IntVar(port, "port", 0, "with --serve, TCP port on 127.0.0.1 to listen on (default a random available port)")
+	cmd.RegisterFlagCompletionFunc("port", cmdutil.NoCompletionFunc)
 	cmd.Flags().AddFlag(cmdutil.QuietFlag())
 	return cmd
 }
 
 func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string, _ io.Writer) {
+	if *serve && *format != cmdutil.FormatEnv && *format != cmdutil.FormatExport {
+		ui.Fatal(`--serve requires --format "env" or --format "export"`)
+	}
 
-	if !*force {
+	// Reuse credentials from the environment, unless serving, which deserves
+	// a fresh 12 hours and must know when they expire, or asked for a
+	// particular role, which those credentials may not be for.
+	if !*force && !*serve && *roleName == "" {
 		if _, err := cfg.GetCallerIdentity(ctx); err == nil {
 			expiry, err := time.Parse(time.RFC3339, os.Getenv(awscfg.SUBSTRATE_CREDENTIALS_EXPIRATION))
 			if err != nil {
@@ -81,14 +98,54 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 		}
 	}
 
+	creds, err := mint()
+	if err != nil {
+		ui.Fatal(err)
+	}
+	if creds == nil {
+		return
+	}
+
+	cfg.SetCredentials(ctx, *creds)
+
+	go cfg.Telemetry().Post(ctx) // post earlier, finish earlier
+	defer cfg.Telemetry().Wait(ctx)
+
+	versionutil.WarnDowngrade(ctx, cfg)
+
+	if !features.IgnoreMacOSKeychain.Enabled() {
+		ui.Must(cmdutil.SetTPM(*creds))
+	}
+
+	// Serve credentials to every AWS SDK that's configured to ask, refreshing
+	// them before they expire, until interrupted.
+	if *serve {
+		serveCredentials(ctx, creds)
+		return
+	}
+
+	// Print credentials in whatever format was requested.
+	cmdutil.PrintCredentials(*format, *creds)
+
+}
+
+// mint exchanges a random token for credentials from the Credential Factory
+// once the user's authenticated in their web browser. It returns nil, after
+// saying so, if they don't within an hour.
+func mint() (*aws.Credentials, error) {
+
 	// Generate the token we'll exchange for AWS credentials.
 	token := randutil.String()
 
+	query := url.Values{"token": []string{token}}
+	if *roleName != "" {
+		query.Set("role", *roleName)
+	}
 	u := &url.URL{
 		Scheme:   "https",
 		Host:     naming.MustIntranetDNSDomainName(),
 		Path:     "/credential-factory/authorize",
-		RawQuery: url.Values{"token": []string{token}}.Encode(),
+		RawQuery: query.Encode(),
 	}
 	if *noOpen {
 		ui.Printf("open <%s> in your web browser; authenticate if prompted, then return here", u)
@@ -101,39 +158,23 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	ui.Spin("fetching credentials")
 	u.Path = "/credential-factory/fetch"
 	ch := time.After(time.Hour)
-	var creds *aws.Credentials
 	for range time.Tick(time.Second) {
 		select {
 		case <-ch:
 			ui.Stop("timed out")
-			return
+			return nil, nil
 		default:
 		}
-		var err error
-		creds, err = fetch(u)
+		creds, err := fetch(u)
 		if err != nil {
-			ui.Fatal(err)
+			return nil, err
 		}
 		if creds != nil {
-			break
+			ui.Stop("ok")
+			return creds, nil
 		}
 	}
-	ui.Stop("ok")
-
-	cfg.SetCredentials(ctx, *creds)
-
-	go cfg.Telemetry().Post(ctx) // post earlier, finish earlier
-	defer cfg.Telemetry().Wait(ctx)
-
-	versionutil.WarnDowngrade(ctx, cfg)
-
-	if !features.IgnoreMacOSKeychain.Enabled() {
-		ui.Must(cmdutil.SetTPM(*creds))
-	}
-
-	// Print credentials in whatever format was requested.
-	cmdutil.PrintCredentials(*format, *creds)
-
+	panic("unreachable")
 }
 
 func fetch(u *url.URL) (*aws.Credentials, error) {
@@ -0,0 +1,107 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/features"
+	"github.com/src-bin/substrate/ui"
+)
+
+const (
+	// refreshBefore is how long before credentials expire that --serve mints
+	// new ones, which leaves time to authenticate if the IdP insists.
+	refreshBefore = time.Hour
+
+	// retryAfter is how long --serve waits to try again after failing to
+	// mint new credentials, so as not to open a browser tab every second.
+	retryAfter = 10 * time.Minute
+)
+
+// serveCredentials serves creds on 127.0.0.1 and prints the environment
+// variables that point AWS SDKs at it. It mints new credentials via the
+// Credential Factory an hour before the current ones expire and serves
+// those instead, forever.
+func serveCredentials(ctx context.Context, creds *aws.Credentials) {
+	var mu sync.RWMutex
+	current := *creds
+	s, err := cmdutil.ServeCredentials(*port, func(context.Context) (aws.Credentials, error) {
+		mu.RLock()
+		defer mu.RUnlock()
+		return current, nil
+	})
+	ui.Must(err)
+	ui.Printf("serving AWS credentials at <%s>; leave this running and paste this into every shell that should use them:", s.URL)
+	printServeEnv(*format, s.URL, s.Token)
+
+	next := creds.Expires.Add(-refreshBefore)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		ui.Printf("access key %s expires %s; minting new credentials", creds.AccessKeyID, creds.Expires.Format(time.RFC3339))
+		newCreds, err := mint()
+		if err != nil {
+			ui.Print(err)
+		}
+		if newCreds == nil {
+			next = time.Now().Add(retryAfter)
+			continue
+		}
+		creds = newCreds
+		mu.Lock()
+		current = *creds
+		mu.Unlock()
+		if !features.IgnoreMacOSKeychain.Enabled() {
+			if err := cmdutil.SetTPM(*creds); err != nil {
+				ui.Print(err)
+			}
+		}
+		next = creds.Expires.Add(-refreshBefore)
+	}
+}
+
+// printServeEnv prints the environment variables that configure AWS SDKs to
+// get credentials from serveCredentials, unsetting any static credentials
+// because SDKs prefer those.
+func printServeEnv(format cmdutil.Format, u, token string) {
+	switch format {
+	case cmdutil.FormatEnv:
+		fmt.Printf(
+			"%s=%q\n%s=%q\n",
+			cmdutil.AWS_CONTAINER_CREDENTIALS_FULL_URI, u,
+			cmdutil.AWS_CONTAINER_AUTHORIZATION_TOKEN, token,
+		)
+	case cmdutil.FormatExport:
+		if cmdutil.CheckForFish() {
+			fmt.Printf(
+				" set -e %s; set -e %s; set -e %s; set -e %s; set -x %s %q; set -x %s %q\n",
+				awscfg.AWS_ACCESS_KEY_ID,
+				awscfg.AWS_SECRET_ACCESS_KEY,
+				awscfg.AWS_SESSION_TOKEN,
+				awscfg.SUBSTRATE_CREDENTIALS_EXPIRATION,
+				cmdutil.AWS_CONTAINER_CREDENTIALS_FULL_URI, u,
+				cmdutil.AWS_CONTAINER_AUTHORIZATION_TOKEN, token,
+			)
+		} else {
+			fmt.Printf(
+				" unset %s %s %s %s; export %s=%q %s=%q\n",
+				awscfg.AWS_ACCESS_KEY_ID,
+				awscfg.AWS_SECRET_ACCESS_KEY,
+				awscfg.AWS_SESSION_TOKEN,
+				awscfg.SUBSTRATE_CREDENTIALS_EXPIRATION,
+				cmdutil.AWS_CONTAINER_CREDENTIALS_FULL_URI, u,
+				cmdutil.AWS_CONTAINER_AUTHORIZATION_TOKEN, token,
+			)
+		}
+	default:
+		ui.Fatal(cmdutil.FormatFlagError(format))
+	}
+}
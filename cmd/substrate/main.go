@@ -15,8 +15,10 @@ import (
 	"github.com/src-bin/substrate/cmd/substrate/credentials"
 	deleterole "github.com/src-bin/substrate/cmd/substrate/delete-role"
 	intranetzip "github.com/src-bin/substrate/cmd/substrate/intranet-zip"
+	"github.com/src-bin/substrate/cmd/substrate/network"
 	"github.com/src-bin/substrate/cmd/substrate/role"
 	"github.com/src-bin/substrate/cmd/substrate/roles"
+	"github.com/src-bin/substrate/cmd/substrate/scp"
 	"github.com/src-bin/substrate/cmd/substrate/setup"
 	"github.com/src-bin/substrate/cmd/substrate/terraform"
 	"github.com/src-bin/substrate/cmd/substrate/upgrade"
@@ -121,7 +123,9 @@ func main() {
 	rootCmd.AddCommand(assumerole.Command())
 	rootCmd.AddCommand(credentials.Command())
 	rootCmd.AddCommand(intranetzip.Command())
+	rootCmd.AddCommand(network.Command())
 	rootCmd.AddCommand(role.Command())
+	rootCmd.AddCommand(scp.Command())
 	rootCmd.AddCommand(setup.Command())
 	rootCmd.AddCommand(terraform.Command())
 	rootCmd.AddCommand(upgrade.Command())
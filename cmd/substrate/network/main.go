@@ -0,0 +1,18 @@
+package network
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/src-bin/substrate/cmd/substrate/network/report"
+)
+
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "network",
+		Short: "inspect Substrate-managed networks",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(report.Command())
+
+	return cmd
+}
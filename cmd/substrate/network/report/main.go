@@ -0,0 +1,122 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/jsonutil"
+	"github.com/src-bin/substrate/networks"
+	"github.com/src-bin/substrate/regions"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/table"
+	"github.com/src-bin/substrate/ui"
+	"github.com/src-bin/substrate/versionutil"
+)
+
+var (
+	format, formatFlag, formatCompletionFunc = cmdutil.FormatFlag(
+		cmdutil.FormatText,
+		[]cmdutil.Format{cmdutil.FormatJSON, cmdutil.FormatText},
+	)
+	regionNames = new([]string)
+)
+
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report [--region <region> [...]] [--format <format>]",
+		Short: "report every Substrate-managed VPC, its NAT Gateways, EIPs, and peering connections, and what they cost",
+		Long:  ``,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			Main(cmdutil.Main(cmd, args))
+		},
+		DisableFlagsInUseLine: true,
+		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return []string{
+				"--region",
+				"--format",
+			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+		},
+	}
+	cmd.Flags().StringArrayVar(regionNames, "region", []string{}, "only report on networks in this region (may be repeated; default all the regions you've selected)")
+	cmd.RegisterFlagCompletionFunc("region", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return regions.Selected(), cobra.ShellCompDirectiveNoFileComp
+	})
+	cmd.Flags().AddFlag(formatFlag)
+	cmd.RegisterFlagCompletionFunc(formatFlag.Name, formatCompletionFunc)
+	return cmd
+}
+
+func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string, w io.Writer) {
+
+	go cfg.Telemetry().Post(ctx) // post earlier, finish earlier
+	defer cfg.Telemetry().Wait(ctx)
+
+	versionutil.WarnDowngrade(ctx, cfg)
+
+	selected := *regionNames
+	if len(selected) == 0 {
+		selected = regions.Selected()
+	}
+	prices, err := networks.ReadPrices()
+	ui.Must(err)
+
+	mgmtCfg := awscfg.Must(cfg.AssumeManagementRole(ctx, roles.Substrate, time.Hour))
+	networkCfg := awscfg.Must(mgmtCfg.AssumeSpecialRole(ctx, accounts.Network, roles.NetworkAdministrator, time.Hour))
+
+	ui.Spinf("inspecting your networks in %d region(s)", len(selected))
+	reports, err := networks.Report(ctx, networkCfg, selected, prices)
+	ui.Must(err)
+	ui.Stop("ok")
+
+	switch *format {
+
+	case cmdutil.FormatJSON:
+		jsonutil.PrettyPrint(w, reports)
+
+	case cmdutil.FormatText:
+		cells := [][]string{{
+			"Environment",
+			"Quality",
+			"Region",
+			"IPv4",
+			"VPC",
+			"NAT Gateways",
+			"EIPs",
+			"Peering Connections",
+			"Monthly Cost",
+		}}
+		var total float64
+		for _, r := range reports {
+			cells = append(cells, []string{
+				r.Environment,
+				r.Quality,
+				r.Region,
+				r.IPv4.String(),
+				r.VPC,
+				strings.Join(r.NATGateways, " "),
+				strings.Join(r.EIPs, " "),
+				fmt.Sprint(len(r.VPCPeeringConnections)),
+				fmt.Sprintf("$%.2f", r.MonthlyCost),
+			})
+			total += r.MonthlyCost
+		}
+		table.Ftable(w, cells)
+		fmt.Fprintf(
+			w,
+			"estimated total: $%.2f per month, not including data transfer or NAT Gateway data processing (override the prices used for this estimate in %s)\n",
+			total,
+			networks.PricesFilename,
+		)
+
+	default:
+		ui.Fatal(cmdutil.FormatFlagError(*format))
+	}
+}
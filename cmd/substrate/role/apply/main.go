@@ -0,0 +1,141 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsiam"
+	"github.com/src-bin/substrate/awsutil"
+	"github.com/src-bin/substrate/cmd/substrate/role/create"
+	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/customroles"
+	"github.com/src-bin/substrate/jsonutil"
+	"github.com/src-bin/substrate/profiles"
+	"github.com/src-bin/substrate/ui"
+	"github.com/src-bin/substrate/version"
+	"github.com/src-bin/substrate/versionutil"
+)
+
+var force = new(bool)
+
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply [--force] [--quiet]",
+		Short: "create, update, and delete AWS IAM roles to match " + customroles.Filename,
+		Long:  ``,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			Main(cmdutil.Main(cmd, args))
+		},
+		DisableFlagsInUseLine: true,
+		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return []string{
+				"--force",
+				"--quiet",
+			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+		},
+	}
+	cmd.Flags().BoolVar(force, "force", false, "delete Substrate-managed roles that aren't in "+customroles.Filename+" without confirmation")
+	cmd.Flags().AddFlag(cmdutil.QuietFlag())
+	return cmd
+}
+
+func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string, _ io.Writer) {
+
+	go cfg.Telemetry().Post(ctx) // post earlier, finish earlier
+	defer cfg.Telemetry().Wait(ctx)
+
+	versionutil.PreventDowngrade(ctx, cfg)
+
+	// If there's no roles file yet, write one that describes the roles that
+	// already exist so that applying it is a no-op until it's changed.
+	doc, err := customroles.ReadDocument()
+	if errors.Is(err, fs.ErrNotExist) {
+		ui.Spin("inspecting all the roles in all your AWS accounts")
+		collated, _, err := customroles.Inspect(ctx, cfg)
+		ui.Must(err)
+		ui.Stop("ok")
+		doc = &customroles.Document{
+			Roles:            collated,
+			SubstrateVersion: jsonutil.SubstrateVersion(version.Version),
+		}
+		ui.Must(doc.Write())
+		ui.Printf(
+			"wrote %s to describe your %d existing custom IAM roles; review it, commit it to version control, and run `substrate role apply` again after you change it",
+			customroles.Filename,
+			len(collated),
+		)
+		return
+	}
+	ui.Must(err)
+
+	// Check every role's policies before changing any of them.
+	create.Lint(ctx, cfg, doc.Roles...)
+
+	// Create or update every role in the file.
+	for _, role := range doc.Roles {
+		ui.Printf("applying the %s role from %s", role.RoleName, customroles.Filename)
+		create.Create(ctx, cfg, role)
+	}
+
+	// Delete every Substrate-managed role that's not in the file, which is
+	// only safe to do after creating and updating everything that is.
+	ui.Spinf("finding Substrate-managed roles that aren't in %s", customroles.Filename)
+	collated, instances, err := customroles.Inspect(ctx, cfg)
+	ui.Must(err)
+	ui.Stop("ok")
+	var deleted []string
+	for _, role := range collated {
+		if doc.Find(role.RoleName) != nil {
+			continue
+		}
+		if !*force {
+			ok, err := ui.Confirmf(
+				"delete the %s role, which isn't in %s, from %d AWS account(s)? (yes/no)",
+				role.RoleName,
+				customroles.Filename,
+				len(instances[role.RoleName]),
+			)
+			ui.Must(err)
+			if !ok {
+				continue
+			}
+		}
+		deleted = append(deleted, role.RoleName)
+		for _, instance := range instances[role.RoleName] {
+			account := instance.Account
+			if err := awsiam.DeleteRoleWithConfirmation(
+				ctx,
+				awscfg.Must(account.Config(ctx, cfg, account.AdministratorRoleName(), time.Hour)),
+				role.RoleName,
+				true, // already confirmed above
+			); err != nil && !awsutil.ErrorCodeIs(err, awsiam.NoSuchEntity) {
+				ui.Fatal(err)
+			}
+		}
+	}
+
+	// Keep the Substrate-managed profiles in ~/.aws/config, if any, current.
+	// Having just inspected every account, there's no need to rely on the
+	// custom roles already in those profiles.
+	customRoles := profiles.NewCustomRoles(instances)
+	for _, roleName := range deleted {
+		customRoles.Delete(roleName)
+	}
+	if err := profiles.Update(ctx, cfg, func(existing profiles.CustomRoles) {
+		for accountId := range existing {
+			delete(existing, accountId)
+		}
+		for accountId, roleNames := range customRoles {
+			existing[accountId] = roleNames
+		}
+	}); err != nil {
+		ui.Print(err)
+	}
+
+}
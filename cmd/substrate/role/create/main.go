@@ -7,16 +7,17 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/spf13/cobra"
 	"github.com/src-bin/substrate/accounts"
 	"github.com/src-bin/substrate/awscfg"
 	"github.com/src-bin/substrate/awsiam"
 	"github.com/src-bin/substrate/awsutil"
 	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/customroles"
 	"github.com/src-bin/substrate/humans"
-	"github.com/src-bin/substrate/jsonutil"
-	"github.com/src-bin/substrate/naming"
 	"github.com/src-bin/substrate/policies"
+	"github.com/src-bin/substrate/profiles"
 	"github.com/src-bin/substrate/roles"
 	"github.com/src-bin/substrate/tagging"
 	"github.com/src-bin/substrate/ui"
@@ -40,9 +41,14 @@ func Command() *cobra.Command {
                                 [--number <number> [...]]
     [assume-role policy flags]: [--humans]
                                 [--aws-service <service.amazonaws.com>] [--github-actions <org/repo>]
+                                [--oidc-provider <issuer> --oidc-subject <pattern> [...] [--oidc-audience <audience>]]
+                                [--gitlab-ci <group/project>] [--circleci <org-id>/<project-id>]
+                                [--trusted-accounts|--trusted-role <role> [--trusted-domain <domain> [...]]
+                                    [--trusted-environment <environment> [...]] [--trusted-quality <quality> [...]]]
                                 [--assume-role-policy <filename> [...]]
     [policy attachment flags]:  [--administrator-access|--read-only-access]
-                                [--policy-arn <arn> [...]] [--policy <filename> [...]]`,
+                                [--policy-arn <arn> [...]] [--policy <filename> [...]]
+                                [--permissions-boundary <policy>] [--max-session-duration <duration>]`,
 		Short: "create or update an AWS IAM role in selected AWS accounts",
 		Long:  ``,
 		Args:  cobra.NoArgs,
@@ -58,8 +64,12 @@ func Command() *cobra.Command {
 				"--all-qualities", "--quality",
 				"--management", "--special", "--substrate",
 				"--number",
-				"--humans", "--aws-service", "--github-actions", "--assume-role-policy",
+				"--humans", "--aws-service", "--github-actions",
+				"--oidc-provider", "--oidc-subject", "--oidc-audience", "--gitlab-ci", "--circleci",
+				"--trusted-accounts", "--trusted-role", "--trusted-domain", "--trusted-environment", "--trusted-quality",
+				"--assume-role-policy",
 				"--administrator-access", "--read-only-access", "--policy-arn", "--policy",
+				"--permissions-boundary", "--max-session-duration",
 				"--quiet",
 			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
 		},
@@ -79,16 +89,28 @@ func Command() *cobra.Command {
 		Numbers:         "create this role in a specific AWS account, by 12-digit account number (may be repeated)",
 	}))
 	cmd.Flags().AddFlagSet(managedAssumeRolePolicy.FlagSet(roles.ManagedAssumeRolePolicyFlagsUsage{
-		Humans:        "allow humans with this role set in your IdP to assume this role via the Credential Factory (implies --substrate)",
-		AWSServices:   `allow an AWS service (by URL; e.g. "ec2.amazonaws.com") to assume role (may be repeated)`,
-		GitHubActions: `allow GitHub Actions to assume this role in the context of the given GitHub organization and repository (separated by a literal '/'; may be repeated)`,
-		Filenames:     "filename containing an assume-role policy to be merged into this role's final assume-role policy (may be repeated)",
+		Humans:             "allow humans with this role set in your IdP to assume this role via the Credential Factory (implies --substrate)",
+		AWSServices:        `allow an AWS service (by URL; e.g. "ec2.amazonaws.com") to assume role (may be repeated)`,
+		GitHubActions:      `allow GitHub Actions to assume this role in the context of the given GitHub organization and repository (separated by a literal '/'; may be repeated)`,
+		OIDCProvider:       `allow tokens from this OpenID Connect issuer (by URL; e.g. a Kubernetes cluster's "https://oidc.eks.us-west-2.amazonaws.com/id/...") to assume this role if they match a following --oidc-subject (may be repeated)`,
+		OIDCSubject:        `allow tokens from the preceding --oidc-provider with a matching subject (may contain '*' and '?'; e.g. "system:serviceaccount:<namespace>:<service-account>") to assume this role (may be repeated)`,
+		OIDCAudience:       `require tokens from the preceding --oidc-provider to have been issued for this audience (may be repeated; default "sts.amazonaws.com")`,
+		GitLabCI:           "allow GitLab CI/CD on gitlab.com to assume this role in the context of the given GitLab group and project (separated by a literal '/'; may be repeated)",
+		CircleCI:           "allow CircleCI to assume this role in the context of the given CircleCI organization ID and project ID (separated by a literal '/'; may be repeated)",
+		TrustedAccounts:    "allow any principal in the service accounts selected by the following --trusted-domain, --trusted-environment, and --trusted-quality to assume this role, if those accounts' own policies allow it (may be repeated)",
+		TrustedRole:        "allow the role by this name in the service accounts selected by the following --trusted-domain, --trusted-environment, and --trusted-quality to assume this role (may be repeated)",
+		TrustedDomain:      "select service accounts in this domain for the preceding --trusted-accounts or --trusted-role (may be repeated; default all domains)",
+		TrustedEnvironment: "select service accounts in this environment for the preceding --trusted-accounts or --trusted-role (may be repeated; default all environments)",
+		TrustedQuality:     "select service accounts of this quality for the preceding --trusted-accounts or --trusted-role (may be repeated; default all qualities)",
+		Filenames:          "filename containing an assume-role policy to be merged into this role's final assume-role policy (may be repeated)",
 	}))
 	cmd.Flags().AddFlagSet(managedPolicyAttachments.FlagSet(roles.ManagedPolicyAttachmentsFlagsUsage{
 		AdministratorAccess: "attach the AWS-managed AdministratorAccess policy to these roles, allowing total access to all AWS APIs and resources",
 		ReadOnlyAccess:      "attach the AWS-managed ReadOnlyAccess policy to these roles, allowing read access to all AWS resources",
 		ARNs:                "attach a specific AWS-managed policy to these roles (may be repeated)",
 		Filenames:           "filename containing a policy to attach to these roles (may be repeated)",
+		PermissionsBoundary: "name of a customer managed policy, which must exist in every selected account, or ARN of an AWS managed policy to set as these roles' permissions boundary, which limits what they're allowed to do no matter what other policies allow",
+		MaxSessionDuration:  `maximum duration of these roles' sessions, between "1h" and "12h" (default "12h")`,
 	}))
 	cmd.Flags().AddFlag(cmdutil.QuietFlag())
 	return cmd
@@ -117,6 +139,54 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 
 	versionutil.PreventDowngrade(ctx, cfg)
 
+	customRole := &customroles.Role{
+		RoleName:          *roleName,
+		AccountSelection:  selection,
+		AssumeRolePolicy:  managedAssumeRolePolicy,
+		PolicyAttachments: managedPolicyAttachments,
+	}
+	Lint(ctx, cfg, customRole)
+	accountIds := Create(ctx, cfg, customRole)
+
+	// Keep the Substrate-managed profiles in ~/.aws/config, if any, current.
+	if err := profiles.Update(ctx, cfg, func(customRoles profiles.CustomRoles) {
+		customRoles.Delete(*roleName)
+		for _, accountId := range accountIds {
+			customRoles.Add(accountId, *roleName)
+		}
+	}); err != nil {
+		ui.Print(err)
+	}
+}
+
+// Create creates or updates a custom role in every account selected by its
+// account selection and offers to delete it from every account that isn't.
+// The role must have been validated by customRole.Validate or equivalent and
+// linted by Lint, which callers creating many roles should call once for all
+// of them. It returns the IDs of every account the role is now in.
+func Create(ctx context.Context, cfg *awscfg.Config, customRole *customroles.Role) (accountIds []string) {
+	var (
+		roleName                 = customRole.RoleName
+		selection                = customRole.AccountSelection
+		managedAssumeRolePolicy  = customRole.AssumeRolePolicy
+		managedPolicyAttachments = customRole.PolicyAttachments
+	)
+
+	// The permissions boundary is a customer managed policy by the same name
+	// in every account or an AWS managed policy, so its ARN differs from one
+	// account to the next.
+	roleOptions := func(accountId string) *awsiam.RoleOptions {
+		return &awsiam.RoleOptions{
+			MaxSessionDuration:           managedPolicyAttachments.MaxSessionDurationOrZero(),
+			PermissionsBoundaryPolicyARN: managedPolicyAttachments.PermissionsBoundaryARN(accountId),
+		}
+	}
+
+	// Digest the policy files we've been given so that `substrate role diff`
+	// can tell later whether they've changed.
+	digest, err := customRole.PolicyDigest()
+	ui.Must(err)
+
 	// Partition accounts by the given options so the role may be created or
 	// deleted as appropriate.
 	ui.Spin("inspecting all your AWS accounts")
@@ -128,35 +198,31 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	// given options. We do this first so that if one of the confirmations
 	// spooks the user, there's less to unwind.
 	if len(unselected) > 0 {
-		ui.Printf("finding Substrate-managed %s roles that should now be deleted according to these account selection flags", *roleName)
+		ui.Printf("finding Substrate-managed %s roles that should now be deleted according to these account selection flags", roleName)
 		for _, account := range unselected {
+			accountCfg := awscfg.Must(account.Config(
+				ctx,
+				cfg,
+				account.AdministratorRoleName(),
+				time.Hour,
+			))
 			if err := awsiam.DeleteRoleWithConfirmation(
 				ctx,
-				awscfg.Must(account.Config(
-					ctx,
-					cfg,
-					account.AdministratorRoleName(),
-					time.Hour,
-				)),
-				*roleName,
+				accountCfg,
+				roleName,
 				false, // always confirm these probably surprising deletes
 			); err != nil && !awsutil.ErrorCodeIs(err, awsiam.NoSuchEntity) {
 				ui.Fatal(err)
 			}
+			if role, err := awsiam.GetRole(ctx, accountCfg, roleName); err == nil && customroles.IsCustom(role) {
+				accountIds = append(accountIds, aws.ToString(account.Id)) // deleting it was declined
+			}
 		}
 	}
 
 	// Every role we create needs these minimal privileges in order to use
 	// the Substrate command-line tools.
-	minimalPolicy := &policies.Document{
-		Statement: []policies.Statement{{
-			Action: []string{
-				"organizations:DescribeOrganization",
-				"sts:AssumeRole",
-			},
-			Resource: []string{"*"},
-		}},
-	}
+	minimalPolicy := customroles.MinimalPolicy()
 
 	// And during the transition from (theoretically multiple) admin accounts
 	// to exactly one Substrate account, we need to know about those beyond
@@ -167,6 +233,11 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	intranetAssumeRolePolicy, err := humans.IntranetAssumeRolePolicy(ctx, cfg)
 	ui.Must(err)
 
+	// Trusted accounts are resolved to account numbers from the same list of
+	// accounts for every instance of this role.
+	allAccounts, err := cfg.ListAccounts(ctx)
+	ui.Must(err)
+
 	// If this role's for humans to use via the IdP, create a role by the same
 	// name in the Substrate account. This role must exist before we enter the
 	// main role and policy loop because that loop will need to reference these
@@ -178,7 +249,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	// managing this role so we'll use EnsureRoleWithPolicy.
 	adminPrincipals := &policies.Principal{AWS: []string{}} // TODO turn into a singular substratePrincipal when removing admin accounts
 	if selection.Humans {
-		ui.Spinf("finding or creating the %s role in your Substrate account for humans to assume via your IdP", *roleName)
+		ui.Spinf("finding or creating the %s role in your Substrate account for humans to assume via your IdP", roleName)
 
 		for _, account := range append(adminAccounts, substrateAccount) {
 			if account == nil { // substrateAccount will be nil until they've run `substrate setup`
@@ -190,15 +261,17 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 				role, err = awsiam.CreateRole(
 					ctx,
 					accountCfg,
-					*roleName,
+					roleName,
 					intranetAssumeRolePolicy,
+					roleOptions(aws.ToString(account.Id)),
 				)
 			} else {
 				role, err = awsiam.EnsureRoleWithPolicy(
 					ctx,
 					accountCfg,
-					*roleName,
+					roleName,
 					intranetAssumeRolePolicy,
+					roleOptions(aws.ToString(account.Id)),
 					minimalPolicy,
 				)
 			}
@@ -207,26 +280,39 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 					tagging.SubstrateAccountSelectors: "humans",
 				}))
 			} else if awsutil.ErrorCodeIs(err, awsiam.EntityAlreadyExists) {
-				role, err = awsiam.GetRole(ctx, accountCfg, *roleName)
+				role, err = awsiam.GetRole(ctx, accountCfg, roleName)
 			}
 			ui.Must(err)
 			adminPrincipals.AWS = append(adminPrincipals.AWS, role.ARN)
+			accountIds = append(accountIds, aws.ToString(account.Id))
 		}
 		ui.Stop("ok")
 	}
 
+	// Every account's OpenID Connect provider for an issuer gets the same
+	// certificate thumbprint so fetch each one just once.
+	thumbprints := make(map[string][]string)
+	for _, t := range managedAssumeRolePolicy.MergedOIDC() {
+		if _, ok := thumbprints[t.Provider]; ok {
+			continue
+		}
+		var err error
+		thumbprints[t.Provider], err = awsiam.OpenIDConnectProviderThumbprints(t.Provider)
+		if err != nil {
+			ui.Printf("couldn't find %s's certificate thumbprint (%v); using a placeholder, which works if IAM trusts its CA", t.Provider, err)
+		}
+	}
+
 	// Create the role in each account, constructing the assume-role policy
 	// uniquely for each one because certain aspects, e.g. the GitHub Actions
 	// OAuth OIDC provider, may differ in the details from one account to
 	// another.
 	for _, as := range selected {
 		account := as.Account
+		accountIds = append(accountIds, aws.ToString(account.Id))
 		accountCfg := awscfg.Must(account.Config(ctx, cfg, account.AdministratorRoleName(), time.Hour))
 		selectors := as.Selectors
-		ui.Printf("constructing an assume-role policy for the %s role in %s", *roleName, account)
-
-		// Begin with an empty (technically nil) assume-role policy.
-		var assumeRolePolicy *policies.Document
+		ui.Printf("constructing an assume-role policy for the %s role in %s", roleName, account)
 
 		// If -humans was given, allow the pre-created roles in the Substrate
 		// account to assume this role. This is much simpler than it used to be
@@ -235,84 +321,92 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 		// instance profiles everywhere in anticipation of Instance Factory
 		// being able to launch instances in any account (as oft requested).
 		if managedAssumeRolePolicy.Humans {
-			ui.Printf("allowing humans to assume the %s role in %s via your IdP", *roleName, account)
-			assumeRolePolicy = policies.Merge(
-				assumeRolePolicy,
-				intranetAssumeRolePolicy,
-				policies.AssumeRolePolicyDocument(adminPrincipals),
-			)
-			ui.Must2(awsiam.EnsureInstanceProfile(ctx, cfg, *roleName))
+			ui.Printf("allowing humans to assume the %s role in %s via your IdP", roleName, account)
+			ui.Must2(awsiam.EnsureInstanceProfile(ctx, cfg, roleName))
 		}
 
 		if len(managedAssumeRolePolicy.AWSServices) > 0 {
 			ui.Printf(
 				"allowing %s to assume the %s role in %s",
 				strings.Join(managedAssumeRolePolicy.AWSServices, ", "),
-				*roleName,
+				roleName,
 				account,
 			)
-			assumeRolePolicy = policies.Merge(
-				assumeRolePolicy,
-				policies.AssumeRolePolicyDocument(&policies.Principal{
-					Service: jsonutil.StringSlice(managedAssumeRolePolicy.AWSServices),
-				}),
-			)
 		}
 
 		if len(managedAssumeRolePolicy.GitHubActions) > 0 {
 			ui.Printf(
 				"allowing GitHub Actions to assume the %s role in %s on behalf of %s",
-				*roleName,
+				roleName,
 				account,
 				strings.Join(managedAssumeRolePolicy.GitHubActions, ", "),
 			)
-			arn, err := awsiam.EnsureOpenIDConnectProvider(
+			ui.Must2(awsiam.EnsureOpenIDConnectProvider(
 				ctx,
 				accountCfg,
 				[]string{"sts.amazonaws.com"},
 				[]string{awsiam.GitHubActionsOAuthOIDCThumbprint},
 				awsiam.GitHubActionsOAuthOIDCURL,
+			))
+		}
+
+		for _, t := range managedAssumeRolePolicy.MergedOIDC() {
+			ui.Printf(
+				"allowing tokens from %s to assume the %s role in %s on behalf of %s",
+				t.Provider,
+				roleName,
+				account,
+				strings.Join(t.Subjects, ", "),
 			)
-			ui.Must(err)
-			subs, err := managedAssumeRolePolicy.GitHubActionsSubs()
-			ui.Must(err)
-			assumeRolePolicy = policies.Merge(
-				assumeRolePolicy,
-				&policies.Document{
-					Statement: []policies.Statement{{
-						Action: []string{"sts:AssumeRoleWithWebIdentity"},
-						Condition: policies.Condition{"StringEquals": {
-							"token.actions.githubusercontent.com:sub": subs,
-						}},
-						Principal: &policies.Principal{
-							Federated: []string{arn},
-						},
-					}},
-				},
+			ui.Must2(awsiam.EnsureOpenIDConnectProvider(
+				ctx,
+				accountCfg,
+				t.AudiencesOrDefault(),
+				thumbprints[t.Provider],
+				t.Provider,
+			))
+		}
+
+		for _, t := range managedAssumeRolePolicy.TrustedAccounts {
+			principal := "any principal"
+			if t.RoleName != "" {
+				principal = "the " + t.RoleName + " role"
+			}
+			ui.Printf(
+				"allowing %s in accounts with domain %s, environment %s, and quality %s to assume the %s role in %s",
+				principal,
+				anyOf(t.Domains),
+				anyOf(t.Environments),
+				anyOf(t.Qualities),
+				roleName,
+				account,
 			)
 		}
 
 		for _, filename := range managedAssumeRolePolicy.Filenames {
 			ui.Printf("reading additional assume-role policy statements from %s", filename)
-			var filePolicy policies.Document
-			ui.Must(jsonutil.Read(filename, &filePolicy))
-			assumeRolePolicy = policies.Merge(assumeRolePolicy, &filePolicy)
 		}
 
-		if assumeRolePolicy == nil {
-			ui.Fatal("at least one assume-role policy flag is required")
-		}
-		ui.Spinf("finding or creating the %s role in %s", *roleName, account)
+		assumeRolePolicy, err := customRole.AssumeRolePolicyDocument(
+			intranetAssumeRolePolicy,
+			adminPrincipals,
+			aws.ToString(account.Id),
+			allAccounts,
+		)
+		ui.Must(err)
+		ui.Spinf("finding or creating the %s role in %s", roleName, account)
 		role, err := awsiam.EnsureRoleWithPolicy(
 			ctx,
 			accountCfg,
-			*roleName,
+			roleName,
 			assumeRolePolicy,
+			roleOptions(aws.ToString(account.Id)),
 			minimalPolicy,
 		)
 		ui.Must(err)
 		tags := tagging.Map{
 			tagging.SubstrateAccountSelectors: strings.Join(selectors, " "),
+			tagging.SubstratePolicyDigest:     digest,
 		}
 		if len(managedAssumeRolePolicy.Filenames) > 0 {
 			tags[tagging.SubstrateAssumeRolePolicyFilenames] = strings.Join(managedAssumeRolePolicy.Filenames, " ")
@@ -320,45 +414,59 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 		if len(managedPolicyAttachments.Filenames) > 0 {
 			tags[tagging.SubstratePolicyAttachmentFilenames] = strings.Join(managedPolicyAttachments.Filenames, " ")
 		}
+		var untags []string
+		if managedPolicyAttachments.MaxSessionDuration != "" {
+			tags[tagging.SubstrateMaxSessionDuration] = managedPolicyAttachments.MaxSessionDuration
+		} else {
+			untags = append(untags, tagging.SubstrateMaxSessionDuration)
+		}
+		if len(managedAssumeRolePolicy.TrustedAccounts) > 0 {
+			tags[tagging.SubstrateTrustedAccounts] = managedAssumeRolePolicy.TrustedAccountsTag()
+		} else {
+			untags = append(untags, tagging.SubstrateTrustedAccounts)
+		}
+		if managedPolicyAttachments.PermissionsBoundary != "" {
+			tags[tagging.SubstratePermissionsBoundary] = managedPolicyAttachments.PermissionsBoundary
+		} else {
+			untags = append(untags, tagging.SubstratePermissionsBoundary)
+		}
 		ui.Must(awsiam.TagRole(ctx, accountCfg, role.Name, tags))
+		if len(untags) > 0 {
+			ui.Must(awsiam.UntagRole(ctx, accountCfg, role.Name, untags))
+		}
 		for _, service := range managedAssumeRolePolicy.AWSServices {
 			if service == "ec2.amazonaws.com" {
-				_, err = awsiam.EnsureInstanceProfile(ctx, accountCfg, *roleName)
+				_, err = awsiam.EnsureInstanceProfile(ctx, accountCfg, roleName)
 				ui.Must(err)
 			}
 		}
 		ui.Stopf("ok")
 
-		// Attach policies to selected accounts. If this account is an admin
-		// account or the Substrate account, only attach policies if -admin
-		// or -substrate was given. If this account is not an admin account or
-		// the Substrate account, attach them without further conditions.
-		// This complication is because -humans implies that roles must exist
-		// in admin/Substrate accounts but not that they should have all the
-		// same permissions in those accounts that they have elsewhere.
-		if is := account.Tags[tagging.Domain] == naming.Admin || account.Tags[tagging.SubstrateType] == naming.Substrate; is && selection.Substrate || !is {
+		// Attach policies to selected accounts, subject to the conditions
+		// explained in customroles.Role.AttachesPolicies.
+		if customRole.AttachesPolicies(account) {
 
 			if managedPolicyAttachments.AdministratorAccess {
-				ui.Spinf("attaching the AdministratorAccess policy to the %s role in %s", *roleName, account)
+				ui.Spinf("attaching the AdministratorAccess policy to the %s role in %s", roleName, account)
 			}
 			if managedPolicyAttachments.ReadOnlyAccess {
-				ui.Spinf("attaching the ReadOnlyAccess policy to the %s role in %s", *roleName, account)
+				ui.Spinf("attaching the ReadOnlyAccess policy to the %s role in %s", roleName, account)
 			}
 			if managedPolicyAttachments.AdministratorAccess {
-				ui.Must(awsiam.AttachRolePolicy(ctx, accountCfg, *roleName, policies.AdministratorAccess))
+				ui.Must(awsiam.AttachRolePolicy(ctx, accountCfg, roleName, policies.AdministratorAccess))
 			} else {
-				ui.Must(awsiam.DetachRolePolicy(ctx, accountCfg, *roleName, policies.AdministratorAccess))
+				ui.Must(awsiam.DetachRolePolicy(ctx, accountCfg, roleName, policies.AdministratorAccess))
 			}
 			if managedPolicyAttachments.ReadOnlyAccess {
-				ui.Must(awsiam.AttachRolePolicy(ctx, accountCfg, *roleName, policies.ReadOnlyAccess))
+				ui.Must(awsiam.AttachRolePolicy(ctx, accountCfg, roleName, policies.ReadOnlyAccess))
 			} else {
-				ui.Must(awsiam.DetachRolePolicy(ctx, accountCfg, *roleName, policies.ReadOnlyAccess))
+				ui.Must(awsiam.DetachRolePolicy(ctx, accountCfg, roleName, policies.ReadOnlyAccess))
 			}
 			if managedPolicyAttachments.AdministratorAccess || managedPolicyAttachments.ReadOnlyAccess {
 				ui.Stop("ok")
 			}
 
-			attachedARNs := ui.Must2(awsiam.ListAttachedRolePolicies(ctx, accountCfg, *roleName))
+			attachedARNs := ui.Must2(awsiam.ListAttachedRolePolicies(ctx, accountCfg, roleName))
 			managedPolicyAttachments.Sort()
 			arns := managedPolicyAttachments.ARNs // just to shorten its name in the loop below
 			for _, arn := range attachedARNs {
@@ -366,33 +474,90 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 					continue // these two specific policies are handled just above
 				}
 				if i := sort.SearchStrings(arns, arn); i == len(arns) || arns[i] != arn { // <https://pkg.go.dev/sort#Search>
-					ui.Must(awsiam.DetachRolePolicy(ctx, accountCfg, *roleName, arn))
+					ui.Must(awsiam.DetachRolePolicy(ctx, accountCfg, roleName, arn))
 				}
 			}
 			if len(managedPolicyAttachments.ARNs) > 0 {
-				ui.Spinf("attaching AWS-managed policies to the %s role in %s", *roleName, account)
+				ui.Spinf("attaching AWS-managed policies to the %s role in %s", roleName, account)
 				for _, arn := range managedPolicyAttachments.ARNs {
-					ui.Must(awsiam.AttachRolePolicy(ctx, accountCfg, *roleName, arn))
+					ui.Must(awsiam.AttachRolePolicy(ctx, accountCfg, roleName, arn))
 				}
 				ui.Stopf("ok")
 			}
 
 			if len(managedPolicyAttachments.Filenames) > 0 {
-				ui.Spinf("merging custom policies for the %s role in %s", *roleName, account)
+				ui.Spinf("merging custom policies for the %s role in %s", roleName, account)
 			} else {
-				ui.Spinf("setting Substrate's minimal custom policy for the %s role in %s", *roleName, account)
-			}
-			policy := minimalPolicy
-			for _, filename := range managedPolicyAttachments.Filenames {
-				var filePolicy policies.Document
-				ui.Must(jsonutil.Read(filename, &filePolicy))
-				policy = policies.Merge(policy, &filePolicy)
+				ui.Spinf("setting Substrate's minimal custom policy for the %s role in %s", roleName, account)
 			}
-			ui.Must(awsiam.PutRolePolicy(ctx, accountCfg, *roleName, awsiam.SubstrateManaged, policy))
+			policy, err := customRole.PolicyDocument()
+			ui.Must(err)
+			ui.Must(awsiam.PutRolePolicy(ctx, accountCfg, roleName, awsiam.SubstrateManaged, policy))
 			ui.Stopf("ok")
 
 		}
 
 	}
 
+	return
+}
+
+// Lint reports every problem with the policy files given to any of these
+// custom roles and with the assume-role policies they'll have in every
+// selected account and exits if there are any, before anything's been
+// changed.
+func Lint(ctx context.Context, cfg *awscfg.Config, customRoles ...*customroles.Role) {
+	adminAccounts, _, substrateAccount, _, _, _, _, err := accounts.Grouped(ctx, cfg)
+	ui.Must(err)
+	intranetAssumeRolePolicy, err := humans.IntranetAssumeRolePolicy(ctx, cfg)
+	ui.Must(err)
+	allAccounts, err := cfg.ListAccounts(ctx)
+	ui.Must(err)
+
+	var n int
+	for _, customRole := range customRoles {
+		errs := customRole.Lint()
+		for _, err := range errs {
+			ui.Printf("%s role: %v", customRole.RoleName, err)
+			n++
+		}
+		if len(errs) > 0 {
+			continue // the assume-role policies can't be constructed from broken files
+		}
+
+		// Create will have created the roles by this name in the Substrate
+		// account (and any admin accounts) by the time it constructs these
+		// assume-role policies but their ARNs are predictable.
+		humanPrincipals := &policies.Principal{AWS: []string{}}
+		if customRole.AccountSelection.Humans {
+			for _, account := range append(adminAccounts, substrateAccount) {
+				if account != nil {
+					humanPrincipals.AWS = append(humanPrincipals.AWS, roles.ARN(aws.ToString(account.Id), customRole.RoleName))
+				}
+			}
+		}
+		selected, _, err := customRole.AccountSelection.Partition(ctx, cfg)
+		ui.Must(err)
+		for _, as := range selected {
+			for _, err := range customRole.LintAssumeRolePolicy(
+				intranetAssumeRolePolicy,
+				humanPrincipals,
+				aws.ToString(as.Account.Id),
+				allAccounts,
+			) {
+				ui.Printf("%s role's assume-role policy in %s: %v", customRole.RoleName, as.Account, err)
+				n++
+			}
+		}
+	}
+	if n > 0 {
+		ui.Fatalf("found %d problem(s) in policies; fix them and try again", n)
+	}
+}
+
+func anyOf(ss []string) string {
+	if len(ss) == 0 {
+		return "any"
+	}
+	return strings.Join(ss, " or ")
 }
@@ -5,12 +5,15 @@ import (
 	"io"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/spf13/cobra"
 	"github.com/src-bin/substrate/awscfg"
 	"github.com/src-bin/substrate/awsiam"
 	"github.com/src-bin/substrate/awsorgs"
 	"github.com/src-bin/substrate/awsutil"
 	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/customroles"
+	"github.com/src-bin/substrate/profiles"
 	"github.com/src-bin/substrate/roles"
 	"github.com/src-bin/substrate/ui"
 	"github.com/src-bin/substrate/versionutil"
@@ -61,16 +64,20 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 
 	allAccounts, err := awsorgs.ListAccounts(ctx, cfg)
 	ui.Must(err)
-	var found bool
+	var (
+		found     bool
+		remaining []string // accounts where deleting the role was declined
+	)
 	for _, account := range allAccounts {
+		accountCfg := awscfg.Must(account.Config(
+			ctx,
+			cfg,
+			account.AdministratorRoleName(),
+			time.Hour,
+		))
 		if err := awsiam.DeleteRoleWithConfirmation(
 			ctx,
-			awscfg.Must(account.Config(
-				ctx,
-				cfg,
-				account.AdministratorRoleName(),
-				time.Hour,
-			)),
+			accountCfg,
 			*roleName,
 			*force,
 		); err == nil {
@@ -78,6 +85,11 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 		} else if !awsutil.ErrorCodeIs(err, awsiam.NoSuchEntity) {
 			ui.Fatal(err)
 		}
+		if !*force {
+			if role, err := awsiam.GetRole(ctx, accountCfg, *roleName); err == nil && customroles.IsCustom(role) {
+				remaining = append(remaining, aws.ToString(account.Id))
+			}
+		}
 	}
 
 	// Print a warning if we did not delete _any_ roles as this might mean
@@ -86,4 +98,14 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 		ui.Printf("did not find any roles named %q", roleName)
 	}
 
+	// Keep the Substrate-managed profiles in ~/.aws/config, if any, current.
+	if err := profiles.Update(ctx, cfg, func(customRoles profiles.CustomRoles) {
+		customRoles.Delete(*roleName)
+		for _, accountId := range remaining {
+			customRoles.Add(accountId, *roleName)
+		}
+	}); err != nil {
+		ui.Print(err)
+	}
+
 }
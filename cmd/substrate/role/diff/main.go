@@ -0,0 +1,134 @@
+package diff
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/customroles"
+	"github.com/src-bin/substrate/jsonutil"
+	"github.com/src-bin/substrate/ui"
+	"github.com/src-bin/substrate/versionutil"
+)
+
+var (
+	format, formatFlag, formatCompletionFunc = cmdutil.FormatFlag(
+		cmdutil.FormatText,
+		[]cmdutil.Format{cmdutil.FormatJSON, cmdutil.FormatText},
+	)
+	roleName = new(string)
+)
+
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [--role <role>] [--format <format>]",
+		Short: "compare Substrate-managed IAM roles to what `substrate role create` would make of them now",
+		Long:  ``,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			Main(cmdutil.Main(cmd, args))
+		},
+		DisableFlagsInUseLine: true,
+		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return []string{
+				"--role",
+				"--format",
+			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+		},
+	}
+	cmd.Flags().StringVar(roleName, "role", "", "name of the IAM role to compare (default all Substrate-managed IAM roles)")
+	cmd.RegisterFlagCompletionFunc("role", cmdutil.NoCompletionFunc)
+	cmd.Flags().AddFlag(formatFlag)
+	cmd.RegisterFlagCompletionFunc(formatFlag.Name, formatCompletionFunc)
+	return cmd
+}
+
+func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string, w io.Writer) {
+
+	go cfg.Telemetry().Post(ctx) // post earlier, finish earlier
+	defer cfg.Telemetry().Wait(ctx)
+
+	versionutil.WarnDowngrade(ctx, cfg)
+
+	ui.Spin("inspecting all the roles in all your AWS accounts")
+	collated, instances, err := customroles.Inspect(ctx, cfg)
+	ui.Must(err)
+	ui.Stop("ok")
+
+	var drifts []*customroles.Drift
+	for _, role := range collated {
+		if *roleName != "" && role.RoleName != *roleName {
+			continue
+		}
+		ui.Spinf("comparing the %s role to what `substrate role create` would make of it now", role.RoleName)
+		drift, err := customroles.Diff(ctx, cfg, role, instances[role.RoleName])
+		ui.Must(err)
+		drifts = append(drifts, drift)
+		ui.Stop("ok")
+	}
+	if *roleName != "" && len(drifts) == 0 {
+		ui.Fatalf("no Substrate-managed %s role found", *roleName)
+	}
+
+	switch *format {
+
+	case cmdutil.FormatJSON:
+		if drifts == nil {
+			drifts = []*customroles.Drift{}
+		}
+		jsonutil.PrettyPrint(w, drifts)
+
+	case cmdutil.FormatText:
+		for i, drift := range drifts {
+			if i > 0 {
+				ui.Print("")
+			}
+			ui.Print(drift.RoleName)
+			if drift.Empty() {
+				ui.Print("\tno drift")
+				continue
+			}
+			if drift.PolicyFilesChanged {
+				ui.Print("\tpolicy files changed on disk since this role was last created or updated")
+			}
+			if len(drift.MissingAccounts) > 0 {
+				ui.Print("\tmissing from accounts selected since this role was last created or updated:")
+				for _, account := range drift.MissingAccounts {
+					ui.Print("\t\t", account)
+				}
+			}
+			if len(drift.UnselectedAccounts) > 0 {
+				ui.Print("\tpresent in accounts no longer selected, where `substrate role create` would delete it:")
+				for _, account := range drift.UnselectedAccounts {
+					ui.Print("\t\t", account)
+				}
+			}
+			for _, instance := range drift.Instances {
+				var what []string
+				if instance.AssumeRolePolicy {
+					what = append(what, "assume-role policy")
+				}
+				if instance.PolicyAttachments {
+					what = append(what, "attached policies")
+				}
+				if instance.Policy {
+					what = append(what, "custom policy")
+				}
+				ui.Printf("\t%s differs in %s (%s)", strings.Join(what, ", "), instance.Account, instance.Cause)
+			}
+		}
+		for _, drift := range drifts {
+			if !drift.Empty() {
+				ui.Print("")
+				ui.Print("run the commands printed by `substrate role list --format shell` to converge these roles")
+				break
+			}
+		}
+
+	default:
+		ui.Fatal(cmdutil.FormatFlagError(*format))
+	}
+}
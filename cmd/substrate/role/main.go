@@ -2,8 +2,10 @@ package role
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/src-bin/substrate/cmd/substrate/role/apply"
 	"github.com/src-bin/substrate/cmd/substrate/role/create"
 	"github.com/src-bin/substrate/cmd/substrate/role/delete"
+	"github.com/src-bin/substrate/cmd/substrate/role/diff"
 	"github.com/src-bin/substrate/cmd/substrate/role/list"
 )
 
@@ -14,8 +16,10 @@ func Command() *cobra.Command {
 		Args:  cobra.NoArgs,
 	}
 
+	cmd.AddCommand(apply.Command())
 	cmd.AddCommand(create.Command())
 	cmd.AddCommand(delete.Command())
+	cmd.AddCommand(diff.Command())
 	cmd.AddCommand(list.Command())
 
 	return cmd
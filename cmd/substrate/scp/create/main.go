@@ -0,0 +1,206 @@
+package create
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/cobra"
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/jsonutil"
+	"github.com/src-bin/substrate/policies"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/scps"
+	"github.com/src-bin/substrate/tagging"
+	"github.com/src-bin/substrate/ui"
+	"github.com/src-bin/substrate/versionutil"
+)
+
+var (
+	scpName   = new(string)
+	filenames = new([]string)
+	targets   = scps.NewTargets()
+)
+
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: `create --scp <name> --policy <filename> [...] [target flags] [account selection flags] [--quiet]
+    [target flags]:            [--root] [--organizational-unit <ou-id> [...]]
+    [account selection flags]: [--all-domains|--domain <domain> [...]]
+                               [--all-environments|--environment <environment> [...]]
+                               [--all-qualities|--quality <quality> [...]]
+                               [--substrate] [--special <special> [...]]
+                               [--number <number> [...]]`,
+		Short: "create or update a service control policy and attach it to the root, OUs, and/or selected AWS accounts",
+		Long:  ``,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			Main(cmdutil.Main(cmd, args))
+		},
+		DisableFlagsInUseLine: true,
+		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return []string{
+				"--scp", "--policy",
+				"--root", "--organizational-unit",
+				"--all-domains", "--domain",
+				"--all-environments", "--environment",
+				"--all-qualities", "--quality",
+				"--substrate", "--special",
+				"--number",
+				"--quiet",
+			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+		},
+	}
+	cmd.Flags().StringVar(scpName, "scp", "", "name of the service control policy to create or update")
+	cmd.RegisterFlagCompletionFunc("scp", cmdutil.NoCompletionFunc)
+	cmd.Flags().StringArrayVar(filenames, "policy", []string{}, "filename containing statements to include in this service control policy (may be repeated)")
+	cmd.Flags().AddFlagSet(targets.FlagSet(scps.TargetsFlagsUsage{
+		Root:                "attach this service control policy to the organization's root, affecting every AWS account except the management account",
+		OrganizationalUnits: "attach this service control policy to an organizational unit, by ID (may be repeated)",
+	}))
+	cmd.Flags().AddFlagSet(targets.Selection.FlagSet(accounts.SelectionFlagsUsage{
+		AllDomains:      "attach this service control policy to AWS accounts in all domains (potentially constrained by --environment and/or --quality)",
+		Domains:         "attach this service control policy to AWS accounts in this domain (may be repeated)",
+		AllEnvironments: "attach this service control policy to AWS accounts in all environments (potentially constrained by --domain and/or --quality)",
+		Environments:    "attach this service control policy to AWS accounts in this environment (may be repeated)",
+		AllQualities:    "attach this service control policy to AWS accounts of all qualities (potentially constrained by --domain and/or --environment)",
+		Qualities:       "attach this service control policy to AWS accounts of this quality (may be repeated)",
+		Substrate:       "attach this service control policy to the organization's Substrate account",
+		Management:      "ignored; service control policies never affect the organization's management AWS account",
+		Specials:        `attach this service control policy to a special AWS account (may be repeated; "audit", "deploy", and/or "network")`,
+		Numbers:         "attach this service control policy to a specific AWS account, by 12-digit account number (may be repeated)",
+	}))
+	cmd.Flags().AddFlag(cmdutil.QuietFlag())
+	return cmd
+}
+
+func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string, _ io.Writer) {
+	if *scpName == "" {
+		ui.Fatal(`--scp "..." is required`)
+	}
+	if *scpName == scps.Substrate {
+		ui.Fatalf("cannot manage %s with `substrate scp create`; it's managed by `substrate setup`", *scpName)
+	}
+	if len(*filenames) == 0 {
+		ui.Fatal(`at least one --policy "..." is required`)
+	}
+	for _, ou := range targets.OrganizationalUnits {
+		if !strings.HasPrefix(ou, "ou-") {
+			ui.Fatalf(`--organizational-unit %q doesn't look like an organizational unit ID (which begins with "ou-")`, ou)
+		}
+	}
+	ui.Must(targets.Validate())
+	ui.Must(targets.Sort())
+	targetsTag, err := targets.Tag()
+	ui.Must(err)
+	filenamesTag := strings.Join(*filenames, " ")
+	if len(filenamesTag) > tagging.MaxValueLength {
+		ui.Fatalf(
+			"too many --policy filenames to record in a tag (%d characters; the limit is %d); merge some of these files or give them shorter names",
+			len(filenamesTag),
+			tagging.MaxValueLength,
+		)
+	}
+
+	go cfg.Telemetry().Post(ctx) // post earlier, finish earlier
+	defer cfg.Telemetry().Wait(ctx)
+
+	mgmtCfg := awscfg.Must(cfg.AssumeManagementRole(ctx, roles.Substrate, time.Hour))
+	versionutil.PreventDowngrade(ctx, mgmtCfg)
+
+	// Read and merge all the policy files before touching anything so that
+	// a typo doesn't leave the organization half-configured.
+	var doc *policies.Document
+	for _, filename := range *filenames {
+		ui.Printf("reading service control policy statements from %s", filename)
+		var filePolicy policies.Document
+		ui.Must(jsonutil.Read(filename, &filePolicy))
+		doc = policies.Merge(doc, &filePolicy)
+	}
+
+	// Figure out where the policy should be attached.
+	ui.Spin("inspecting your organization")
+	var targetIds []string
+	descriptions := make(map[string]string) // target ID to human-readable description
+	if targets.Root {
+		root, err := awsorgs.DescribeRoot(ctx, mgmtCfg)
+		ui.Must(err)
+		targetIds = append(targetIds, aws.ToString(root.Id))
+		descriptions[aws.ToString(root.Id)] = "the root " + aws.ToString(root.Id)
+	}
+	for _, ou := range targets.OrganizationalUnits {
+		targetIds = append(targetIds, ou)
+		descriptions[ou] = "organizational unit " + ou
+	}
+	if targets.Selection != nil && !targets.Selection.Empty() {
+		selected, _, err := targets.Selection.Partition(ctx, cfg)
+		ui.Must(err)
+		for _, as := range selected {
+			if as.Account.Tags[tagging.SubstrateType] == accounts.Management {
+				continue // service control policies don't apply to the management account
+			}
+			targetIds = append(targetIds, aws.ToString(as.Account.Id))
+			descriptions[aws.ToString(as.Account.Id)] = as.Account.String()
+		}
+	}
+	ui.Stop("ok")
+	if targets.Selection.Management {
+		ui.Print("warning: service control policies do not affect the management account so --management is being ignored")
+	}
+
+	ui.Spinf("creating or updating the %s service control policy", *scpName)
+	policy, err := awsorgs.EnsurePolicyDocument(
+		ctx,
+		mgmtCfg,
+		*scpName,
+		awsorgs.SERVICE_CONTROL_POLICY,
+		doc,
+		tagging.Map{
+			tagging.SubstratePolicyFilenames: filenamesTag,
+			tagging.SubstratePolicyTargets:   targetsTag,
+		},
+	)
+	ui.Must(err)
+	policyId := aws.ToString(policy.PolicySummary.Id)
+	ui.Stop(policyId)
+
+	// Detach this policy from targets it's no longer meant to be attached to.
+	// This loosens restrictions so confirm each one, just like `substrate
+	// role create` confirms the deletion of roles that are no longer
+	// selected.
+	attached, err := awsorgs.ListTargetsForPolicy(ctx, mgmtCfg, policyId)
+	ui.Must(err)
+	for _, target := range attached {
+		targetId := aws.ToString(target.TargetId)
+		if _, ok := descriptions[targetId]; ok {
+			continue
+		}
+		ok, err := ui.Confirmf(
+			"detach the %s service control policy from %s %s (%s)? (yes/no)",
+			*scpName,
+			strings.ReplaceAll(strings.ToLower(string(target.Type)), "_", " "),
+			targetId,
+			aws.ToString(target.Name),
+		)
+		ui.Must(err)
+		if !ok {
+			continue
+		}
+		ui.Spinf("detaching the %s service control policy from %s", *scpName, targetId)
+		ui.Must(awsorgs.DetachPolicy(ctx, mgmtCfg, policyId, targetId))
+		ui.Stop("ok")
+	}
+
+	// Attach this policy everywhere it's meant to be attached.
+	for _, targetId := range targetIds {
+		ui.Spinf("attaching the %s service control policy to %s", *scpName, descriptions[targetId])
+		ui.Must(awsorgs.AttachPolicy(ctx, mgmtCfg, policyId, targetId))
+		ui.Stop("ok")
+	}
+
+}
@@ -0,0 +1,101 @@
+package delete
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/cobra"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/scps"
+	"github.com/src-bin/substrate/tagging"
+	"github.com/src-bin/substrate/ui"
+	"github.com/src-bin/substrate/versionutil"
+)
+
+var (
+	scpName = new(string)
+	force   = new(bool)
+)
+
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete --scp <name> [--force] [--quiet]",
+		Short: "detach and delete a Substrate-managed service control policy",
+		Long:  ``,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			Main(cmdutil.Main(cmd, args))
+		},
+		DisableFlagsInUseLine: true,
+		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return []string{
+				"--scp",
+				"--force",
+				"--quiet",
+			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+		},
+	}
+	cmd.Flags().StringVar(scpName, "scp", "", "name of the service control policy to delete")
+	cmd.RegisterFlagCompletionFunc("scp", cmdutil.NoCompletionFunc)
+	cmd.Flags().BoolVar(force, "force", false, "detach and delete the service control policy without confirmation")
+	cmd.Flags().AddFlag(cmdutil.QuietFlag())
+	return cmd
+}
+
+func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string, _ io.Writer) {
+	if *scpName == "" {
+		ui.Fatal(`--scp "..." is required`)
+	}
+	if *scpName == scps.Substrate {
+		ui.Fatalf("cannot delete %s with `substrate scp delete`; it's managed by `substrate setup`", *scpName)
+	}
+
+	go cfg.Telemetry().Post(ctx) // post earlier, finish earlier
+	defer cfg.Telemetry().Wait(ctx)
+
+	mgmtCfg := awscfg.Must(cfg.AssumeManagementRole(ctx, roles.Substrate, time.Hour))
+	versionutil.PreventDowngrade(ctx, mgmtCfg)
+
+	summary, err := awsorgs.FindPolicy(ctx, mgmtCfg, *scpName, awsorgs.SERVICE_CONTROL_POLICY)
+	ui.Must(err)
+	if summary == nil {
+		ui.Printf("did not find any service control policy named %q", *scpName)
+		return
+	}
+	policyId := aws.ToString(summary.Id)
+
+	// Only offer to delete Substrate-managed policies.
+	tags, err := awsorgs.ListPolicyTags(ctx, mgmtCfg, policyId)
+	ui.Must(err)
+	if tags[tagging.Manager] != tagging.Substrate {
+		ui.Fatalf("the %s service control policy is not managed by Substrate", *scpName)
+	}
+
+	targets, err := awsorgs.ListTargetsForPolicy(ctx, mgmtCfg, policyId)
+	ui.Must(err)
+	if !*force {
+		ui.Printf("the %s service control policy is attached to %d target(s):", *scpName, len(targets))
+		for _, target := range targets {
+			ui.Printf("\t%s (%s)", aws.ToString(target.TargetId), aws.ToString(target.Name))
+		}
+		if !ui.Must2(ui.Confirmf("detach and delete the %s service control policy? (yes/no)", *scpName)) {
+			return
+		}
+	}
+
+	ui.Spinf("detaching the %s service control policy", *scpName)
+	for _, target := range targets {
+		ui.Must(awsorgs.DetachPolicy(ctx, mgmtCfg, policyId, aws.ToString(target.TargetId)))
+	}
+	ui.Stop("ok")
+
+	ui.Spinf("deleting the %s service control policy", *scpName)
+	ui.Must(awsorgs.DeletePolicy(ctx, mgmtCfg, policyId))
+	ui.Stop("ok")
+
+}
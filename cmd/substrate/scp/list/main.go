@@ -0,0 +1,159 @@
+package list
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/cobra"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/jsonutil"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/scps"
+	"github.com/src-bin/substrate/tagging"
+	"github.com/src-bin/substrate/ui"
+	"github.com/src-bin/substrate/versionutil"
+)
+
+var format, formatFlag, formatCompletionFunc = cmdutil.FormatFlag(
+	cmdutil.FormatText,
+	[]cmdutil.Format{cmdutil.FormatJSON, cmdutil.FormatShell, cmdutil.FormatText},
+)
+
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list [--format <format>]",
+		Short: "list Substrate-managed service control policies and where they're attached",
+		Long:  ``,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			Main(cmdutil.Main(cmd, args))
+		},
+		DisableFlagsInUseLine: true,
+		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return []string{
+				"--format",
+			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+		},
+	}
+	cmd.Flags().AddFlag(formatFlag)
+	cmd.RegisterFlagCompletionFunc(formatFlag.Name, formatCompletionFunc)
+	return cmd
+}
+
+func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string, w io.Writer) {
+
+	go cfg.Telemetry().Post(ctx) // post earlier, finish earlier
+	defer cfg.Telemetry().Wait(ctx)
+
+	mgmtCfg := awscfg.Must(cfg.AssumeManagementRole(ctx, roles.Substrate, time.Hour))
+	versionutil.WarnDowngrade(ctx, mgmtCfg)
+
+	ui.Spin("inspecting your organization's service control policies")
+	summaries, err := awsorgs.ListPolicies(ctx, mgmtCfg, awsorgs.SERVICE_CONTROL_POLICY)
+	ui.Must(err)
+	var doc []policy
+	for _, summary := range summaries {
+		tags, err := awsorgs.ListPolicyTags(ctx, mgmtCfg, aws.ToString(summary.Id))
+		ui.Must(err)
+		if tags[tagging.Manager] != tagging.Substrate {
+			continue
+		}
+		p := policy{
+			Name:     aws.ToString(summary.Name),
+			PolicyId: aws.ToString(summary.Id),
+			Targets:  scps.NewTargets(),
+		}
+		if filenames := tags[tagging.SubstratePolicyFilenames]; filenames != "" {
+			p.Filenames = strings.Split(filenames, " ")
+		}
+		ui.Must(p.Targets.ParseTag(tags[tagging.SubstratePolicyTargets]))
+		attached, err := awsorgs.ListTargetsForPolicy(ctx, mgmtCfg, p.PolicyId)
+		ui.Must(err)
+		for _, target := range attached {
+			p.AttachedTo = append(p.AttachedTo, attachment{
+				Name:     aws.ToString(target.Name),
+				TargetId: aws.ToString(target.TargetId),
+				Type:     string(target.Type),
+			})
+		}
+		sort.Slice(p.AttachedTo, func(i, j int) bool {
+			return p.AttachedTo[i].TargetId < p.AttachedTo[j].TargetId
+		})
+		doc = append(doc, p)
+	}
+	sort.Slice(doc, func(i, j int) bool {
+		return doc[i].Name < doc[j].Name
+	}) // so that all output formats are stable
+	ui.Stop("ok")
+
+	switch *format {
+
+	case cmdutil.FormatJSON:
+		jsonutil.PrettyPrint(w, doc)
+
+	case cmdutil.FormatShell:
+		fmt.Fprintln(w, "set -e -x")
+		for _, p := range doc {
+			if p.Name == scps.Substrate {
+				continue // managed by `substrate setup`
+			}
+			fmt.Fprintln(w, strings.Join(
+				append(
+					append(
+						[]string{fmt.Sprintf("substrate scp create --scp %q", p.Name)},
+						p.PolicyArguments()...,
+					),
+					p.Targets.Arguments()...,
+				),
+				" ",
+			))
+		}
+
+	case cmdutil.FormatText:
+		for i, p := range doc {
+			if i > 0 {
+				ui.Print("")
+			}
+			ui.Printf("%s (%s)", p.Name, p.PolicyId)
+			if p.Name == scps.Substrate {
+				ui.Print("\tmanaged by `substrate setup`")
+			} else {
+				ui.Print("\tpolicy flags: ", strings.Join(p.PolicyArguments(), " "))
+				ui.Print("\ttarget flags: ", p.Targets)
+			}
+			ui.Print("\tattached to:")
+			for _, a := range p.AttachedTo {
+				ui.Printf("\t\t%s (%s)", a.TargetId, a.Name)
+			}
+		}
+
+	default:
+		ui.Fatal(cmdutil.FormatFlagError(*format))
+	}
+}
+
+type attachment struct {
+	Name, TargetId, Type string
+}
+
+type policy struct {
+	Name, PolicyId string
+	Filenames      []string
+	Targets        *scps.Targets
+	AttachedTo     []attachment
+}
+
+func (p policy) PolicyArguments() []string {
+	var ss []string
+	for _, filename := range p.Filenames {
+		ss = append(ss, "--policy", fmt.Sprintf("%q", filename))
+	}
+	return ss
+}
@@ -0,0 +1,22 @@
+package scp
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/src-bin/substrate/cmd/substrate/scp/create"
+	"github.com/src-bin/substrate/cmd/substrate/scp/delete"
+	"github.com/src-bin/substrate/cmd/substrate/scp/list"
+)
+
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scp",
+		Short: "manage AWS Organizations service control policies",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(create.Command())
+	cmd.AddCommand(delete.Command())
+	cmd.AddCommand(list.Command())
+
+	return cmd
+}
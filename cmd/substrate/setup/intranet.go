@@ -2,7 +2,9 @@ package setup
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -27,6 +29,7 @@ import (
 	"github.com/src-bin/substrate/policies"
 	"github.com/src-bin/substrate/regions"
 	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/sessions"
 	"github.com/src-bin/substrate/terraform"
 	"github.com/src-bin/substrate/ui"
 )
@@ -35,9 +38,13 @@ const (
 	Domain      = "admin"
 	Environment = "admin"
 
-	AzureADTenantFilename = "substrate.azure-ad-tenant"
-	OktaHostnameFilename  = "substrate.okta-hostname"
-	SAMLMetadataFilename  = "substrate.saml-metadata.xml"
+	AzureADTenantFilename     = "substrate.azure-ad-tenant"
+	GroupRolesFilename        = "substrate.group-roles.json"
+	OIDCIssuerFilename        = "substrate.oidc-issuer"
+	OIDCRoleNameClaimFilename = "substrate.oidc-role-name-claim"
+	OktaHostnameFilename      = "substrate.okta-hostname"
+	SAMLMetadataFilename      = "substrate.saml-metadata.xml"
+	SessionLifetimeFilename   = "substrate.intranet-session-lifetime"
 
 	OAuthOIDCClientIdFilename              = "substrate.oauth-oidc-client-id"
 	OAuthOIDCClientSecretTimestampFilename = "substrate.oauth-oidc-client-secret-timestamp"
@@ -125,11 +132,30 @@ func intranet(ctx context.Context, mgmtCfg, substrateCfg *awscfg.Config) (dnsDom
 	}
 
 	// Collect whatever additional information we need, depending on which sort
-	// of IdP they're using.
-	idpName = oauthoidc.IdPName(clientId)
+	// of IdP they're using. Client IDs that don't look like Google's or Azure
+	// AD's could belong to Okta or any other OpenID Connect provider so, unless
+	// we've been told before, ask for an Okta hostname or an issuer URL and
+	// decide based on the answer.
+	issuer, err := ui.PromptFile(OIDCIssuerFilename) // no prompt, just read
+	ui.Must(err)
+	idpName = oauthoidc.IdPName(clientId, issuer)
+	if idpName == oauthoidc.Okta && !fileutil.Exists(OktaHostnameFilename) {
+		s, err := ui.Prompt("paste the hostname of your Okta installation or, for any other OpenID Connect provider, its issuer URL (e.g. https://keycloak.example.com/realms/example):")
+		ui.Must(err)
+		pathname := OktaHostnameFilename
+		if strings.HasPrefix(s, "https://") {
+			issuer, idpName, pathname = s, oauthoidc.Generic, OIDCIssuerFilename
+		}
+		ui.Must(os.WriteFile(pathname, []byte(s+"\n"), 0666))
+		ui.Printf("%q written to %s, which you should commit to version control", s, pathname)
+	}
 	ui.Printf("configuring %s as your organization's OAuth OIDC identity provider", idpName)
 	hostname := oauthoidc.OktaHostnameValueForNonOktaIdP
 	tenantId := oauthoidc.AzureADTenantValueForNonAzureADIdP
+	roleNameClaim := oauthoidc.OIDCRoleNameClaimDefaultValue
+	if idpName != oauthoidc.Generic {
+		issuer = oauthoidc.OIDCIssuerValueForNonOIDCIdP
+	}
 	switch idpName {
 	case oauthoidc.AzureAD:
 		tenantId, err = ui.PromptFile(
@@ -138,6 +164,15 @@ func intranet(ctx context.Context, mgmtCfg, substrateCfg *awscfg.Config) (dnsDom
 		)
 		ui.Must(err)
 		ui.Printf("using Azure AD tenant ID %s", tenantId)
+	case oauthoidc.Generic:
+		ui.Spinf("fetching the OpenID Connect discovery document for %s", issuer)
+		ui.Must2(oauthoidc.Discover(issuer))
+		ui.Stop("ok")
+		ui.Printf("using OpenID Connect issuer %s", issuer)
+		if s, err := ui.PromptFile(OIDCRoleNameClaimFilename); err == nil && s != "" { // no prompt, just read
+			roleNameClaim = s
+		}
+		ui.Printf("using the %s claim as users' IAM role names (write a different claim name to %s to change this)", roleNameClaim, OIDCRoleNameClaimFilename)
 	case oauthoidc.Okta:
 		hostname, err = ui.PromptFile(
 			OktaHostnameFilename,
@@ -147,6 +182,29 @@ func intranet(ctx context.Context, mgmtCfg, substrateCfg *awscfg.Config) (dnsDom
 		ui.Printf("using Okta hostname %s", hostname)
 	}
 
+	// Optionally, map groups in ID tokens to additional roles users may choose.
+	var groupRoles *oauthoidc.GroupRoles
+	if b, err := os.ReadFile(GroupRolesFilename); err == nil {
+		groupRoles, err = oauthoidc.ParseGroupRoles(b)
+		if err != nil {
+			ui.Fatalf("%s: %v", GroupRolesFilename, err)
+		}
+		ui.Printf("mapping %d groups to IAM roles according to %s", len(groupRoles.Groups), GroupRolesFilename)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		ui.Fatal(err)
+	}
+
+	// Optionally, change how long Intranet sessions last. Within a session,
+	// the Intranet uses refresh tokens, if the IdP issues them, to renew
+	// users' ID tokens without sending them back to the IdP.
+	sessionLifetime := sessions.DefaultLifetime
+	if s, err := ui.PromptFile(SessionLifetimeFilename); err == nil && s != "" { // no prompt, just read
+		if sessionLifetime, err = time.ParseDuration(s); err != nil || sessionLifetime <= 0 {
+			ui.Fatalf("%s: %q isn't a positive duration like \"12h\" or \"168h\"", SessionLifetimeFilename, s)
+		}
+	}
+	ui.Printf("Intranet sessions last %v (write a different duration to %s to change this)", sessionLifetime, SessionLifetimeFilename)
+
 	// We've finished gathering configuration.
 	//
 	// Find or create the API Gateway v2-based Intranet.
@@ -162,14 +220,27 @@ func intranet(ctx context.Context, mgmtCfg, substrateCfg *awscfg.Config) (dnsDom
 		"AZURE_AD_TENANT_ID":                 tenantId,
 		"OAUTH_OIDC_CLIENT_ID":               clientId,
 		"OAUTH_OIDC_CLIENT_SECRET_TIMESTAMP": clientSecretTimestamp,
+		"OIDC_ISSUER":                        issuer,
+		"OIDC_ROLE_NAME_CLAIM":               roleNameClaim,
 		"OKTA_HOSTNAME":                      hostname,
 		"SELECTED_REGIONS":                   strings.Join(regions.Selected(), ","),
+		"SESSIONS_REGION":                    regions.Default(),
+		"SESSION_LIFETIME":                   sessionLifetime.String(),
 		"SUBSTRATE_PREFIX":                   naming.Prefix(),
 	}
+	if groupRoles != nil {
+		environment[oauthoidc.GroupRolesJSON] = groupRoles.String()
+	}
 	if distribution, err := awscloudfront.GetDistributionByName(ctx, substrateCfg, naming.Substrate); err == nil {
 		environment["DNS_DOMAIN_NAME"] = distribution.DomainName
 	}
 
+	// Sessions live in one table in the default region so that users' sessions
+	// are valid no matter which region's Intranet serves them.
+	ui.Spin("finding or creating the DynamoDB table for Intranet sessions")
+	ui.Must(sessions.EnsureTable(ctx, substrateCfg.Regional(regions.Default())))
+	ui.Stopf("%s in %s", sessions.TableName, regions.Default())
+
 	// Construct the Intranet in every region we're using. For legacy reasons
 	// this requires knowing the quality associated with the VPC created for
 	// the Substrate account (from when it was called the admin account and
@@ -268,7 +339,7 @@ function handler(event) {
 			delete event.request.cookies.id;
 		}
 
-		if (!event.request.cookies.a && event.request.uri !== "/credential-factory/fetch" && event.request.uri !== "/login") {
+		if (!event.request.cookies.a && !event.request.cookies.s && event.request.uri !== "/credential-factory/fetch" && event.request.uri !== "/login" && event.request.uri !== "/logout") {
 			var properties = Object.getOwnPropertyNames(event.request.querystring),
 				query = {};
 			for (var i = 0; i < properties.length; ++i) {
@@ -29,6 +29,7 @@ import (
 	"github.com/src-bin/substrate/policies"
 	"github.com/src-bin/substrate/regions"
 	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/scps"
 	"github.com/src-bin/substrate/tagging"
 	"github.com/src-bin/substrate/telemetry"
 	"github.com/src-bin/substrate/terraform"
@@ -41,18 +42,24 @@ import (
 
 const (
 	EnforceIMDSv2Filename    = "substrate.enforce-imdsv2"
-	ServiceControlPolicyName = "SubstrateServiceControlPolicy"
+	ServiceControlPolicyName = scps.Substrate
 )
 
 var (
 	runTerraform, autoApprove, noApply = new(bool), new(bool), new(bool)
 	providersLock                      = new(bool)
 	ignoreServiceQuotas                = new(bool)
+	plan                               = new(bool)
+
+	planFormat, planFormatFlag, planFormatCompletionFunc = cmdutil.FormatFlag(
+		cmdutil.FormatText,
+		[]cmdutil.Format{cmdutil.FormatJSON, cmdutil.FormatText},
+	)
 )
 
 func Command() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "setup [--terraform [--auto-approve|--no-apply] [--providers-lock]] [--ignore-service-quotas]",
+		Use:   "setup [--plan [--plan-format <format>]|--terraform [--auto-approve|--no-apply] [--providers-lock]] [--ignore-service-quotas]",
 		Short: "setup Substrate in your AWS organization",
 		Long: "`substrate setup`" + ` finds or creates your AWS organization, finds or creates the
 AWS accounts and IAM principals Substrate uses to manage your organization, and
@@ -65,12 +72,18 @@ to run repeatedly`,
 		DisableFlagsInUseLine: true,
 		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
 			return []string{
+				"--plan", "--plan-format",
 				"--terraform", "--auto-approve", "--no-apply", "--providers-lock",
 				"--ignore-service-quotas",
 				"--fully-interactive", "--minimally-interactive", "--non-interactive",
 			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
 		},
 	}
+	cmd.Flags().BoolVar(plan, "plan", false, "print the changes setup would make to your AWS organization without making any of them")
+	planFormatFlag.Name = "plan-format" // there's no other output to format
+	planFormatFlag.Usage = "with --plan, " + planFormatFlag.Usage
+	cmd.Flags().AddFlag(planFormatFlag)
+	cmd.RegisterFlagCompletionFunc(planFormatFlag.Name, planFormatCompletionFunc)
 	cmd.Flags().BoolVar(runTerraform, "terraform", false, "initialize and plan or apply Terraform in the special deploy and network accounts")
 	cmd.Flags().BoolVar(autoApprove, "auto-approve", false, "with --terraform, apply Terraform changes without waiting for confirmation")
 	cmd.Flags().BoolVar(noApply, "no-apply", false, "with --terraform, plan but do not apply Terraform changes")
@@ -90,6 +103,9 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 
 	//ui.Debug(cfg.MustGetCallerIdentity(ctx))
 	regions.Default()
+	if *plan {
+		startPlan(ctx, cfg)
+	}
 	ui.Must2(cfg.BootstrapCredentials(ctx)) // get from anywhere to IAM credentials so we can assume roles
 	mgmtCfg := awscfg.Must(cfg.AssumeManagementRole(
 		ctx,
@@ -299,9 +315,9 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	ui.Must(err)
 
 	// Ensure service control policies are enabled and that Substrate's is
-	// attached and up-to-date. This is pretty basic and may eventually be
-	// expanded into `substrate scp create|delete|list` commands; there's also
-	// an opportunity in managing tagging policies that requires more research.
+	// attached and up-to-date. Additional service control policies may be
+	// managed via `substrate scp create|delete|list`; there's also an
+	// opportunity in managing tagging policies that requires more research.
 	ui.Spin("configuring your organization's service control policy")
 	statements := []policies.Statement{
 
@@ -364,6 +380,10 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	))
 	ui.Stop("ok")
 
+	// Maybe organize service accounts into organizational units named after
+	// their environments and maybe qualities.
+	organizationalUnits(ctx, mgmtCfg, root, environments, qualities)
+
 	// Enable resource sharing throughout the organization.
 	ui.Spin("enabling resource sharing throughout your organization")
 	ui.Must(awsram.EnableSharingWithAwsOrganization(ctx, mgmtCfg))
@@ -390,6 +410,9 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	}
 	if substrateAccount == nil { // genuinely a new installation
 		ui.Stop("not found")
+		if cfg.Planning() {
+			planFatal(cfg, "the Substrate account doesn't exist yet")
+		}
 		ui.Spin("creating the Substrate account")
 		substrateAccount, err = awsorgs.EnsureSpecialAccount(ctx, cfg, accounts.Substrate)
 		ui.Must(err)
@@ -459,7 +482,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	}
 	mgmtRole, err := awsiam.EnsureRole(ctx, mgmtCfg, roles.Substrate, policies.AssumeRolePolicyDocument(&policies.Principal{
 		AWS: mgmtPrincipals,
-	}))
+	}), nil)
 	ui.Must(err)
 	ui.Must(awsiam.AttachRolePolicy(ctx, mgmtCfg, mgmtRole.Name, policies.AdministratorAccess))
 	//log.Print(jsonutil.MustString(mgmtRole))
@@ -467,7 +490,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	substrateRole, err := awsiam.EnsureRole(ctx, substrateCfg, roles.Substrate, policies.AssumeRolePolicyDocument(&policies.Principal{
 		AWS:     substratePrincipals,
 		Service: []string{"apigateway.amazonaws.com", "lambda.amazonaws.com"},
-	}))
+	}), nil)
 	ui.Must(err)
 	ui.Must(awsiam.AttachRolePolicy(ctx, substrateCfg, substrateRole.Name, policies.AdministratorAccess))
 	//log.Print(jsonutil.MustString(substrateRole))
@@ -497,6 +520,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 			aws.ToString(mgmtUser.Arn),
 			aws.ToString(substrateUser.Arn),
 		}}),
+		nil,
 	)
 	ui.Must(err)
 	ui.Must(awsiam.AttachRolePolicy(ctx, mgmtCfg, mgmtRole.Name, policies.AdministratorAccess))
@@ -511,7 +535,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 		},
 		Service: []string{"apigateway.amazonaws.com", "lambda.amazonaws.com"},
 	})
-	substrateRole, err = awsiam.EnsureRole(ctx, substrateCfg, roles.Substrate, substrateAssumeRolePolicy)
+	substrateRole, err = awsiam.EnsureRole(ctx, substrateCfg, roles.Substrate, substrateAssumeRolePolicy, nil)
 	ui.Must(err)
 	ui.Must(awsiam.AttachRolePolicy(ctx, substrateCfg, substrateRole.Name, policies.AdministratorAccess))
 	ui.Must(awsiam.AttachRolePolicy(ctx, substrateCfg, substrateRole.Name, policies.AmazonAPIGatewayPushToCloudWatchLogs))
@@ -531,6 +555,10 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 				break
 			}
 		}
+		if cfg.Planning() {
+			planMust(cfg, err)
+			break
+		}
 		time.Sleep(1e9) // TODO exponential backoff
 	}
 	for {
@@ -540,6 +568,10 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 				break
 			}
 		}
+		if cfg.Planning() {
+			planMust(cfg, err)
+			break
+		}
 		time.Sleep(1e9) // TODO exponential backoff
 	}
 	ui.Stop("ok")
@@ -563,6 +595,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 		mgmtCfg,
 		"CloudWatch-CrossAccountSharing-ListAccountsRole",
 		orgAssumeRolePolicy,
+		nil,
 		&policies.Document{
 			Statement: []policies.Statement{{
 				Action: []string{
@@ -618,6 +651,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 				}),
 				extraAdministrator,
 			),
+			nil,
 		)
 		ui.Must(err)
 		ui.Must(awsiam.AttachRolePolicy(ctx, deployCfg, deployRole.Name, policies.AdministratorAccess))
@@ -637,7 +671,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	if err != nil { // if tags are too eventually consistent
 		networkCfg, err = mgmtCfg.AssumeRole(ctx, aws.ToString(networkAccount.Id), roles.OrganizationAccountAccessRole, time.Hour)
 	}
-	ui.Must(err)
+	planMust(cfg, err)
 	networkRole, err := awsiam.EnsureRole(
 		ctx,
 		networkCfg,
@@ -655,6 +689,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 			}),
 			extraAdministrator,
 		),
+		nil,
 	)
 	ui.Must(err)
 	ui.Must(awsiam.AttachRolePolicy(ctx, networkCfg, networkRole.Name, policies.AdministratorAccess))
@@ -665,6 +700,10 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 				break
 			}
 		}
+		if cfg.Planning() {
+			planMust(cfg, err)
+			break
+		}
 		time.Sleep(1e9) // TODO exponential backoff
 	}
 	ui.Stop("ok")
@@ -686,6 +725,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 			}),
 			extraAdministrator,
 		),
+		nil,
 	)
 	ui.Must(err)
 	ui.Must(awsiam.AttachRolePolicy(ctx, mgmtCfg, orgAdminRole.Name, policies.AdministratorAccess))
@@ -704,6 +744,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 		mgmtCfg,
 		roles.OrganizationReader,
 		orgAssumeRolePolicy,
+		nil,
 		&policies.Document{
 			Statement: []policies.Statement{{
 				Action: []string{
@@ -719,7 +760,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	ui.Must(err)
 	ui.Spin("testing the OrganizationReader role (because AWS IAM is eventually consistent)")
 	for {
-		if _, err := substrateCfg.OrganizationReader(ctx); err == nil { // same config as terraform.EnsureStateManager
+		if _, err := substrateCfg.OrganizationReader(ctx); err == nil || cfg.Planning() { // same config as terraform.EnsureStateManager
 			break
 		}
 		time.Sleep(1e9) // TODO exponential backoff
@@ -738,7 +779,7 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 		} else {
 			_, err = networkCfg.AssumeSpecialRole(ctx, accounts.Deploy, roles.TerraformStateManager, time.Hour)
 		}
-		if err == nil {
+		if err == nil || cfg.Planning() {
 			break
 		}
 		time.Sleep(1e9) // TODO exponential backoff
@@ -835,6 +876,13 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	// If we find an IAM Identity Center installation, take it under our wing.
 	sso(ctx, mgmtCfg)
 
+	// Stop here if we're planning since there's nothing new to render into
+	// the cheat sheet and nothing new to commit to version control.
+	if cfg.Planning() {
+		printPlan(cfg)
+		return
+	}
+
 	// Render a "cheat sheet" of sorts that has all the account numbers, role
 	// names, and role ARNs that folks might need to get the job done.
 	//
@@ -880,6 +928,9 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, _ []string,
 	case oauthoidc.AzureAD:
 		ui.Print("- onboard your coworkers by setting the AWS.RoleName custom security attribute in Azure AD")
 		ui.Print("  (see <https://docs.substrate.tools/substrate/bootstrapping/integrating-your-identity-provider/azure-ad> for details)")
+	case oauthoidc.Generic:
+		ui.Printf("- onboard your coworkers by mapping each one's IAM role name into the claim named in %s (AWS_RoleName if it's missing) in your IdP", OIDCRoleNameClaimFilename)
+		ui.Print("  (see <https://docs.substrate.tools/substrate/bootstrapping/integrating-your-identity-provider/generic-oidc> for details)")
 	case oauthoidc.Google:
 		ui.Print("- onboard your coworkers by setting the AWS.RoleName custom attribute in Google Workspace")
 		ui.Print("  (see <https://docs.substrate.tools/substrate/bootstrapping/integrating-your-identity-provider/google> for details)")
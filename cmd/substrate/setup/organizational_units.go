@@ -0,0 +1,110 @@
+package setup
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/naming"
+	"github.com/src-bin/substrate/tagging"
+	"github.com/src-bin/substrate/ui"
+)
+
+// organizationalUnits optionally keeps service accounts in organizational
+// units named after their environments and, optionally, their qualities,
+// which allows service control policies and tag policies to be attached per
+// environment instead of per account. It reports and fixes any accounts
+// that have drifted out of the organizational unit where they belong, which
+// can happen when someone moves them in the AWS Console.
+func organizationalUnits(
+	ctx context.Context,
+	mgmtCfg *awscfg.Config,
+	root *awsorgs.Root,
+	environments, qualities []string,
+) {
+	byEnvironment, err := ui.ConfirmFile(
+		accounts.OrganizationalUnitsFilename,
+		`do you want to organize your service accounts into organizational units named after their environments? (yes/no; answering "yes" allows you to attach service control policies per environment)`,
+	)
+	ui.Must(err)
+	if !byEnvironment {
+		return
+	}
+	if len(qualities) > 1 {
+		_, err = ui.ConfirmFile(
+			accounts.QualityOrganizationalUnitsFilename,
+			`do you want to further organize your service accounts into organizational units named after their qualities within each environment? (yes/no)`,
+		)
+		ui.Must(err)
+	}
+
+	ui.Spin("placing service accounts in organizational units")
+	_, serviceAccounts, _, _, _, _, _, err := accounts.Grouped(ctx, mgmtCfg)
+	ui.Must(err)
+
+	// List each organizational unit's accounts once instead of asking for
+	// every account's parent, which leaves DescribeParent for the few
+	// accounts that have drifted.
+	destinations := make(map[string]string)      // environment and quality to organizational unit ID
+	children := make(map[string]map[string]bool) // organizational unit ID to account IDs
+	var moved int
+	for _, account := range serviceAccounts {
+		environment, quality := account.Tags[tagging.Environment], account.Tags[tagging.Quality]
+		if environment == "" || quality == "" {
+			continue // don't overreach into not-quite-Substrate-managed accounts
+		}
+		key := environment + " " + quality
+		destinationParentId, ok := destinations[key]
+		if !ok {
+			destinationParentId, err = accounts.EnsureOrganizationalUnit(ctx, mgmtCfg, environment, quality)
+			ui.Must(err)
+			destinations[key] = destinationParentId
+		}
+		if _, ok := children[destinationParentId]; !ok {
+			accountIds, err := awsorgs.ListAccountIdsForParent(ctx, mgmtCfg, destinationParentId)
+			ui.Must(err)
+			children[destinationParentId] = make(map[string]bool)
+			for _, accountId := range accountIds {
+				children[destinationParentId][accountId] = true
+			}
+		}
+		accountId := aws.ToString(account.Id)
+		if children[destinationParentId][accountId] {
+			continue
+		}
+		sourceParentId, err := awsorgs.DescribeParent(ctx, mgmtCfg, accountId)
+		ui.Must(err)
+		ui.Must(awsorgs.MoveAccount(ctx, mgmtCfg, accountId, sourceParentId, destinationParentId))
+		ui.Printf("moved %s from %s to %s", account, sourceParentId, destinationParentId)
+		moved++
+	}
+	if moved == 0 {
+		ui.Stop("ok")
+	} else {
+		ui.Stopf("moved %d account(s) that had drifted", moved)
+	}
+
+	// Report, but don't delete, Substrate-managed organizational units for
+	// environments that no longer exist. They may still have policies
+	// attached or accounts in them that warrant a human's attention.
+	ous, err := awsorgs.ListOrganizationalUnitsForParent(ctx, mgmtCfg, aws.ToString(root.Id))
+	ui.Must(err)
+	for _, ou := range ous {
+		tags, err := awsorgs.ListOrganizationalUnitTags(ctx, mgmtCfg, aws.ToString(ou.Id))
+		ui.Must(err)
+		if tags[tagging.Manager] != tagging.Substrate {
+			continue
+		}
+		if naming.Index(environments, tags[tagging.Environment]) < 0 {
+			ui.Printf(
+				"organizational unit %s (%s) is for an environment that's no longer in %s; consider deleting it",
+				aws.ToString(ou.Id),
+				aws.ToString(ou.Name),
+				naming.EnvironmentsFilename,
+			)
+		}
+	}
+
+}
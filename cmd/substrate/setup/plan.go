@@ -0,0 +1,66 @@
+package setup
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/cmdutil"
+	"github.com/src-bin/substrate/jsonutil"
+	"github.com/src-bin/substrate/ui"
+)
+
+// planFatal prints the changes planned so far, which are still worth
+// reviewing, and then explains why planning can't continue.
+func planFatal(cfg *awscfg.Config, reason string) {
+	printPlan(cfg)
+	ui.Fatalf("%s so `substrate setup --plan` can't plan any further; run `substrate setup` without --plan to create it", reason)
+}
+
+// planMust is ui.Must except, when planning, it assumes err is because
+// something setup would have created so far only exists in the plan.
+func planMust(cfg *awscfg.Config, err error) {
+	if err != nil && cfg.Planning() {
+		printPlan(cfg)
+		ui.Fatalf("%v, probably because it's only been created in this plan, so `substrate setup --plan` can't plan any further", err)
+	}
+	ui.Must(err)
+}
+
+// printPlan prints the changes planned so far in the format given by
+// --plan-format, JSON going to standard output, including each change's
+// complete API request input, so that it may be reviewed by a program.
+func printPlan(cfg *awscfg.Config) {
+	switch *planFormat {
+	case cmdutil.FormatJSON:
+		jsonutil.PrettyPrint(os.Stdout, cfg.Plan().Changes())
+	case cmdutil.FormatText:
+		ui.Print("")
+		ui.Print("`substrate setup --plan` would make the following changes to your AWS organization:")
+		cfg.Plan().Print()
+		ui.Print("")
+	default:
+		ui.Fatal(cmdutil.FormatFlagError(*planFormat))
+	}
+	ui.Print("no changes were made; run `substrate setup` without --plan to make them")
+}
+
+// startPlan causes cfg and every Config derived from it to record changes
+// instead of making them. Root credentials are refused because setup would
+// immediately exchange them for an IAM user's access key, which can't be
+// planned.
+func startPlan(ctx context.Context, cfg *awscfg.Config) {
+	if *runTerraform {
+		ui.Fatal("--plan and --terraform are mutually exclusive")
+	}
+	callerIdentity, err := cfg.GetCallerIdentity(ctx)
+	if err != nil {
+		ui.Fatalf("--plan requires AWS credentials from your organization's management account (%v)", err)
+	}
+	if strings.HasSuffix(aws.ToString(callerIdentity.Arn), ":root") {
+		ui.Fatal("--plan can't use root credentials; use credentials for an IAM user or role in your organization's management account")
+	}
+	cfg.StartPlan()
+}
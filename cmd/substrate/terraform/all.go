@@ -0,0 +1,79 @@
+package terraform
+
+import (
+	"path/filepath"
+
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/naming"
+	"github.com/src-bin/substrate/regions"
+	"github.com/src-bin/substrate/tagging"
+	"github.com/src-bin/substrate/terraform"
+	"github.com/src-bin/substrate/veqp"
+)
+
+// rootModules lists the root modules, global and/or in the given regions, of
+// every selected account that has any. The management and audit accounts and
+// accounts selected by number don't run Terraform so they're left out. The
+// network account's root modules are limited to the environment and quality
+// pairs in eqs and it has no global root module.
+func rootModules(
+	selected []accounts.AccountWithSelectors,
+	eqs []veqp.EnvironmentQualityPair,
+	global bool,
+	regionNames []string,
+) (dirnames []string) {
+	regional := func(dirname string) {
+		for _, region := range regionNames {
+			dirnames = append(dirnames, filepath.Join(dirname, region))
+		}
+	}
+	globalAndRegional := func(dirname string) {
+		if global {
+			dirnames = append(dirnames, filepath.Join(dirname, regions.Global))
+		}
+		regional(dirname)
+	}
+	for _, a := range selected {
+		if a.Account == nil {
+			continue // e.g. --special "deploy" in an organization without one
+		}
+		switch {
+		case contains(a.Selectors, "management"), contains(a.Selectors, "number"):
+		case contains(a.Selectors, "special"):
+			switch special(a) {
+			case accounts.Deploy:
+				globalAndRegional(filepath.Join(terraform.RootModulesDirname, accounts.Deploy))
+			case accounts.Network:
+				for _, eq := range eqs {
+					regional(filepath.Join(terraform.RootModulesDirname, accounts.Network, eq.Environment, eq.Quality))
+				}
+			}
+		case contains(a.Selectors, "substrate"), contains(a.Selectors, "humans"):
+			globalAndRegional(filepath.Join(terraform.RootModulesDirname, naming.Admin, a.Account.Tags[tagging.Quality]))
+		default:
+			globalAndRegional(filepath.Join(
+				terraform.RootModulesDirname,
+				a.Account.Tags[tagging.Domain],
+				a.Account.Tags[tagging.Environment],
+				a.Account.Tags[tagging.Quality],
+			))
+		}
+	}
+	return
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func special(a accounts.AccountWithSelectors) string {
+	if t := a.Account.Tags[tagging.SubstrateType]; t != "" {
+		return t
+	}
+	return a.Account.Tags[tagging.SubstrateSpecialAccount]
+}
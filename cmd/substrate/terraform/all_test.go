@@ -0,0 +1,61 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/tagging"
+	"github.com/src-bin/substrate/veqp"
+)
+
+func TestRootModules(t *testing.T) {
+	selected := []accounts.AccountWithSelectors{
+		{Account: &awsorgs.Account{Tags: tagging.Map{
+			tagging.Domain:      "foo",
+			tagging.Environment: "staging",
+			tagging.Quality:     "default",
+		}}, Selectors: []string{"domain", "environment", "all-qualities"}},
+		{Account: &awsorgs.Account{Tags: tagging.Map{
+			tagging.SubstrateType: accounts.Network,
+		}}, Selectors: []string{"special"}},
+		{Account: &awsorgs.Account{Tags: tagging.Map{
+			tagging.SubstrateSpecialAccount: accounts.Deploy,
+		}}, Selectors: []string{"special"}},
+		{Account: &awsorgs.Account{Tags: tagging.Map{
+			tagging.Quality: "default",
+		}}, Selectors: []string{"substrate"}},
+		{Account: &awsorgs.Account{}, Selectors: []string{"management"}},
+		{Account: nil, Selectors: []string{"special"}},
+	}
+	eqs := []veqp.EnvironmentQualityPair{{Environment: "staging", Quality: "default"}}
+
+	actual := rootModules(selected, eqs, true, []string{"us-east-1", "us-west-2"})
+	expected := []string{
+		"root-modules/foo/staging/default/global",
+		"root-modules/foo/staging/default/us-east-1",
+		"root-modules/foo/staging/default/us-west-2",
+		"root-modules/network/staging/default/us-east-1",
+		"root-modules/network/staging/default/us-west-2",
+		"root-modules/deploy/global",
+		"root-modules/deploy/us-east-1",
+		"root-modules/deploy/us-west-2",
+		"root-modules/admin/default/global",
+		"root-modules/admin/default/us-east-1",
+		"root-modules/admin/default/us-west-2",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("actual: %q, expected: %q", actual, expected)
+	}
+
+	actual = rootModules(selected, eqs, true, nil)
+	expected = []string{
+		"root-modules/foo/staging/default/global",
+		"root-modules/deploy/global",
+		"root-modules/admin/default/global",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("--global actual: %q, expected: %q", actual, expected)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -17,28 +18,42 @@ import (
 	rootmodules "github.com/src-bin/substrate/cmd/substrate/terraform/root-modules"
 	"github.com/src-bin/substrate/cmdutil"
 	"github.com/src-bin/substrate/fileutil"
+	"github.com/src-bin/substrate/jsonutil"
 	"github.com/src-bin/substrate/naming"
 	"github.com/src-bin/substrate/regions"
+	"github.com/src-bin/substrate/table"
 	"github.com/src-bin/substrate/terraform"
 	"github.com/src-bin/substrate/ui"
+	"github.com/src-bin/substrate/veqp"
 )
 
 var (
-	domain, domainFlag, domainCompletionFunc                = cmdutil.DomainFlag("domain of an AWS account in which to run Terraform")
-	environment, environmentFlag, environmentCompletionFunc = cmdutil.EnvironmentFlag("environment of an AWS account in which to run Terraform")
-	quality, qualityFlag, qualityCompletionFunc             = cmdutil.QualityFlag("quality of an AWS account in which to run Terraform")
-	special                                                 = new(string)
-	substrate                                               = new(bool)
-	global                                                  = new(bool)
-	region                                                  = new(string)
+	selection                                = &accounts.Selection{}
+	_, _, domainCompletionFunc               = cmdutil.DomainFlag("")
+	_, _, environmentCompletionFunc          = cmdutil.EnvironmentFlag("")
+	_, _, qualityCompletionFunc              = cmdutil.QualityFlag("")
+	all, failFast                            = new(bool), new(bool)
+	format, formatFlag, formatCompletionFunc = cmdutil.FormatFlag(
+		cmdutil.FormatText,
+		[]cmdutil.Format{cmdutil.FormatJSON, cmdutil.FormatText},
+	)
+	global      = new(bool)
+	parallelism = new(int)
+	region      = new(string)
 )
 
 func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use: `terraform --domain <domain> --environment <environment> [--quality <quality>] [--global|--region <region>] init|plan|apply|... [...]
   substrate terraform --special <special> [--global|--region <region>] init|plan|apply|... [...]
-  substrate terraform --substrate [--global|--region <region>] init|plan|apply|... [...]`,
-		Short: "run Terraform in a specific AWS account",
+  substrate terraform --substrate [--global|--region <region>] init|plan|apply|... [...]
+  substrate terraform --all [account selection flags] [--global|--region <region>] [--fail-fast] [--parallelism <n>] init|plan|apply -auto-approve|... [...]
+  substrate terraform [--all] [...] --format json plan|show
+    [account selection flags]:  [--all-domains|--domain <domain> [...]]
+                                [--all-environments|--environment <environment> [...]]
+                                [--all-qualities|--quality <quality> [...]]
+                                [--special <special> [...]] [--substrate]`,
+		Short: "run Terraform in a specific AWS account or, in parallel, in many",
 		Long:  ``,
 		Args:  cobra.ArbitraryArgs,
 		Run: func(cmd *cobra.Command, args []string) {
@@ -50,7 +65,7 @@ func Command() *cobra.Command {
 			// Once we're past the account selection arguments, defer to
 			// Terraform's own autocomplete, which is not very good. But if it
 			// gets better, we'll be ready!
-			if (*domain != "" && *environment != "" || *special != "" || *substrate) && (*global || *region != "") {
+			if (len(selection.Domains) > 0 && len(selection.Environments) > 0 || len(selection.Specials) > 0 || selection.Substrate || *all) && (*global || *region != "" || *all) {
 				b := &bytes.Buffer{}
 				cmd := exec.Command("terraform")
 				cmd.Env = append(
@@ -65,22 +80,39 @@ func Command() *cobra.Command {
 			return []string{
 				"--domain", "--environment", "--quality",
 				"--special", "--substrate",
+				"--all", "--all-domains", "--all-environments", "--all-qualities", "--fail-fast",
+				"--format", "--parallelism",
 			}, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
 		},
 	}
-	cmd.Flags().AddFlag(domainFlag)
-	cmd.RegisterFlagCompletionFunc(domainFlag.Name, domainCompletionFunc)
-	cmd.Flags().AddFlag(environmentFlag)
-	cmd.RegisterFlagCompletionFunc(environmentFlag.Name, environmentCompletionFunc)
-	cmd.Flags().AddFlag(qualityFlag)
-	cmd.RegisterFlagCompletionFunc(qualityFlag.Name, qualityCompletionFunc)
-	cmd.Flags().StringVar(special, "special", "", `name of a special AWS account in which to run Terraform ("deploy" or "network")`)
+	cmd.Flags().AddFlagSet(selection.FlagSet(accounts.SelectionFlagsUsage{
+		AllDomains:      "with --all, run Terraform in all domains (potentially constrained by --environment and/or --quality)",
+		Domains:         "domain of an AWS account in which to run Terraform (may be repeated with --all)",
+		AllEnvironments: "with --all, run Terraform in all environments (potentially constrained by --domain and/or --quality)",
+		Environments:    "environment of an AWS account in which to run Terraform (may be repeated with --all)",
+		AllQualities:    "with --all, run Terraform in all qualities (potentially constrained by --domain and/or --environment)",
+		Qualities:       "quality of an AWS account in which to run Terraform (may be repeated with --all)",
+		Substrate:       "run Terraform in the AWS organization's Substrate account",
+		Management:      "not supported; the management AWS account doesn't have any Terraform root modules",
+		Specials:        `name of a special AWS account in which to run Terraform ("deploy" or "network"; may be repeated with --all)`,
+		Numbers:         "not supported; AWS accounts selected by number don't have any Terraform root modules",
+	}))
+	cmd.Flags().MarkHidden("management")
+	cmd.Flags().MarkHidden("number")
+	cmd.RegisterFlagCompletionFunc("domain", domainCompletionFunc)
+	cmd.RegisterFlagCompletionFunc("environment", environmentCompletionFunc)
+	cmd.RegisterFlagCompletionFunc("quality", qualityCompletionFunc)
 	cmd.RegisterFlagCompletionFunc("special", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
 		return []string{"deploy", "network"}, cobra.ShellCompDirectiveNoFileComp
 	})
-	cmd.Flags().BoolVar(substrate, "substrate", false, "run Terraform in the AWS organization's Substrate account")
-	cmd.Flags().BoolVarP(global, "global", "g", false, "run Terraform in a global root module")
-	cmd.Flags().StringVarP(region, "region", "r", "", "name of the region in which to run Terraform")
+	cmd.Flags().BoolVar(all, "all", false, "run Terraform in parallel in every root module of every selected AWS account or, if no accounts are selected, of every AWS account")
+	cmd.Flags().BoolVar(failFast, "fail-fast", false, "with --all, interrupt every other root module as soon as one fails")
+	cmd.Flags().IntVar(parallelism, "parallelism", runtime.NumCPU(), "with --all, maximum number of root modules in which to run Terraform at once (lower it to stay under AWS or other providers' API rate limits)")
+	formatFlag.Usage += `; with json, plan saves a plan in each root module, show reads the plans saved by an earlier plan or ` + "`substrate account update --terraform --no-apply`" + `, and both print a summary of the resources those plans would add, change, and destroy`
+	cmd.Flags().AddFlag(formatFlag)
+	cmd.RegisterFlagCompletionFunc(formatFlag.Name, formatCompletionFunc)
+	cmd.Flags().BoolVarP(global, "global", "g", false, "run Terraform in a global root module (with --all, only in global root modules)")
+	cmd.Flags().StringVarP(region, "region", "r", "", "name of the region in which to run Terraform (with --all, only in this region's root modules)")
 	cmd.RegisterFlagCompletionFunc("region", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
 		return regions.Selected(), cobra.ShellCompDirectiveNoFileComp
 	})
@@ -93,49 +125,67 @@ func Command() *cobra.Command {
 }
 
 func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, args []string, _ io.Writer) {
-	if *environment != "" && *quality == "" {
-		*quality = cmdutil.QualityForEnvironment(*environment)
+	if *all {
+		mainAll(ctx, cfg, args)
+		return
 	}
-	if (*domain == "" || *environment == "" || *quality == "") && *special == "" && !*substrate {
+	if selection.AllDomains || selection.AllEnvironments || selection.AllQualities {
+		ui.Fatal("--all-domains, --all-environments, and --all-qualities are only allowed with --all")
+	}
+	if len(selection.Domains) > 1 || len(selection.Environments) > 1 || len(selection.Qualities) > 1 || len(selection.Specials) > 1 {
+		ui.Fatal("use --all to run Terraform in more than one AWS account")
+	}
+	if selection.Management || len(selection.Numbers) > 0 {
+		ui.Fatal("--management and --number are not supported because those AWS accounts don't have any Terraform root modules")
+	}
+	domain, environment, quality, special := first(selection.Domains), first(selection.Environments), first(selection.Qualities), first(selection.Specials)
+	substrate := selection.Substrate
+	if environment != "" && quality == "" {
+		quality = cmdutil.QualityForEnvironment(environment)
+	}
+	if qualities, _ := naming.Qualities(); quality == "" && len(qualities) == 1 {
+		quality = qualities[0]
+	}
+	if (domain == "" || environment == "" || quality == "") && special == "" && !substrate {
 		ui.Fatal(`one of --domain "..." --environment "..." --quality "..." or --special "..." or --substrate is required`)
 	}
-	if *domain != "" && *special != "" {
+	if domain != "" && special != "" {
 		ui.Fatal(`can't mix --domain "..." with --special "..."`)
 	}
-	if (*domain != "" || *environment != "" /* || *quality != "" */) && *substrate {
+	if (domain != "" || environment != "" /* || quality != "" */) && substrate {
 		ui.Fatal(`can't mix --domain "..." --environment "..." --quality "..." with --substrate`)
 	}
-	if *special != "" && *substrate {
+	if special != "" && substrate {
 		ui.Fatal(`can't mix --special "..." with --substrate`)
 	}
 	if !*global && *region == "" {
-		ui.Fatal("one of --global or --region \"...\" is required; use `substrate account update` to run all of an account's root modules")
+		ui.Fatal("one of --global or --region \"...\" is required; use --all or `substrate account update` to run all of an account's root modules")
 	}
 
 	dirname := terraform.RootModulesDirname
-	if *domain != "" && *environment != "" && *quality != "" {
-		dirname = filepath.Join(dirname, *domain, *environment, *quality)
-	} else if *special != "" {
-		switch *special {
+	if domain != "" && environment != "" && quality != "" {
+		dirname = filepath.Join(dirname, domain, environment, quality)
+	} else if special != "" {
+		switch special {
 		case accounts.Deploy:
 			dirname = filepath.Join(dirname, accounts.Deploy)
 		case accounts.Network:
-			if *environment == "" || *quality == "" {
+			if environment == "" || quality == "" {
 				ui.Fatal(`--environment "..." is required with --special "network"`)
 			}
-			if *quality == "" {
+			if quality == "" {
 				ui.Fatal(`--quality "..." is required with --special "network"`)
 			}
-			dirname = filepath.Join(dirname, accounts.Network, *environment, *quality)
+			dirname = filepath.Join(dirname, accounts.Network, environment, quality)
 		default:
 			ui.Fatalf("--special %q is invalid", special)
 		}
-	} else if *substrate {
-		if *quality == "" {
+	} else if substrate {
+		if quality == "" {
 			substrateAccount := ui.Must2(cfg.FindSubstrateAccount(ctx))
-			*quality = ui.Must2(substrateAccount.Quality())
+			quality = ui.Must2(substrateAccount.Quality())
 		}
-		dirname = filepath.Join(dirname, naming.Admin, *quality)
+		dirname = filepath.Join(dirname, naming.Admin, quality)
 	}
 	if *global {
 		dirname = filepath.Join(dirname, regions.Global)
@@ -144,13 +194,182 @@ func Main(ctx context.Context, cfg *awscfg.Config, _ *cobra.Command, args []stri
 	}
 	//ui.PrintWithCaller(dirname)
 
+	if *format == cmdutil.FormatJSON {
+		mainJSON(ctx, []string{dirname}, args)
+		return
+	}
+
+	removeStalePlans([]string{dirname}, args, true)
 	cmd := exec.Command("terraform", args...)
 	cmd.Dir = dirname
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	//ui.PrintfWithCaller("%+v", cmd)
-	if err := cmd.Run(); err != nil {
+	err := cmd.Run()
+	removeStalePlans([]string{dirname}, args, false)
+	if err != nil {
+		ui.Fatal(err)
+	}
+}
+
+func mainAll(ctx context.Context, cfg *awscfg.Config, args []string) {
+	if len(args) == 0 {
+		ui.Fatal("a Terraform subcommand, e.g. plan or apply, is required")
+	}
+	if (args[0] == "apply" || args[0] == "destroy") && !contains(args, "-auto-approve") {
+		ui.Fatalf("`terraform %s` requires -auto-approve with --all because there's no way to answer every root module's prompt", args[0])
+	}
+	if selection.Management || len(selection.Numbers) > 0 {
+		ui.Fatal("--management and --number are not supported because those AWS accounts don't have any Terraform root modules")
+	}
+	if *parallelism < 1 {
+		ui.Fatal("--parallelism must be at least 1")
+	}
+
+	// With no account selection flags, select every account that has root
+	// modules. With some, omitting any of --domain, --environment, or
+	// --quality selects all of them, the same as --all-domains et al.
+	if selection.Empty() {
+		selection.AllDomains, selection.AllEnvironments, selection.AllQualities = true, true, true
+		selection.Substrate = true
+		selection.Specials = []string{accounts.Deploy, accounts.Network}
+	} else if err := selection.Validate(); err != nil {
 		ui.Fatal(err)
+	} else if selection.AllDomains || len(selection.Domains) > 0 ||
+		selection.AllEnvironments || len(selection.Environments) > 0 ||
+		selection.AllQualities || len(selection.Qualities) > 0 {
+		selection.AllDomains = selection.AllDomains || len(selection.Domains) == 0
+		selection.AllEnvironments = selection.AllEnvironments || len(selection.Environments) == 0
+		selection.AllQualities = selection.AllQualities || len(selection.Qualities) == 0
+	}
+
+	cfg = awscfg.Must(cfg.OrganizationReader(ctx))
+	ui.Spin("finding AWS accounts and their Terraform root modules")
+	selected, _, err := selection.Partition(ctx, cfg)
+	ui.Must(err)
+	veqpDoc, err := veqp.ReadDocument()
+	ui.Must(err)
+	var eqs []veqp.EnvironmentQualityPair
+	for _, eq := range veqpDoc.ValidEnvironmentQualityPairs {
+		if (selection.AllEnvironments || len(selection.Environments) == 0 || contains(selection.Environments, eq.Environment)) &&
+			(selection.AllQualities || len(selection.Qualities) == 0 || contains(selection.Qualities, eq.Quality)) {
+			eqs = append(eqs, eq)
+		}
+	}
+	var regionNames []string
+	if *region != "" {
+		regionNames = []string{*region}
+	} else if !*global {
+		regionNames = regions.Selected()
+	}
+	var dirnames []string
+	for _, dirname := range rootModules(selected, eqs, *global || *region == "", regionNames) {
+		if fileutil.IsDir(dirname) {
+			dirnames = append(dirnames, dirname)
+		}
+	}
+	ui.Stopf("found %d", len(dirnames))
+	if len(dirnames) == 0 {
+		ui.Fatal("no Terraform root modules match the account selection flags")
+	}
+
+	if *format == cmdutil.FormatJSON {
+		mainJSON(ctx, dirnames, args)
+		return
+	}
+
+	ui.Printf("running `terraform %s` in %d root modules", strings.Join(args, " "), len(dirnames))
+	removeStalePlans(dirnames, args, true)
+	results := terraform.Parallel(ctx, dirnames, args, *parallelism, *failFast, os.Stdout)
+	removeStalePlans(dirnames, args, false)
+
+	counts := make(map[terraform.Outcome]int)
+	cells := [][]string{{"Root module", "Result"}}
+	for _, r := range results {
+		counts[r.Outcome]++
+		cells = append(cells, []string{r.Dirname, string(r.Outcome)})
+	}
+	table.Ftable(os.Stdout, cells)
+	ui.Printf(
+		"%d root modules with changes, %d without, %d failed, %d skipped",
+		counts[terraform.Changes], counts[terraform.NoChanges], counts[terraform.Failed], counts[terraform.Skipped],
+	)
+	if counts[terraform.Failed] > 0 {
+		ui.Fatalf("`terraform %s` failed in %d root modules", args[0], counts[terraform.Failed])
+	}
+}
+
+// mainJSON prints a machine-readable summary of the plans, saved in each of
+// dirnames, that are about to be created by `terraform plan` or that already
+// exist for `terraform show`.
+func mainJSON(ctx context.Context, dirnames []string, args []string) {
+	if len(args) == 0 || args[0] != "plan" && args[0] != "show" {
+		ui.Fatal("--format json is only supported with `terraform plan` and `terraform show`")
+	}
+	if args[0] == "show" && len(args) > 1 {
+		ui.Fatal("--format json shows the plans saved in " + terraform.PlanFilename + " so `terraform show` can't take any other arguments")
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-out") {
+			ui.Fatal("--format json saves plans in " + terraform.PlanFilename + " so `terraform plan` can't take -out")
+		}
+	}
+
+	// Terraform's own output goes to standard error to keep standard output
+	// clean for the summary.
+	results := make(map[string]*terraform.Result)
+	if args[0] == "plan" {
+		ui.Printf("running `terraform %s` in %d root modules", strings.Join(args, " "), len(dirnames))
+		removeStalePlans(dirnames, args, true)
+		for _, r := range terraform.Parallel(ctx, dirnames, append(args, "-input=false", "-out="+terraform.PlanFilename), *parallelism, *failFast, os.Stderr) {
+			results[r.Dirname] = r
+		}
+	}
+
+	var failed int
+	summaries := make([]*terraform.PlanSummary, len(dirnames))
+	for i, dirname := range dirnames {
+		if r, ok := results[dirname]; ok && (r.Outcome == terraform.Failed || r.Outcome == terraform.Skipped) {
+			summaries[i] = terraform.NewPlanSummary(dirname)
+			if r.Err != nil {
+				summaries[i].Error = r.Err.Error()
+			} else {
+				summaries[i].Error = string(r.Outcome)
+			}
+			failed++
+			continue
+		}
+		s, err := terraform.ShowPlan(dirname)
+		if err != nil {
+			s = terraform.NewPlanSummary(dirname)
+			s.Error = err.Error()
+			failed++
+		}
+		summaries[i] = s
+	}
+	jsonutil.PrettyPrint(os.Stdout, terraform.NewPlanSummaries(summaries))
+	if failed > 0 {
+		ui.Fatalf("`terraform %s` failed in %d root modules", args[0], failed)
+	}
+}
+
+func first(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	return ss[0]
+}
+
+// removeStalePlans removes the plans saved in each of dirnames, which become
+// stale, before `terraform plan`, which may fail and leave the old plan in
+// place, and after `terraform apply` and `terraform destroy`. Plans aren't
+// removed before applying in case they're what's being applied.
+func removeStalePlans(dirnames []string, args []string, before bool) {
+	if len(args) == 0 {
+		return
+	}
+	if before && args[0] == "plan" || !before && (args[0] == "apply" || args[0] == "destroy") {
+		ui.Must(terraform.RemovePlans(dirnames...))
 	}
 }
@@ -0,0 +1,96 @@
+package cmdutil
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/randutil"
+	"github.com/src-bin/substrate/ui"
+)
+
+const (
+	AWS_CONTAINER_AUTHORIZATION_TOKEN  = "AWS_CONTAINER_AUTHORIZATION_TOKEN"
+	AWS_CONTAINER_CREDENTIALS_FULL_URI = "AWS_CONTAINER_CREDENTIALS_FULL_URI"
+)
+
+// CredentialServer serves credentials using the ECS container credentials
+// protocol, which every AWS SDK will use when AWS_CONTAINER_CREDENTIALS_FULL_URI
+// and AWS_CONTAINER_AUTHORIZATION_TOKEN are set in its environment. It calls
+// Retrieve on every request so it can serve fresh credentials as the ones
+// it served before near expiration.
+type CredentialServer struct {
+	Retrieve func(context.Context) (aws.Credentials, error)
+	Token    string
+	URL      string
+}
+
+// ServeCredentials listens on 127.0.0.1 on the given port, or a random
+// available port if it's zero, and serves credentials from retrieve there
+// in the background, requiring a new random token.
+func ServeCredentials(port int, retrieve func(context.Context) (aws.Credentials, error)) (*CredentialServer, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	s := &CredentialServer{
+		Retrieve: retrieve,
+		Token:    randutil.String(),
+		URL:      fmt.Sprintf("http://%s/", ln.Addr()),
+	}
+	go func() {
+		ui.Fatal(http.Serve(ln, s))
+	}()
+	return s, nil
+}
+
+// Environ returns the environment variables, in the form os.Environ uses,
+// that point AWS SDKs at s.
+func (s *CredentialServer) Environ() []string {
+	return []string{
+		fmt.Sprintf("%s=%s", AWS_CONTAINER_CREDENTIALS_FULL_URI, s.URL),
+		fmt.Sprintf("%s=%s", AWS_CONTAINER_AUTHORIZATION_TOKEN, s.Token),
+	}
+}
+
+func (s *CredentialServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	authorization := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(authorization), []byte(s.Token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	creds, err := s.Retrieve(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if creds.Expired() {
+		http.Error(w, fmt.Sprintf("access key %s expired %s", creds.AccessKeyID, creds.Expires.Format(time.RFC3339)), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		AccessKeyId     string
+		SecretAccessKey string
+		Token           string // "Token" not "SessionToken" in this protocol
+		Expiration      string
+	}{
+		creds.AccessKeyID,
+		creds.SecretAccessKey,
+		creds.SessionToken,
+		creds.Expires.UTC().Format(time.RFC3339),
+	})
+}
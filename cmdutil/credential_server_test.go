@@ -0,0 +1,76 @@
+package cmdutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestCredentialServer(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	s := testCredentialServer(aws.Credentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+		CanExpire:       true,
+		Expires:         expires,
+	})
+
+	for _, authorization := range []string{"", "wrong", "Bearer wrong"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", authorization)
+		s.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Authorization: %q: %d", authorization, w.Code)
+		}
+	}
+
+	for _, authorization := range []string{"token", "Bearer token"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", authorization)
+		s.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Authorization: %q: %d %s", authorization, w.Code, w.Body)
+		}
+		var body struct{ AccessKeyId, SecretAccessKey, Token, Expiration string }
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.AccessKeyId != "AKIAEXAMPLE" || body.SecretAccessKey != "secret" || body.Token != "session" {
+			t.Fatalf("%+v", body)
+		}
+		if actual, err := time.Parse(time.RFC3339, body.Expiration); err != nil || !actual.Equal(expires) {
+			t.Fatalf("Expiration: %q", body.Expiration)
+		}
+	}
+}
+
+func TestCredentialServerExpired(t *testing.T) {
+	s := testCredentialServer(aws.Credentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+		CanExpire:       true,
+		Expires:         time.Now().Add(-time.Minute),
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "token")
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("%d", w.Code)
+	}
+}
+
+func testCredentialServer(creds aws.Credentials) *CredentialServer {
+	return &CredentialServer{
+		Retrieve: func(context.Context) (aws.Credentials, error) { return creds, nil },
+		Token:    "token",
+	}
+}
@@ -18,6 +18,8 @@ func PrintCredentials(format Format, creds aws.Credentials) {
 	isFish := CheckForFish()
 
 	switch format {
+	case FormatCredentialProcess:
+		PrintCredentialsCredentialProcess(creds)
 	case FormatEnv:
 		PrintCredentialsEnv(creds, isFish)
 	case FormatExport:
@@ -167,3 +169,33 @@ func PrintCredentialsJSON(creds aws.Credentials) {
 		1,
 	})
 }
+
+// PrintCredentialsCredentialProcess prints credentials exactly as the AWS
+// SDKs' credential_process expects them. Unlike PrintCredentialsJSON, it
+// leaves Expiration out when it's unknown, which the SDKs take to mean the
+// credentials don't expire, rather than claim they expired long ago, so that
+// SDKs refresh credentials when they actually expire and not on every call.
+func PrintCredentialsCredentialProcess(creds aws.Credentials) {
+	jsonutil.PrettyPrint(os.Stdout, newCredentialProcess(creds))
+}
+
+type credentialProcess struct {
+	Version         int
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string `json:",omitempty"`
+	Expiration      string `json:",omitempty"`
+}
+
+func newCredentialProcess(creds aws.Credentials) *credentialProcess {
+	cp := &credentialProcess{
+		Version:         1,
+		AccessKeyId:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}
+	if !creds.Expires.IsZero() {
+		cp.Expiration = creds.Expires.UTC().Format(time.RFC3339)
+	}
+	return cp
+}
@@ -0,0 +1,38 @@
+package cmdutil
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestCredentialProcess(t *testing.T) {
+	b, err := json.Marshal(newCredentialProcess(aws.Credentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		CanExpire:       true,
+		Expires:         time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("PST", -8*60*60)),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := string(b), `{"Version":1,"AccessKeyId":"AKIAEXAMPLE","SecretAccessKey":"secret","SessionToken":"token","Expiration":"2024-01-02T11:04:05Z"}`; actual != expected {
+		t.Fatalf("actual: %s, expected: %s", actual, expected)
+	}
+}
+
+func TestCredentialProcessUnknownExpiration(t *testing.T) {
+	b, err := json.Marshal(newCredentialProcess(aws.Credentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := string(b), `{"Version":1,"AccessKeyId":"AKIAEXAMPLE","SecretAccessKey":"secret"}`; actual != expected {
+		t.Fatalf("actual: %s, expected: %s", actual, expected)
+	}
+}
@@ -41,10 +41,14 @@ func EnvironmentFlag(usage string) (
 type Format string
 
 const (
+	FormatAWSConfig         Format = "aws-config"
+	FormatCSV               Format = "csv"
+	FormatCredentialProcess Format = "credential-process"
 	FormatEnv               Format = "env"
 	FormatExport            Format = "export"
 	FormatExportWithHistory Format = "export-with-history"
 	FormatJSON              Format = "json"
+	FormatMarkdown          Format = "markdown"
 	FormatShell             Format = "shell"
 	FormatText              Format = "text"
 )
@@ -57,6 +61,12 @@ func FormatFlag(defaultFormat Format, validFormats []Format) (
 	var ss []string
 	for _, v := range validFormats {
 		switch v {
+		case FormatAWSConfig:
+			ss = append(ss, "aws-config (profiles for ~/.aws/config)")
+		case FormatCSV:
+			ss = append(ss, "csv (for spreadsheets)")
+		case FormatCredentialProcess:
+			ss = append(ss, "credential-process (for credential_process in ~/.aws/config)")
 		case FormatExport:
 			ss = append(ss, "export (exported shell environment variables)")
 		case FormatExportWithHistory:
@@ -65,6 +75,8 @@ func FormatFlag(defaultFormat Format, validFormats []Format) (
 			ss = append(ss, "env (.env file)")
 		case FormatJSON:
 			ss = append(ss, "json")
+		case FormatMarkdown:
+			ss = append(ss, "markdown")
 		case FormatShell:
 			ss = append(ss, "shell (executable shell commands)")
 		case FormatText:
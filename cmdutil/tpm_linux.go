@@ -3,8 +3,146 @@
 
 package cmdutil
 
-import "github.com/aws/aws-sdk-go-v2/aws"
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
 
-// Linux users have to suck it up and set environment variables.
-func SetTPM(aws.Credentials) error { return nil }
-func SetenvFromTPM(string) error   { return nil }
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/fileutil"
+	"github.com/src-bin/substrate/naming"
+	"github.com/src-bin/substrate/secretservice"
+	"github.com/src-bin/substrate/ui"
+)
+
+// SetTPM stores credentials in the Secret Service (GNOME Keyring, KWallet,
+// etc.) if it's available and unlocked. Otherwise it stores them in a file
+// in $XDG_RUNTIME_DIR, which is private to the user and cleared when they
+// log out, encrypted with a key in ~/.config/substrate so neither the file
+// nor the key alone reveals them. Without either, Linux users have to suck it
+// up and set environment variables.
+func SetTPM(creds aws.Credentials) error {
+	prefix, err := naming.PrefixNoninteractive()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	if c, err := secretservice.Connect(); err == nil {
+		defer c.Close()
+		ui.Printf("storing access key %s (expires %s) in the Secret Service", creds.AccessKeyID, creds.Expires.Format(time.RFC3339))
+		err := c.Set(secretLabel(prefix), secretAttributes(prefix), data)
+		if err != secretservice.ErrLocked {
+			return err
+		}
+		ui.Print("the Secret Service is locked; falling back to an encrypted file")
+	}
+
+	pathname, ok := encryptedCredentialsPathname(prefix)
+	if !ok {
+		return nil
+	}
+	key, err := fileutil.Key(encryptionKeyPathname())
+	if err != nil {
+		return err
+	}
+	ui.Printf("storing access key %s (expires %s) in %s", creds.AccessKeyID, creds.Expires.Format(time.RFC3339), pathname)
+	if err := os.MkdirAll(filepath.Dir(pathname), 0700); err != nil {
+		return err
+	}
+	return fileutil.WriteEncryptedFile(pathname, data, key)
+}
+
+func SetenvFromTPM(subcommand string) error {
+	prefix, err := naming.PrefixNoninteractive()
+	if err != nil {
+		return nil // same as on macOS, fall back to missing-environment helper
+	}
+
+	data, where, err := getTPM(prefix)
+	if err != nil || data == nil {
+		return err
+	}
+	var creds aws.Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return err
+	}
+	if creds.Expires.Before(time.Now()) {
+		return nil
+	}
+	if err := awscfg.Setenv(creds); err != nil {
+		return err
+	}
+	if subcommand == "credentials" || subcommand == "whoami" {
+		ui.Printf("found access key %s (expires %s) in %s", creds.AccessKeyID, creds.Expires.Format(time.RFC3339), where)
+	}
+	return nil
+}
+
+// encryptedCredentialsPathname returns where to store credentials if the
+// Secret Service isn't available and false if $XDG_RUNTIME_DIR isn't set.
+func encryptedCredentialsPathname(prefix string) (string, bool) {
+	dirname := os.Getenv("XDG_RUNTIME_DIR")
+	if dirname == "" {
+		return "", false
+	}
+	return filepath.Join(dirname, naming.Substrate, prefix+".credentials"), true
+}
+
+func encryptionKeyPathname() string {
+	dirname, err := os.UserConfigDir()
+	if err != nil {
+		dirname = os.TempDir() // in the unlikely event there's no $HOME
+	}
+	return filepath.Join(dirname, naming.Substrate, "credentials.key")
+}
+
+// getTPM returns credentials stored by SetTPM and where they were found or
+// nil if there aren't any. Encrypted files that can't be decrypted, e.g.
+// because the key's been deleted, are ignored as if they weren't there.
+func getTPM(prefix string) ([]byte, string, error) {
+	if c, err := secretservice.Connect(); err == nil {
+		defer c.Close()
+		data, err := c.Get(secretAttributes(prefix))
+		if err == nil {
+			return data, "the Secret Service", nil
+		} else if err != secretservice.ErrNotFound && err != secretservice.ErrLocked {
+			return nil, "", err
+		}
+	}
+
+	pathname, ok := encryptedCredentialsPathname(prefix)
+	if !ok || !fileutil.Exists(pathname) {
+		return nil, "", nil
+	}
+	key, err := os.ReadFile(encryptionKeyPathname())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", err
+	}
+	data, err := fileutil.ReadEncryptedFile(pathname, key)
+	if _, ok := err.(fileutil.DecryptionError); ok {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", err
+	}
+	return data, pathname, nil
+}
+
+// secretAttributes are the same as the account and service the macOS
+// keychain stores credentials under.
+func secretAttributes(prefix string) map[string]string {
+	return map[string]string{"account": prefix, "service": naming.Substrate}
+}
+
+func secretLabel(prefix string) string {
+	return "Substrate AWS credentials for " + prefix
+}
@@ -0,0 +1,61 @@
+//go:build linux
+// +build linux
+
+package cmdutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/fileutil"
+	"github.com/src-bin/substrate/naming"
+)
+
+func TestTPMEncryptedFile(t *testing.T) {
+	dirname := t.TempDir()
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+filepath.Join(dirname, "no-such-bus"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dirname, "config"))
+	t.Setenv("XDG_RUNTIME_DIR", filepath.Join(dirname, "run"))
+	t.Setenv(awscfg.AWS_ACCESS_KEY_ID, "")
+	t.Setenv(awscfg.AWS_SECRET_ACCESS_KEY, "")
+	t.Setenv(awscfg.AWS_SESSION_TOKEN, "")
+	t.Setenv(awscfg.SUBSTRATE_CREDENTIALS_EXPIRATION, "")
+	if err := os.WriteFile(filepath.Join(dirname, naming.PrefixFilename), []byte("example\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dirname); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := SetTPM(aws.Credentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		CanExpire:       true,
+		Expires:         time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !fileutil.Exists(filepath.Join(dirname, "run", naming.Substrate, "example.credentials")) {
+		t.Fatal("encrypted credentials not found in $XDG_RUNTIME_DIR")
+	}
+	if !fileutil.Exists(filepath.Join(dirname, "config", naming.Substrate, "credentials.key")) {
+		t.Fatal("encryption key not found in $XDG_CONFIG_HOME")
+	}
+
+	if err := SetenvFromTPM("credentials"); err != nil {
+		t.Fatal(err)
+	}
+	if creds := awscfg.Getenv(); creds.AccessKeyID != "AKIAEXAMPLE" || creds.SecretAccessKey != "secret" || creds.SessionToken != "token" {
+		t.Fatalf("%+v", creds)
+	}
+}
@@ -0,0 +1,371 @@
+package customroles
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsiam"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/naming"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/tagging"
+	"github.com/src-bin/substrate/ui"
+	"github.com/src-bin/substrate/users"
+)
+
+// Role is the compact, singular definition of a custom IAM role that
+// `substrate role create` would be given to create it everywhere it exists.
+// The field names are part of the output of `substrate role list --format
+// json` so they mustn't change.
+type Role struct {
+	RoleName          string
+	AccountSelection  *accounts.Selection
+	AssumeRolePolicy  *roles.ManagedAssumeRolePolicy
+	PolicyAttachments *roles.ManagedPolicyAttachments
+}
+
+// Instance is a custom IAM role as it actually exists in one AWS account.
+type Instance struct {
+	Account    *awsorgs.Account
+	PolicyARNs []string
+	Role       *awsiam.Role
+}
+
+// IsCustom returns true if the role is a custom role created by `substrate
+// role create`, as opposed to one of Substrate's own roles or a role that
+// Substrate doesn't manage at all.
+func IsCustom(role *awsiam.Role) bool {
+	return role.Tags[tagging.Manager] == tagging.Substrate && role.Tags[tagging.SubstrateAccountSelectors] != ""
+}
+
+// Inspect gathers up all the Substrate-managed custom IAM roles from all the
+// AWS accounts in the whole organization and collates them into compact,
+// singular definitions. Roles are sorted by name and each role's instances
+// are sorted by ARN so that all output formats are stable.
+func Inspect(ctx context.Context, cfg *awscfg.Config) ([]*Role, map[string][]*Instance, error) {
+	allAccounts, err := cfg.ListAccounts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		firstErr  error
+		mu        sync.Mutex
+		roleNames []string
+		tree      = make(map[string][]*Instance)
+		wg        sync.WaitGroup
+	)
+	for _, account := range allAccounts {
+		wg.Add(1)
+		go func(account *awscfg.Account) {
+			defer wg.Done()
+			err := func() error {
+				accountCfg, err := account.Config(ctx, cfg, account.AdministratorRoleName(), time.Hour)
+				if err != nil {
+					return err
+				}
+				roles, err := awsiam.ListRoles(ctx, accountCfg)
+				if err != nil {
+					return err
+				}
+				for _, role := range roles { // TODO could possibly do this loop concurrently, too
+					if !IsCustom(role) {
+						continue
+					}
+					arns, err := awsiam.ListAttachedRolePolicies(ctx, accountCfg, role.Name)
+					if err != nil {
+						return err
+					}
+					mu.Lock()
+					if _, ok := tree[role.Name]; !ok {
+						roleNames = append(roleNames, role.Name)
+					}
+					tree[role.Name] = append(tree[role.Name], &Instance{
+						Account:    account,
+						PolicyARNs: arns,
+						Role:       role,
+					})
+					mu.Unlock()
+				}
+				return nil
+			}()
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(account)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	sort.Strings(roleNames)
+	for _, instances := range tree {
+		sort.Slice(instances, func(i, j int) bool {
+			return instances[i].Role.ARN < instances[j].Role.ARN
+		})
+	}
+
+	// Needed later but no need to parse it on every loop.
+	u, err := url.Parse(awsiam.GitHubActionsOAuthOIDCURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Get the Substrate account number for when we need it later to detect
+	// -humans from the statements in an assume-role policy.
+	substrateAccount, err := cfg.FindSubstrateAccount(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var substrateAccountId string
+	if substrateAccount != nil { // might be nil prior to running `substrate setup`
+		substrateAccountId = aws.ToString(substrateAccount.Id)
+	}
+
+	// Collate the Substrate-managed roles from all the AWS accounts into
+	// compact singular definitions of what they are.
+	collated := make([]*Role, len(roleNames))
+	for i, roleName := range roleNames {
+		collated[i] = &Role{
+			RoleName:          roleName,
+			AccountSelection:  &accounts.Selection{},
+			AssumeRolePolicy:  &roles.ManagedAssumeRolePolicy{},
+			PolicyAttachments: &roles.ManagedPolicyAttachments{},
+		}
+		managedAssumeRolePolicy := collated[i].AssumeRolePolicy
+		managedPolicyAttachments := collated[i].PolicyAttachments
+		selection := collated[i].AccountSelection
+
+		for _, instance := range tree[roleName] {
+			account := instance.Account
+			policyARNs := instance.PolicyARNs
+			role := instance.Role
+
+			// Derive the account selection flags from the selectors stored
+			// in the SubstrateAccountSelectors tag on the role.
+			selectors := strings.Split(role.Tags[tagging.SubstrateAccountSelectors], " ")
+			for _, selector := range selectors {
+				switch selector {
+				case "all-domains":
+					selection.AllDomains = true
+				case "domain":
+					domain := account.Tags[tagging.Domain]
+					if naming.Index(selection.Domains, domain) < 0 {
+						selection.Domains = append(selection.Domains, domain)
+					}
+				case "all-environments":
+					selection.AllEnvironments = true
+				case "environment":
+					environment := account.Tags[tagging.Environment]
+					if naming.Index(selection.Environments, environment) < 0 {
+						selection.Environments = append(selection.Environments, environment)
+					}
+				case "all-qualities":
+					selection.AllQualities = true
+				case "quality":
+					quality := account.Tags[tagging.Quality]
+					if naming.Index(selection.Qualities, quality) < 0 {
+						selection.Qualities = append(selection.Qualities, quality)
+					}
+				case "admin": // as the Substrate account was tagged long ago
+					selection.Substrate = true
+				case "substrate": // not "Substrate" because it's referencing the --substrate flag
+					selection.Substrate = true
+				case "humans":
+					selection.Humans = true
+				case "management":
+					selection.Management = true
+				case "special":
+					special := account.Tags[tagging.SubstrateSpecialAccount]
+					if naming.Index(selection.Specials, special) < 0 {
+						selection.Specials = append(selection.Specials, special)
+					}
+				case "number":
+					number := aws.ToString(account.Id)
+					if naming.Index(selection.Numbers, number) < 0 {
+						selection.Numbers = append(selection.Numbers, number)
+					}
+				default:
+					ui.Printf("unknown account selector %q", selector)
+				}
+			}
+			if err := selection.Sort(); err != nil {
+				return nil, nil, err
+			}
+
+			// Derive most assume-role policy flags from the statements in the
+			// assume-role policy.
+			for _, statement := range role.AssumeRolePolicy.Statement {
+
+				// -humans
+				var (
+					credentialFactory, intranet  bool // must have both of these...
+					substrateRole, substrateUser bool // ...or both of these...
+					ec2                          bool // ...and this to be detected as -humans
+				)
+				if statement.Principal == nil {
+					continue
+				}
+				for _, arn := range statement.Principal.AWS {
+
+					// From before 2023.08 but need to hang around potentially
+					// forever so we can detect -humans even on very old roles.
+					if strings.HasSuffix(arn, fmt.Sprintf(":user/%s", users.CredentialFactory)) {
+						credentialFactory = true
+					}
+					if strings.HasSuffix(arn, fmt.Sprintf(":role/%s", roles.Intranet)) {
+						intranet = true
+					}
+
+					if arn == roles.ARN(substrateAccountId, roles.Substrate) {
+						substrateRole = true
+					}
+					if arn == users.ARN(substrateAccountId, users.Substrate) {
+						substrateUser = true
+					}
+				}
+				for _, service := range statement.Principal.Service {
+					if service == "ec2.amazonaws.com" {
+						ec2 = true
+					}
+				}
+				if len(statement.Principal.AWS) == 2 && credentialFactory && intranet && len(statement.Principal.Service) == 1 && ec2 {
+					managedAssumeRolePolicy.Humans = true
+				} else if len(statement.Principal.AWS) == 2 && substrateRole && substrateUser && len(statement.Principal.Service) == 1 && ec2 {
+					managedAssumeRolePolicy.Humans = true
+				} else {
+
+					// -aws-service "..."
+					// This is nested in the else-statement because the
+					// "Service" array is overloaded - used by both -humans
+					// and -aws-service - and we need to tell the difference.
+					for _, service := range statement.Principal.Service {
+						if naming.Index(managedAssumeRolePolicy.AWSServices, service) < 0 {
+							managedAssumeRolePolicy.AWSServices = append(managedAssumeRolePolicy.AWSServices, service)
+						}
+					}
+
+				}
+
+				// -github-actions "..."
+				if len(statement.Principal.Federated) == 1 && strings.HasSuffix(statement.Principal.Federated[0], fmt.Sprintf("/%s", u.Host)) {
+					for operator, predicates := range statement.Condition {
+						if operator != "StringEquals" {
+							continue
+						}
+						for key, values := range predicates {
+							if key != fmt.Sprintf("%s:sub", u.Host) {
+								continue
+							}
+							for _, value := range values {
+								parts := strings.Split(value, ":")
+								if len(parts) != 3 || parts[0] != "repo" || parts[2] != "*" {
+									continue
+								}
+								if naming.Index(managedAssumeRolePolicy.GitHubActions, parts[1]) < 0 {
+									managedAssumeRolePolicy.GitHubActions = append(managedAssumeRolePolicy.GitHubActions, parts[1])
+								}
+							}
+						}
+					}
+				}
+
+				// --oidc-provider "..." --oidc-subject "..." [--oidc-audience "..."]
+				// This also covers --gitlab-ci and --circleci, which are
+				// just presets for these flags.
+				if len(statement.Principal.Federated) == 1 && !strings.HasSuffix(statement.Principal.Federated[0], fmt.Sprintf("/%s", u.Host)) {
+					_, name, ok := strings.Cut(statement.Principal.Federated[0], ":oidc-provider/")
+					if !ok {
+						continue
+					}
+					trust := roles.OIDCTrust{Provider: "https://" + name}
+					for operator, predicates := range statement.Condition {
+						if operator != "StringLike" && operator != "StringEquals" {
+							continue
+						}
+						for key, values := range predicates {
+							switch key {
+							case fmt.Sprintf("%s:aud", name):
+								trust.Audiences = append(trust.Audiences, values...)
+							case fmt.Sprintf("%s:sub", name):
+								trust.Subjects = append(trust.Subjects, values...)
+							}
+						}
+					}
+					if len(trust.Audiences) == 1 && trust.Audiences[0] == roles.DefaultOIDCAudience {
+						trust.Audiences = nil
+					}
+					if len(trust.Subjects) > 0 {
+						managedAssumeRolePolicy.OIDC = append(managedAssumeRolePolicy.OIDC, trust)
+					}
+				}
+
+			}
+
+			// Derive the -assume-role-policy flag from the
+			// SubstrateAssumeRolePolicyFilenames tag, if present.
+			if filenames, ok := role.Tags[tagging.SubstrateAssumeRolePolicyFilenames]; ok && filenames != "" {
+				for _, filename := range strings.Split(filenames, " ") {
+					if naming.Index(managedAssumeRolePolicy.Filenames, filename) < 0 {
+						managedAssumeRolePolicy.Filenames = append(managedAssumeRolePolicy.Filenames, filename)
+					}
+				}
+			}
+
+			// Derive the trusted accounts from the SubstrateTrustedAccounts
+			// tag, if present, because the account numbers in the
+			// assume-role policy can't say how they were selected.
+			if tag, ok := role.Tags[tagging.SubstrateTrustedAccounts]; ok && len(managedAssumeRolePolicy.TrustedAccounts) == 0 {
+				trusts, err := roles.ParseTrustedAccountsTag(tag)
+				if err != nil {
+					return nil, nil, err
+				}
+				managedAssumeRolePolicy.TrustedAccounts = trusts
+			}
+
+			// Derive the policy flags from the policies attached to the role
+			// plus the SubstratePolicyAttachmentFilenames tag, if present.
+			for _, arn := range policyARNs {
+				if arn == "arn:aws:iam::aws:policy/AdministratorAccess" {
+					managedPolicyAttachments.AdministratorAccess = true
+				} else if arn == "arn:aws:iam::aws:policy/ReadOnlyAccess" {
+					managedPolicyAttachments.ReadOnlyAccess = true
+				} else if naming.Index(managedPolicyAttachments.ARNs, arn) < 0 {
+					managedPolicyAttachments.ARNs = append(managedPolicyAttachments.ARNs, arn)
+				}
+			}
+			if filenames, ok := role.Tags[tagging.SubstratePolicyAttachmentFilenames]; ok && filenames != "" {
+				for _, filename := range strings.Split(filenames, " ") {
+					if naming.Index(managedPolicyAttachments.Filenames, filename) < 0 {
+						managedPolicyAttachments.Filenames = append(managedPolicyAttachments.Filenames, filename)
+					}
+				}
+			}
+
+			// Derive the permissions boundary and maximum session duration
+			// from their tags, which are absent if they're the defaults.
+			if arn := role.Tags[tagging.SubstratePermissionsBoundary]; arn != "" {
+				managedPolicyAttachments.PermissionsBoundary = arn
+			}
+			if duration := role.Tags[tagging.SubstrateMaxSessionDuration]; duration != "" {
+				managedPolicyAttachments.MaxSessionDuration = duration
+			}
+
+			managedAssumeRolePolicy.Sort()
+			managedPolicyAttachments.Sort()
+		}
+	}
+
+	return collated, tree, nil
+}
@@ -0,0 +1,249 @@
+package customroles
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsiam"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/awsutil"
+	"github.com/src-bin/substrate/humans"
+	"github.com/src-bin/substrate/policies"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/tagging"
+)
+
+// Causes of drift in a single instance of a custom role.
+const (
+	EditedOutsideSubstrate = "edited outside Substrate"
+	PolicyFilesChanged     = "policy files changed on disk"
+	TrustedAccountsChanged = "trusted accounts created or closed"
+	UnknownCause           = "unknown cause" // roles created before the SubstratePolicyDigest tag
+)
+
+// Drift describes how a custom role as it exists throughout the organization
+// differs from what `substrate role create` would make of it now.
+type Drift struct {
+	RoleName string
+
+	// PolicyFilesChanged is true if the contents of any file named by
+	// --assume-role-policy or --policy have changed since the role was last
+	// created or updated in any account.
+	PolicyFilesChanged bool
+
+	// MissingAccounts are selected by the role's account selection but don't
+	// have the role, typically because they were created after the role.
+	MissingAccounts []*awsorgs.Account
+
+	// UnselectedAccounts have the role but are no longer selected by its
+	// account selection so `substrate role create` would delete it.
+	UnselectedAccounts []*awsorgs.Account
+
+	// Instances are the roles whose policies differ.
+	Instances []*InstanceDrift
+}
+
+// Empty returns true if there's no drift at all.
+func (d *Drift) Empty() bool {
+	return !d.PolicyFilesChanged && len(d.MissingAccounts) == 0 && len(d.UnselectedAccounts) == 0 && len(d.Instances) == 0
+}
+
+// InstanceDrift describes how a custom role in one account differs from what
+// `substrate role create` would make of it now.
+type InstanceDrift struct {
+	Account *awsorgs.Account
+	Cause   string // EditedOutsideSubstrate, PolicyFilesChanged, TrustedAccountsChanged, or UnknownCause
+
+	AssumeRolePolicy  bool // the assume-role policy differs
+	PolicyAttachments bool // the set of attached policy ARNs differs
+	Policy            bool // Substrate's inline policy differs
+}
+
+// Diff compares every instance of a custom role, as returned by Inspect, to
+// what `substrate role create` would make of it now.
+func Diff(ctx context.Context, cfg *awscfg.Config, role *Role, instances []*Instance) (*Drift, error) {
+	drift := &Drift{RoleName: role.RoleName}
+
+	digest, err := role.PolicyDigest()
+	if err != nil {
+		return nil, err
+	}
+	desiredPolicy, err := role.PolicyDocument()
+	if err != nil {
+		return nil, err
+	}
+
+	adminAccounts, _, substrateAccount, _, _, _, _, err := accounts.Grouped(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	humanPrincipals := &policies.Principal{AWS: []string{}}
+	for _, account := range append(adminAccounts, substrateAccount) {
+		if account != nil { // substrateAccount will be nil until they've run `substrate setup`
+			humanPrincipals.AWS = append(humanPrincipals.AWS, roles.ARN(aws.ToString(account.Id), role.RoleName))
+		}
+	}
+	var intranetAssumeRolePolicy *policies.Document
+	if role.AssumeRolePolicy.Humans {
+		if intranetAssumeRolePolicy, err = humans.IntranetAssumeRolePolicy(ctx, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	allAccounts, err := cfg.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byAccountId := make(map[string]*Instance)
+	for _, instance := range instances {
+		byAccountId[aws.ToString(instance.Account.Id)] = instance
+		if d := instance.Role.Tags[tagging.SubstratePolicyDigest]; d != "" && d != digest {
+			drift.PolicyFilesChanged = true
+		}
+	}
+
+	selected, unselected, err := role.AccountSelection.Partition(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range unselected {
+		if _, ok := byAccountId[aws.ToString(account.Id)]; ok {
+			drift.UnselectedAccounts = append(drift.UnselectedAccounts, account)
+		}
+	}
+	for _, as := range selected {
+		account := as.Account
+		accountId := aws.ToString(account.Id)
+		instance, ok := byAccountId[accountId]
+		if !ok {
+			drift.MissingAccounts = append(drift.MissingAccounts, account)
+			continue
+		}
+		accountCfg, err := account.Config(ctx, cfg, account.AdministratorRoleName(), time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		instanceDrift := &InstanceDrift{Account: account}
+
+		desiredAssumeRolePolicy, err := role.AssumeRolePolicyDocument(
+			intranetAssumeRolePolicy,
+			humanPrincipals,
+			accountId,
+			allAccounts,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if instanceDrift.AssumeRolePolicy, err = documentsDiffer(desiredAssumeRolePolicy, instance.Role.AssumeRolePolicy); err != nil {
+			return nil, err
+		}
+		var onlyTrustedAccountsDiffer bool
+		if instanceDrift.AssumeRolePolicy && instance.Role.AssumeRolePolicy != nil {
+			differ, err := documentsDiffer(
+				withoutTrustedAccounts(desiredAssumeRolePolicy),
+				withoutTrustedAccounts(instance.Role.AssumeRolePolicy),
+			)
+			if err != nil {
+				return nil, err
+			}
+			onlyTrustedAccountsDiffer = !differ
+		}
+
+		// Roles that don't get policies attached in this account still get
+		// Substrate's minimal policy.
+		policy := MinimalPolicy()
+		if role.AttachesPolicies(account) {
+			policy = desiredPolicy
+			instanceDrift.PolicyAttachments = !equalSets(policyARNs(role.PolicyAttachments), instance.PolicyARNs)
+		}
+		livePolicy, err := awsiam.GetRolePolicy(ctx, accountCfg, role.RoleName, awsiam.SubstrateManaged)
+		if awsutil.ErrorCodeIs(err, awsiam.NoSuchEntity) {
+			livePolicy, err = nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if instanceDrift.Policy, err = documentsDiffer(policy, livePolicy); err != nil {
+			return nil, err
+		}
+
+		if instanceDrift.AssumeRolePolicy || instanceDrift.PolicyAttachments || instanceDrift.Policy {
+			switch instance.Role.Tags[tagging.SubstratePolicyDigest] {
+			case "":
+				instanceDrift.Cause = UnknownCause
+			case digest:
+				if onlyTrustedAccountsDiffer && !instanceDrift.PolicyAttachments && !instanceDrift.Policy {
+					instanceDrift.Cause = TrustedAccountsChanged
+				} else {
+					instanceDrift.Cause = EditedOutsideSubstrate
+				}
+			default:
+				instanceDrift.Cause = PolicyFilesChanged
+			}
+			drift.Instances = append(drift.Instances, instanceDrift)
+		}
+	}
+
+	return drift, nil
+}
+
+func documentsDiffer(desired, live *policies.Document) (bool, error) {
+	if live == nil {
+		return true, nil
+	}
+	desiredJSON, err := desired.Marshal()
+	if err != nil {
+		return false, err
+	}
+	liveJSON, err := live.Marshal()
+	if err != nil {
+		return false, err
+	}
+	return desiredJSON != liveJSON, nil
+}
+
+// withoutTrustedAccounts returns a copy of an assume-role policy without the
+// statements trustedAccountsStatement constructed, so the rest may be compared
+// on its own.
+func withoutTrustedAccounts(doc *policies.Document) *policies.Document {
+	filtered := &policies.Document{}
+	for _, statement := range doc.Statement {
+		if !isTrustedAccountsStatement(statement) {
+			filtered.Statement = append(filtered.Statement, statement)
+		}
+	}
+	return filtered
+}
+
+// policyARNs returns the ARNs of every policy `substrate role create` would
+// attach given these policy attachment flags.
+func policyARNs(a *roles.ManagedPolicyAttachments) []string {
+	arns := append([]string{}, a.ARNs...)
+	if a.AdministratorAccess {
+		arns = append(arns, policies.AdministratorAccess)
+	}
+	if a.ReadOnlyAccess {
+		arns = append(arns, policies.ReadOnlyAccess)
+	}
+	return arns
+}
+
+func equalSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package customroles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/jsonutil"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/version"
+)
+
+const Filename = "substrate.roles.json"
+
+// Document is the declarative definition of every custom IAM role in the
+// organization, as applied by `substrate role apply`.
+type Document struct {
+	Admonition       jsonutil.Admonition `json:"#"`
+	Roles            []*Role
+	SubstrateVersion jsonutil.SubstrateVersion
+}
+
+// ReadDocument reads and validates substrate.roles.json from the current
+// working directory, where relative policy filenames are also resolved.
+// Errors satisfy errors.Is(err, fs.ErrNotExist) if the file doesn't exist.
+func ReadDocument() (*Document, error) {
+	b, err := os.ReadFile(Filename)
+	if err != nil {
+		return nil, err
+	}
+	d := &Document{}
+	if err := json.Unmarshal(b, d); err != nil {
+		return nil, err
+	}
+
+	// If d.SubstrateVersion != version.Version, migrate here.
+
+	d.SubstrateVersion = jsonutil.SubstrateVersion(version.Version)
+	return d, d.Validate()
+}
+
+// Find returns the role by the given name or nil if there isn't one.
+func (d *Document) Find(roleName string) *Role {
+	for _, role := range d.Roles {
+		if role.RoleName == roleName {
+			return role
+		}
+	}
+	return nil
+}
+
+// Validate returns nil iff every role in the document is valid and no role
+// name appears more than once. It also fills in the zero values of any
+// omitted fields and sorts the roles by name.
+func (d *Document) Validate() error {
+	seen := make(map[string]bool)
+	for _, role := range d.Roles {
+		if seen[role.RoleName] {
+			return RoleError(fmt.Sprintf("%s lists the %s role more than once", Filename, role.RoleName))
+		}
+		seen[role.RoleName] = true
+		if err := role.Validate(); err != nil {
+			return err
+		}
+	}
+	sort.Slice(d.Roles, func(i, j int) bool { return d.Roles[i].RoleName < d.Roles[j].RoleName })
+	return nil
+}
+
+func (d *Document) Write() error {
+	return jsonutil.Write(d, Filename)
+}
+
+// Validate returns nil iff this role may be created by `substrate role
+// create` or `substrate role apply`. Like `substrate role create`, it sets
+// AccountSelection.Humans if AssumeRolePolicy.Humans is set.
+func (r *Role) Validate() error {
+	if r.RoleName == "" {
+		return RoleError("RoleName is required")
+	}
+	if r.RoleName == roles.Administrator || r.RoleName == roles.Auditor {
+		return RoleError(fmt.Sprintf("cannot manage %s roles as custom roles", r.RoleName))
+	}
+	if r.AccountSelection == nil {
+		r.AccountSelection = &accounts.Selection{}
+	}
+	if r.AssumeRolePolicy == nil {
+		r.AssumeRolePolicy = &roles.ManagedAssumeRolePolicy{}
+	}
+	if r.PolicyAttachments == nil {
+		r.PolicyAttachments = &roles.ManagedPolicyAttachments{}
+	}
+	if err := r.AssumeRolePolicy.Validate(); err != nil {
+		return fmt.Errorf("%s role: %w", r.RoleName, err)
+	}
+	if err := r.PolicyAttachments.Validate(); err != nil {
+		return fmt.Errorf("%s role: %w", r.RoleName, err)
+	}
+	if err := r.AccountSelection.Validate(); err != nil {
+		return fmt.Errorf("%s role: %w", r.RoleName, err)
+	}
+	if r.AssumeRolePolicy.Humans {
+		r.AccountSelection.Humans = true
+	}
+	return nil
+}
+
+type RoleError string
+
+func (err RoleError) Error() string {
+	return fmt.Sprint("RoleError: ", string(err))
+}
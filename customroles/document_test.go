@@ -0,0 +1,53 @@
+package customroles
+
+import (
+	"testing"
+
+	"github.com/src-bin/substrate/roles"
+)
+
+func TestDocumentValidate(t *testing.T) {
+	d := &Document{Roles: []*Role{
+		{RoleName: "Zebra"},
+		{RoleName: "Aardvark"},
+	}}
+	for _, role := range d.Roles {
+		role.Validate() // just to fill in zero values; it's not valid yet
+		role.AccountSelection.AllDomains = true
+		role.AccountSelection.AllEnvironments = true
+		role.AccountSelection.Qualities = []string{"default"}
+	}
+	if err := d.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Roles[0].RoleName != "Aardvark" || d.Roles[1].RoleName != "Zebra" {
+		t.Errorf("roles not sorted: %s, %s", d.Roles[0].RoleName, d.Roles[1].RoleName)
+	}
+	if d.Find("Zebra") != d.Roles[1] || d.Find("Unicorn") != nil {
+		t.Error("Find didn't find the right role")
+	}
+
+	d.Roles = append(d.Roles, d.Roles[0])
+	if err := d.Validate(); err == nil {
+		t.Error("duplicate role names should be invalid")
+	}
+}
+
+func TestRoleValidate(t *testing.T) {
+	for _, roleName := range []string{"", roles.Administrator, roles.Auditor} {
+		if err := (&Role{RoleName: roleName}).Validate(); err == nil {
+			t.Errorf("%q should be an invalid custom role name", roleName)
+		}
+	}
+
+	r := testRole()
+	r.AccountSelection.Substrate = true
+	r.AccountSelection.Qualities = []string{"default"}
+	r.AssumeRolePolicy.Humans = true
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.AccountSelection.Humans {
+		t.Error("AssumeRolePolicy.Humans should imply AccountSelection.Humans")
+	}
+}
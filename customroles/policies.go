@@ -0,0 +1,287 @@
+package customroles
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsiam"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/jsonutil"
+	"github.com/src-bin/substrate/naming"
+	"github.com/src-bin/substrate/policies"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/tagging"
+)
+
+// trustedAccountsSid, followed by a number, is the Sid of every statement
+// trustedAccountsStatement constructs.
+const trustedAccountsSid = "SubstrateTrustedAccounts"
+
+// MinimalPolicy returns the privileges every custom role needs in order to
+// use the Substrate command-line tools.
+func MinimalPolicy() *policies.Document {
+	return &policies.Document{
+		Statement: []policies.Statement{{
+			Action: []string{
+				"organizations:DescribeOrganization",
+				"sts:AssumeRole",
+			},
+			Resource: []string{"*"},
+		}},
+	}
+}
+
+// AttachesPolicies returns true if policies should be attached to this role
+// in the given account. If the account is an admin account or the Substrate
+// account, only attach policies if --substrate was given. If it's not,
+// attach them without further conditions. This complication is because
+// --humans implies that roles must exist in admin/Substrate accounts but not
+// that they should have all the same permissions in those accounts that they
+// have elsewhere.
+func (r *Role) AttachesPolicies(account *awsorgs.Account) bool {
+	is := account.Tags[tagging.Domain] == naming.Admin || account.Tags[tagging.SubstrateType] == naming.Substrate
+	return is && r.AccountSelection.Substrate || !is
+}
+
+// AssumeRolePolicyDocument constructs this role's assume-role policy for the
+// given account. humanPrincipals must contain the ARNs of the roles by the
+// same name in the Substrate account (and any admin accounts) if --humans was
+// given. allAccounts, as returned by awscfg.Config.ListAccounts, is used to
+// resolve trusted accounts to account numbers. OpenID Connect providers are
+// referenced but not created.
+func (r *Role) AssumeRolePolicyDocument(
+	intranetAssumeRolePolicy *policies.Document,
+	humanPrincipals *policies.Principal,
+	accountId string,
+	allAccounts []*awscfg.Account,
+) (*policies.Document, error) {
+	p := r.AssumeRolePolicy
+
+	// Begin with an empty (technically nil) assume-role policy.
+	var assumeRolePolicy *policies.Document
+
+	if p.Humans {
+		assumeRolePolicy = policies.Merge(
+			assumeRolePolicy,
+			intranetAssumeRolePolicy,
+			policies.AssumeRolePolicyDocument(humanPrincipals),
+		)
+	}
+
+	if len(p.AWSServices) > 0 {
+		assumeRolePolicy = policies.Merge(
+			assumeRolePolicy,
+			policies.AssumeRolePolicyDocument(&policies.Principal{
+				Service: jsonutil.StringSlice(p.AWSServices),
+			}),
+		)
+	}
+
+	if len(p.GitHubActions) > 0 {
+		subs, err := p.GitHubActionsSubs()
+		if err != nil {
+			return nil, err
+		}
+		name, err := awsiam.OpenIDConnectProviderName(awsiam.GitHubActionsOAuthOIDCURL)
+		if err != nil {
+			return nil, err
+		}
+		assumeRolePolicy = policies.Merge(
+			assumeRolePolicy,
+			&policies.Document{
+				Statement: []policies.Statement{{
+					Action: []string{"sts:AssumeRoleWithWebIdentity"},
+					Condition: policies.Condition{"StringEquals": {
+						"token.actions.githubusercontent.com:sub": subs,
+					}},
+					Principal: &policies.Principal{
+						Federated: []string{awsiam.OpenIDConnectProviderARN(accountId, name)},
+					},
+				}},
+			},
+		)
+	}
+
+	for _, t := range p.MergedOIDC() {
+		name, err := awsiam.OpenIDConnectProviderName(t.Provider)
+		if err != nil {
+			return nil, err
+		}
+		assumeRolePolicy = policies.Merge(
+			assumeRolePolicy,
+			&policies.Document{
+				Statement: []policies.Statement{{
+					Action: []string{"sts:AssumeRoleWithWebIdentity"},
+					Condition: policies.Condition{"StringLike": {
+						name + ":aud": t.AudiencesOrDefault(),
+						name + ":sub": t.Subjects,
+					}},
+					Principal: &policies.Principal{
+						Federated: []string{awsiam.OpenIDConnectProviderARN(accountId, name)},
+					},
+				}},
+			},
+		)
+	}
+
+	for i, t := range p.TrustedAccounts {
+		if statement := trustedAccountsStatement(i, t, allAccounts); statement != nil {
+			assumeRolePolicy = policies.Merge(
+				assumeRolePolicy,
+				&policies.Document{Statement: []policies.Statement{*statement}},
+			)
+		}
+	}
+
+	for _, filename := range p.Filenames {
+		var filePolicy policies.Document
+		if err := jsonutil.Read(filename, &filePolicy); err != nil {
+			return nil, err
+		}
+		assumeRolePolicy = policies.Merge(assumeRolePolicy, &filePolicy)
+	}
+
+	if assumeRolePolicy == nil {
+		return nil, AssumeRolePolicyError("at least one assume-role policy flag is required")
+	}
+	return assumeRolePolicy, nil
+}
+
+// Lint checks every file named by --assume-role-policy or --policy and the
+// custom policy merged from them for mistakes IAM would otherwise only
+// report partway through creating or updating this role. It returns every
+// problem it finds so they can all be reported before anything changes.
+func (r *Role) Lint() []error {
+	var errs []error
+	for _, filename := range r.AssumeRolePolicy.Filenames {
+		errs = append(errs, policies.LintFile(filename, policies.TrustPolicy)...)
+	}
+	for _, filename := range r.PolicyAttachments.Filenames {
+		errs = append(errs, policies.LintFile(filename, policies.IdentityPolicy)...)
+	}
+	if len(errs) > 0 {
+		return errs // the merged policy can't be read or would be wrong anyway
+	}
+	policy, err := r.PolicyDocument()
+	if err != nil {
+		return []error{err}
+	}
+	return policy.Lint(policies.IdentityPolicy)
+}
+
+// LintAssumeRolePolicy checks the assume-role policy this role will have in
+// the given account, constructed by AssumeRolePolicyDocument from the same
+// arguments, which may be too large for IAM even when every file given with
+// --assume-role-policy is fine if the role trusts many accounts or OpenID
+// Connect subjects.
+func (r *Role) LintAssumeRolePolicy(
+	intranetAssumeRolePolicy *policies.Document,
+	humanPrincipals *policies.Principal,
+	accountId string,
+	allAccounts []*awscfg.Account,
+) []error {
+	assumeRolePolicy, err := r.AssumeRolePolicyDocument(intranetAssumeRolePolicy, humanPrincipals, accountId, allAccounts)
+	if err != nil {
+		return []error{err}
+	}
+	return assumeRolePolicy.Lint(policies.TrustPolicy)
+}
+
+// PolicyDigest returns a SHA-256 digest of the contents of every file that
+// contributes to this role's assume-role policy or its custom policy so
+// that changes to those files may be detected later.
+func (r *Role) PolicyDigest() (string, error) {
+	h := sha256.New()
+	for _, files := range []struct {
+		kind      string
+		filenames []string
+	}{
+		{"assume-role-policy", r.AssumeRolePolicy.Filenames},
+		{"policy", r.PolicyAttachments.Filenames},
+	} {
+		filenames := append([]string{}, files.filenames...)
+		sort.Strings(filenames)
+		for _, filename := range filenames {
+			b, err := os.ReadFile(filename)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s %s %d\n", files.kind, filename, len(b))
+			h.Write(b)
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// PolicyDocument constructs this role's custom policy, which is Substrate's
+// minimal policy merged with the contents of every --policy file.
+func (r *Role) PolicyDocument() (*policies.Document, error) {
+	policy := MinimalPolicy()
+	for _, filename := range r.PolicyAttachments.Filenames {
+		var filePolicy policies.Document
+		if err := jsonutil.Read(filename, &filePolicy); err != nil {
+			return nil, err
+		}
+		policy = policies.Merge(policy, &filePolicy)
+	}
+	return policy, nil
+}
+
+// trustedAccountsStatement returns an assume-role policy statement that
+// trusts every service account that t, the ith trusted accounts flag, matches
+// or nil if none match. Accounts are trusted as a whole and, if t names a
+// role, narrowed to that role by a condition rather than named directly as
+// principals because IAM refuses to save a policy that names a role that
+// doesn't exist. The statement's Sid marks it as Substrate's so it may be
+// told apart from statements in --assume-role-policy files that also trust
+// whole accounts.
+func trustedAccountsStatement(i int, t roles.TrustedAccounts, allAccounts []*awscfg.Account) *policies.Statement {
+	var accountPrincipals, rolePrincipals []string
+	for _, account := range allAccounts {
+		domain := account.Tags[tagging.Domain]
+		if domain == "" || domain == naming.Admin {
+			continue // only service accounts may be trusted this way
+		}
+		if !t.Match(domain, account.Tags[tagging.Environment], account.Tags[tagging.Quality]) {
+			continue
+		}
+		accountId := aws.ToString(account.Id)
+		accountPrincipals = append(accountPrincipals, fmt.Sprintf("arn:aws:iam::%s:root", accountId))
+		if t.RoleName != "" {
+			rolePrincipals = append(rolePrincipals, roles.ARN(accountId, t.RoleName))
+		}
+	}
+	if len(accountPrincipals) == 0 {
+		return nil
+	}
+	sort.Strings(accountPrincipals)
+	statement := &policies.Statement{
+		Action:    []string{"sts:AssumeRole"},
+		Principal: &policies.Principal{AWS: accountPrincipals},
+		Sid:       fmt.Sprint(trustedAccountsSid, i), // Sids must be unique within a policy
+	}
+	if len(rolePrincipals) > 0 {
+		sort.Strings(rolePrincipals)
+		statement.Condition = policies.Condition{"ArnEquals": {
+			"aws:PrincipalArn": rolePrincipals,
+		}}
+	}
+	return statement
+}
+
+// isTrustedAccountsStatement returns true if the statement was constructed by
+// trustedAccountsStatement.
+func isTrustedAccountsStatement(statement policies.Statement) bool {
+	return strings.HasPrefix(statement.Sid, trustedAccountsSid)
+}
+
+type AssumeRolePolicyError string
+
+func (err AssumeRolePolicyError) Error() string {
+	return fmt.Sprint("AssumeRolePolicyError: ", string(err))
+}
@@ -0,0 +1,202 @@
+package customroles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/naming"
+	"github.com/src-bin/substrate/policies"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/tagging"
+)
+
+func TestAttachesPolicies(t *testing.T) {
+	r := testRole()
+	substrateAccount := awsorgs.StringableZeroAccount("123456789012")
+	substrateAccount.Tags[tagging.SubstrateType] = naming.Substrate
+	serviceAccount := awsorgs.StringableZeroAccount("234567890123")
+	serviceAccount.Tags[tagging.Domain] = "example"
+	if r.AttachesPolicies(substrateAccount) {
+		t.Error("attaches policies in the Substrate account without --substrate")
+	}
+	if !r.AttachesPolicies(serviceAccount) {
+		t.Error("doesn't attach policies in a service account")
+	}
+	r.AccountSelection.Substrate = true
+	if !r.AttachesPolicies(substrateAccount) {
+		t.Error("doesn't attach policies in the Substrate account with --substrate")
+	}
+}
+
+func TestLint(t *testing.T) {
+	dirname := t.TempDir()
+	trust, policy := filepath.Join(dirname, "trust.json"), filepath.Join(dirname, "policy.json")
+	if err := os.WriteFile(trust, []byte(`{"Statement":[{"Action":"sts:AssumeRole","Principal":{"AWS":"123456789012"},"Resource":"*"}]}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(policy, []byte(`{"Statement":[{"Effect":"Permit","Action":"*","Resource":"*"}]}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	r := testRole()
+	r.AssumeRolePolicy.Filenames = []string{trust}
+	r.PolicyAttachments.Filenames = []string{policy}
+	if errs := r.Lint(); len(errs) != 2 {
+		t.Errorf("expected one error from each file: %v", errs)
+	}
+
+	r.AssumeRolePolicy.Filenames = nil
+	r.PolicyAttachments.Filenames = nil
+	if errs := r.Lint(); len(errs) != 0 {
+		t.Error(errs)
+	}
+}
+
+func TestLintAssumeRolePolicy(t *testing.T) {
+	r := testRole()
+	r.AssumeRolePolicy.OIDC = []roles.OIDCTrust{{
+		Provider: "https://gitlab.com",
+		Subjects: []string{"project_path:example/*:ref_type:branch:ref:main"},
+	}}
+	if errs := r.LintAssumeRolePolicy(nil, nil, "123456789012", nil); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	// Every file's fine (there are none) but trusting this many subjects
+	// makes the merged assume-role policy too large for IAM.
+	for i := 0; i < 50; i++ {
+		r.AssumeRolePolicy.OIDC[0].Subjects = append(
+			r.AssumeRolePolicy.OIDC[0].Subjects,
+			fmt.Sprintf("project_path:example/project%d:ref_type:branch:ref:main", i),
+		)
+	}
+	if errs := r.Lint(); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	errs := r.LintAssumeRolePolicy(nil, nil, "123456789012", nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "exceeds the limit of 2048") {
+		t.Fatalf("expected the assume-role policy to be too large: %v", errs)
+	}
+}
+
+func TestPolicyDigest(t *testing.T) {
+	dirname := t.TempDir()
+	a, b := filepath.Join(dirname, "a.json"), filepath.Join(dirname, "b.json")
+	if err := os.WriteFile(a, []byte(`{"Statement":[]}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte(`{"Statement":[]}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	r := testRole()
+	r.PolicyAttachments.Filenames = []string{a, b}
+	digest1, err := r.PolicyDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.PolicyAttachments.Filenames = []string{b, a}
+	digest2, err := r.PolicyDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest1 != digest2 {
+		t.Errorf("digest depends on the order of filenames: %s != %s", digest1, digest2)
+	}
+
+	r.PolicyAttachments.Filenames = []string{a}
+	r.AssumeRolePolicy.Filenames = []string{b}
+	digest3, err := r.PolicyDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest1 == digest3 {
+		t.Error("digest doesn't distinguish assume-role policy files from policy files")
+	}
+
+	if err := os.WriteFile(a, []byte(`{"Statement":[{"Action":"*","Resource":"*"}]}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	digest4, err := r.PolicyDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest3 == digest4 {
+		t.Error("digest doesn't change when a file changes")
+	}
+}
+
+func TestTrustedAccountsStatement(t *testing.T) {
+	var allAccounts []*awsorgs.Account
+	for number, tags := range map[string][3]string{
+		"123456789012": {"payments", "staging", "default"},
+		"234567890123": {"payments", "production", "default"},
+		"345678901234": {"billing", "staging", "default"},
+		"456789012345": {naming.Admin, "admin", "default"},
+	} {
+		account := awsorgs.StringableZeroAccount(number)
+		account.Tags[tagging.Domain] = tags[0]
+		account.Tags[tagging.Environment] = tags[1]
+		account.Tags[tagging.Quality] = tags[2]
+		allAccounts = append(allAccounts, account)
+	}
+
+	statement := trustedAccountsStatement(0, roles.TrustedAccounts{
+		RoleName:     "Deployer",
+		Environments: []string{"staging", "admin"},
+	}, allAccounts)
+	if statement == nil {
+		t.Fatal("no statement")
+	}
+	if expected := []string{"arn:aws:iam::123456789012:root", "arn:aws:iam::345678901234:root"}; !reflect.DeepEqual([]string(statement.Principal.AWS), expected) {
+		t.Errorf("statement.Principal.AWS: %+v != %+v", statement.Principal.AWS, expected)
+	}
+	if expected := []string{"arn:aws:iam::123456789012:role/Deployer", "arn:aws:iam::345678901234:role/Deployer"}; !reflect.DeepEqual([]string(statement.Condition["ArnEquals"]["aws:PrincipalArn"]), expected) {
+		t.Errorf("statement.Condition: %+v", statement.Condition)
+	}
+
+	if statement.Sid != "SubstrateTrustedAccounts0" || !isTrustedAccountsStatement(*statement) {
+		t.Errorf("statement.Sid: %q", statement.Sid)
+	}
+
+	statement = trustedAccountsStatement(1, roles.TrustedAccounts{Domains: []string{"payments"}}, allAccounts)
+	if statement == nil || len(statement.Principal.AWS) != 2 || statement.Condition != nil || statement.Sid != "SubstrateTrustedAccounts1" {
+		t.Errorf("statement: %+v", statement)
+	}
+
+	if statement := trustedAccountsStatement(2, roles.TrustedAccounts{Domains: []string{"nonexistent"}}, allAccounts); statement != nil {
+		t.Errorf("statement: %+v != nil", statement)
+	}
+}
+
+func TestWithoutTrustedAccounts(t *testing.T) {
+	vendor := policies.Statement{ // from an --assume-role-policy file
+		Action:    []string{"sts:AssumeRole"},
+		Principal: &policies.Principal{AWS: []string{"arn:aws:iam::999999999999:root"}},
+	}
+	trusted := policies.Statement{
+		Action:    []string{"sts:AssumeRole"},
+		Principal: &policies.Principal{AWS: []string{"arn:aws:iam::123456789012:root"}},
+		Sid:       "SubstrateTrustedAccounts0",
+	}
+	doc := withoutTrustedAccounts(&policies.Document{Statement: []policies.Statement{vendor, trusted}})
+	if len(doc.Statement) != 1 || !statementsEqual(doc.Statement[0], vendor) {
+		t.Fatalf("doc: %s", doc.MustMarshal())
+	}
+}
+
+func testRole() *Role {
+	return &Role{
+		RoleName:          "Example",
+		AccountSelection:  &accounts.Selection{},
+		AssumeRolePolicy:  &roles.ManagedAssumeRolePolicy{},
+		PolicyAttachments: &roles.ManagedPolicyAttachments{},
+	}
+}
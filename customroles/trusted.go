@@ -0,0 +1,154 @@
+package customroles
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsiam"
+	"github.com/src-bin/substrate/policies"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/tagging"
+	"github.com/src-bin/substrate/ui"
+)
+
+// DistrustAccount rewrites the assume-role policy of every custom role that
+// trusts service accounts with the given domain, environment, and quality so
+// that the account with the given number, which is being closed, is no longer
+// trusted. Nothing else about those roles changes.
+func DistrustAccount(ctx context.Context, cfg *awscfg.Config, accountId, domain, environment, quality string) error {
+	return updateTrustedAccounts(ctx, cfg, accountId, domain, environment, quality)
+}
+
+// TrustAccount rewrites the assume-role policy of every custom role that
+// trusts service accounts with the given domain, environment, and quality so
+// that a newly created or adopted account like that is trusted as soon as it
+// exists. Nothing else about those roles changes.
+func TrustAccount(ctx context.Context, cfg *awscfg.Config, domain, environment, quality string) error {
+	return updateTrustedAccounts(ctx, cfg, "", domain, environment, quality)
+}
+
+// trustedAccountsPolicy replaces the statements in an assume-role policy that
+// trustedAccountsStatement constructed with ones that trust the accounts in
+// allAccounts that trusts match, in the same place, and leaves every other
+// statement alone, including those from --assume-role-policy files that trust
+// whole accounts of their own. It returns nil if no statements would remain.
+func trustedAccountsPolicy(
+	live *policies.Document,
+	trusts []roles.TrustedAccounts,
+	allAccounts []*awscfg.Account,
+) *policies.Document {
+	var statements []policies.Statement
+	for i, t := range trusts {
+		if statement := trustedAccountsStatement(i, t, allAccounts); statement != nil {
+			statements = append(statements, *statement)
+		}
+	}
+	others := withoutTrustedAccounts(live).Statement
+	i := 0 // where the first trusted accounts statement was
+	for ; i < len(live.Statement) && i < len(others); i++ {
+		if !statementsEqual(live.Statement[i], others[i]) {
+			break
+		}
+	}
+	doc := &policies.Document{Version: live.Version}
+	doc.Statement = append(doc.Statement, others[:i]...)
+	doc.Statement = append(doc.Statement, statements...)
+	doc.Statement = append(doc.Statement, others[i:]...)
+	if len(doc.Statement) == 0 {
+		return nil
+	}
+	return doc
+}
+
+func statementsEqual(a, b policies.Statement) bool {
+	differ, err := documentsDiffer(
+		&policies.Document{Statement: []policies.Statement{a}},
+		&policies.Document{Statement: []policies.Statement{b}},
+	)
+	return err == nil && !differ
+}
+
+func updateTrustedAccounts(ctx context.Context, cfg *awscfg.Config, excludedAccountId, domain, environment, quality string) error {
+	if err := cfg.ClearCachedAccounts(); err != nil { // the new account and its tags must be listed
+		return err
+	}
+	allAccounts, err := cfg.ListAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	var trustable []*awscfg.Account
+	for _, account := range allAccounts {
+		if account.Status == types.AccountStatusActive && aws.ToString(account.Id) != excludedAccountId {
+			trustable = append(trustable, account)
+		}
+	}
+
+	var (
+		firstErr error
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+	)
+	for _, account := range allAccounts {
+		if aws.ToString(account.Id) == excludedAccountId {
+			continue
+		}
+		wg.Add(1)
+		go func(account *awscfg.Account) {
+			defer wg.Done()
+			err := func() error {
+				accountCfg, err := account.Config(ctx, cfg, account.AdministratorRoleName(), time.Hour)
+				if err != nil {
+					return err
+				}
+				iamRoles, err := awsiam.ListRoles(ctx, accountCfg)
+				if err != nil {
+					return err
+				}
+				for _, role := range iamRoles {
+					if role.Tags[tagging.Manager] != tagging.Substrate || role.Tags[tagging.SubstrateTrustedAccounts] == "" {
+						continue
+					}
+					trusts, err := roles.ParseTrustedAccountsTag(role.Tags[tagging.SubstrateTrustedAccounts])
+					if err != nil {
+						return err
+					}
+					matched := false
+					for _, t := range trusts {
+						matched = matched || t.Match(domain, environment, quality)
+					}
+					if !matched || role.AssumeRolePolicy == nil {
+						continue
+					}
+					doc := trustedAccountsPolicy(role.AssumeRolePolicy, trusts, trustable)
+					if doc == nil {
+						ui.Printf("not updating the %s role in %s because it would no longer trust anyone", role.Name, account)
+						continue
+					}
+					if differ, err := documentsDiffer(doc, role.AssumeRolePolicy); err != nil {
+						return err
+					} else if !differ {
+						continue
+					}
+					ui.Printf("updating the %s role in %s to trust accounts with domain %s, environment %s, and quality %s", role.Name, account, domain, environment, quality)
+					if err := awsiam.UpdateAssumeRolePolicy(ctx, accountCfg, role.Name, doc); err != nil {
+						return err
+					}
+				}
+				return nil
+			}()
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(account)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,74 @@
+package customroles
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/policies"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/tagging"
+)
+
+func TestTrustedAccountsPolicy(t *testing.T) {
+	var allAccounts []*awsorgs.Account
+	for _, number := range []string{"123456789012", "234567890123"} {
+		account := awsorgs.StringableZeroAccount(number)
+		account.Tags[tagging.Domain] = "payments"
+		account.Tags[tagging.Environment] = "staging"
+		account.Tags[tagging.Quality] = "default"
+		allAccounts = append(allAccounts, account)
+	}
+	trusts := []roles.TrustedAccounts{{Domains: []string{"payments"}}}
+	service := policies.Statement{
+		Action:    []string{"sts:AssumeRole"},
+		Principal: &policies.Principal{Service: []string{"ec2.amazonaws.com"}},
+	}
+	file := policies.Statement{
+		Action:    []string{"sts:TagSession"},
+		Principal: &policies.Principal{Service: []string{"ecs-tasks.amazonaws.com"}},
+	}
+	live := &policies.Document{Statement: []policies.Statement{
+		service,
+		{
+			Action:    []string{"sts:AssumeRole"},
+			Principal: &policies.Principal{AWS: []string{"arn:aws:iam::123456789012:root"}},
+			Sid:       "SubstrateTrustedAccounts0",
+		},
+		file,
+	}}
+
+	// A new account is trusted in place and nothing else changes.
+	doc := trustedAccountsPolicy(live, trusts, allAccounts)
+	if len(doc.Statement) != 3 || !statementsEqual(doc.Statement[0], service) || !statementsEqual(doc.Statement[2], file) {
+		t.Fatalf("doc: %s", doc.MustMarshal())
+	}
+	if expected := []string{"arn:aws:iam::123456789012:root", "arn:aws:iam::234567890123:root"}; !reflect.DeepEqual([]string(doc.Statement[1].Principal.AWS), expected) {
+		t.Errorf("doc.Statement[1].Principal.AWS: %+v != %+v", doc.Statement[1].Principal.AWS, expected)
+	}
+
+	// Once no accounts match, the statement that trusted them is removed.
+	doc = trustedAccountsPolicy(live, trusts, nil)
+	if len(doc.Statement) != 2 || !statementsEqual(doc.Statement[0], service) || !statementsEqual(doc.Statement[1], file) {
+		t.Fatalf("doc: %s", doc.MustMarshal())
+	}
+
+	// Statements from --assume-role-policy files that trust whole accounts
+	// outside the organization are kept, too.
+	vendor := policies.Statement{
+		Action:    []string{"sts:AssumeRole"},
+		Principal: &policies.Principal{AWS: []string{"arn:aws:iam::999999999999:root"}},
+	}
+	doc = trustedAccountsPolicy(&policies.Document{Statement: append([]policies.Statement{vendor}, live.Statement...)}, trusts, allAccounts)
+	if len(doc.Statement) != 4 || !statementsEqual(doc.Statement[0], vendor) || !statementsEqual(doc.Statement[1], service) || !statementsEqual(doc.Statement[3], file) {
+		t.Fatalf("doc: %s", doc.MustMarshal())
+	}
+	if doc = trustedAccountsPolicy(&policies.Document{Statement: []policies.Statement{vendor}}, trusts, nil); len(doc.Statement) != 1 || !statementsEqual(doc.Statement[0], vendor) {
+		t.Fatalf("doc: %s", doc.MustMarshal())
+	}
+
+	// A role that trusts nothing else is left alone.
+	if doc := trustedAccountsPolicy(&policies.Document{Statement: live.Statement[1:2]}, trusts, nil); doc != nil {
+		t.Fatalf("doc: %s", doc.MustMarshal())
+	}
+}
@@ -6,7 +6,7 @@ package features
 // comma-delimited value of the SUBSTRATE_FEATURES environment variable.
 const (
 	DelegatedOrganizationAdministration feature = "DelegatedOrganizationAdministration"
-	IgnoreMacOSKeychain                 feature = "IgnoreMacOSKeychain"
+	IgnoreMacOSKeychain                 feature = "IgnoreMacOSKeychain" // also the Secret Service or encrypted file on Linux
 	ProxyTelemetry                      feature = "ProxyTelemetry"
 	Telemetry                           feature = "Telemetry"
 	UpgradeButton                       feature = "UpgradeButton"
@@ -0,0 +1,108 @@
+package fileutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// KeySize is the size of the AES-256 keys ReadEncryptedFile and
+// WriteEncryptedFile expect.
+const KeySize = 32
+
+// Key reads the AES-256 key in pathname, creating it with a new random key
+// and mode 0600 (and its directory with mode 0700) if it doesn't exist.
+func Key(pathname string) ([]byte, error) {
+	key, err := os.ReadFile(pathname)
+	if errors.Is(err, fs.ErrNotExist) {
+		key = make([]byte, KeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(pathname), 0700); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(pathname, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			return Key(pathname) // someone else created it first; use theirs
+		} else if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(key); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return key, f.Close()
+	} else if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, KeySizeError(pathname)
+	}
+	return key, nil
+}
+
+// ReadEncryptedFile reads and decrypts a file written by WriteEncryptedFile
+// using the same key. A key of the wrong size can't have encrypted it so it's
+// a DecryptionError, too.
+func ReadEncryptedFile(pathname string, key []byte) ([]byte, error) {
+	if len(key) != KeySize {
+		return nil, DecryptionError(pathname)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(pathname)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < aead.NonceSize() {
+		return nil, DecryptionError(pathname)
+	}
+	plaintext, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(filepath.Base(pathname)))
+	if err != nil {
+		return nil, DecryptionError(pathname)
+	}
+	return plaintext, nil
+}
+
+// WriteEncryptedFile encrypts b with AES-256-GCM using key and atomically
+// writes it to pathname with mode 0600. The filename is authenticated, too,
+// so an encrypted file can't be passed off as another.
+func WriteEncryptedFile(pathname string, b, key []byte) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return WriteFileAtomic(pathname, aead.Seal(nonce, nonce, b, []byte(filepath.Base(pathname))), 0600)
+}
+
+type DecryptionError string
+
+func (err DecryptionError) Error() string {
+	return fmt.Sprintf("DecryptionError: %s is corrupt or was encrypted with a different key", string(err))
+}
+
+type KeySizeError string
+
+func (err KeySizeError) Error() string {
+	return fmt.Sprintf("KeySizeError: %s doesn't contain a 256-bit key", string(err))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
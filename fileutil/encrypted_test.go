@@ -0,0 +1,58 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptedFile(t *testing.T) {
+	dirname := t.TempDir()
+	keyPathname := filepath.Join(dirname, "key", "substrate.key")
+	key, err := Key(keyPathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(keyPathname); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Fatalf("key mode: %v", fi.Mode())
+	}
+	if again, err := Key(keyPathname); err != nil {
+		t.Fatal(err)
+	} else if string(again) != string(key) {
+		t.Fatal("Key didn't return the existing key")
+	}
+
+	pathname := filepath.Join(dirname, "secret")
+	if err := WriteEncryptedFile(pathname, []byte("plaintext"), key); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(pathname); err != nil {
+		t.Fatal(err)
+	} else if string(b) == "plaintext" {
+		t.Fatal("not encrypted")
+	}
+	b, err := ReadEncryptedFile(pathname, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "plaintext" {
+		t.Fatalf("decrypted: %q", b)
+	}
+
+	otherKey := make([]byte, KeySize)
+	if _, err := ReadEncryptedFile(pathname, otherKey); err != DecryptionError(pathname) {
+		t.Fatal(err)
+	}
+	if _, err := ReadEncryptedFile(pathname, key[:KeySize/2]); err != DecryptionError(pathname) {
+		t.Fatal(err)
+	}
+	renamed := filepath.Join(dirname, "renamed")
+	if err := os.Rename(pathname, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadEncryptedFile(renamed, key); err != DecryptionError(renamed) {
+		t.Fatal(err)
+	}
+}
@@ -2,11 +2,13 @@ package fileutil
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 const DefaultEditor = "vim"
@@ -90,6 +92,29 @@ func ToLines(b []byte) []string {
 	return strings.Split(Tidy(b), "\n")
 }
 
+// WriteFileAtomic writes b to a temporary file in the same directory as
+// pathname and then renames it to pathname so that readers see either the
+// old contents or the new but never a partially written file.
+func WriteFileAtomic(pathname string, b []byte, perm os.FileMode) error {
+	tmp := filepath.Join(
+		filepath.Dir(pathname),
+		fmt.Sprintf(".%s.%d.%d", filepath.Base(pathname), os.Getpid(), time.Now().UnixNano()),
+	)
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm) // respects umask, unlike os.CreateTemp and os.Chmod
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) // fails harmlessly once renamed
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, pathname)
+}
+
 func WriteFileIfNotExists(pathname string, b []byte) error {
 	f, err := os.OpenFile(pathname, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
 	if errors.Is(err, fs.ErrExist) {
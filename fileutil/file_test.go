@@ -3,6 +3,7 @@ package fileutil
 import (
 	"bytes"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -82,3 +83,32 @@ func TestWriteFileIfNotExists(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	const filename = "TestWriteFileAtomic"
+	if err := Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	defer Remove(filename)
+
+	for _, write := range [][]byte{
+		[]byte("foo\n"), // we'll create the file and write this
+		[]byte("bar\n"), // and then replace it with this
+	} {
+		if err := WriteFileAtomic(filename, write, 0666); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, write) {
+			t.Fatalf("%#v", string(b))
+		}
+	}
+
+	// No temporary files should be left behind.
+	if matches, err := filepath.Glob("." + filename + ".*"); err != nil || len(matches) != 0 {
+		t.Fatal(matches, err)
+	}
+}
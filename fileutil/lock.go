@@ -0,0 +1,25 @@
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// Lock takes an exclusive advisory lock on pathname, creating it if it
+// doesn't exist, and blocks until the lock is available. Call the returned
+// function to release the lock. The operating system releases it, too, when
+// the process exits so a crashed process can't wedge all the others.
+func Lock(pathname string) (unlock func() error, err error) {
+	f, err := os.OpenFile(pathname, os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() error {
+		defer f.Close()
+		return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	}, nil
+}
@@ -0,0 +1,45 @@
+package fileutil
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	pathname := filepath.Join(t.TempDir(), "TestLock.lock")
+	var (
+		inside, maxInside int
+		mu                sync.Mutex
+		wg                sync.WaitGroup
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := Lock(pathname) // each opens its own file so they contend like processes do
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			inside++
+			if inside > maxInside {
+				maxInside = inside
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond) // give any other holder time to overlap
+			mu.Lock()
+			inside--
+			mu.Unlock()
+			if err := unlock(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxInside != 1 {
+		t.Errorf("%d goroutines held the lock at once", maxInside)
+	}
+}
@@ -29,6 +29,7 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/ssoadmin v1.18.2
 	github.com/aws/aws-sdk-go-v2/service/sts v1.17.6
 	github.com/aws/smithy-go v1.14.2
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/keybase/go-keychain v0.0.0-20230523030712-b5615109f100
 	github.com/spf13/cobra v1.8.0
 	github.com/spf13/pflag v1.0.5
@@ -18,7 +18,7 @@ func EnsureAdministratorRole(ctx context.Context, mgmtOrSubstrateCfg, cfg *awscf
 		return nil, err
 	}
 
-	role, err := awsiam.EnsureRole(ctx, cfg, roles.Administrator, assumeRolePolicy)
+	role, err := awsiam.EnsureRole(ctx, cfg, roles.Administrator, assumeRolePolicy, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +37,7 @@ func EnsureAuditorRole(ctx context.Context, mgmtOrSubstrateCfg, cfg *awscfg.Conf
 		return nil, err
 	}
 
-	role, err := awsiam.EnsureRole(ctx, cfg, roles.Auditor, assumeRolePolicy)
+	role, err := awsiam.EnsureRole(ctx, cfg, roles.Auditor, assumeRolePolicy, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -94,6 +94,7 @@ func EnsureAuditAccountRoles(ctx context.Context, mgmtCfg, substrateCfg, auditCf
 			}),
 			extraAdministrator,
 		),
+		nil,
 	)
 	if err != nil {
 		return ui.StopErr(err)
@@ -109,7 +110,7 @@ func EnsureAuditAccountRoles(ctx context.Context, mgmtCfg, substrateCfg, auditCf
 	if err != nil {
 		return ui.StopErr(err)
 	}
-	auditorRole, err := awsiam.EnsureRole(ctx, auditCfg, roles.Auditor, auditorAssumeRolePolicy)
+	auditorRole, err := awsiam.EnsureRole(ctx, auditCfg, roles.Auditor, auditorAssumeRolePolicy, nil)
 	if err != nil {
 		return ui.StopErr(err)
 	}
@@ -3,6 +3,8 @@ package jsonutil
 import (
 	"encoding/json"
 	"os"
+
+	"github.com/src-bin/substrate/fileutil"
 )
 
 func Read(pathname string, document interface{}) error {
@@ -14,10 +16,27 @@ func Read(pathname string, document interface{}) error {
 }
 
 func Write(document interface{}, pathname string) error {
-	b, err := json.MarshalIndent(document, "", "\t")
+	b, err := marshalIndent(document)
 	if err != nil {
 		return err
 	}
-	b = append(b, '\n') // I wish there was a less wasteful way to do this
 	return os.WriteFile(pathname, b, 0666)
 }
+
+// WriteAtomic is Write via fileutil.WriteFileAtomic for documents that may be
+// read by other processes while they're being written.
+func WriteAtomic(document interface{}, pathname string) error {
+	b, err := marshalIndent(document)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(pathname, b, 0666)
+}
+
+func marshalIndent(document interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(document, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil // I wish there was a less wasteful way to do this
+}
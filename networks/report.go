@@ -0,0 +1,190 @@
+package networks
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsec2"
+	"github.com/src-bin/substrate/cidr"
+	"github.com/src-bin/substrate/jsonutil"
+)
+
+const (
+	HoursPerMonth = 730 // AWS' own convention for monthly estimates
+
+	PricesFilename = "substrate.network-prices.json"
+)
+
+// Prices are the hourly prices, in US dollars, of the network resources
+// Substrate creates. Peering connections themselves are free but the field's
+// there in case that ever changes; data transfer isn't estimated at all.
+// Regions, if present, overrides the prices it gives in the given regions;
+// the others stay the same as everywhere else.
+type Prices struct {
+	NATGatewayPerHour           float64
+	EIPPerHour                  float64
+	VPCPeeringConnectionPerHour float64
+	Regions                     map[string]*RegionalPrices `json:",omitempty"`
+}
+
+// RegionalPrices override Prices in one region. Fields left nil keep the
+// top-level price so that, unlike zero, they're distinguishable from a price
+// overridden to zero, e.g. by a committed spend discount.
+type RegionalPrices struct {
+	NATGatewayPerHour           *float64 `json:",omitempty"`
+	EIPPerHour                  *float64 `json:",omitempty"`
+	VPCPeeringConnectionPerHour *float64 `json:",omitempty"`
+}
+
+// DefaultPrices returns the prices in us-east-1 as of this writing, which are
+// close enough in most other regions to estimate with.
+func DefaultPrices() *Prices {
+	return &Prices{
+		NATGatewayPerHour:           0.045,
+		EIPPerHour:                  0.005, // every public IPv4 address, even a NAT Gateway's
+		VPCPeeringConnectionPerHour: 0,
+	}
+}
+
+// ReadPrices returns DefaultPrices overridden by the contents of
+// substrate.network-prices.json, if it exists.
+func ReadPrices() (*Prices, error) {
+	p := DefaultPrices()
+	if err := jsonutil.Read(PricesFilename, p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return p, nil
+}
+
+// MonthlyCost estimates how much the NAT Gateways, EIPs, and peering
+// connections in the given VPC cost per month. Peering connections are only
+// counted in the VPC that requested them so that each is counted once.
+func (p *Prices) MonthlyCost(v *VPCReport) float64 {
+	natGatewayPerHour, eipPerHour, vpcPeeringConnectionPerHour := p.NATGatewayPerHour, p.EIPPerHour, p.VPCPeeringConnectionPerHour
+	if regional := p.Regions[v.Region]; regional != nil {
+		if regional.NATGatewayPerHour != nil {
+			natGatewayPerHour = *regional.NATGatewayPerHour
+		}
+		if regional.EIPPerHour != nil {
+			eipPerHour = *regional.EIPPerHour
+		}
+		if regional.VPCPeeringConnectionPerHour != nil {
+			vpcPeeringConnectionPerHour = *regional.VPCPeeringConnectionPerHour
+		}
+	}
+	return HoursPerMonth * (float64(len(v.NATGateways))*natGatewayPerHour +
+		float64(len(v.EIPs))*eipPerHour +
+		float64(len(v.RequestedVPCPeeringConnections))*vpcPeeringConnectionPerHour)
+}
+
+// VPCReport describes the costly parts of one Substrate-managed VPC.
+type VPCReport struct {
+	Environment, Quality, Region   string
+	IPv4                           cidr.IPv4
+	VPC                            string   // empty if the VPC doesn't exist (yet)
+	NATGateways                    []string // IDs
+	EIPs                           []string // public IPv4 addresses
+	VPCPeeringConnections          []string // IDs
+	RequestedVPCPeeringConnections []string // IDs of the subset this VPC requested
+	MonthlyCost                    float64  // estimated, in US dollars
+}
+
+// Report describes every network in substrate.admin-networks.json and
+// substrate.networks.json in the given regions, which is to say every VPC
+// Substrate manages there, including estimates of what they cost per month.
+// cfg must be in the network account.
+func Report(
+	ctx context.Context,
+	cfg *awscfg.Config,
+	regions []string,
+	prices *Prices,
+) ([]*VPCReport, error) {
+	var nets []*Network
+	for _, filename := range []string{AdminFilename, Filename} {
+		doc, err := ReadDocument(filename, cidr.IPv4{}, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, region := range regions {
+			nets = append(nets, doc.FindAll(&Network{Region: region})...)
+		}
+	}
+
+	// Describe NAT Gateways and peering connections once per region rather
+	// than once per VPC.
+	natGateways := make(map[string][]awsec2.NATGateway)
+	peeringConnections := make(map[string][]awsec2.VPCPeeringConnection)
+	for _, region := range regions {
+		var err error
+		if natGateways[region], err = awsec2.DescribeNATGateways(ctx, cfg.Regional(region)); err != nil {
+			return nil, err
+		}
+		if peeringConnections[region], err = awsec2.DescribeVPCPeeringConnections(ctx, cfg.Regional(region)); err != nil {
+			return nil, err
+		}
+	}
+
+	reports := make([]*VPCReport, 0, len(nets))
+	for _, n := range nets {
+		r := &VPCReport{
+			Environment: n.Environment,
+			Quality:     n.Quality,
+			Region:      n.Region,
+			IPv4:        n.IPv4,
+		}
+		vpc, err := awsec2.DescribeVPC(ctx, cfg.Regional(n.Region), n.Environment, n.Quality)
+		if err != nil {
+			return nil, err
+		}
+		if vpc != nil {
+			r.VPC = aws.ToString(vpc.VpcId)
+		}
+		for _, ngw := range natGateways[n.Region] {
+			if r.VPC == "" || aws.ToString(ngw.VpcId) != r.VPC {
+				continue
+			}
+			if ngw.State == types.NatGatewayStateDeleted || ngw.State == types.NatGatewayStateDeleting {
+				continue
+			}
+			r.NATGateways = append(r.NATGateways, aws.ToString(ngw.NatGatewayId))
+			for _, address := range ngw.NatGatewayAddresses {
+				if ip := aws.ToString(address.PublicIp); ip != "" {
+					r.EIPs = append(r.EIPs, ip)
+				}
+			}
+		}
+		if r.VPC != "" {
+			r.VPCPeeringConnections, r.RequestedVPCPeeringConnections = vpcPeeringConnections(peeringConnections[n.Region], r.VPC)
+		}
+		sort.Strings(r.NATGateways)
+		sort.Strings(r.EIPs)
+		r.MonthlyCost = prices.MonthlyCost(r)
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// vpcPeeringConnections returns the IDs of the active peering connections to
+// or from the given VPC and, separately, those the VPC requested.
+func vpcPeeringConnections(pcs []awsec2.VPCPeeringConnection, vpcId string) (all, requested []string) {
+	for _, pc := range pcs {
+		if pc.Status == nil || pc.Status.Code != types.VpcPeeringConnectionStateReasonCodeActive {
+			continue
+		}
+		id := aws.ToString(pc.VpcPeeringConnectionId)
+		if pc.RequesterVpcInfo != nil && aws.ToString(pc.RequesterVpcInfo.VpcId) == vpcId {
+			all = append(all, id)
+			requested = append(requested, id)
+		} else if pc.AccepterVpcInfo != nil && aws.ToString(pc.AccepterVpcInfo.VpcId) == vpcId {
+			all = append(all, id)
+		}
+	}
+	sort.Strings(all)
+	sort.Strings(requested)
+	return
+}
@@ -0,0 +1,101 @@
+package networks
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/src-bin/substrate/awsec2"
+)
+
+func TestMonthlyCost(t *testing.T) {
+	p := DefaultPrices()
+	p.VPCPeeringConnectionPerHour = 0.01
+	p.Regions = map[string]*RegionalPrices{
+		"eu-west-1": {NATGatewayPerHour: aws.Float64(0.1)},
+		"us-east-2": {NATGatewayPerHour: aws.Float64(0)},
+	}
+	r := &VPCReport{
+		Region:                         "us-west-2",
+		NATGateways:                    []string{"nat-1", "nat-2", "nat-3"},
+		EIPs:                           []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+		VPCPeeringConnections:          []string{"pcx-1", "pcx-2"},
+		RequestedVPCPeeringConnections: []string{"pcx-1"}, // pcx-2 is counted in the VPC that requested it
+	}
+	if cost := p.MonthlyCost(r); cost < 116.79 || cost > 116.81 {
+		t.Errorf("p.MonthlyCost(r) in us-west-2: %.2f != 116.80", cost)
+	}
+	r.Region = "eu-west-1"
+	if cost := p.MonthlyCost(r); cost < 237.24 || cost > 237.26 { // EIPs still at the top-level price
+		t.Errorf("p.MonthlyCost(r) in eu-west-1: %.2f != 237.25", cost)
+	}
+	r.Region = "us-east-2"
+	if cost := p.MonthlyCost(r); cost < 18.24 || cost > 18.26 { // NAT Gateways overridden to free
+		t.Errorf("p.MonthlyCost(r) in us-east-2: %.2f != 18.25", cost)
+	}
+}
+
+func TestVPCPeeringConnections(t *testing.T) {
+	active := &types.VpcPeeringConnectionStateReason{Code: types.VpcPeeringConnectionStateReasonCodeActive}
+	pcs := []awsec2.VPCPeeringConnection{
+		{
+			AccepterVpcInfo:        &types.VpcPeeringConnectionVpcInfo{VpcId: aws.String("vpc-b")},
+			RequesterVpcInfo:       &types.VpcPeeringConnectionVpcInfo{VpcId: aws.String("vpc-a")},
+			Status:                 active,
+			VpcPeeringConnectionId: aws.String("pcx-1"),
+		},
+		{
+			AccepterVpcInfo:        &types.VpcPeeringConnectionVpcInfo{VpcId: aws.String("vpc-a")},
+			RequesterVpcInfo:       &types.VpcPeeringConnectionVpcInfo{VpcId: aws.String("vpc-c")},
+			Status:                 active,
+			VpcPeeringConnectionId: aws.String("pcx-2"),
+		},
+		{
+			AccepterVpcInfo:        &types.VpcPeeringConnectionVpcInfo{VpcId: aws.String("vpc-c")},
+			RequesterVpcInfo:       &types.VpcPeeringConnectionVpcInfo{VpcId: aws.String("vpc-a")},
+			Status:                 &types.VpcPeeringConnectionStateReason{Code: types.VpcPeeringConnectionStateReasonCodeDeleted},
+			VpcPeeringConnectionId: aws.String("pcx-3"),
+		},
+	}
+	all, requested := vpcPeeringConnections(pcs, "vpc-a")
+	if !reflect.DeepEqual(all, []string{"pcx-1", "pcx-2"}) {
+		t.Errorf("all: %v", all)
+	}
+	if !reflect.DeepEqual(requested, []string{"pcx-1"}) {
+		t.Errorf("requested: %v", requested)
+	}
+	if _, requested := vpcPeeringConnections(pcs, "vpc-b"); len(requested) != 0 {
+		t.Errorf("vpc-b requested: %v", requested)
+	}
+}
+
+func TestReadPrices(t *testing.T) {
+	dirname, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(dirname)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ReadPrices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, DefaultPrices()) {
+		t.Errorf("ReadPrices() without %s: %+v != %+v", PricesFilename, p, DefaultPrices())
+	}
+
+	if err := os.WriteFile(PricesFilename, []byte(`{"NATGatewayPerHour": 0.05}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = ReadPrices(); err != nil {
+		t.Fatal(err)
+	}
+	if p.NATGatewayPerHour != 0.05 || p.EIPPerHour != DefaultPrices().EIPPerHour {
+		t.Errorf("ReadPrices() with %s: %+v", PricesFilename, p)
+	}
+}
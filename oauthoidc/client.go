@@ -25,8 +25,10 @@ const (
 type Client struct {
 	AccessToken   string
 	ClientId      string
+	GroupRoles    *GroupRoles // optional
+	RoleNameClaim string      // only for generic OIDC IdPs; see OIDCRoleNameClaimDefaultValue
 	clientSecret  string
-	memoizedKeys  []*Key
+	keys          *keyCache // shared by all copies
 	pathQualifier PathQualifier
 	provider      Provider
 }
@@ -36,12 +38,14 @@ func NewClient(
 	cfg *awscfg.Config,
 	clientId string,
 	clientSecretTimestamp string, // for finding the real client secret in Secrets Manager
+	provider Provider,
 	pathQualifier PathQualifier,
 ) (*Client, error) {
 	c := &Client{
 		ClientId:      clientId,
+		keys:          &keyCache{},
 		pathQualifier: pathQualifier,
-		provider:      IdPName(clientId),
+		provider:      provider,
 	}
 
 	chErr := make(chan error) // the first philosopher to dine
@@ -86,8 +90,10 @@ func NewClient(
 func (c *Client) Copy() *Client {
 	return &Client{
 		ClientId:      c.ClientId,
+		GroupRoles:    c.GroupRoles,
+		RoleNameClaim: c.RoleNameClaim,
 		clientSecret:  c.clientSecret,
-		memoizedKeys:  c.memoizedKeys,
+		keys:          c.keys,
 		pathQualifier: c.pathQualifier,
 		provider:      c.provider,
 	}
@@ -115,27 +121,12 @@ func (c *Client) GetURL(u *url.URL, query url.Values, i interface{}) (*http.Resp
 
 func (c *Client) IsAzureAD() bool { return c.provider == AzureAD }
 
+func (c *Client) IsGeneric() bool { return c.provider == Generic }
+
 func (c *Client) IsGoogle() bool { return c.provider == Google }
 
 func (c *Client) IsOkta() bool { return c.provider == Okta }
 
-// Keys returns the OAuth OIDC provider's current list of public keys,
-// memoizing the response for the rest of this process's lifetime.
-// Google's Cache-Control header suggests they rotate keys every few hours;
-// Okta's suggests they rotate keys about every two months. This client is
-// expected to run in Lambda so it seems safe to not invalidate.
-func (c *Client) Keys() ([]*Key, error) {
-	if c.memoizedKeys != nil {
-		return c.memoizedKeys, nil
-	}
-	doc := &KeysResponse{}
-	if _, _, err := c.Get(Keys, nil, doc); err != nil {
-		return nil, err
-	}
-	c.memoizedKeys = doc.Keys
-	return c.memoizedKeys, nil
-}
-
 // Post requests the given path with the given body (form-encoded) from the
 // client's host and unmarshals the JSON response body into the given
 // interface{}.  It returns the *http.Response, though its Body field is not
@@ -160,10 +151,30 @@ func (c *Client) PostURL(u *url.URL, body url.Values, i interface{}) (*http.Resp
 
 func (c *Client) Provider() Provider { return c.provider }
 
+// Refresh exchanges a refresh token for new tokens. The response may or may
+// not include a new refresh token, depending on whether the IdP rotates them,
+// and, if it does, the old one is no longer valid.
+func (c *Client) Refresh(refreshToken string) (*TokenResponse, error) {
+	doc := &TokenResponse{}
+	resp, body, err := c.Post(Token, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	}, doc)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, RefreshError(fmt.Sprintf("%s %s", resp.Status, body))
+	}
+	return doc, nil
+}
+
 func (c *Client) RoleNameFromIdP(user string) (string, error) {
 	switch c.provider {
 	case AzureAD:
 		return roleNameFromAzureADIdP(c, user)
+	case Generic:
+		return roleNameFromGenericIdP(c, user)
 	case Google:
 		return roleNameFromGoogleIdP(c, user)
 	case Okta:
@@ -214,11 +225,13 @@ func (c *Client) request(method string, u *url.URL) *http.Request {
 }
 
 // IdPName detects what sort of IdP this is, definitively, and covering all
-// the supported IdPs, from the structure of the client ID. If this ever
-// becomes impossible then we'll have to ask or just name things so that
-// it doesn't matter.
-func IdPName(clientId string) Provider {
-	if strings.HasSuffix(clientId, ".apps.googleusercontent.com") {
+// the supported IdPs, from the structure of the client ID. Generic OIDC IdPs'
+// client IDs are as unstructured as Okta's so those are identified by having
+// an issuer, which may be empty or OIDCIssuerValueForNonOIDCIdP otherwise.
+func IdPName(clientId, issuer string) Provider {
+	if issuer != "" && issuer != OIDCIssuerValueForNonOIDCIdP {
+		return Generic
+	} else if strings.HasSuffix(clientId, ".apps.googleusercontent.com") {
 		return Google
 	} else if ok, _ := regexp.MatchString(
 		"^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$",
@@ -238,6 +251,7 @@ type Provider string
 
 const (
 	AzureAD Provider = "Azure AD"
+	Generic Provider = "generic OIDC"
 	Google  Provider = "Google"
 	Okta    Provider = "Okta"
 )
@@ -0,0 +1,53 @@
+package oauthoidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRefresh(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Basic ") {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "refresh-token" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-token",
+			"expires_in":    3600,
+			"id_token":      "id-token",
+			"refresh_token": "rotated-refresh-token",
+			"token_type":    "Bearer",
+		})
+	}))
+	defer s.Close()
+	c := &Client{
+		ClientId:     "client-id",
+		clientSecret: "client-secret",
+		pathQualifier: func(UnqualifiedPath) *url.URL {
+			u, _ := url.Parse(s.URL)
+			return u
+		},
+	}
+
+	doc, err := c.Refresh("refresh-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.AccessToken != "access-token" || doc.IDToken != "id-token" || doc.RefreshToken != "rotated-refresh-token" {
+		t.Fatalf("%+v", doc)
+	}
+
+	if _, err := c.Refresh("revoked-refresh-token"); err == nil {
+		t.Fatal("expected RefreshError")
+	} else if _, ok := err.(RefreshError); !ok {
+		t.Fatalf("expected RefreshError, got %v", err)
+	}
+}
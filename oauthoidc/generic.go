@@ -0,0 +1,124 @@
+package oauthoidc
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	OIDCIssuer                    = "OIDC_ISSUER"            // Lambda environment variable name
+	OIDCIssuerValueForNonOIDCIdP  = "unused-by-non-OIDC-IdP" // sentinel value
+	OIDCRoleNameClaim             = "OIDC_ROLE_NAME_CLAIM"   // Lambda environment variable name
+	OIDCRoleNameClaimDefaultValue = "AWS_RoleName"
+)
+
+// DiscoveryDocument is the subset of an OpenID Connect provider's
+// configuration, served at /.well-known/openid-configuration relative to its
+// issuer, that Substrate needs to use it as an IdP. See
+// <https://openid.net/specs/openid-connect-discovery-1_0.html>.
+type DiscoveryDocument struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	Issuer                string `json:"issuer"`
+	JWKSURI               string `json:"jwks_uri"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// Discover fetches and validates the discovery document for the given
+// issuer. Keycloak, Auth0, JumpCloud, OneLogin, and every other OpenID
+// Connect provider worth using serves one.
+func Discover(issuer string) (*DiscoveryDocument, error) {
+	u := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, DiscoveryError(fmt.Sprintf("GET %s responded %s", u, resp.Status))
+	}
+	doc := &DiscoveryDocument{}
+	if _, _, err := unmarshalJSON(resp, doc); err != nil {
+		return nil, err
+	}
+
+	// The issuer in the document must match exactly else ID tokens, whose iss
+	// claim is compared to it, won't verify.
+	if doc.Issuer != issuer {
+		return nil, VerificationError{"issuer", doc.Issuer, issuer}
+	}
+
+	for name, endpoint := range map[string]string{
+		"authorization_endpoint": doc.AuthorizationEndpoint,
+		"jwks_uri":               doc.JWKSURI,
+		"token_endpoint":         doc.TokenEndpoint,
+		"userinfo_endpoint":      doc.UserinfoEndpoint,
+	} {
+		if endpoint == "" {
+			return nil, DiscoveryError(fmt.Sprintf("%s missing from %s", name, u))
+		}
+		if _, err := url.Parse(endpoint); err != nil {
+			return nil, DiscoveryError(fmt.Sprintf("%s in %s: %v", name, u, err))
+		}
+	}
+
+	return doc, nil
+}
+
+func DiscoveryPathQualifier(doc *DiscoveryDocument) PathQualifier {
+	return func(p UnqualifiedPath) *url.URL {
+		var s string
+		switch p {
+		case Authorize:
+			s = doc.AuthorizationEndpoint
+		case Issuer:
+			s = doc.Issuer
+		case Keys:
+			s = doc.JWKSURI
+		case Token:
+			s = doc.TokenEndpoint
+		case User:
+			s = doc.UserinfoEndpoint
+		default:
+			panic("unreachable")
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			panic(err) // unreachable because Discover parsed every endpoint
+		}
+		return u
+	}
+}
+
+func roleNameFromGenericIdP(c *Client, user string) (string, error) {
+	claim := c.RoleNameClaim
+	if claim == "" {
+		claim = OIDCRoleNameClaimDefaultValue
+	}
+	var claims map[string]interface{}
+	_, _, err := c.Get(User, nil, &claims)
+	if err != nil {
+		return "", err
+	}
+	//log.Printf("%+v", claims)
+	if roleName, ok := claims[claim].(string); ok && roleName != "" {
+		return roleName, nil
+	}
+	return "", UndefinedRoleClaimError{claim, user}
+}
+
+type DiscoveryError string
+
+func (err DiscoveryError) Error() string {
+	return fmt.Sprintf("DiscoveryError: %s", string(err))
+}
+
+type UndefinedRoleClaimError struct {
+	Claim, User string
+}
+
+func (err UndefinedRoleClaimError) Error() string {
+	return fmt.Sprintf("UndefinedRoleClaimError: %s doesn't have the %q claim", err.User, err.Claim)
+}
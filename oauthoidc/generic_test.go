@@ -0,0 +1,143 @@
+package oauthoidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeIdP serves a discovery document and a userinfo endpoint that returns
+// the given claims to requests bearing the access token "access-token".
+func fakeIdP(t *testing.T, claims map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	mux.HandleFunc("/realms/example/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"authorization_endpoint": s.URL + "/realms/example/protocol/openid-connect/auth",
+			"issuer":                 s.URL + "/realms/example",
+			"jwks_uri":               s.URL + "/realms/example/protocol/openid-connect/certs",
+			"token_endpoint":         s.URL + "/realms/example/protocol/openid-connect/token",
+			"userinfo_endpoint":      s.URL + "/realms/example/protocol/openid-connect/userinfo",
+		})
+	})
+	mux.HandleFunc("/realms/example/protocol/openid-connect/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			http.Error(w, `{"error":"invalid_token"}`, http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(claims)
+	})
+	return s
+}
+
+func TestDiscover(t *testing.T) {
+	s := fakeIdP(t, nil)
+	doc, err := Discover(s.URL + "/realms/example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pathQualifier := DiscoveryPathQualifier(doc)
+	for p, expected := range map[UnqualifiedPath]string{
+		Authorize: s.URL + "/realms/example/protocol/openid-connect/auth",
+		Issuer:    s.URL + "/realms/example",
+		Keys:      s.URL + "/realms/example/protocol/openid-connect/certs",
+		Token:     s.URL + "/realms/example/protocol/openid-connect/token",
+		User:      s.URL + "/realms/example/protocol/openid-connect/userinfo",
+	} {
+		if actual := pathQualifier(p).String(); actual != expected {
+			t.Errorf("%s: actual: %s, expected: %s", p, actual, expected)
+		}
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	s := fakeIdP(t, nil)
+	_, err := Discover(s.URL + "/realms/example/") // trailing slash makes it a different issuer
+	if _, ok := err.(VerificationError); !ok {
+		t.Fatalf("expected VerificationError, got %v", err)
+	}
+}
+
+func TestDiscoverNotFound(t *testing.T) {
+	s := fakeIdP(t, nil)
+	if _, err := Discover(s.URL + "/realms/nonexistent"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestDiscoverServerError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("<html><body>Internal Server Error</body></html>"))
+	}))
+	defer s.Close()
+	if _, err := Discover(s.URL); err == nil {
+		t.Fatal("expected DiscoveryError")
+	} else if _, ok := err.(DiscoveryError); !ok {
+		t.Fatalf("expected DiscoveryError, got %v", err)
+	}
+}
+
+func TestIdPName(t *testing.T) {
+	for _, c := range []struct {
+		clientId, issuer string
+		expected         Provider
+	}{
+		{"example.apps.googleusercontent.com", "", Google},
+		{"01234567-89ab-cdef-0123-456789abcdef", OIDCIssuerValueForNonOIDCIdP, AzureAD},
+		{"0oa1b2c3d4e5f6g7h8i9", "", Okta},
+		{"substrate", "https://keycloak.example.com/realms/example", Generic},
+	} {
+		if actual := IdPName(c.clientId, c.issuer); actual != c.expected {
+			t.Errorf("IdPName(%q, %q): actual: %s, expected: %s", c.clientId, c.issuer, actual, c.expected)
+		}
+	}
+}
+
+func TestRoleNameFromGenericIdP(t *testing.T) {
+	s := fakeIdP(t, map[string]interface{}{
+		"AWS_RoleName":                 "Administrator",
+		"email":                        "user@example.com",
+		"https://example.com/aws_role": "Auditor",
+	})
+	doc, err := Discover(s.URL + "/realms/example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{
+		AccessToken:   "access-token",
+		pathQualifier: DiscoveryPathQualifier(doc),
+		provider:      Generic,
+	}
+
+	roleName, err := c.RoleNameFromIdP("user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roleName != "Administrator" {
+		t.Fatalf("actual: %s, expected: Administrator", roleName)
+	}
+
+	c.RoleNameClaim = "https://example.com/aws_role"
+	roleName, err = c.RoleNameFromIdP("user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roleName != "Auditor" {
+		t.Fatalf("actual: %s, expected: Auditor", roleName)
+	}
+
+	c.RoleNameClaim = "nonexistent"
+	if _, err := c.RoleNameFromIdP("user@example.com"); err == nil {
+		t.Fatal("expected UndefinedRoleClaimError")
+	}
+
+	c.RoleNameClaim = ""
+	c.AccessToken = "wrong"
+	if _, err := c.RoleNameFromIdP("user@example.com"); err == nil {
+		t.Fatal("expected an error for a bad access token")
+	}
+}
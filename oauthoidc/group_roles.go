@@ -0,0 +1,89 @@
+package oauthoidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const (
+	GroupRolesDefaultClaim = "groups"
+	GroupRolesJSON         = "GROUP_ROLES_JSON" // Lambda environment variable name
+)
+
+// GroupRoles maps the groups in an ID token's groups claim, or the claim
+// named by Claim, to the IAM roles members of each group may assume. It's
+// optional and complements, rather than replaces, the single role name
+// each IdP may define per user.
+type GroupRoles struct {
+	Claim  string              `json:"claim,omitempty"` // default GroupRolesDefaultClaim
+	Groups map[string][]string `json:"groups"`
+}
+
+func ParseGroupRoles(b []byte) (*GroupRoles, error) {
+	g := &GroupRoles{}
+	if err := json.Unmarshal(b, g); err != nil {
+		return nil, err
+	}
+	if len(g.Groups) == 0 {
+		return nil, GroupRolesError("no groups")
+	}
+	for group, roleNames := range g.Groups {
+		if len(roleNames) == 0 {
+			return nil, GroupRolesError(fmt.Sprintf("no role names for group %q", group))
+		}
+	}
+	return g, nil
+}
+
+// RoleNames returns the sorted, deduplicated role names allowed by all the
+// groups the ID token says its subject is a member of. It's safe to call on
+// a nil *GroupRoles, which allows no role names.
+func (g *GroupRoles) RoleNames(t *IDToken) []string {
+	if g == nil || t == nil {
+		return nil
+	}
+	claim := g.Claim
+	if claim == "" {
+		claim = GroupRolesDefaultClaim
+	}
+	var groups []string
+	switch v := t.Claims[claim].(type) {
+	case string: // some IdPs send a single group as a string instead of an array
+		groups = []string{v}
+	case []interface{}:
+		for _, group := range v {
+			if s, ok := group.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	set := make(map[string]bool)
+	for _, group := range groups {
+		for _, roleName := range g.Groups[group] {
+			set[roleName] = true
+		}
+	}
+	roleNames := make([]string, 0, len(set))
+	for roleName := range set {
+		roleNames = append(roleNames, roleName)
+	}
+	sort.Strings(roleNames)
+	return roleNames
+}
+
+// String returns g as compact JSON, suitable for a Lambda environment
+// variable, which ParseGroupRoles can parse.
+func (g *GroupRoles) String() string {
+	b, err := json.Marshal(g)
+	if err != nil {
+		panic(err) // unreachable because GroupRoles is only strings
+	}
+	return string(b)
+}
+
+type GroupRolesError string
+
+func (err GroupRolesError) Error() string {
+	return fmt.Sprintf("GroupRolesError: %s", string(err))
+}
@@ -0,0 +1,101 @@
+package oauthoidc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGroupRoles(t *testing.T) {
+	g, err := ParseGroupRoles([]byte(`{
+		"groups": {
+			"aws-admins": ["Administrator"],
+			"engineering": ["Developer", "Auditor"],
+			"security": ["Auditor"]
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idToken := &IDToken{}
+	if err := json.Unmarshal([]byte(`{
+		"email": "user@example.com",
+		"groups": ["engineering", "security", "unmapped"]
+	}`), idToken); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := g.RoleNames(idToken), []string{"Auditor", "Developer"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("actual: %v, expected: %v", actual, expected)
+	}
+	if idToken.Email != "user@example.com" || !reflect.DeepEqual(idToken.Groups, Strings{"engineering", "security", "unmapped"}) {
+		t.Fatalf("well-known claims not unmarshaled: %+v", idToken)
+	}
+}
+
+func TestGroupRolesClaim(t *testing.T) {
+	g, err := ParseGroupRoles([]byte(`{
+		"claim": "https://example.com/roles",
+		"groups": {"aws-admins": ["Administrator"]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idToken := &IDToken{}
+	if err := json.Unmarshal([]byte(`{
+		"groups": ["engineering"],
+		"https://example.com/roles": "aws-admins"
+	}`), idToken); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := g.RoleNames(idToken), []string{"Administrator"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("actual: %v, expected: %v", actual, expected)
+	}
+
+	// A round trip through the Lambda environment variable loses nothing.
+	g2, err := ParseGroupRoles([]byte(g.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, g2) {
+		t.Fatalf("%+v != %+v", g, g2)
+	}
+}
+
+func TestGroupRolesSingleGroup(t *testing.T) {
+	g, err := ParseGroupRoles([]byte(`{"groups": {"aws-admins": ["Administrator"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idToken := &IDToken{}
+	if err := json.Unmarshal([]byte(`{
+		"email": "user@example.com",
+		"groups": "aws-admins"
+	}`), idToken); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(idToken.Groups, Strings{"aws-admins"}) {
+		t.Fatalf("actual: %v, expected: [aws-admins]", idToken.Groups)
+	}
+	if actual, expected := g.RoleNames(idToken), []string{"Administrator"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("actual: %v, expected: %v", actual, expected)
+	}
+}
+
+func TestGroupRolesInvalid(t *testing.T) {
+	for _, s := range []string{
+		`{}`,
+		`{"groups": {"aws-admins": []}}`,
+		`not JSON`,
+	} {
+		if _, err := ParseGroupRoles([]byte(s)); err == nil {
+			t.Errorf("expected an error parsing %s", s)
+		}
+	}
+}
+
+func TestGroupRolesNil(t *testing.T) {
+	var g *GroupRoles
+	if roleNames := g.RoleNames(&IDToken{Groups: []string{"aws-admins"}}); roleNames != nil {
+		t.Fatal(roleNames)
+	}
+}
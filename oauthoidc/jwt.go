@@ -2,11 +2,15 @@ package oauthoidc
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"strings"
 )
 
@@ -61,22 +65,12 @@ func ParseJWT(s string, p JWTPayload) (*JWT, error) {
 
 func (jwt *JWT) Verify(c *Client) error {
 
-	key, err := jwt.findKey(c)
+	key, err := c.Key(jwt.Header.KeyID)
 	if err != nil {
 		return err
 	}
 
-	pub, err := key.RSAPublicKey()
-	if err != nil {
-		return err
-	}
-	hashed := sha256.Sum256(jwt.signingInput)
-	if err := rsa.VerifyPKCS1v15(
-		pub,
-		crypto.SHA256,
-		hashed[:],
-		[]byte(jwt.Signature),
-	); err != nil {
+	if err := jwt.verifySignature(key); err != nil {
 		return err
 	}
 
@@ -87,17 +81,80 @@ func (jwt *JWT) Verify(c *Client) error {
 	return nil
 }
 
-func (jwt *JWT) findKey(c *Client) (*Key, error) {
-	keys, err := c.Keys()
-	if err != nil {
-		return nil, err
+// verifySignature verifies the JWT's signature using key according to the
+// algorithm in its header, which must agree with the key's own algorithm,
+// if it declares one, and type. Only the algorithms IdPs actually use are
+// supported; in particular, "none" and the HMAC algorithms are not.
+func (jwt *JWT) verifySignature(key *Key) error {
+	alg := jwt.Header.Algorithm
+	if key.Algorithm != "" && key.Algorithm != alg {
+		return VerificationError{"alg", alg, key.Algorithm}
 	}
-	for _, key := range keys {
-		if jwt.Header.KeyID == key.KeyID {
-			return key, nil
+
+	switch alg {
+
+	case "ES256", "ES384":
+		pub, err := key.ECDSAPublicKey()
+		if err != nil {
+			return err
+		}
+		var hashed []byte
+		if alg == "ES256" {
+			if pub.Curve != elliptic.P256() {
+				return UnsupportedCurveError(key.Curve)
+			}
+			sum := sha256.Sum256(jwt.signingInput)
+			hashed = sum[:]
+		} else {
+			if pub.Curve != elliptic.P384() {
+				return UnsupportedCurveError(key.Curve)
+			}
+			sum := sha512.Sum384(jwt.signingInput)
+			hashed = sum[:]
+		}
+
+		// JWS encodes ECDSA signatures as r || s, each the size of the
+		// curve's order, rather than in ASN.1 like crypto/ecdsa expects.
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(jwt.Signature) != 2*size {
+			return InvalidJWTError(fmt.Sprintf("%s signature is %d bytes long instead of %d", alg, len(jwt.Signature), 2*size))
 		}
+		r := new(big.Int).SetBytes(jwt.Signature[:size])
+		s := new(big.Int).SetBytes(jwt.Signature[size:])
+		if !ecdsa.Verify(pub, hashed, r, s) {
+			return InvalidJWTError(fmt.Sprintf("%s signature verification failed", alg))
+		}
+		return nil
+
+	case "PS256":
+		pub, err := key.RSAPublicKey()
+		if err != nil {
+			return err
+		}
+		hashed := sha256.Sum256(jwt.signingInput)
+		return rsa.VerifyPSS(
+			pub,
+			crypto.SHA256,
+			hashed[:],
+			[]byte(jwt.Signature),
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}, // per RFC 7518 section 3.5
+		)
+
+	case "RS256":
+		pub, err := key.RSAPublicKey()
+		if err != nil {
+			return err
+		}
+		hashed := sha256.Sum256(jwt.signingInput)
+		return rsa.VerifyPKCS1v15(
+			pub,
+			crypto.SHA256,
+			hashed[:],
+			[]byte(jwt.Signature),
+		)
+
 	}
-	return nil, KeyNotFoundError(jwt.Header.KeyID)
+	return UnsupportedAlgorithmError(alg)
 }
 
 type JWTHeader struct {
@@ -161,6 +218,12 @@ func (err MalformedJWTError) Error() string {
 	return fmt.Sprintf("MalformedJWTError: %s", string(err))
 }
 
+type UnsupportedAlgorithmError string
+
+func (err UnsupportedAlgorithmError) Error() string {
+	return fmt.Sprintf("UnsupportedAlgorithmError: %q", string(err))
+}
+
 type VerificationError struct {
 	Field            string
 	Actual, Expected string
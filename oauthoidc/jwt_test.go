@@ -0,0 +1,251 @@
+package oauthoidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testClientId = "test-client-id"
+
+// testIdP serves a JWKS whose keys may be changed and counts how many times
+// it's been fetched.
+type testIdP struct {
+	*httptest.Server
+	fetches atomic.Int32
+	mu      sync.Mutex
+	keys    []*Key
+}
+
+func newTestIdP(t *testing.T, keys ...*Key) *testIdP {
+	idp := &testIdP{keys: keys}
+	idp.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idp.fetches.Add(1)
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		json.NewEncoder(w).Encode(KeysResponse{idp.keys})
+	}))
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *testIdP) client() *Client {
+	return &Client{
+		ClientId: testClientId,
+		keys:     &keyCache{},
+		pathQualifier: func(p UnqualifiedPath) *url.URL {
+			u, _ := url.Parse(idp.URL)
+			if p != Issuer {
+				u.Path = "/" + string(p)
+			}
+			return u
+		},
+	}
+}
+
+func (idp *testIdP) setKeys(keys ...*Key) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys = keys
+}
+
+func ecKey(t *testing.T, kid string, curve elliptic.Curve, crv string) (*ecdsa.PrivateKey, *Key) {
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	return priv, &Key{
+		Curve:   crv,
+		KeyID:   kid,
+		KeyType: "EC",
+		X:       base64.RawURLEncoding.EncodeToString(priv.X.FillBytes(make([]byte, size))),
+		Y:       base64.RawURLEncoding.EncodeToString(priv.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+func rsaKey(t *testing.T, kid string) (*rsa.PrivateKey, *Key) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, &Key{
+		Exponent: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(priv.E)).Bytes()),
+		KeyID:    kid,
+		KeyType:  "RSA",
+		Modulus:  base64.RawURLEncoding.EncodeToString(priv.N.Bytes()),
+	}
+}
+
+// sign returns a JWT with a valid ID token payload for issuer signed by priv
+// using alg.
+func sign(t *testing.T, alg, kid, issuer string, priv crypto.Signer) string {
+	header, _ := json.Marshal(JWTHeader{Algorithm: alg, KeyID: kid})
+	payload, _ := json.Marshal(map[string]interface{}{
+		"aud": testClientId,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+		"iss": issuer,
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var (
+		sig []byte
+		err error
+	)
+	switch alg {
+	case "ES256", "ES384":
+		var hashed []byte
+		if alg == "ES256" {
+			sum := sha256.Sum256([]byte(signingInput))
+			hashed = sum[:]
+		} else {
+			sum := sha512.Sum384([]byte(signingInput))
+			hashed = sum[:]
+		}
+		ecPriv := priv.(*ecdsa.PrivateKey)
+		r, s, err := ecdsa.Sign(rand.Reader, ecPriv, hashed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (ecPriv.Curve.Params().BitSize + 7) / 8
+		sig = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	case "PS256":
+		hashed := sha256.Sum256([]byte(signingInput))
+		sig, err = rsa.SignPSS(rand.Reader, priv.(*rsa.PrivateKey), crypto.SHA256, hashed[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "RS256":
+		hashed := sha256.Sum256([]byte(signingInput))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, priv.(*rsa.PrivateKey), crypto.SHA256, hashed[:])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	ec256Priv, ec256Key := ecKey(t, "ec256", elliptic.P256(), "P-256")
+	ec384Priv, ec384Key := ecKey(t, "ec384", elliptic.P384(), "P-384")
+	rsaPriv, rsaPub := rsaKey(t, "rsa")
+	idp := newTestIdP(t, ec256Key, ec384Key, rsaPub)
+	c := idp.client()
+
+	for _, tc := range []struct {
+		alg, kid string
+		priv     crypto.Signer
+	}{
+		{"ES256", "ec256", ec256Priv},
+		{"ES384", "ec384", ec384Priv},
+		{"PS256", "rsa", rsaPriv},
+		{"RS256", "rsa", rsaPriv},
+	} {
+		s := sign(t, tc.alg, tc.kid, idp.URL, tc.priv)
+		if _, err := ParseAndVerifyJWT(s, c, &IDToken{}); err != nil {
+			t.Errorf("%s: %v", tc.alg, err)
+		}
+
+		// Tamper with the payload and make sure verification fails.
+		parts := strings.Split(s, ".")
+		parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"aud":"` + testClientId + `","exp":9999999999}`))
+		if _, err := ParseAndVerifyJWT(strings.Join(parts, "."), c, &IDToken{}); err == nil {
+			t.Errorf("%s: tampered JWT verified", tc.alg)
+		}
+	}
+}
+
+func TestVerifyAlgorithmMismatch(t *testing.T) {
+	ecPriv, ecPub := ecKey(t, "ec", elliptic.P256(), "P-256")
+	rsaPriv, rsaPub := rsaKey(t, "rsa")
+	rsaPub.Algorithm = "RS256"
+	idp := newTestIdP(t, ecPub, rsaPub)
+	c := idp.client()
+
+	// An RSA-PSS signature by a key that declares it's for RS256 only.
+	if _, err := ParseAndVerifyJWT(sign(t, "PS256", "rsa", idp.URL, rsaPriv), c, &IDToken{}); err == nil {
+		t.Error("PS256 verified with an RS256 key")
+	}
+
+	// An ES256 header naming an RSA key.
+	if _, err := ParseAndVerifyJWT(sign(t, "ES256", "rsa", idp.URL, ecPriv), c, &IDToken{}); err == nil {
+		t.Error("ES256 verified with an RSA key")
+	}
+
+	// An ES384 header naming a P-256 key.
+	if _, err := ParseAndVerifyJWT(sign(t, "ES384", "ec", idp.URL, ecPriv), c, &IDToken{}); err == nil {
+		t.Error("ES384 verified with a P-256 key")
+	}
+
+	// No signature at all.
+	s := sign(t, "RS256", "rsa", idp.URL, rsaPriv)
+	parts := strings.Split(s, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa"}`))
+	parts[2] = ""
+	if _, err := ParseAndVerifyJWT(strings.Join(parts, "."), c, &IDToken{}); err == nil {
+		t.Error(`"none" verified`)
+	}
+}
+
+func TestKeysCache(t *testing.T) {
+	oldPriv, oldPub := rsaKey(t, "old")
+	idp := newTestIdP(t, oldPub)
+	c := idp.client()
+
+	// Many verifications, including by copies, fetch the JWKS once.
+	for i := 0; i < 3; i++ {
+		if _, err := ParseAndVerifyJWT(sign(t, "RS256", "old", idp.URL, oldPriv), c.Copy(), &IDToken{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fetches := idp.fetches.Load(); fetches != 1 {
+		t.Fatalf("%d fetches, expected 1", fetches)
+	}
+
+	// A new key ID causes one refetch.
+	newPriv, newPub := rsaKey(t, "new")
+	idp.setKeys(oldPub, newPub)
+	if _, err := ParseAndVerifyJWT(sign(t, "RS256", "new", idp.URL, newPriv), c, &IDToken{}); err != nil {
+		t.Fatal(err)
+	}
+	if fetches := idp.fetches.Load(); fetches != 2 {
+		t.Fatalf("%d fetches, expected 2", fetches)
+	}
+
+	// Unknown key IDs don't cause another refetch so soon after.
+	if _, err := c.Key("unknown"); err == nil {
+		t.Fatal("expected KeyNotFoundError")
+	}
+	if fetches := idp.fetches.Load(); fetches != 2 {
+		t.Fatalf("%d fetches, expected 2", fetches)
+	}
+
+	// The cache expires after KeysTTL.
+	c.keys.fetched = time.Now().Add(-KeysTTL)
+	if _, err := c.Key("new"); err != nil {
+		t.Fatal(err)
+	}
+	if fetches := idp.fetches.Load(); fetches != 3 {
+		t.Fatalf("%d fetches, expected 3", fetches)
+	}
+
+	// Stale keys are better than none when the IdP's unavailable.
+	c.keys.fetched = time.Now().Add(-KeysTTL)
+	idp.Close()
+	if _, err := c.Key("old"); err != nil {
+		t.Fatal(err)
+	}
+}
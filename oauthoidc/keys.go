@@ -0,0 +1,235 @@
+package oauthoidc
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/src-bin/substrate/ui"
+)
+
+const (
+	// KeysTTL is how long Client caches the IdP's JWKS. Google's
+	// Cache-Control header suggests they rotate keys every few hours; Okta's
+	// suggests about every two months. Either way, a key we've never seen
+	// causes a refetch, too.
+	KeysTTL = time.Hour
+
+	// keysRefetchInterval limits how often a JWT signed by a key we've never
+	// seen can cause a refetch, so that forged JWTs with random key IDs can't
+	// make the Intranet hammer the IdP.
+	keysRefetchInterval = time.Minute
+)
+
+type Key struct {
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv"` // EC only
+	Exponent  string `json:"e"`   // RSA only; comes base64-URL-encoded
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Modulus   string `json:"n"` // RSA only; comes base64-URL-encoded
+	Status    string `json:"status"`
+	Use       string `json:"use"`
+	X         string `json:"x"` // EC only; comes base64-URL-encoded
+	Y         string `json:"y"` // EC only; comes base64-URL-encoded
+}
+
+func (k *Key) ECDSAPublicKey() (*ecdsa.PublicKey, error) {
+	if k.KeyType != "EC" {
+		return nil, KeyTypeError{k.KeyID, k.KeyType, "EC"}
+	}
+	var (
+		curve     elliptic.Curve
+		ecdhCurve ecdh.Curve
+	)
+	switch k.Curve {
+	case "P-256":
+		curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+	default:
+		return nil, UnsupportedCurveError(k.Curve)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) > size || len(y) > size {
+		return nil, InvalidKeyError(k.KeyID)
+	}
+
+	// Validate the point is on the curve by way of crypto/ecdh, which takes
+	// the uncompressed encoding, 0x04 || x || y, each padded to size.
+	point := make([]byte, 1+2*size)
+	point[0] = 4
+	copy(point[1+size-len(x):1+size], x)
+	copy(point[1+2*size-len(y):], y)
+	if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+		return nil, InvalidKeyError(k.KeyID)
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+func (k *Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "EC":
+		return k.ECDSAPublicKey()
+	case "RSA":
+		return k.RSAPublicKey()
+	}
+	return nil, KeyTypeError{k.KeyID, k.KeyType, "EC or RSA"}
+}
+
+func (k *Key) RSAPublicKey() (*rsa.PublicKey, error) {
+	if k.KeyType != "RSA" {
+		return nil, KeyTypeError{k.KeyID, k.KeyType, "RSA"}
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(k.Exponent)
+	if err != nil {
+		return nil, err
+	}
+	if len(e) > 4 {
+		return nil, InvalidKeyError(k.KeyID)
+	}
+	e4 := make([]byte, 4)
+	copy(e4[len(e4)-len(e):], e) // it may come off the wire in too-compact a representation
+
+	n, err := base64.RawURLEncoding.DecodeString(k.Modulus)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &big.Int{}
+	return &rsa.PublicKey{
+		E: int(binary.BigEndian.Uint32(e4)),
+		N: i.SetBytes(n),
+	}, nil
+}
+
+type KeysResponse struct {
+	Keys []*Key `json:"keys"`
+}
+
+// keyCache is the IdP's JWKS as of fetched. It's shared by every copy of
+// a Client so that one Lambda process fetches the JWKS once per KeysTTL
+// rather than once per request.
+type keyCache struct {
+	mu        sync.Mutex
+	keys      []*Key
+	fetched   time.Time
+	refetched time.Time // the last refetch caused by an unknown key ID
+}
+
+// Key returns the IdP's public key with the given key ID, refetching the
+// JWKS if the cache is older than KeysTTL or if it's never heard of this key
+// ID (but no more than once every keysRefetchInterval).
+func (c *Client) Key(keyID string) (*Key, error) {
+	keys, err := c.Keys()
+	if err != nil {
+		return nil, err
+	}
+	if key := findKey(keys, keyID); key != nil {
+		return key, nil
+	}
+
+	c.keys.mu.Lock()
+	defer c.keys.mu.Unlock()
+	if time.Since(c.keys.refetched) < keysRefetchInterval {
+		return nil, KeyNotFoundError(keyID)
+	}
+	c.keys.refetched = time.Now()
+	if err := c.fetchKeys(); err != nil {
+		return nil, err
+	}
+	if key := findKey(c.keys.keys, keyID); key != nil {
+		return key, nil
+	}
+	return nil, KeyNotFoundError(keyID)
+}
+
+// Keys returns the OAuth OIDC provider's current list of public keys,
+// cached for KeysTTL. If they can't be refetched when the cache expires,
+// it logs the error and returns the stale keys, if any, since the IdP
+// being briefly unavailable shouldn't lock everyone out.
+func (c *Client) Keys() ([]*Key, error) {
+	if c.keys == nil {
+		c.keys = &keyCache{}
+	}
+	c.keys.mu.Lock()
+	defer c.keys.mu.Unlock()
+	if c.keys.keys != nil && time.Since(c.keys.fetched) < KeysTTL {
+		return c.keys.keys, nil
+	}
+	if err := c.fetchKeys(); err != nil {
+		if c.keys.keys == nil {
+			return nil, err
+		}
+		ui.PrintWithCaller(err)
+	}
+	return c.keys.keys, nil
+}
+
+// fetchKeys fetches the IdP's JWKS into the cache. Its caller must hold
+// c.keys.mu.
+func (c *Client) fetchKeys() error {
+	doc := &KeysResponse{}
+	resp, _, err := c.Get(Keys, nil, doc)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("GET %s responded %s", c.URL(Keys, nil), resp.Status)
+	}
+	c.keys.keys = doc.Keys
+	c.keys.fetched = time.Now()
+	return nil
+}
+
+func findKey(keys []*Key, keyID string) *Key {
+	for _, key := range keys {
+		if key.KeyID == keyID {
+			return key
+		}
+	}
+	return nil
+}
+
+type InvalidKeyError string
+
+func (err InvalidKeyError) Error() string {
+	return fmt.Sprintf("InvalidKeyError: key %s is malformed", string(err))
+}
+
+type KeyTypeError struct {
+	KeyID, Actual, Expected string
+}
+
+func (err KeyTypeError) Error() string {
+	return fmt.Sprintf("KeyTypeError: key %s has type %q but %s is required", err.KeyID, err.Actual, err.Expected)
+}
+
+type UnsupportedCurveError string
+
+func (err UnsupportedCurveError) Error() string {
+	return fmt.Sprintf("UnsupportedCurveError: %q", string(err))
+}
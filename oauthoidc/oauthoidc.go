@@ -1,43 +1,63 @@
 package oauthoidc
 
 import (
-	"crypto/rsa"
-	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"math/big"
 	"time"
 )
 
 type IDToken struct {
-	Address               map[string]string `json:"address"`
-	Audience              string            `json:"aud"`
-	AuthenticationMethods []string          `json:"amr"`
-	AuthenticationTime    int64             `json:"auth_time"`
-	DebugID               string            `json:"jti"`
-	Email                 string            `json:"email"`
-	EmailVerified         bool              `json:"email_verified"`
-	Expires               int64             `json:"exp"`
-	FamilyName            string            `json:"family_name"`
-	GivenName             string            `json:"given_name"`
-	Groups                []string          `json:"groups"`
-	IdentityProvider      string            `json:"idp"`
-	IssuedAt              int64             `json:"iat"`
-	Issuer                string            `json:"iss"`
-	Login                 string            `json:"login"`
-	Locale                string            `json:"locale"`
-	MiddleName            string            `json:"middle_name"`
-	Name                  string            `json:"name"`
-	Nonce                 string            `json:"nonce"`
-	Nickname              string            `json:"nickname"`
-	PhoneNumber           string            `json:"phone_number"`
-	PreferredUsername     string            `json:"preferred_username"`
-	ProfileURL            string            `json:"profile"`
-	Subject               string            `json:"sub"`
-	UpdatedAt             int64             `json:"updated_at"`
-	Version               interface{}       `json:"ver"` // Azure AD encodes this as a string; Okta encodes this as an integer; fucking hell, folks
-	ZoneInfo              string            `json:"zoneinfo"`
+	Address               map[string]string      `json:"address"`
+	Audience              string                 `json:"aud"`
+	AuthenticationMethods []string               `json:"amr"`
+	AuthenticationTime    int64                  `json:"auth_time"`
+	Claims                map[string]interface{} `json:"-"` // every claim, including those not enumerated here
+	DebugID               string                 `json:"jti"`
+	Email                 string                 `json:"email"`
+	EmailVerified         bool                   `json:"email_verified"`
+	Expires               int64                  `json:"exp"`
+	FamilyName            string                 `json:"family_name"`
+	GivenName             string                 `json:"given_name"`
+	Groups                Strings                `json:"groups"` // some IdPs send a single group as a string instead of an array
+	IdentityProvider      string                 `json:"idp"`
+	IssuedAt              int64                  `json:"iat"`
+	Issuer                string                 `json:"iss"`
+	Login                 string                 `json:"login"`
+	Locale                string                 `json:"locale"`
+	MiddleName            string                 `json:"middle_name"`
+	Name                  string                 `json:"name"`
+	Nonce                 string                 `json:"nonce"`
+	Nickname              string                 `json:"nickname"`
+	PhoneNumber           string                 `json:"phone_number"`
+	PreferredUsername     string                 `json:"preferred_username"`
+	ProfileURL            string                 `json:"profile"`
+	Subject               string                 `json:"sub"`
+	UpdatedAt             int64                  `json:"updated_at"`
+	Version               interface{}            `json:"ver"` // Azure AD encodes this as a string; Okta encodes this as an integer; fucking hell, folks
+	ZoneInfo              string                 `json:"zoneinfo"`
+}
+
+// UnmarshalJSON unmarshals the well-known claims into their fields, as usual,
+// and all the claims into Claims so that configurable claims may be read.
+func (t *IDToken) UnmarshalJSON(b []byte) error {
+	type idToken IDToken // no methods so no infinite recursion
+	if err := json.Unmarshal(b, (*idToken)(t)); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &t.Claims)
+}
+
+// Strings is a list of strings that also unmarshals from a single JSON
+// string, for claims that IdPs encode either way.
+type Strings []string
+
+func (ss *Strings) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*ss = Strings{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(ss))
 }
 
 func (t *IDToken) JSONString() (string, error) {
@@ -65,47 +85,19 @@ func (t *IDToken) Verify(c *Client) error {
 	return nil
 }
 
-type Key struct {
-	Algorithm string `json:"alg"`
-	Exponent  string `json:"e"` // comes base64-URL-encoded
-	KeyID     string `json:"kid"`
-	KeyType   string `json:"kty"`
-	Modulus   string `json:"n"` // comes base64-URL-encoded
-	Status    string `json:"status"`
-	Use       string `json:"use"`
-}
-
-func (k *Key) RSAPublicKey() (*rsa.PublicKey, error) {
-
-	e, err := base64.RawURLEncoding.DecodeString(k.Exponent)
-	if err != nil {
-		return nil, err
-	}
-	e4 := make([]byte, 4)
-	copy(e4[len(e4)-len(e):], e) // it may come off the wire in too-compact a representation
-
-	n, err := base64.RawURLEncoding.DecodeString(k.Modulus)
-	if err != nil {
-		return nil, err
-	}
-
-	i := &big.Int{}
-	return &rsa.PublicKey{
-		E: int(binary.BigEndian.Uint32(e4)),
-		N: i.SetBytes(n),
-	}, nil
+type TokenResponse struct {
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	Scope        string `json:"scope"`
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"` // only if offline access was requested and granted
 }
 
-type KeysResponse struct {
-	Keys []*Key `json:"keys"`
-}
+type RefreshError string
 
-type TokenResponse struct {
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
-	AccessToken string `json:"access_token"`
-	Scope       string `json:"scope"`
-	IDToken     string `json:"id_token"`
+func (err RefreshError) Error() string {
+	return fmt.Sprintf("RefreshError: %s", string(err))
 }
 
 type UndefinedRoleError string
@@ -0,0 +1,30 @@
+package oauthoidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+)
+
+// PKCEChallengeMethod is the only code challenge method worth using, per
+// RFC 7636 section 4.2.
+const PKCEChallengeMethod = "S256"
+
+// PKCEChallenge returns the code_challenge parameter for the authorize
+// request that corresponds to the given code verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PKCEVerifier returns a new random code_verifier parameter for the token
+// request, which is 43 characters long, the minimum RFC 7636 allows, and
+// has 256 bits of entropy.
+func PKCEVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
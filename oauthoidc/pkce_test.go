@@ -0,0 +1,31 @@
+package oauthoidc
+
+import "testing"
+
+func TestPKCEChallenge(t *testing.T) {
+	// The example in RFC 7636 Appendix B.
+	const (
+		verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+	if actual := PKCEChallenge(verifier); actual != challenge {
+		t.Fatalf("actual: %s, expected: %s", actual, challenge)
+	}
+}
+
+func TestPKCEVerifier(t *testing.T) {
+	v1, err := PKCEVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := PKCEVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v1) != 43 {
+		t.Errorf("len(v1): actual: %d, expected: 43", len(v1))
+	}
+	if v1 == v2 {
+		t.Errorf("PKCEVerifier returned %s twice", v1)
+	}
+}
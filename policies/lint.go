@@ -0,0 +1,340 @@
+package policies
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind distinguishes the kinds of policies Lint knows how to check, which
+// differ in which elements they allow and how large they may be.
+type Kind string
+
+const (
+	IdentityPolicy Kind = "identity policy" // inline in a role or user
+	TrustPolicy    Kind = "trust policy"    // a role's assume-role policy
+)
+
+// Size limits, in characters not counting whitespace, per
+// <https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_iam-quotas.html>.
+// The trust policy limit is the default, which applies in every new account,
+// though it may be raised to 4,096 by request, in which case set the
+// MaxTrustPolicySizeEnv environment variable to the raised limit.
+const (
+	MaxIdentityPolicySize = 10240
+	MaxTrustPolicySize    = 2048
+
+	MaxTrustPolicySizeEnv = "SUBSTRATE_MAX_TRUST_POLICY_SIZE"
+)
+
+var (
+	actionRegexp = regexp.MustCompile(`^(\*|[a-z0-9-]+:[A-Za-z0-9*?]+)$`)
+
+	conditionOperators = map[string]bool{
+		"StringEquals":              true,
+		"StringNotEquals":           true,
+		"StringEqualsIgnoreCase":    true,
+		"StringNotEqualsIgnoreCase": true,
+		"StringLike":                true,
+		"StringNotLike":             true,
+		"NumericEquals":             true,
+		"NumericNotEquals":          true,
+		"NumericLessThan":           true,
+		"NumericLessThanEquals":     true,
+		"NumericGreaterThan":        true,
+		"NumericGreaterThanEquals":  true,
+		"DateEquals":                true,
+		"DateNotEquals":             true,
+		"DateLessThan":              true,
+		"DateLessThanEquals":        true,
+		"DateGreaterThan":           true,
+		"DateGreaterThanEquals":     true,
+		"Bool":                      true,
+		"BinaryEquals":              true,
+		"IpAddress":                 true,
+		"NotIpAddress":              true,
+		"ArnEquals":                 true,
+		"ArnNotEquals":              true,
+		"ArnLike":                   true,
+		"ArnNotLike":                true,
+		"Null":                      true,
+	}
+
+	documentKeys  = []string{"Id", "Statement", "Version"}
+	principalKeys = []string{"AWS", "CanonicalUser", "Federated", "Service"}
+	statementKeys = []string{"Action", "Condition", "Effect", "NotAction", "NotPrincipal", "NotResource", "Principal", "Resource", "Sid"}
+)
+
+// Lint checks a policy in its JSON form for mistakes IAM would otherwise
+// only report when it's used, which may be halfway through changing dozens
+// of accounts. It returns every problem it finds rather than just the first.
+func Lint(b []byte, kind Kind) []error {
+	var errs []error
+	report := func(format string, args ...interface{}) {
+		errs = append(errs, LintError(fmt.Sprintf(format, args...)))
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		report("%s is not a JSON object: %v", kind, err)
+		return errs
+	}
+	for _, key := range unknownKeys(doc, documentKeys) {
+		report("unknown key %q", key)
+	}
+	if version, ok := doc["Version"]; ok && version != "2012-10-17" && version != "2008-10-17" {
+		report(`Version %v must be "2012-10-17" or "2008-10-17"`, version)
+	}
+
+	var statements []interface{}
+	switch v := doc["Statement"].(type) {
+	case []interface{}:
+		statements = v
+	case map[string]interface{}:
+		statements = []interface{}{v}
+	case nil:
+		report("Statement is required")
+	default:
+		report("Statement must be an object or an array of objects")
+	}
+	for i, s := range statements {
+		statement, ok := s.(map[string]interface{})
+		if !ok {
+			report("Statement[%d] must be an object", i)
+			continue
+		}
+		lintStatement(statement, kind, func(format string, args ...interface{}) {
+			report("Statement[%d]: "+format, append([]interface{}{i}, args...)...)
+		})
+	}
+
+	max, err := maxSize(kind)
+	if err != nil {
+		report("%v", err)
+	}
+	if size := len(strings.Join(strings.Fields(string(b)), "")); size > max {
+		if kind == TrustPolicy {
+			report("%s is %d characters, not counting whitespace, which exceeds the limit of %d (set %s if your accounts' limit has been raised)", kind, size, max, MaxTrustPolicySizeEnv)
+		} else {
+			report("%s is %d characters, not counting whitespace, which exceeds the limit of %d", kind, size, max)
+		}
+	}
+
+	return errs
+}
+
+// LintFile is Lint for the policy in the named file, with the filename
+// included in every error it returns.
+func LintFile(filename string, kind Kind) []error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return []error{err}
+	}
+	errs := Lint(b, kind)
+	for i, err := range errs {
+		errs[i] = LintError(fmt.Sprintf("%s: %s", filename, strings.TrimPrefix(err.Error(), "LintError: ")))
+	}
+	return errs
+}
+
+// Lint is Lint for a Document that's already been constructed, which is
+// mostly useful for checking the size of documents merged from many files.
+func (d *Document) Lint(kind Kind) []error {
+	s, err := d.Marshal()
+	if err != nil {
+		return []error{err}
+	}
+	return Lint([]byte(s), kind)
+}
+
+type LintError string
+
+func (err LintError) Error() string {
+	return fmt.Sprint("LintError: ", string(err))
+}
+
+func lintStatement(statement map[string]interface{}, kind Kind, report func(string, ...interface{})) {
+	for _, key := range unknownKeys(statement, statementKeys) {
+		report("unknown key %q", key)
+	}
+
+	// Effect may be omitted because Substrate fills in "Allow".
+	if effect, ok := statement["Effect"]; ok && effect != string(Allow) && effect != string(Deny) {
+		report(`Effect %v must be "Allow" or "Deny"`, effect)
+	}
+
+	_, hasAction := statement["Action"]
+	_, hasNotAction := statement["NotAction"]
+	if hasAction == hasNotAction {
+		report("exactly one of Action or NotAction is required")
+	}
+	for _, key := range []string{"Action", "NotAction"} {
+		for _, action := range stringsOrReport(statement, key, report) {
+			if !actionRegexp.MatchString(action) {
+				report("%s %q must look like \"service:Action\"", key, action)
+			}
+		}
+	}
+
+	_, hasResource := statement["Resource"]
+	_, hasNotResource := statement["NotResource"]
+	_, hasPrincipal := statement["Principal"]
+	_, hasNotPrincipal := statement["NotPrincipal"]
+	switch kind {
+	case IdentityPolicy:
+		if hasResource == hasNotResource {
+			report("exactly one of Resource or NotResource is required")
+		}
+		if hasPrincipal || hasNotPrincipal {
+			report("Principal and NotPrincipal aren't allowed in an %s", kind)
+		}
+	case TrustPolicy:
+		if hasResource || hasNotResource {
+			report("Resource and NotResource aren't allowed in a %s", kind)
+		}
+		if !hasPrincipal {
+			report("Principal is required in a %s", kind)
+		}
+		if hasNotPrincipal {
+			report("NotPrincipal isn't allowed in a %s", kind)
+		}
+	}
+	for _, key := range []string{"Resource", "NotResource"} {
+		for _, resource := range stringsOrReport(statement, key, report) {
+			if resource != "*" && !validARN(resource) {
+				report("%s %q must be \"*\" or an ARN", key, resource)
+			}
+		}
+	}
+
+	for _, key := range []string{"Principal", "NotPrincipal"} {
+		switch principal := statement[key].(type) {
+		case nil:
+		case string:
+			if principal != "*" {
+				report(`%s %q must be "*" or an object`, key, principal)
+			}
+		case map[string]interface{}:
+			for _, k := range unknownKeys(principal, principalKeys) {
+				report("unknown key %q in %s", k, key)
+			}
+			for _, arn := range stringsOrReport(principal, "AWS", report) {
+				if arn != "*" && !validAccountId(arn) && !validARN(arn) {
+					report("%s.AWS %q must be \"*\", an account number, or an ARN", key, arn)
+				}
+			}
+			for _, arn := range stringsOrReport(principal, "Federated", report) {
+				if strings.HasPrefix(arn, "arn:") && !validARN(arn) {
+					report("%s.Federated %q is not a valid ARN", key, arn)
+				}
+			}
+			stringsOrReport(principal, "Service", report)
+		default:
+			report(`%s must be "*" or an object`, key)
+		}
+	}
+
+	switch condition := statement["Condition"].(type) {
+	case nil:
+	case map[string]interface{}:
+		operators := make([]string, 0, len(condition))
+		for operator := range condition {
+			operators = append(operators, operator)
+		}
+		sort.Strings(operators)
+		for _, operator := range operators {
+			if !validConditionOperator(operator) {
+				report("unknown condition operator %q", operator)
+			}
+			if _, ok := condition[operator].(map[string]interface{}); !ok {
+				report("Condition %q must be an object", operator)
+			}
+		}
+	default:
+		report("Condition must be an object")
+	}
+}
+
+// maxSize returns the size limit for the kind of policy, taking the trust
+// policy limit from MaxTrustPolicySizeEnv if it's set. If it's set to
+// something other than a positive number it returns the default along with
+// an error.
+func maxSize(kind Kind) (int, error) {
+	if kind != TrustPolicy {
+		return MaxIdentityPolicySize, nil
+	}
+	s := os.Getenv(MaxTrustPolicySizeEnv)
+	if s == "" {
+		return MaxTrustPolicySize, nil
+	}
+	max, err := strconv.Atoi(s)
+	if err != nil || max <= 0 {
+		return MaxTrustPolicySize, fmt.Errorf("%s %q must be a positive number", MaxTrustPolicySizeEnv, s)
+	}
+	return max, nil
+}
+
+// stringsOrReport returns the string or array of strings in m[key], if any,
+// and reports any other type.
+func stringsOrReport(m map[string]interface{}, key string, report func(string, ...interface{})) []string {
+	switch v := m[key].(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []interface{}:
+		ss := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				ss = append(ss, s)
+			} else {
+				report("%s must contain only strings", key)
+			}
+		}
+		return ss
+	default:
+		report("%s must be a string or an array of strings", key)
+		return nil
+	}
+}
+
+func unknownKeys(m map[string]interface{}, known []string) []string {
+	var unknown []string
+	for key := range m {
+		if i := sort.SearchStrings(known, key); i == len(known) || known[i] != key {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func validAccountId(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// validARN returns true if s has all six parts of an ARN, which is as much
+// as can be checked without knowing every service's resource types.
+func validARN(s string) bool {
+	parts := strings.SplitN(s, ":", 6)
+	return len(parts) == 6 && parts[0] == "arn" && parts[1] != "" && parts[2] != "" && parts[5] != ""
+}
+
+func validConditionOperator(operator string) bool {
+	operator = strings.TrimPrefix(operator, "ForAllValues:")
+	operator = strings.TrimPrefix(operator, "ForAnyValue:")
+	operator = strings.TrimSuffix(operator, "IfExists")
+	return conditionOperators[operator]
+}
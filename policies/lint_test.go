@@ -0,0 +1,106 @@
+package policies
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLintValid(t *testing.T) {
+	for kind, s := range map[Kind]string{
+		IdentityPolicy: `{
+			"Version": "2012-10-17",
+			"Statement": [
+				{
+					"Effect": "Allow",
+					"Action": ["s3:GetObject", "s3:List*"],
+					"Resource": "arn:aws:s3:::example/*",
+					"Condition": {"ForAnyValue:StringLikeIfExists": {"aws:PrincipalTag/Team": ["*"]}}
+				},
+				{"Effect": "Deny", "NotAction": "iam:*", "Resource": "*"}
+			]
+		}`,
+		TrustPolicy: `{
+			"Statement": {
+				"Action": "sts:AssumeRole",
+				"Principal": {"AWS": ["123456789012", "arn:aws:iam::123456789012:role/Example"], "Service": "ec2.amazonaws.com"}
+			}
+		}`,
+	} {
+		if errs := Lint([]byte(s), kind); len(errs) > 0 {
+			t.Errorf("%s: %v", kind, errs)
+		}
+	}
+}
+
+func TestLintInvalid(t *testing.T) {
+	errs := Lint([]byte(`{
+		"Version": "2012-10-17",
+		"Statements": [],
+		"Statement": [
+			{
+				"Effect": "allow",
+				"Action": "GetObject",
+				"Resource": "arn:aws:s3",
+				"Condition": {"StringEqualz": {"aws:username": "example"}},
+				"Principal": "*"
+			}
+		]
+	}`), IdentityPolicy)
+	for _, expected := range []string{
+		`unknown key "Statements"`,
+		`Effect allow must be "Allow" or "Deny"`,
+		`Action "GetObject" must look like "service:Action"`,
+		`Resource "arn:aws:s3" must be "*" or an ARN`,
+		`unknown condition operator "StringEqualz"`,
+		`Principal and NotPrincipal aren't allowed`,
+	} {
+		if !containsError(errs, expected) {
+			t.Errorf("expected an error containing %q in %v", expected, errs)
+		}
+	}
+
+	errs = Lint([]byte(`{"Statement": [{"Action": "sts:AssumeRole", "Resource": "*"}]}`), TrustPolicy)
+	for _, expected := range []string{
+		"Resource and NotResource aren't allowed in a trust policy",
+		"Principal is required in a trust policy",
+	} {
+		if !containsError(errs, expected) {
+			t.Errorf("expected an error containing %q in %v", expected, errs)
+		}
+	}
+}
+
+func TestLintSize(t *testing.T) {
+	actions := make([]string, 200)
+	for i := range actions {
+		actions[i] = fmt.Sprintf(`"s3:Action%d"`, i)
+	}
+	s := fmt.Sprintf(`{"Statement": [{"Action": [%s], "Resource": "*"}]}`, strings.Join(actions, ", "))
+	if errs := Lint([]byte(s), IdentityPolicy); len(errs) > 0 {
+		t.Errorf("identity policy: %v", errs)
+	}
+	s = strings.Replace(s, `"Resource": "*"`, `"Principal": {"AWS": "123456789012"}`, 1)
+	if errs := Lint([]byte(s), TrustPolicy); !containsError(errs, "exceeds the limit of 2048") {
+		t.Errorf("trust policy: %v", errs)
+	}
+
+	t.Setenv(MaxTrustPolicySizeEnv, "4096")
+	if errs := Lint([]byte(s), TrustPolicy); len(errs) > 0 {
+		t.Errorf("trust policy with %s=4096: %v", MaxTrustPolicySizeEnv, errs)
+	}
+
+	t.Setenv(MaxTrustPolicySizeEnv, "lots")
+	if errs := Lint([]byte(s), TrustPolicy); !containsError(errs, "must be a positive number") || !containsError(errs, "exceeds the limit of 2048") {
+		t.Errorf("trust policy with %s=lots: %v", MaxTrustPolicySizeEnv, errs)
+	}
+}
+
+func containsError(errs []error, substr string) bool {
+	for _, err := range errs {
+		if strings.Contains(err.Error(), substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,400 @@
+package profiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsorgs"
+	"github.com/src-bin/substrate/customroles"
+	"github.com/src-bin/substrate/fileutil"
+	"github.com/src-bin/substrate/regions"
+	"github.com/src-bin/substrate/roles"
+	"github.com/src-bin/substrate/tagging"
+)
+
+const (
+	BeginMarker = "# BEGIN Substrate-managed profiles; changes between these lines will be overwritten"
+	EndMarker   = "# END Substrate-managed profiles"
+)
+
+// Profile is one named profile in ~/.aws/config that gets credentials for
+// RoleName in the AWS account AccountId by running `substrate assume-role`
+// with Args, which select the account the same way a human would.
+type Profile struct {
+	Name      string
+	AccountId string
+	RoleName  string
+	Args      []string
+}
+
+// CustomRoles maps account numbers to the sorted names of the custom roles
+// in each account, which is the part of the profiles that's expensive to
+// discover because it takes listing the roles in every account.
+type CustomRoles map[string][]string
+
+// InspectCustomRoles finds every custom role in every AWS account in the
+// organization.
+func InspectCustomRoles(ctx context.Context, cfg *awscfg.Config) (CustomRoles, error) {
+	_, instances, err := customroles.Inspect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewCustomRoles(instances), nil
+}
+
+// NewCustomRoles organizes the instances of custom roles returned by
+// customroles.Inspect by account.
+func NewCustomRoles(instances map[string][]*customroles.Instance) CustomRoles {
+	customRoles := make(CustomRoles)
+	for roleName, roleInstances := range instances {
+		for _, instance := range roleInstances {
+			customRoles.Add(aws.ToString(instance.Account.Id), roleName)
+		}
+	}
+	return customRoles
+}
+
+// Add records that the named custom role exists in the given account.
+func (c CustomRoles) Add(accountId, roleName string) {
+	roleNames := c[accountId]
+	i := sort.SearchStrings(roleNames, roleName)
+	if i < len(roleNames) && roleNames[i] == roleName {
+		return
+	}
+	c[accountId] = append(roleNames[:i], append([]string{roleName}, roleNames[i:]...)...)
+}
+
+// Delete records that the named custom role no longer exists in any account.
+func (c CustomRoles) Delete(roleName string) {
+	for accountId, roleNames := range c {
+		if i := sort.SearchStrings(roleNames, roleName); i < len(roleNames) && roleNames[i] == roleName {
+			c[accountId] = append(roleNames[:i], roleNames[i+1:]...)
+		}
+	}
+}
+
+// Generate lists a Profile for every Substrate-managed role, including the
+// given custom roles, in every AWS account in the organization, in the same
+// order as substrate.accounts.txt.
+func Generate(ctx context.Context, cfg *awscfg.Config, customRoles CustomRoles) ([]*Profile, error) {
+	accountProfiles, err := listAccountProfiles(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var profiles []*Profile
+	for _, ap := range accountProfiles {
+		profiles = append(profiles, NewProfiles(
+			ap.account,
+			ap.prefix,
+			ap.args,
+			append(append([]string{}, ap.roleNames...), customRoles[aws.ToString(ap.account.Id)]...),
+		)...)
+	}
+	return profiles, nil
+}
+
+// NewProfiles returns one Profile for each of roleNames in account, each
+// named prefix-RoleName.
+func NewProfiles(account *awsorgs.Account, prefix string, args []string, roleNames []string) []*Profile {
+	profiles := make([]*Profile, len(roleNames))
+	for i, roleName := range roleNames {
+		profiles[i] = &Profile{
+			Name:      fmt.Sprintf("%s-%s", prefix, roleName),
+			AccountId: aws.ToString(account.Id),
+			RoleName:  roleName,
+			Args:      append(append([]string{}, args...), "--role", roleName),
+		}
+	}
+	return profiles
+}
+
+// Fprint writes profiles to w as a block of ~/.aws/config, between
+// BeginMarker and EndMarker, in which every profile's credential_process
+// runs the substrate executable in executable with SUBSTRATE_ROOT set to
+// root. AWS_PROFILE is unset for the credential_process so that Substrate
+// doesn't find its own profile and recurse. If region is empty, the profiles
+// don't set one.
+func Fprint(w io.Writer, profiles []*Profile, executable, root, region string) error {
+	if _, err := fmt.Fprintln(w, BeginMarker); err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		args := append([]string{
+			"env", "-u", "AWS_PROFILE",
+			fmt.Sprintf("SUBSTRATE_ROOT=%s", root),
+			executable, "assume-role", "--format", "credential-process", "--quiet",
+		}, p.Args...)
+		for i, arg := range args {
+			args[i] = quote(arg)
+		}
+		if _, err := fmt.Fprintf(w, "\n[profile %s]\ncredential_process = %s\n", p.Name, strings.Join(args, " ")); err != nil {
+			return err
+		}
+		if region != "" {
+			if _, err := fmt.Fprintf(w, "region = %s\n", region); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", EndMarker)
+	return err
+}
+
+// FawsConfig generates every profile, inspecting every account for custom
+// roles, and writes them to w as a block of ~/.aws/config that uses this
+// substrate executable, this Substrate repository, and its default region.
+func FawsConfig(ctx context.Context, cfg *awscfg.Config, w io.Writer) error {
+	customRoles, err := InspectCustomRoles(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	return fawsConfig(ctx, cfg, w, customRoles)
+}
+
+// Pathname returns the pathname of the AWS CLI and SDKs' shared config file,
+// which is ~/.aws/config unless AWS_CONFIG_FILE says otherwise.
+func Pathname() (string, error) {
+	if pathname := os.Getenv("AWS_CONFIG_FILE"); pathname != "" {
+		return pathname, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aws", "config"), nil
+}
+
+// Splice replaces the block between BeginMarker and EndMarker, inclusive, in
+// an existing ~/.aws/config with block, leaving everything else untouched.
+// It returns a MarkerError if the markers aren't both there in order.
+func Splice(config, block []byte) ([]byte, error) {
+	i := bytes.Index(config, []byte(BeginMarker))
+	if i < 0 {
+		return nil, MarkerError(BeginMarker)
+	}
+	j := bytes.Index(config[i:], []byte(EndMarker))
+	if j < 0 {
+		return nil, MarkerError(EndMarker)
+	}
+	j += i + len(EndMarker)
+	if j < len(config) && config[j] == '\n' {
+		j++
+	}
+	spliced := append(append([]byte{}, config[:i]...), block...)
+	return append(spliced, config[j:]...), nil
+}
+
+// Update regenerates the Substrate-managed profiles in ~/.aws/config if it
+// contains BeginMarker, which is how to opt into keeping them up-to-date as
+// accounts and roles are created and deleted. It does nothing otherwise.
+// Rather than inspect every account for custom roles, it keeps the custom
+// roles already in the block, as changed by edit, if it's not nil.
+func Update(ctx context.Context, cfg *awscfg.Config, edit func(CustomRoles)) error {
+	pathname, err := Pathname()
+	if err != nil {
+		return err
+	}
+	config, err := os.ReadFile(pathname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !bytes.Contains(config, []byte(BeginMarker)) {
+		return nil
+	}
+	fi, err := os.Stat(pathname)
+	if err != nil {
+		return err
+	}
+
+	accountProfiles, err := listAccountProfiles(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	customRoles := parseCustomRoles(config, accountProfiles)
+	if edit != nil {
+		edit(customRoles)
+	}
+	block := &bytes.Buffer{}
+	if err := fawsConfig(ctx, cfg, block, customRoles); err != nil {
+		return err
+	}
+
+	if config, err = Splice(config, block.Bytes()); err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(pathname, config, fi.Mode().Perm())
+}
+
+type MarkerError string
+
+func (err MarkerError) Error() string {
+	return fmt.Sprintf("MarkerError: %q not found in your AWS config file", string(err))
+}
+
+// accountProfile is how to name and select one account in its profiles and
+// which of Substrate's own roles it has.
+type accountProfile struct {
+	account   *awsorgs.Account
+	prefix    string
+	args      []string
+	roleNames []string
+}
+
+func fawsConfig(ctx context.Context, cfg *awscfg.Config, w io.Writer, customRoles CustomRoles) error {
+	profiles, err := Generate(ctx, cfg, customRoles)
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	root, err := filepath.Abs(accounts.CheatSheetDirname())
+	if err != nil {
+		return err
+	}
+	region, _ := regions.DefaultNoninteractive() // leave region unset if there's no default
+	return Fprint(w, profiles, executable, root, region)
+}
+
+func listAccountProfiles(ctx context.Context, cfg *awscfg.Config) ([]accountProfile, error) {
+	adminAccounts, serviceAccounts, substrateAccount, auditAccount, deployAccount, managementAccount, networkAccount, err := accounts.Grouped(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var accountProfiles []accountProfile
+	add := func(account *awsorgs.Account, prefix string, args []string, roleNames ...string) {
+		if account != nil {
+			accountProfiles = append(accountProfiles, accountProfile{account, prefix, args, roleNames})
+		}
+	}
+	add(managementAccount, accounts.Management, []string{"--management"}, roles.OrganizationAdministrator, roles.OrganizationReader)
+	add(auditAccount, accounts.Audit, []string{"--special", accounts.Audit}, roles.AuditAdministrator, roles.Auditor)
+	add(deployAccount, accounts.Deploy, []string{"--special", accounts.Deploy}, roles.DeployAdministrator, roles.Auditor)
+	add(networkAccount, accounts.Network, []string{"--special", accounts.Network}, roles.NetworkAdministrator, roles.Auditor)
+	add(substrateAccount, accounts.Substrate, []string{"--substrate"}, roles.Administrator, roles.Auditor)
+	for _, account := range adminAccounts {
+		add(
+			account,
+			strings.Join([]string{accounts.Admin, account.Tags[tagging.Quality]}, "-"),
+			[]string{"--number", aws.ToString(account.Id)},
+			roles.Administrator, roles.Auditor,
+		)
+	}
+	for _, account := range serviceAccounts {
+		domain, environment, quality := account.Tags[tagging.Domain], account.Tags[tagging.Environment], account.Tags[tagging.Quality]
+		if domain == "" || environment == "" || quality == "" {
+			add(account, aws.ToString(account.Id), []string{"--number", aws.ToString(account.Id)}, roles.Administrator, roles.Auditor)
+			continue
+		}
+		add(
+			account,
+			strings.Join([]string{domain, environment, quality}, "-"),
+			[]string{"--domain", domain, "--environment", environment, "--quality", quality},
+			roles.Administrator, roles.Auditor,
+		)
+	}
+	return accountProfiles, nil
+}
+
+// parseCustomRoles recovers the custom roles from the profiles in the
+// Substrate-managed block of ~/.aws/config by matching each profile's
+// `substrate assume-role` arguments to one of the given accounts. Profiles
+// for accounts that no longer exist are dropped.
+func parseCustomRoles(config []byte, accountProfiles []accountProfile) CustomRoles {
+	byArgs := make(map[string]accountProfile)
+	for _, ap := range accountProfiles {
+		byArgs[strings.Join(ap.args, " ")] = ap
+	}
+	customRoles := make(CustomRoles)
+	if i := bytes.Index(config, []byte(BeginMarker)); i >= 0 {
+		config = config[i:]
+	}
+	if j := bytes.Index(config, []byte(EndMarker)); j >= 0 {
+		config = config[:j]
+	}
+	for _, line := range strings.Split(string(config), "\n") {
+		value, ok := strings.CutPrefix(line, "credential_process = ")
+		if !ok {
+			continue
+		}
+		fields := unquoteFields(value)
+		var args []string
+		for i, field := range fields {
+			if field == "--quiet" {
+				args = fields[i+1:]
+				break
+			}
+		}
+		if len(args) < 2 || args[len(args)-2] != "--role" {
+			continue
+		}
+		roleName := args[len(args)-1]
+		ap, ok := byArgs[strings.Join(args[:len(args)-2], " ")]
+		if !ok {
+			continue
+		}
+		builtIn := false
+		for _, name := range ap.roleNames {
+			builtIn = builtIn || name == roleName
+		}
+		if !builtIn {
+			customRoles.Add(aws.ToString(ap.account.Id), roleName)
+		}
+	}
+	return customRoles
+}
+
+// quote double-quotes s if it contains anything the AWS SDKs would split a
+// credential_process command on.
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return fmt.Sprintf(`"%s"`, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s))
+}
+
+// unquoteFields is the inverse of quoting every field with quote and joining
+// them with spaces.
+func unquoteFields(s string) []string {
+	var (
+		fields          []string
+		field           strings.Builder
+		inField, quoted bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\' && i+1 < len(s):
+			i++
+			field.WriteByte(s[i])
+		case c == '"':
+			quoted, inField = !quoted, true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteByte(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
@@ -0,0 +1,123 @@
+package profiles
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/src-bin/substrate/awsorgs"
+)
+
+func TestFprint(t *testing.T) {
+	account := &awsorgs.Account{}
+	account.Id = aws.String("234567890123")
+	b := &bytes.Buffer{}
+	if err := Fprint(
+		b,
+		NewProfiles(account, "www-production-default", []string{"--domain", "www", "--environment", "production", "--quality", "default"}, []string{"Administrator"}),
+		"/usr/local/bin/substrate",
+		"/home/me/My Substrate",
+		"us-west-2",
+	); err != nil {
+		t.Fatal(err)
+	}
+	expected := BeginMarker + `
+
+[profile www-production-default-Administrator]
+credential_process = env -u AWS_PROFILE "SUBSTRATE_ROOT=/home/me/My Substrate" /usr/local/bin/substrate assume-role --format credential-process --quiet --domain www --environment production --quality default --role Administrator
+region = us-west-2
+
+` + EndMarker + "\n"
+	if actual := b.String(); actual != expected {
+		t.Fatalf("actual:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
+
+func TestSplice(t *testing.T) {
+	config := "[default]\nregion = us-east-1\n\n" + BeginMarker + "\n[profile old]\n" + EndMarker + "\n\n[profile mine]\nregion = us-east-2\n"
+	actual, err := Splice([]byte(config), []byte(BeginMarker+"\n[profile new]\n"+EndMarker+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[default]\nregion = us-east-1\n\n" + BeginMarker + "\n[profile new]\n" + EndMarker + "\n\n[profile mine]\nregion = us-east-2\n"
+	if string(actual) != expected {
+		t.Fatalf("actual:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
+
+func TestSpliceMissingEndMarker(t *testing.T) {
+	_, err := Splice([]byte(BeginMarker+"\n[profile old]\n"), []byte{})
+	if err != MarkerError(EndMarker) {
+		t.Fatal(err)
+	}
+}
+
+func TestCustomRoles(t *testing.T) {
+	c := make(CustomRoles)
+	c.Add("234567890123", "Deployer")
+	c.Add("234567890123", "Builder")
+	c.Add("234567890123", "Deployer")
+	c.Add("345678901234", "Deployer")
+	if !reflect.DeepEqual(c, CustomRoles{
+		"234567890123": {"Builder", "Deployer"},
+		"345678901234": {"Deployer"},
+	}) {
+		t.Fatalf("after Add: %+v", c)
+	}
+	c.Delete("Deployer")
+	if !reflect.DeepEqual(c, CustomRoles{
+		"234567890123": {"Builder"},
+		"345678901234": {},
+	}) {
+		t.Fatalf("after Delete: %+v", c)
+	}
+}
+
+func TestParseCustomRoles(t *testing.T) {
+	account := &awsorgs.Account{}
+	account.Id = aws.String("234567890123")
+	ap := accountProfile{
+		account:   account,
+		prefix:    "www-production-default",
+		args:      []string{"--domain", "www", "--environment", "production", "--quality", "default"},
+		roleNames: []string{"Administrator", "Auditor"},
+	}
+	closed := &awsorgs.Account{}
+	closed.Id = aws.String("345678901234")
+	b := &bytes.Buffer{}
+	b.WriteString("[default]\nregion = us-east-1\n\n")
+	if err := Fprint(
+		b,
+		append(
+			NewProfiles(account, ap.prefix, ap.args, []string{"Administrator", "Auditor", "Builder", "Deployer"}),
+			NewProfiles(closed, "345678901234", []string{"--number", "345678901234"}, []string{"Administrator", "Deployer"})...,
+		),
+		"/usr/local/bin/substrate",
+		"/home/me/My Substrate",
+		"us-west-2",
+	); err != nil {
+		t.Fatal(err)
+	}
+	actual := parseCustomRoles(b.Bytes(), []accountProfile{ap})
+	expected := CustomRoles{"234567890123": {"Builder", "Deployer"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("actual: %+v, expected: %+v", actual, expected)
+	}
+}
+
+func TestUnquoteFields(t *testing.T) {
+	for _, fields := range [][]string{
+		{"env", "-u", "AWS_PROFILE"},
+		{"SUBSTRATE_ROOT=/home/me/My Substrate", `back\slash`, `"quoted"`, ""},
+	} {
+		quoted := make([]string, len(fields))
+		for i, field := range fields {
+			quoted[i] = quote(field)
+		}
+		if actual := unquoteFields(strings.Join(quoted, " ")); !reflect.DeepEqual(actual, fields) {
+			t.Errorf("actual: %q, expected: %q", actual, fields)
+		}
+	}
+}
@@ -4,15 +4,22 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 )
 
+const awsManagedPolicyARNPrefix = "arn:aws:iam::aws:policy/"
+
 type ManagedAssumeRolePolicy struct {
 	Humans        bool
 	AWSServices   []string
 	GitHubActions []string
-	Filenames     []string
+	OIDC          []OIDCTrust `json:",omitempty"`
+
+	TrustedAccounts []TrustedAccounts `json:",omitempty"`
+
+	Filenames []string
 }
 
 func (p *ManagedAssumeRolePolicy) Arguments() []string {
@@ -26,6 +33,31 @@ func (p *ManagedAssumeRolePolicy) Arguments() []string {
 	for _, githubActions := range p.GitHubActions {
 		ss = append(ss, "--github-actions", fmt.Sprintf("%q", githubActions))
 	}
+	for _, t := range p.OIDC {
+		ss = append(ss, "--oidc-provider", fmt.Sprintf("%q", t.Provider))
+		for _, subject := range t.Subjects {
+			ss = append(ss, "--oidc-subject", fmt.Sprintf("%q", subject))
+		}
+		for _, audience := range t.Audiences {
+			ss = append(ss, "--oidc-audience", fmt.Sprintf("%q", audience))
+		}
+	}
+	for _, t := range p.TrustedAccounts {
+		if t.RoleName == "" {
+			ss = append(ss, "--trusted-accounts")
+		} else {
+			ss = append(ss, "--trusted-role", fmt.Sprintf("%q", t.RoleName))
+		}
+		for _, domain := range t.Domains {
+			ss = append(ss, "--trusted-domain", fmt.Sprintf("%q", domain))
+		}
+		for _, environment := range t.Environments {
+			ss = append(ss, "--trusted-environment", fmt.Sprintf("%q", environment))
+		}
+		for _, quality := range t.Qualities {
+			ss = append(ss, "--trusted-quality", fmt.Sprintf("%q", quality))
+		}
+	}
 	for _, filename := range p.Filenames {
 		ss = append(ss, "--assume-role-policy", fmt.Sprintf("%q", filename))
 	}
@@ -42,6 +74,36 @@ func (p *ManagedAssumeRolePolicy) FlagSet(u ManagedAssumeRolePolicyFlagsUsage) *
 	if u.GitHubActions == "" {
 		panic("ManagedAssumeRolePolicyFlagsUsage.GitHubActions can't be empty")
 	}
+	if u.OIDCProvider == "" {
+		panic("ManagedAssumeRolePolicyFlagsUsage.OIDCProvider can't be empty")
+	}
+	if u.OIDCSubject == "" {
+		panic("ManagedAssumeRolePolicyFlagsUsage.OIDCSubject can't be empty")
+	}
+	if u.OIDCAudience == "" {
+		panic("ManagedAssumeRolePolicyFlagsUsage.OIDCAudience can't be empty")
+	}
+	if u.GitLabCI == "" {
+		panic("ManagedAssumeRolePolicyFlagsUsage.GitLabCI can't be empty")
+	}
+	if u.CircleCI == "" {
+		panic("ManagedAssumeRolePolicyFlagsUsage.CircleCI can't be empty")
+	}
+	if u.TrustedAccounts == "" {
+		panic("ManagedAssumeRolePolicyFlagsUsage.TrustedAccounts can't be empty")
+	}
+	if u.TrustedRole == "" {
+		panic("ManagedAssumeRolePolicyFlagsUsage.TrustedRole can't be empty")
+	}
+	if u.TrustedDomain == "" {
+		panic("ManagedAssumeRolePolicyFlagsUsage.TrustedDomain can't be empty")
+	}
+	if u.TrustedEnvironment == "" {
+		panic("ManagedAssumeRolePolicyFlagsUsage.TrustedEnvironment can't be empty")
+	}
+	if u.TrustedQuality == "" {
+		panic("ManagedAssumeRolePolicyFlagsUsage.TrustedQuality can't be empty")
+	}
 	if u.Filenames == "" {
 		panic("ManagedAssumeRolePolicyFlagsUsage.Filenames can't be empty")
 	}
@@ -50,6 +112,16 @@ func (p *ManagedAssumeRolePolicy) FlagSet(u ManagedAssumeRolePolicyFlagsUsage) *
 	set.BoolVar(&p.Humans, "humans", false, u.Humans)
 	set.StringArrayVar(&p.AWSServices, "aws-service", []string{}, u.AWSServices)
 	set.StringArrayVar(&p.GitHubActions, "github-actions", []string{}, u.GitHubActions)
+	set.Var(oidcProviderValue{p}, "oidc-provider", u.OIDCProvider)
+	set.Var(oidcSubjectValue{p}, "oidc-subject", u.OIDCSubject)
+	set.Var(oidcAudienceValue{p}, "oidc-audience", u.OIDCAudience)
+	set.Var(gitLabCIValue{p}, "gitlab-ci", u.GitLabCI)
+	set.Var(circleCIValue{p}, "circleci", u.CircleCI)
+	set.VarPF(trustedAccountsValue{p}, "trusted-accounts", "", u.TrustedAccounts).NoOptDefVal = "true"
+	set.Var(trustedRoleValue{p}, "trusted-role", u.TrustedRole)
+	set.Var(trustedDomainValue{p}, "trusted-domain", u.TrustedDomain)
+	set.Var(trustedEnvironmentValue{p}, "trusted-environment", u.TrustedEnvironment)
+	set.Var(trustedQualityValue{p}, "trusted-quality", u.TrustedQuality)
 	set.StringArrayVar(&p.Filenames, "assume-role-policy", []string{}, u.Filenames)
 	return set
 }
@@ -69,12 +141,18 @@ func (p *ManagedAssumeRolePolicy) Reset() {
 	p.Humans = false
 	p.AWSServices = []string{}
 	p.GitHubActions = []string{}
+	p.OIDC = nil
+	p.TrustedAccounts = nil
 	p.Filenames = []string{}
 }
 
 func (p *ManagedAssumeRolePolicy) Sort() {
 	sort.Strings(p.AWSServices)
 	sort.Strings(p.GitHubActions)
+	if len(p.OIDC) > 0 {
+		p.OIDC = p.MergedOIDC()
+	}
+	p.sortTrustedAccounts()
 	sort.Strings(p.Filenames)
 }
 
@@ -83,7 +161,10 @@ func (p *ManagedAssumeRolePolicy) String() string {
 }
 
 func (p *ManagedAssumeRolePolicy) Validate() error {
-	return nil
+	if err := p.validateOIDC(); err != nil {
+		return err
+	}
+	return p.validateTrustedAccounts()
 }
 
 type ManagedAssumeRolePolicyError string
@@ -96,7 +177,19 @@ type ManagedAssumeRolePolicyFlagsUsage struct {
 	Humans        string
 	AWSServices   string
 	GitHubActions string
-	Filenames     string
+	OIDCProvider  string
+	OIDCSubject   string
+	OIDCAudience  string
+	GitLabCI      string
+	CircleCI      string
+
+	TrustedAccounts    string
+	TrustedRole        string
+	TrustedDomain      string
+	TrustedEnvironment string
+	TrustedQuality     string
+
+	Filenames string
 }
 
 type ManagedPolicyAttachments struct {
@@ -104,6 +197,9 @@ type ManagedPolicyAttachments struct {
 	ReadOnlyAccess      bool
 	ARNs                []string
 	Filenames           []string
+
+	PermissionsBoundary string `json:",omitempty"` // name of a customer managed policy or ARN of an AWS managed policy
+	MaxSessionDuration  string `json:",omitempty"` // e.g. "1h"; empty means 12 hours
 }
 
 func (a *ManagedPolicyAttachments) Arguments() []string {
@@ -120,6 +216,12 @@ func (a *ManagedPolicyAttachments) Arguments() []string {
 	for _, filename := range a.Filenames {
 		ss = append(ss, "--policy", fmt.Sprintf("%q", filename))
 	}
+	if a.PermissionsBoundary != "" {
+		ss = append(ss, "--permissions-boundary", fmt.Sprintf("%q", a.PermissionsBoundary))
+	}
+	if a.MaxSessionDuration != "" {
+		ss = append(ss, "--max-session-duration", a.MaxSessionDuration)
+	}
 	return ss
 }
 
@@ -136,12 +238,20 @@ func (a *ManagedPolicyAttachments) FlagSet(u ManagedPolicyAttachmentsFlagsUsage)
 	if u.Filenames == "" {
 		panic("ManagedPolicyAttachmentsFlagsUsage.Filenames can't be empty")
 	}
+	if u.PermissionsBoundary == "" {
+		panic("ManagedPolicyAttachmentsFlagsUsage.PermissionsBoundary can't be empty")
+	}
+	if u.MaxSessionDuration == "" {
+		panic("ManagedPolicyAttachmentsFlagsUsage.MaxSessionDuration can't be empty")
+	}
 	a.Reset()
 	set := pflag.NewFlagSet("[policy attachment flags]", pflag.ExitOnError)
 	set.BoolVar(&a.AdministratorAccess, "administrator-access", false, u.AdministratorAccess)
 	set.BoolVar(&a.ReadOnlyAccess, "read-only-access", false, u.ReadOnlyAccess)
 	set.StringArrayVar(&a.ARNs, "policy-arn", []string{}, u.ARNs)
 	set.StringArrayVar(&a.Filenames, "policy", []string{}, u.Filenames)
+	set.StringVar(&a.PermissionsBoundary, "permissions-boundary", "", u.PermissionsBoundary)
+	set.StringVar(&a.MaxSessionDuration, "max-session-duration", "", u.MaxSessionDuration)
 	return set
 }
 
@@ -150,6 +260,8 @@ func (a *ManagedPolicyAttachments) Reset() {
 	a.ReadOnlyAccess = false
 	a.ARNs = []string{}
 	a.Filenames = []string{}
+	a.PermissionsBoundary = ""
+	a.MaxSessionDuration = ""
 }
 
 func (a *ManagedPolicyAttachments) Sort() {
@@ -165,9 +277,45 @@ func (a *ManagedPolicyAttachments) Validate() error {
 	if a.AdministratorAccess && a.ReadOnlyAccess {
 		return ManagedPolicyAttachmentsError("can't provide both --administrator-access and --read-only-access")
 	}
+	if strings.HasPrefix(a.PermissionsBoundary, "arn:") && !strings.HasPrefix(a.PermissionsBoundary, awsManagedPolicyARNPrefix) {
+		return ManagedPolicyAttachmentsError("--permissions-boundary must be the name of a customer managed policy, which must exist in every selected account, or the ARN of an AWS managed policy")
+	}
+	if a.MaxSessionDuration != "" {
+		d, err := a.maxSessionDuration()
+		if err != nil {
+			return ManagedPolicyAttachmentsError(fmt.Sprintf("--max-session-duration %q is not a duration", a.MaxSessionDuration))
+		}
+		if d < time.Hour || d > 12*time.Hour || d%time.Second != 0 {
+			return ManagedPolicyAttachmentsError("--max-session-duration must be a whole number of seconds between 1h and 12h")
+		}
+	}
 	return nil
 }
 
+// PermissionsBoundaryARN returns the ARN of the permissions boundary in the
+// given account, which is the same everywhere for AWS managed policies, or
+// the empty string if there's no permissions boundary.
+func (a *ManagedPolicyAttachments) PermissionsBoundaryARN(accountId string) string {
+	if a.PermissionsBoundary == "" || strings.HasPrefix(a.PermissionsBoundary, awsManagedPolicyARNPrefix) {
+		return a.PermissionsBoundary
+	}
+	return fmt.Sprintf("arn:aws:iam::%s:policy/%s", accountId, strings.TrimPrefix(a.PermissionsBoundary, "/"))
+}
+
+// MaxSessionDurationOrZero returns the parsed --max-session-duration or zero
+// if it wasn't given (or is invalid, which Validate will have reported).
+func (a *ManagedPolicyAttachments) MaxSessionDurationOrZero() time.Duration {
+	d, _ := a.maxSessionDuration()
+	return d
+}
+
+func (a *ManagedPolicyAttachments) maxSessionDuration() (time.Duration, error) {
+	if a.MaxSessionDuration == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(a.MaxSessionDuration)
+}
+
 type ManagedPolicyAttachmentsError string
 
 func (err ManagedPolicyAttachmentsError) Error() string {
@@ -176,4 +324,5 @@ func (err ManagedPolicyAttachmentsError) Error() string {
 
 type ManagedPolicyAttachmentsFlagsUsage struct {
 	AdministratorAccess, ReadOnlyAccess, ARNs, Filenames string
+	PermissionsBoundary, MaxSessionDuration              string
 }
@@ -1,6 +1,9 @@
 package roles
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestManagedAssumeRolePolicyZero(t *testing.T) {
 	p := &ManagedAssumeRolePolicy{}
@@ -21,3 +24,61 @@ func TestManagedPolicyAttachmentsZero(t *testing.T) {
 		t.Errorf(`a.String(): %q != ""`, a.String())
 	}
 }
+
+func TestManagedPolicyAttachmentsMaxSessionDuration(t *testing.T) {
+	for duration, ok := range map[string]bool{
+		"":      true,
+		"1h":    true,
+		"4h30m": true,
+		"12h":   true,
+		"59m":   false,
+		"13h":   false,
+		"1.5s":  false,
+		"soon":  false,
+	} {
+		a := &ManagedPolicyAttachments{MaxSessionDuration: duration}
+		if err := a.Validate(); (err == nil) != ok {
+			t.Errorf("MaxSessionDuration %q: %v", duration, err)
+		}
+	}
+	a := &ManagedPolicyAttachments{MaxSessionDuration: "4h30m"}
+	if d := a.MaxSessionDurationOrZero(); d != 270*time.Minute {
+		t.Errorf("a.MaxSessionDurationOrZero(): %v != 4h30m0s", d)
+	}
+}
+
+func TestManagedPolicyAttachmentsPermissionsBoundary(t *testing.T) {
+	a := &ManagedPolicyAttachments{PermissionsBoundary: "Boundary"}
+	if err := a.Validate(); err != nil {
+		t.Error(err)
+	}
+	if s := a.String(); s != `--permissions-boundary "Boundary"` {
+		t.Errorf("a.String(): %q", s)
+	}
+	for accountId, expected := range map[string]string{
+		"123456789012": "arn:aws:iam::123456789012:policy/Boundary",
+		"210987654321": "arn:aws:iam::210987654321:policy/Boundary",
+	} {
+		if arn := a.PermissionsBoundaryARN(accountId); arn != expected {
+			t.Errorf("a.PermissionsBoundaryARN(%q): %q != %q", accountId, arn, expected)
+		}
+	}
+
+	a.PermissionsBoundary = "arn:aws:iam::aws:policy/PowerUserAccess"
+	if err := a.Validate(); err != nil {
+		t.Error(err)
+	}
+	if arn := a.PermissionsBoundaryARN("123456789012"); arn != a.PermissionsBoundary {
+		t.Errorf("AWS managed policy ARN %q changed to %q", a.PermissionsBoundary, arn)
+	}
+
+	a.PermissionsBoundary = "arn:aws:iam::123456789012:policy/Boundary"
+	if err := a.Validate(); err == nil {
+		t.Error("permissions boundary that's a customer managed policy ARN in one account should be invalid")
+	}
+
+	a.PermissionsBoundary = ""
+	if arn := a.PermissionsBoundaryARN("123456789012"); arn != "" {
+		t.Errorf("a.PermissionsBoundaryARN: %q with no permissions boundary", arn)
+	}
+}
@@ -0,0 +1,174 @@
+package roles
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	CircleCIOAuthOIDCURLPrefix = "https://oidc.circleci.com/org/"
+	GitLabOAuthOIDCURL         = "https://gitlab.com"
+
+	DefaultOIDCAudience = "sts.amazonaws.com"
+)
+
+// OIDCTrust allows tokens issued by an OpenID Connect provider, e.g. a CI/CD
+// system or a Kubernetes cluster, whose subject matches one of the given
+// patterns to assume a role via sts:AssumeRoleWithWebIdentity.
+type OIDCTrust struct {
+	Provider  string   // issuer URL, e.g. "https://gitlab.com"
+	Subjects  []string // patterns, which may contain '*' and '?'
+	Audiences []string `json:",omitempty"` // if empty, DefaultOIDCAudience
+}
+
+// AudiencesOrDefault returns the audiences a token may be issued for.
+func (t OIDCTrust) AudiencesOrDefault() []string {
+	if len(t.Audiences) == 0 {
+		return []string{DefaultOIDCAudience}
+	}
+	return t.Audiences
+}
+
+// MergedOIDC returns p.OIDC with the subjects of every trust in the same
+// provider with the same audiences merged together, sorted by provider and
+// then audiences. Trusts with different audiences remain separate because
+// merging them would also admit every audience paired with every subject.
+func (p *ManagedAssumeRolePolicy) MergedOIDC() []OIDCTrust {
+	m := make(map[string]*OIDCTrust)
+	for _, t := range p.OIDC {
+		audiences := appendUnique(nil, t.AudiencesOrDefault()...)
+		sort.Strings(audiences)
+		key := t.Provider + " " + strings.Join(audiences, " ")
+		if _, ok := m[key]; !ok {
+			m[key] = &OIDCTrust{Provider: t.Provider, Audiences: audiences}
+		}
+		m[key].Subjects = appendUnique(m[key].Subjects, t.Subjects...)
+	}
+	trusts := make([]OIDCTrust, 0, len(m))
+	for _, t := range m {
+		if len(t.Audiences) == 1 && t.Audiences[0] == DefaultOIDCAudience {
+			t.Audiences = nil
+		}
+		sort.Strings(t.Subjects)
+		trusts = append(trusts, *t)
+	}
+	sort.Slice(trusts, func(i, j int) bool {
+		if trusts[i].Provider != trusts[j].Provider {
+			return trusts[i].Provider < trusts[j].Provider
+		}
+		return strings.Join(trusts[i].Audiences, " ") < strings.Join(trusts[j].Audiences, " ")
+	})
+	return trusts
+}
+
+func (p *ManagedAssumeRolePolicy) validateOIDC() error {
+	for _, t := range p.OIDC {
+		if u, err := url.Parse(t.Provider); err != nil || u.Scheme != "https" || u.Host == "" {
+			return ManagedAssumeRolePolicyError(fmt.Sprintf("--oidc-provider %q must be an https:// URL", t.Provider))
+		}
+		if len(t.Subjects) == 0 {
+			return ManagedAssumeRolePolicyError(fmt.Sprintf("--oidc-provider %q requires at least one --oidc-subject", t.Provider))
+		}
+	}
+	return nil
+}
+
+func appendUnique(ss []string, more ...string) []string {
+	for _, s := range more {
+		found := false
+		for _, s0 := range ss {
+			if s0 == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// The following pflag.Value implementations accumulate OIDCTrusts in the
+// order the flags are given so that --oidc-subject and --oidc-audience apply
+// to the --oidc-provider that most recently preceded them.
+
+type circleCIValue struct{ p *ManagedAssumeRolePolicy }
+
+func (v circleCIValue) Set(s string) error {
+	orgId, projectId, ok := strings.Cut(s, "/")
+	if !ok || orgId == "" || projectId == "" {
+		return ManagedAssumeRolePolicyError(`--circleci "..." must be a CircleCI organization ID and project ID separated by a literal '/'`)
+	}
+	v.p.OIDC = append(v.p.OIDC, OIDCTrust{
+		Provider:  CircleCIOAuthOIDCURLPrefix + orgId,
+		Subjects:  []string{fmt.Sprintf("org/%s/project/%s/user/*", orgId, projectId)},
+		Audiences: []string{orgId},
+	})
+	return nil
+}
+
+func (circleCIValue) String() string { return "" }
+
+func (circleCIValue) Type() string { return "string" }
+
+type gitLabCIValue struct{ p *ManagedAssumeRolePolicy }
+
+func (v gitLabCIValue) Set(s string) error {
+	if !strings.Contains(s, "/") {
+		return ManagedAssumeRolePolicyError(`--gitlab-ci "..." must contain a '/'`)
+	}
+	v.p.OIDC = append(v.p.OIDC, OIDCTrust{
+		Provider:  GitLabOAuthOIDCURL,
+		Subjects:  []string{fmt.Sprintf("project_path:%s:*", s)},
+		Audiences: []string{GitLabOAuthOIDCURL},
+	})
+	return nil
+}
+
+func (gitLabCIValue) String() string { return "" }
+
+func (gitLabCIValue) Type() string { return "string" }
+
+type oidcAudienceValue struct{ p *ManagedAssumeRolePolicy }
+
+func (v oidcAudienceValue) Set(s string) error {
+	if len(v.p.OIDC) == 0 {
+		return ManagedAssumeRolePolicyError("--oidc-audience must follow --oidc-provider")
+	}
+	t := &v.p.OIDC[len(v.p.OIDC)-1]
+	t.Audiences = append(t.Audiences, s)
+	return nil
+}
+
+func (oidcAudienceValue) String() string { return "" }
+
+func (oidcAudienceValue) Type() string { return "string" }
+
+type oidcProviderValue struct{ p *ManagedAssumeRolePolicy }
+
+func (v oidcProviderValue) Set(s string) error {
+	v.p.OIDC = append(v.p.OIDC, OIDCTrust{Provider: s})
+	return nil
+}
+
+func (oidcProviderValue) String() string { return "" }
+
+func (oidcProviderValue) Type() string { return "string" }
+
+type oidcSubjectValue struct{ p *ManagedAssumeRolePolicy }
+
+func (v oidcSubjectValue) Set(s string) error {
+	if len(v.p.OIDC) == 0 {
+		return ManagedAssumeRolePolicyError("--oidc-subject must follow --oidc-provider")
+	}
+	t := &v.p.OIDC[len(v.p.OIDC)-1]
+	t.Subjects = append(t.Subjects, s)
+	return nil
+}
+
+func (oidcSubjectValue) String() string { return "" }
+
+func (oidcSubjectValue) Type() string { return "string" }
@@ -0,0 +1,135 @@
+package roles
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOIDCFlags(t *testing.T) {
+	p := &ManagedAssumeRolePolicy{}
+	set := p.FlagSet(ManagedAssumeRolePolicyFlagsUsage{
+		Humans:        "humans",
+		AWSServices:   "aws-service",
+		GitHubActions: "github-actions",
+		OIDCProvider:  "oidc-provider",
+		OIDCSubject:   "oidc-subject",
+		OIDCAudience:  "oidc-audience",
+		GitLabCI:      "gitlab-ci",
+		CircleCI:      "circleci",
+
+		TrustedAccounts:    "trusted-accounts",
+		TrustedRole:        "trusted-role",
+		TrustedDomain:      "trusted-domain",
+		TrustedEnvironment: "trusted-environment",
+		TrustedQuality:     "trusted-quality",
+
+		Filenames: "assume-role-policy",
+	})
+	if err := set.Parse([]string{
+		"--oidc-provider", "https://oidc.example.com/id/ABC",
+		"--oidc-subject", "system:serviceaccount:default:app",
+		"--gitlab-ci", "example/project",
+		"--circleci", "org-id/project-id",
+		"--oidc-provider", "https://oidc.example.com/id/ABC",
+		"--oidc-subject", "system:serviceaccount:default:worker",
+		"--oidc-audience", "example",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	p.Sort()
+	expected := []OIDCTrust{
+		{
+			Provider:  "https://gitlab.com",
+			Subjects:  []string{"project_path:example/project:*"},
+			Audiences: []string{"https://gitlab.com"},
+		},
+		{
+			Provider:  "https://oidc.circleci.com/org/org-id",
+			Subjects:  []string{"org/org-id/project/project-id/user/*"},
+			Audiences: []string{"org-id"},
+		},
+		{
+			Provider: "https://oidc.example.com/id/ABC",
+			Subjects: []string{"system:serviceaccount:default:app"},
+		},
+		{
+			Provider:  "https://oidc.example.com/id/ABC",
+			Subjects:  []string{"system:serviceaccount:default:worker"},
+			Audiences: []string{"example"},
+		},
+	}
+	if !reflect.DeepEqual(p.OIDC, expected) {
+		t.Errorf("p.OIDC: %+v != %+v", p.OIDC, expected)
+	}
+
+	// Arguments must round-trip through the flags.
+	p2 := &ManagedAssumeRolePolicy{}
+	set2 := p2.FlagSet(ManagedAssumeRolePolicyFlagsUsage{
+		Humans:        "humans",
+		AWSServices:   "aws-service",
+		GitHubActions: "github-actions",
+		OIDCProvider:  "oidc-provider",
+		OIDCSubject:   "oidc-subject",
+		OIDCAudience:  "oidc-audience",
+		GitLabCI:      "gitlab-ci",
+		CircleCI:      "circleci",
+
+		TrustedAccounts:    "trusted-accounts",
+		TrustedRole:        "trusted-role",
+		TrustedDomain:      "trusted-domain",
+		TrustedEnvironment: "trusted-environment",
+		TrustedQuality:     "trusted-quality",
+
+		Filenames: "assume-role-policy",
+	})
+	var args []string
+	for _, arg := range p.Arguments() {
+		args = append(args, strings.Trim(arg, `"`))
+	}
+	if err := set2.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	p2.Sort()
+	if !reflect.DeepEqual(p2.OIDC, p.OIDC) {
+		t.Errorf("p2.OIDC: %+v != %+v", p2.OIDC, p.OIDC)
+	}
+}
+
+func TestMergedOIDC(t *testing.T) {
+	p := &ManagedAssumeRolePolicy{OIDC: []OIDCTrust{
+		{Provider: "https://oidc.example.com", Subjects: []string{"X"}, Audiences: []string{"A"}},
+		{Provider: "https://oidc.example.com", Subjects: []string{"Y"}, Audiences: []string{"B"}},
+		{Provider: "https://oidc.example.com", Subjects: []string{"Z"}, Audiences: []string{"A"}},
+	}}
+	expected := []OIDCTrust{
+		{Provider: "https://oidc.example.com", Subjects: []string{"X", "Z"}, Audiences: []string{"A"}},
+		{Provider: "https://oidc.example.com", Subjects: []string{"Y"}, Audiences: []string{"B"}},
+	}
+	if actual := p.MergedOIDC(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%+v != %+v", actual, expected)
+	}
+}
+
+func TestOIDCValidate(t *testing.T) {
+	for _, trust := range []OIDCTrust{
+		{Provider: "http://oidc.example.com", Subjects: []string{"*"}},
+		{Provider: "oidc.example.com", Subjects: []string{"*"}},
+		{Provider: "https://oidc.example.com"},
+	} {
+		p := &ManagedAssumeRolePolicy{OIDC: []OIDCTrust{trust}}
+		if err := p.Validate(); err == nil {
+			t.Errorf("%+v should be invalid", trust)
+		}
+	}
+	p := &ManagedAssumeRolePolicy{}
+	if err := (oidcSubjectValue{p}).Set("*"); err == nil {
+		t.Error("--oidc-subject without --oidc-provider should be invalid")
+	}
+	if err := (circleCIValue{p}).Set("org-id"); err == nil {
+		t.Error("--circleci without a project ID should be invalid")
+	}
+}
@@ -0,0 +1,197 @@
+package roles
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/src-bin/substrate/tagging"
+)
+
+// TrustedAccounts allows principals in every service account whose domain,
+// environment, and quality match to assume a role via sts:AssumeRole. If
+// RoleName is empty, any principal those accounts' own IAM policies allow may
+// assume it; otherwise only the role by that name in each of those accounts
+// may. Empty Domains, Environments, or Qualities match everything.
+//
+// These are resolved to account numbers each time the role is created or
+// updated, including by `substrate account create` and `substrate account
+// close`, so that accounts that match are trusted only while they exist.
+type TrustedAccounts struct {
+	RoleName     string   `json:",omitempty"`
+	Domains      []string `json:",omitempty"`
+	Environments []string `json:",omitempty"`
+	Qualities    []string `json:",omitempty"`
+}
+
+// Match returns true if a service account with the given domain,
+// environment, and quality is trusted.
+func (t TrustedAccounts) Match(domain, environment, quality string) bool {
+	return matchOrEmpty(t.Domains, domain) &&
+		matchOrEmpty(t.Environments, environment) &&
+		matchOrEmpty(t.Qualities, quality)
+}
+
+// String encodes t compactly enough, and using only characters allowed, to
+// be stored in a tag, as "<RoleName>/<domains>/<environments>/<qualities>"
+// with multiple domains, environments, or qualities separated by '+'.
+func (t TrustedAccounts) String() string {
+	return strings.Join([]string{
+		t.RoleName,
+		strings.Join(t.Domains, "+"),
+		strings.Join(t.Environments, "+"),
+		strings.Join(t.Qualities, "+"),
+	}, "/")
+}
+
+// ParseTrustedAccountsTag parses the space-separated encoding of a slice of
+// TrustedAccounts, each as encoded by TrustedAccounts.String.
+func ParseTrustedAccountsTag(s string) ([]TrustedAccounts, error) {
+	var trusts []TrustedAccounts
+	for _, field := range strings.Fields(s) {
+		parts := strings.Split(field, "/")
+		if len(parts) != 4 {
+			return nil, ManagedAssumeRolePolicyError(fmt.Sprintf("%q is not a valid encoding of trusted accounts", field))
+		}
+		trusts = append(trusts, TrustedAccounts{
+			RoleName:     parts[0],
+			Domains:      splitNonEmpty(parts[1], "+"),
+			Environments: splitNonEmpty(parts[2], "+"),
+			Qualities:    splitNonEmpty(parts[3], "+"),
+		})
+	}
+	return trusts, nil
+}
+
+// TrustedAccountsTag returns the value of the SubstrateTrustedAccounts tag
+// that records p.TrustedAccounts so they may be resolved again later.
+func (p *ManagedAssumeRolePolicy) TrustedAccountsTag() string {
+	ss := make([]string, len(p.TrustedAccounts))
+	for i, t := range p.TrustedAccounts {
+		ss[i] = t.String()
+	}
+	return strings.Join(ss, " ")
+}
+
+func (p *ManagedAssumeRolePolicy) sortTrustedAccounts() {
+	for i := range p.TrustedAccounts {
+		sort.Strings(p.TrustedAccounts[i].Domains)
+		sort.Strings(p.TrustedAccounts[i].Environments)
+		sort.Strings(p.TrustedAccounts[i].Qualities)
+	}
+	sort.SliceStable(p.TrustedAccounts, func(i, j int) bool {
+		return p.TrustedAccounts[i].String() < p.TrustedAccounts[j].String()
+	})
+}
+
+func (p *ManagedAssumeRolePolicy) validateTrustedAccounts() error {
+	for _, t := range p.TrustedAccounts {
+		for _, s := range append(append(append([]string{t.RoleName}, t.Domains...), t.Environments...), t.Qualities...) {
+			if strings.ContainsAny(s, " +/") {
+				return ManagedAssumeRolePolicyError(fmt.Sprintf("%q can't contain spaces, '+', or '/' when used with --trusted-accounts or --trusted-role", s))
+			}
+		}
+	}
+	if tag := p.TrustedAccountsTag(); len(tag) > tagging.MaxValueLength {
+		return ManagedAssumeRolePolicyError(fmt.Sprintf(
+			"too many --trusted-accounts and --trusted-role flags to record in a tag (%d > %d characters)",
+			len(tag),
+			tagging.MaxValueLength,
+		))
+	}
+	return nil
+}
+
+func matchOrEmpty(ss []string, s string) bool {
+	if len(ss) == 0 {
+		return true
+	}
+	for _, s0 := range ss {
+		if s0 == s {
+			return true
+		}
+	}
+	return false
+}
+
+func splitNonEmpty(s, sep string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, sep)
+}
+
+// The following pflag.Value implementations accumulate TrustedAccounts in
+// the order the flags are given so that --trusted-domain,
+// --trusted-environment, and --trusted-quality apply to the --trusted-accounts
+// or --trusted-role that most recently preceded them.
+
+type trustedAccountsValue struct{ p *ManagedAssumeRolePolicy }
+
+func (v trustedAccountsValue) Set(s string) error {
+	if s != "true" {
+		return ManagedAssumeRolePolicyError("--trusted-accounts doesn't take a value")
+	}
+	v.p.TrustedAccounts = append(v.p.TrustedAccounts, TrustedAccounts{})
+	return nil
+}
+
+func (trustedAccountsValue) String() string { return "" }
+
+func (trustedAccountsValue) Type() string { return "bool" }
+
+type trustedDomainValue struct{ p *ManagedAssumeRolePolicy }
+
+func (v trustedDomainValue) Set(s string) error {
+	if len(v.p.TrustedAccounts) == 0 {
+		return ManagedAssumeRolePolicyError("--trusted-domain must follow --trusted-accounts or --trusted-role")
+	}
+	t := &v.p.TrustedAccounts[len(v.p.TrustedAccounts)-1]
+	t.Domains = append(t.Domains, s)
+	return nil
+}
+
+func (trustedDomainValue) String() string { return "" }
+
+func (trustedDomainValue) Type() string { return "string" }
+
+type trustedEnvironmentValue struct{ p *ManagedAssumeRolePolicy }
+
+func (v trustedEnvironmentValue) Set(s string) error {
+	if len(v.p.TrustedAccounts) == 0 {
+		return ManagedAssumeRolePolicyError("--trusted-environment must follow --trusted-accounts or --trusted-role")
+	}
+	t := &v.p.TrustedAccounts[len(v.p.TrustedAccounts)-1]
+	t.Environments = append(t.Environments, s)
+	return nil
+}
+
+func (trustedEnvironmentValue) String() string { return "" }
+
+func (trustedEnvironmentValue) Type() string { return "string" }
+
+type trustedQualityValue struct{ p *ManagedAssumeRolePolicy }
+
+func (v trustedQualityValue) Set(s string) error {
+	if len(v.p.TrustedAccounts) == 0 {
+		return ManagedAssumeRolePolicyError("--trusted-quality must follow --trusted-accounts or --trusted-role")
+	}
+	t := &v.p.TrustedAccounts[len(v.p.TrustedAccounts)-1]
+	t.Qualities = append(t.Qualities, s)
+	return nil
+}
+
+func (trustedQualityValue) String() string { return "" }
+
+func (trustedQualityValue) Type() string { return "string" }
+
+type trustedRoleValue struct{ p *ManagedAssumeRolePolicy }
+
+func (v trustedRoleValue) Set(s string) error {
+	v.p.TrustedAccounts = append(v.p.TrustedAccounts, TrustedAccounts{RoleName: s})
+	return nil
+}
+
+func (trustedRoleValue) String() string { return "" }
+
+func (trustedRoleValue) Type() string { return "string" }
@@ -0,0 +1,87 @@
+package roles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrustedAccountsFlags(t *testing.T) {
+	p := &ManagedAssumeRolePolicy{}
+	set := p.FlagSet(ManagedAssumeRolePolicyFlagsUsage{
+		Humans:             "humans",
+		AWSServices:        "aws-service",
+		GitHubActions:      "github-actions",
+		OIDCProvider:       "oidc-provider",
+		OIDCSubject:        "oidc-subject",
+		OIDCAudience:       "oidc-audience",
+		GitLabCI:           "gitlab-ci",
+		CircleCI:           "circleci",
+		TrustedAccounts:    "trusted-accounts",
+		TrustedRole:        "trusted-role",
+		TrustedDomain:      "trusted-domain",
+		TrustedEnvironment: "trusted-environment",
+		TrustedQuality:     "trusted-quality",
+		Filenames:          "assume-role-policy",
+	})
+	if err := set.Parse([]string{
+		"--trusted-role", "Deployer",
+		"--trusted-environment", "staging",
+		"--trusted-accounts",
+		"--trusted-domain", "payments",
+		"--trusted-domain", "billing",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	p.Sort()
+	expected := []TrustedAccounts{
+		{Domains: []string{"billing", "payments"}},
+		{RoleName: "Deployer", Environments: []string{"staging"}},
+	}
+	if !reflect.DeepEqual(p.TrustedAccounts, expected) {
+		t.Errorf("p.TrustedAccounts: %+v != %+v", p.TrustedAccounts, expected)
+	}
+	if s := p.String(); s != `--trusted-accounts --trusted-domain "billing" --trusted-domain "payments" --trusted-role "Deployer" --trusted-environment "staging"` {
+		t.Errorf("p.String(): %q", s)
+	}
+
+	if err := (trustedDomainValue{&ManagedAssumeRolePolicy{}}).Set("payments"); err == nil {
+		t.Error("--trusted-domain without --trusted-accounts or --trusted-role should be invalid")
+	}
+}
+
+func TestTrustedAccountsMatch(t *testing.T) {
+	trust := TrustedAccounts{RoleName: "Deployer", Environments: []string{"staging", "production"}}
+	if !trust.Match("payments", "staging", "default") {
+		t.Error("payments staging default should match")
+	}
+	if trust.Match("payments", "development", "default") {
+		t.Error("payments development default shouldn't match")
+	}
+	if !(TrustedAccounts{}).Match("payments", "development", "default") {
+		t.Error("empty TrustedAccounts should match everything")
+	}
+}
+
+func TestTrustedAccountsTag(t *testing.T) {
+	p := &ManagedAssumeRolePolicy{TrustedAccounts: []TrustedAccounts{
+		{Domains: []string{"billing", "payments"}},
+		{RoleName: "Deployer", Environments: []string{"staging"}, Qualities: []string{"alpha", "beta"}},
+	}}
+	tag := p.TrustedAccountsTag()
+	if tag != "/billing+payments// Deployer//staging/alpha+beta" {
+		t.Errorf("p.TrustedAccountsTag(): %q", tag)
+	}
+	trusts, err := ParseTrustedAccountsTag(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(trusts, p.TrustedAccounts) {
+		t.Errorf("ParseTrustedAccountsTag(%q): %+v != %+v", tag, trusts, p.TrustedAccounts)
+	}
+	if _, err := ParseTrustedAccountsTag("Deployer/staging"); err == nil {
+		t.Error(`"Deployer/staging" should be an invalid encoding`)
+	}
+}
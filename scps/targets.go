@@ -0,0 +1,194 @@
+package scps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/src-bin/substrate/accounts"
+	"github.com/src-bin/substrate/tagging"
+)
+
+// Substrate is the name of the service control policy that `substrate setup`
+// manages and attaches to the organization's root. It can't be managed via
+// `substrate scp create|delete`.
+const Substrate = "SubstrateServiceControlPolicy"
+
+// Targets describes where a service control policy should be attached, in
+// terms of the same flags that are given to `substrate scp create`.
+type Targets struct {
+	Root                bool
+	OrganizationalUnits []string
+	Selection           *accounts.Selection
+}
+
+func NewTargets() *Targets {
+	return &Targets{Selection: &accounts.Selection{}}
+}
+
+func (t *Targets) Arguments() []string {
+	var ss []string
+	if t.Root {
+		ss = append(ss, "--root")
+	}
+	for _, ou := range t.OrganizationalUnits {
+		ss = append(ss, "--organizational-unit", fmt.Sprintf("%q", ou))
+	}
+	if t.Selection != nil {
+		ss = append(ss, t.Selection.Arguments()...)
+	}
+	return ss
+}
+
+func (t *Targets) FlagSet(u TargetsFlagsUsage) *pflag.FlagSet {
+	if u.Root == "" {
+		panic("TargetsFlagsUsage.Root can't be empty")
+	}
+	if u.OrganizationalUnits == "" {
+		panic("TargetsFlagsUsage.OrganizationalUnits can't be empty")
+	}
+	t.Reset()
+	set := pflag.NewFlagSet("[target flags]", pflag.ExitOnError)
+	set.BoolVar(&t.Root, "root", false, u.Root)
+	set.StringArrayVar(&t.OrganizationalUnits, "organizational-unit", []string{}, u.OrganizationalUnits)
+	return set
+}
+
+// ParseTag is the inverse of Tag; it resets t and then fills it in from the
+// value of the SubstratePolicyTargets tag.
+func (t *Targets) ParseTag(value string) error {
+	t.Reset()
+	for _, token := range strings.Fields(value) {
+		key, arg, _ := strings.Cut(token, ":")
+		switch key {
+		case "root":
+			t.Root = true
+		case "organizational-unit":
+			t.OrganizationalUnits = append(t.OrganizationalUnits, arg)
+		case "all-domains":
+			t.Selection.AllDomains = true
+		case "domain":
+			t.Selection.Domains = append(t.Selection.Domains, arg)
+		case "all-environments":
+			t.Selection.AllEnvironments = true
+		case "environment":
+			t.Selection.Environments = append(t.Selection.Environments, arg)
+		case "all-qualities":
+			t.Selection.AllQualities = true
+		case "quality":
+			t.Selection.Qualities = append(t.Selection.Qualities, arg)
+		case "substrate":
+			t.Selection.Substrate = true
+		case "management":
+			t.Selection.Management = true
+		case "special":
+			t.Selection.Specials = append(t.Selection.Specials, arg)
+		case "number":
+			t.Selection.Numbers = append(t.Selection.Numbers, arg)
+		default:
+			return TargetsError(fmt.Sprintf("unknown target %q", token))
+		}
+	}
+	return nil
+}
+
+func (t *Targets) Reset() {
+	t.Root = false
+	t.OrganizationalUnits = []string{}
+	if t.Selection == nil {
+		t.Selection = &accounts.Selection{}
+	}
+	t.Selection.Reset()
+}
+
+func (t *Targets) Sort() error {
+	sort.Strings(t.OrganizationalUnits)
+	if t.Selection != nil {
+		return t.Selection.Sort()
+	}
+	return nil
+}
+
+func (t *Targets) String() string {
+	return strings.Join(t.Arguments(), " ")
+}
+
+// Tag returns a compact representation of t that's suitable for use as the
+// value of the SubstratePolicyTargets tag. AWS Organizations limits tag
+// values to tagging.MaxValueLength characters so this returns an error if t
+// is too complicated, which callers must check before changing anything.
+func (t *Targets) Tag() (string, error) {
+	var ss []string
+	if t.Root {
+		ss = append(ss, "root")
+	}
+	for _, ou := range t.OrganizationalUnits {
+		ss = append(ss, "organizational-unit:"+ou)
+	}
+	if s := t.Selection; s != nil {
+		if s.AllDomains {
+			ss = append(ss, "all-domains")
+		}
+		for _, domain := range s.Domains {
+			ss = append(ss, "domain:"+domain)
+		}
+		if s.AllEnvironments {
+			ss = append(ss, "all-environments")
+		}
+		for _, environment := range s.Environments {
+			ss = append(ss, "environment:"+environment)
+		}
+		if s.AllQualities {
+			ss = append(ss, "all-qualities")
+		}
+		for _, quality := range s.Qualities {
+			ss = append(ss, "quality:"+quality)
+		}
+		if s.Substrate {
+			ss = append(ss, "substrate")
+		}
+		if s.Management {
+			ss = append(ss, "management")
+		}
+		for _, special := range s.Specials {
+			ss = append(ss, "special:"+special)
+		}
+		for _, number := range s.Numbers {
+			ss = append(ss, "number:"+number)
+		}
+	}
+	value := strings.Join(ss, " ")
+	if len(value) > tagging.MaxValueLength {
+		return "", TargetsError(fmt.Sprintf(
+			"too many targets to record in a tag (%d characters; the limit is %d); try --all-domains, --all-environments, or --all-qualities",
+			len(value),
+			tagging.MaxValueLength,
+		))
+	}
+	return value, nil
+}
+
+func (t *Targets) Validate() error {
+	if t.Selection == nil {
+		t.Selection = &accounts.Selection{}
+	}
+	// Unlike `substrate role create`, it's valid to give no account selection
+	// flags as long as the policy is being attached to the root or an
+	// organizational unit.
+	if !t.Selection.Empty() || !t.Root && len(t.OrganizationalUnits) == 0 {
+		return t.Selection.Validate()
+	}
+	return nil
+}
+
+type TargetsError string
+
+func (err TargetsError) Error() string {
+	return fmt.Sprint("TargetsError: ", string(err))
+}
+
+type TargetsFlagsUsage struct {
+	Root                string
+	OrganizationalUnits string
+}
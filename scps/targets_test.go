@@ -0,0 +1,64 @@
+package scps
+
+import (
+	"testing"
+
+	"github.com/src-bin/substrate/tagging"
+)
+
+func TestTargetsTagRoundTrip(t *testing.T) {
+	t1 := NewTargets()
+	t1.Root = true
+	t1.OrganizationalUnits = []string{"ou-abcd-12345678"}
+	t1.Selection.Domains = []string{"foo"}
+	t1.Selection.AllEnvironments = true
+	t1.Selection.Qualities = []string{"beta"}
+	t1.Selection.Specials = []string{"deploy"}
+	t1.Selection.Numbers = []string{"123456789012"}
+	value, err := t1.Tag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2 := NewTargets()
+	if err := t2.ParseTag(value); err != nil {
+		t.Fatal(err)
+	}
+	if t1.String() != t2.String() {
+		t.Errorf("t1.String(): %q != t2.String(): %q", t1.String(), t2.String())
+	}
+}
+
+func TestTargetsTagTooLong(t *testing.T) {
+	targets := NewTargets()
+	for i := 0; i < 100; i++ {
+		targets.Selection.Numbers = append(targets.Selection.Numbers, "123456789012")
+	}
+	if _, err := targets.Tag(); err == nil {
+		t.Error("expected an error from a 256+ character tag")
+	}
+
+	// Twelve "number:123456789012" tokens and a 16-character domain token,
+	// separated by spaces, are exactly as long as a tag value may be.
+	targets.Selection.Numbers = targets.Selection.Numbers[:12]
+	targets.Selection.Domains = []string{"abcdefghi"}
+	if value, err := targets.Tag(); err != nil || len(value) != tagging.MaxValueLength {
+		t.Errorf("targets.Tag(): %d characters, %v", len(value), err)
+	}
+	targets.Selection.Domains = []string{"abcdefghij"}
+	if _, err := targets.Tag(); err == nil {
+		t.Error("expected an error from a 257-character tag")
+	}
+}
+
+func TestTargetsZero(t *testing.T) {
+	targets := NewTargets()
+	if len(targets.Arguments()) != 0 {
+		t.Errorf("len(targets.Arguments()): %d != 0; targets.Arguments(): %+v", len(targets.Arguments()), targets.Arguments())
+	}
+	if targets.Selection != nil && !targets.Selection.Empty() {
+		t.Error("targets.Selection should be empty")
+	}
+	if value, err := targets.Tag(); err != nil || value != "" {
+		t.Errorf(`targets.Tag(): %q, %v != "", <nil>`, value, err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package secretservice is a minimal client for the freedesktop.org Secret
+// Service D-Bus API, which GNOME Keyring, KWallet, and KeePassXC implement,
+// that's just enough to store and retrieve one secret by its attributes.
+// See <https://specifications.freedesktop.org/secret-service/latest/>.
+package secretservice
+
+import (
+	"errors"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	BusName = "org.freedesktop.secrets"
+
+	DefaultCollection dbus.ObjectPath = "/org/freedesktop/secrets/aliases/default"
+	ServicePath       dbus.ObjectPath = "/org/freedesktop/secrets"
+
+	collectionInterface = "org.freedesktop.Secret.Collection"
+	itemInterface       = "org.freedesktop.Secret.Item"
+	serviceInterface    = "org.freedesktop.Secret.Service"
+	sessionInterface    = "org.freedesktop.Secret.Session"
+
+	noPrompt dbus.ObjectPath = "/"
+)
+
+var (
+	ErrLocked   = errors.New("the Secret Service collection is locked")
+	ErrNotFound = errors.New("secret not found in the Secret Service")
+)
+
+// Secret is the Secret Service API's (oayays) Secret struct. Parameters are
+// empty and Value is plaintext because Client only negotiates the "plain"
+// algorithm, which is reasonable on a bus that's private to one user.
+type Secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// Client is a connection and an open session with the Secret Service.
+type Client struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+// Connect opens a session with the Secret Service on the D-Bus session bus.
+// It returns an error if there's no session bus or no Secret Service on it,
+// which callers should take to mean the Secret Service isn't available.
+func Connect() (*Client, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient opens a session with the Secret Service on an existing D-Bus
+// connection.
+func NewClient(conn *dbus.Conn) (*Client, error) {
+	var (
+		output  dbus.Variant
+		session dbus.ObjectPath
+	)
+	if err := conn.Object(BusName, ServicePath).Call(
+		serviceInterface+".OpenSession",
+		0,
+		"plain",
+		dbus.MakeVariant(""),
+	).Store(&output, &session); err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, session: session}, nil
+}
+
+// Close closes the session and the D-Bus connection.
+func (c *Client) Close() error {
+	err := c.conn.Object(BusName, c.session).Call(sessionInterface+".Close", 0).Err
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Get returns the value of the first unlocked secret with all the given
+// attributes. It returns ErrLocked if the only such secrets are locked and
+// ErrNotFound if there are none at all.
+func (c *Client) Get(attributes map[string]string) ([]byte, error) {
+	var unlocked, locked []dbus.ObjectPath
+	if err := c.conn.Object(BusName, ServicePath).Call(
+		serviceInterface+".SearchItems",
+		0,
+		attributes,
+	).Store(&unlocked, &locked); err != nil {
+		return nil, err
+	}
+	if len(unlocked) == 0 {
+		if len(locked) > 0 {
+			return nil, ErrLocked
+		}
+		return nil, ErrNotFound
+	}
+	var secret Secret
+	if err := c.conn.Object(BusName, unlocked[0]).Call(
+		itemInterface+".GetSecret",
+		0,
+		c.session,
+	).Store(&secret); err != nil {
+		return nil, err
+	}
+	return secret.Value, nil
+}
+
+// Set stores value in the default collection labeled with label and tagged
+// with attributes, replacing any secret there with the same attributes. It
+// returns ErrLocked rather than prompting the user if the default collection
+// is locked.
+func (c *Client) Set(label string, attributes map[string]string, value []byte) error {
+	var item, prompt dbus.ObjectPath
+	if err := c.conn.Object(BusName, DefaultCollection).Call(
+		collectionInterface+".CreateItem",
+		0,
+		map[string]dbus.Variant{
+			itemInterface + ".Label":      dbus.MakeVariant(label),
+			itemInterface + ".Attributes": dbus.MakeVariant(attributes),
+		},
+		Secret{
+			Session:     c.session,
+			Parameters:  []byte{},
+			Value:       value,
+			ContentType: "text/plain",
+		},
+		true, // replace
+	).Store(&item, &prompt); err != nil {
+		return err
+	}
+	if prompt != noPrompt {
+		return ErrLocked
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package secretservice
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestSetGet(t *testing.T) {
+	conn := testBus(t)
+	fake := &fakeSecretService{conn: conn, items: make(map[dbus.ObjectPath]*fakeItem)}
+	fake.export(t)
+
+	c, err := NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attributes := map[string]string{"service": "substrate", "account": "example"}
+
+	if _, err := c.Get(attributes); err != ErrNotFound {
+		t.Fatal(err)
+	}
+	if err := c.Set("Substrate", attributes, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("Substrate", attributes, []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.items) != 1 {
+		t.Fatalf("replace didn't replace: %d items", len(fake.items))
+	}
+	value, err := c.Get(attributes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "second" {
+		t.Fatalf("value: %q", value)
+	}
+	if _, err := c.Get(map[string]string{"service": "substrate", "account": "other"}); err != ErrNotFound {
+		t.Fatal(err)
+	}
+
+	fake.locked = true
+	if _, err := c.Get(attributes); err != ErrLocked {
+		t.Fatal(err)
+	}
+	if err := c.Set("Substrate", attributes, []byte("third")); err != ErrLocked {
+		t.Fatal(err)
+	}
+}
+
+func TestNoSecretService(t *testing.T) {
+	if _, err := NewClient(testBus(t)); err == nil {
+		t.Fatal("NewClient succeeded without a Secret Service on the bus")
+	}
+}
+
+// fakeSecretService implements just enough of the Secret Service API, all
+// on one Go type since D-Bus dispatches by object path and interface, to
+// test Client.
+type fakeSecretService struct {
+	conn   *dbus.Conn
+	items  map[dbus.ObjectPath]*fakeItem
+	locked bool
+	mu     sync.Mutex
+	n      int
+}
+
+type fakeItem struct {
+	attributes map[string]string
+	value      []byte
+}
+
+func (s *fakeSecretService) export(t *testing.T) {
+	t.Helper()
+	for path, iface := range map[dbus.ObjectPath]string{
+		ServicePath:       serviceInterface,
+		DefaultCollection: collectionInterface,
+	} {
+		if err := s.conn.Export(s, path, iface); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.conn.Export(s, "/org/freedesktop/secrets/session/1", sessionInterface); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := s.conn.RequestName(BusName, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	} else if reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("RequestName: %v", reply)
+	}
+}
+
+func (s *fakeSecretService) Close() *dbus.Error { return nil }
+
+func (s *fakeSecretService) CreateItem(
+	properties map[string]dbus.Variant,
+	secret Secret,
+	replace bool,
+) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return noPrompt, "/org/freedesktop/secrets/prompt/1", nil
+	}
+	attributes := properties[itemInterface+".Attributes"].Value().(map[string]string)
+	if replace {
+		for path, item := range s.items {
+			if matches(item.attributes, attributes) {
+				item.value = secret.Value
+				return path, noPrompt, nil
+			}
+		}
+	}
+	s.n++
+	path := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/secrets/collection/login/%d", s.n))
+	s.items[path] = &fakeItem{attributes: attributes, value: secret.Value}
+	if err := s.conn.Export(s, path, itemInterface); err != nil {
+		return noPrompt, noPrompt, dbus.MakeFailedError(err)
+	}
+	return path, noPrompt, nil
+}
+
+func (s *fakeSecretService) GetSecret(msg dbus.Message, session dbus.ObjectPath) (Secret, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[msg.Headers[dbus.FieldPath].Value().(dbus.ObjectPath)]
+	if !ok {
+		return Secret{}, dbus.MakeFailedError(ErrNotFound)
+	}
+	return Secret{Session: session, Parameters: []byte{}, Value: item.value, ContentType: "text/plain"}, nil
+}
+
+func (s *fakeSecretService) OpenSession(algorithm string, _ dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	if algorithm != "plain" {
+		return dbus.MakeVariant(""), noPrompt, dbus.NewError("org.freedesktop.DBus.Error.NotSupported", nil)
+	}
+	return dbus.MakeVariant(""), "/org/freedesktop/secrets/session/1", nil
+}
+
+func (s *fakeSecretService) SearchItems(attributes map[string]string) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []dbus.ObjectPath
+	for path, item := range s.items {
+		if matches(item.attributes, attributes) {
+			matched = append(matched, path)
+		}
+	}
+	if s.locked {
+		return []dbus.ObjectPath{}, matched, nil
+	}
+	return matched, []dbus.ObjectPath{}, nil
+}
+
+func matches(attributes, query map[string]string) bool {
+	for k, v := range query {
+		if attributes[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// testBus starts a private D-Bus daemon for the duration of the test and
+// returns a connection to it, skipping the test if dbus-daemon isn't
+// installed.
+func testBus(t *testing.T) *dbus.Conn {
+	t.Helper()
+	progname, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	dirname := t.TempDir()
+	socket := filepath.Join(dirname, "bus")
+	config := filepath.Join(dirname, "bus.conf")
+	if err := os.WriteFile(config, []byte(fmt.Sprintf(`<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN" "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`, socket)), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(progname, "--config-file="+config, "--nofork", "--nopidfile")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	for i := 0; ; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		} else if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	conn, err := dbus.Connect("unix:path=" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
@@ -0,0 +1,489 @@
+// Package sessions stores Intranet sessions in DynamoDB. The authorizer only
+// allows requests from browsers with a session that's in the table, so
+// deleting one revokes it. Each session also holds the refresh token, if any,
+// that lets the authorizer silently renew a user's ID and access tokens, plus
+// the tokens from the most recent renewal. Renewals are serialized by a lease
+// so that the many requests a browser sends at once don't each spend the same
+// refresh token, which IdPs that detect refresh token reuse would punish by
+// revoking them all. Tokens are encrypted with a key that's only stored in
+// the session cookie so the table alone reveals nothing.
+package sessions
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/src-bin/substrate/awscfg"
+	"github.com/src-bin/substrate/awsdynamodb"
+	"github.com/src-bin/substrate/awsutil"
+	"github.com/src-bin/substrate/randutil"
+)
+
+const (
+	CookieName = "s"
+
+	// DefaultLifetime is how long sessions last unless configured otherwise,
+	// which is the same as the Intranet's cookies lasted before sessions.
+	DefaultLifetime = 12 * time.Hour
+
+	SessionLifetime = "SESSION_LIFETIME" // Lambda environment variable name
+	SessionsRegion  = "SESSIONS_REGION"  // Lambda environment variable name
+
+	TableName = "substrate-intranet-sessions"
+
+	keySize = 32 // AES-256
+
+	// versionCondition is true of sessions that still exist, i.e. haven't been
+	// revoked, and haven't been renewed since they were fetched.
+	versionCondition = "attribute_exists(Id) AND #V = :v"
+)
+
+type Session struct {
+	AccessToken, IDToken string // from the most recent renewal, if any
+	Created, Expires     time.Time
+	Id                   string
+	PrincipalId          string
+	RefreshToken         string
+	Version              int // incremented by every renewal
+	key                  []byte
+}
+
+// New returns a new session, not yet stored, for the given principal that
+// expires after lifetime.
+func New(principalId, refreshToken string, lifetime time.Duration) (*Session, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &Session{
+		Created:      now,
+		Expires:      now.Add(lifetime),
+		Id:           randutil.String(),
+		PrincipalId:  principalId,
+		RefreshToken: refreshToken,
+		key:          key,
+	}, nil
+}
+
+// Get parses the session cookie, fetches the session it refers to, and
+// decrypts its tokens, the refresh token's decryption proving the cookie
+// holds the right key even if the session has no refresh token. It returns
+// awsdynamodb.ItemNotFoundError if the session doesn't exist, e.g. because
+// it's been revoked, and ExpiredError if it's expired but DynamoDB hasn't
+// deleted it yet.
+func Get(ctx context.Context, cfg *awscfg.Config, cookie string) (*Session, error) {
+	id, key, err := parseCookie(cookie)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := awsdynamodb.GetItem(ctx, Regional(cfg), TableName, itemKey(id))
+	if err != nil {
+		return nil, err
+	}
+	s := fromItem(item)
+	s.key = key
+	if time.Now().After(s.Expires) {
+		return nil, ExpiredError(id)
+	}
+	v, ok := item["RefreshToken"].(*awsdynamodb.AttributeValueMemberB)
+	if !ok {
+		return nil, DecryptionError(id)
+	}
+	b, err := s.decrypt(v.Value)
+	if err != nil {
+		return nil, err
+	}
+	s.RefreshToken = string(b)
+	for name, token := range map[string]*string{"AccessToken": &s.AccessToken, "IDToken": &s.IDToken} {
+		if v, ok := item[name].(*awsdynamodb.AttributeValueMemberB); ok {
+			b, err := s.decrypt(v.Value)
+			if err != nil {
+				return nil, err
+			}
+			*token = string(b)
+		}
+	}
+	return s, nil
+}
+
+// List returns every unexpired session, oldest first, without decrypting
+// any of their tokens.
+func List(ctx context.Context, cfg *awscfg.Config) ([]*Session, error) {
+	items, err := awsdynamodb.Scan(ctx, Regional(cfg), TableName)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sessions := make([]*Session, 0, len(items))
+	for _, item := range items {
+		if s := fromItem(item); now.Before(s.Expires) {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Created.Equal(sessions[j].Created) {
+			return sessions[i].Id < sessions[j].Id
+		}
+		return sessions[i].Created.Before(sessions[j].Created)
+	})
+	return sessions, nil
+}
+
+// Revoke deletes the session with the given ID, after which the authorizer
+// won't allow any requests from the browser that holds it. It's not an error
+// to revoke a session that's already gone.
+func Revoke(ctx context.Context, cfg *awscfg.Config, id string) error {
+	return awsdynamodb.DeleteItem(ctx, Regional(cfg), TableName, itemKey(id))
+}
+
+// RevokeAll revokes every session that belongs to the given principal, e.g.
+// when they're offboarded, and returns how many it revoked.
+func RevokeAll(ctx context.Context, cfg *awscfg.Config, principalId string) (int, error) {
+	sessions, err := List(ctx, cfg)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, s := range sessions {
+		if s.PrincipalId != principalId {
+			continue
+		}
+		if err := Revoke(ctx, cfg, s.Id); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ClearCookies returns the Set-Cookie header values that remove the session
+// and every token the Intranet stores in the browser.
+func ClearCookies() []string {
+	var setCookies []string
+	for _, name := range []string{"a", "csrf", "exp", "id", CookieName} {
+		setCookies = append(setCookies, fmt.Sprintf("%s=; HttpOnly; Max-Age=0; Secure", name))
+	}
+	return setCookies
+}
+
+// Cookie returns the value of the session cookie, which identifies the
+// session and carries the key that decrypts its refresh token.
+func (s *Session) Cookie() string {
+	return s.Id + "." + base64.RawURLEncoding.EncodeToString(s.key)
+}
+
+// Lease claims the exclusive right to renew the session's tokens for the
+// given duration. It returns LeaseError if another request holds the lease
+// or has renewed the session since s was fetched, in which case the caller
+// should Get the session again to find the renewed tokens.
+func (s *Session) Lease(ctx context.Context, cfg *awscfg.Config, d time.Duration) error {
+	now := time.Now()
+	err := awsdynamodb.UpdateItemIf(
+		ctx,
+		Regional(cfg),
+		TableName,
+		itemKey(s.Id),
+		"SET #L = :until",
+		versionCondition+" AND (attribute_not_exists(#L) OR #L < :now)",
+		map[string]string{"#L": "Lease", "#V": "Version"},
+		map[string]awsdynamodb.AttributeValue{
+			":now":   &awsdynamodb.AttributeValueMemberN{Value: formatUnix(now)},
+			":until": &awsdynamodb.AttributeValueMemberN{Value: formatUnix(now.Add(d))},
+			":v":     &awsdynamodb.AttributeValueMemberN{Value: strconv.Itoa(s.Version)},
+		},
+	)
+	if awsutil.ErrorCodeIs(err, awsdynamodb.ConditionalCheckFailedException) {
+		return LeaseError(s.Id)
+	}
+	return err
+}
+
+// Put stores the session, encrypting its tokens, replacing any previous
+// version.
+func (s *Session) Put(ctx context.Context, cfg *awscfg.Config) error {
+	item, err := s.item()
+	if err != nil {
+		return err
+	}
+	return awsdynamodb.PutItem(ctx, Regional(cfg), TableName, item)
+}
+
+// Release gives up the lease without renewing the session's tokens, e.g.
+// because the IdP refused the refresh token, so that other requests needn't
+// wait for the lease to expire. It's not an error if the lease has already
+// expired and been taken by another request or if the session's been revoked.
+func (s *Session) Release(ctx context.Context, cfg *awscfg.Config) error {
+	err := awsdynamodb.UpdateItemIf(
+		ctx,
+		Regional(cfg),
+		TableName,
+		itemKey(s.Id),
+		"REMOVE #L",
+		versionCondition,
+		map[string]string{"#L": "Lease", "#V": "Version"},
+		map[string]awsdynamodb.AttributeValue{
+			":v": &awsdynamodb.AttributeValueMemberN{Value: strconv.Itoa(s.Version)},
+		},
+	)
+	if awsutil.ErrorCodeIs(err, awsdynamodb.ConditionalCheckFailedException) {
+		return nil
+	}
+	return err
+}
+
+// Renew stores the tokens from a renewal, which must be made while holding
+// the lease, and releases the lease. It returns LeaseError if the session was
+// renewed by another request in the meantime, i.e. if the lease expired, or
+// revoked, in which case it stays revoked.
+func (s *Session) Renew(ctx context.Context, cfg *awscfg.Config, accessToken, idToken, refreshToken string) error {
+	version := s.Version
+	s.AccessToken, s.IDToken, s.RefreshToken, s.Version = accessToken, idToken, refreshToken, version+1
+	item, err := s.item() // without a lease
+	if err != nil {
+		return err
+	}
+	err = awsdynamodb.PutItemIf(
+		ctx,
+		Regional(cfg),
+		TableName,
+		item,
+		versionCondition,
+		map[string]string{"#V": "Version"},
+		map[string]awsdynamodb.AttributeValue{
+			":v": &awsdynamodb.AttributeValueMemberN{Value: strconv.Itoa(version)},
+		},
+	)
+	if awsutil.ErrorCodeIs(err, awsdynamodb.ConditionalCheckFailedException) {
+		return LeaseError(s.Id)
+	}
+	return err
+}
+
+// SetCookie returns the Set-Cookie header value that stores the session in
+// the browser until it expires.
+func (s *Session) SetCookie() string {
+	return fmt.Sprintf(
+		"%s=%s; HttpOnly; Max-Age=%d; Secure",
+		CookieName,
+		s.Cookie(),
+		int(time.Until(s.Expires).Seconds()),
+	)
+}
+
+// SetTokenCookies returns the Set-Cookie header values that store the access
+// and ID tokens plus the ID token's expiry, which CloudFront checks so it
+// can send users whose ID tokens have expired to log in or to be renewed.
+func SetTokenCookies(accessToken, idToken string, expires int64) []string {
+	maxAge := int(Lifetime().Seconds())
+	return []string{
+		fmt.Sprintf("a=%s; HttpOnly; Max-Age=%d; Secure", accessToken, maxAge),
+		fmt.Sprintf("exp=%d; HttpOnly; Max-Age=%d; Secure", expires, maxAge),
+		fmt.Sprintf("id=%s; HttpOnly; Max-Age=%d; Secure", idToken, maxAge),
+	}
+}
+
+func (s *Session) decrypt(ciphertext []byte) ([]byte, error) {
+	aead, err := newAEAD(s.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, DecryptionError(s.Id)
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(s.Id))
+	if err != nil {
+		return nil, DecryptionError(s.Id)
+	}
+	return plaintext, nil
+}
+
+func (s *Session) encrypt(plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(s.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(s.Id)), nil // authenticate the session ID, too
+}
+
+func (s *Session) item() (map[string]awsdynamodb.AttributeValue, error) {
+	ciphertext, err := s.encrypt([]byte(s.RefreshToken)) // even if it's empty so that Get can verify the key
+	if err != nil {
+		return nil, err
+	}
+	item := map[string]awsdynamodb.AttributeValue{
+		"Created":      &awsdynamodb.AttributeValueMemberN{Value: formatUnix(s.Created)},
+		"Expires":      &awsdynamodb.AttributeValueMemberN{Value: formatUnix(s.Expires)}, // also DynamoDB's TTL
+		"Id":           &awsdynamodb.AttributeValueMemberS{Value: s.Id},
+		"PrincipalId":  &awsdynamodb.AttributeValueMemberS{Value: s.PrincipalId},
+		"RefreshToken": &awsdynamodb.AttributeValueMemberB{Value: ciphertext},
+		"Version":      &awsdynamodb.AttributeValueMemberN{Value: strconv.Itoa(s.Version)},
+	}
+	for name, token := range map[string]string{"AccessToken": s.AccessToken, "IDToken": s.IDToken} {
+		if token == "" {
+			continue
+		}
+		ciphertext, err := s.encrypt([]byte(token))
+		if err != nil {
+			return nil, err
+		}
+		item[name] = &awsdynamodb.AttributeValueMemberB{Value: ciphertext}
+	}
+	return item, nil
+}
+
+// EnsureTable creates the sessions table, if necessary, waits for it to
+// become active, and configures DynamoDB to delete expired sessions.
+func EnsureTable(ctx context.Context, cfg *awscfg.Config) error {
+	if _, err := awsdynamodb.EnsureTable(
+		ctx,
+		cfg,
+		TableName,
+		[]awsdynamodb.AttributeDefinition{{
+			AttributeName: aws.String("Id"),
+			AttributeType: types.ScalarAttributeTypeS,
+		}},
+		[]awsdynamodb.KeySchemaElement{{
+			AttributeName: aws.String("Id"),
+			KeyType:       types.KeyTypeHash,
+		}},
+	); err != nil {
+		return err
+	}
+	for range awsutil.StandardJitteredExponentialBackoff() {
+		table, err := awsdynamodb.DescribeTable(ctx, cfg, TableName)
+		if err != nil {
+			return err
+		}
+		if table.TableStatus == types.TableStatusActive {
+			break
+		}
+	}
+	return awsdynamodb.EnsureTimeToLive(ctx, cfg, TableName, "Expires")
+}
+
+// Lifetime returns the session lifetime configured in the Intranet's
+// environment or DefaultLifetime.
+func Lifetime() time.Duration {
+	if lifetime, err := time.ParseDuration(os.Getenv(SessionLifetime)); err == nil && lifetime > 0 {
+		return lifetime
+	}
+	return DefaultLifetime
+}
+
+// Regional returns cfg for the region where the sessions table lives, which
+// is one region shared by the Intranet in every region.
+func Regional(cfg *awscfg.Config) *awscfg.Config {
+	if region := os.Getenv(SessionsRegion); region != "" {
+		return cfg.Regional(region)
+	}
+	return cfg
+}
+
+// fromItem returns the session stored in item, except its tokens,
+// which can't be decrypted without the key in the session cookie.
+func fromItem(item map[string]awsdynamodb.AttributeValue) *Session {
+	s := &Session{}
+	if v, ok := item["Created"].(*awsdynamodb.AttributeValueMemberN); ok {
+		s.Created = parseUnix(v.Value)
+	}
+	if v, ok := item["Expires"].(*awsdynamodb.AttributeValueMemberN); ok {
+		s.Expires = parseUnix(v.Value)
+	}
+	if v, ok := item["Id"].(*awsdynamodb.AttributeValueMemberS); ok {
+		s.Id = v.Value
+	}
+	if v, ok := item["PrincipalId"].(*awsdynamodb.AttributeValueMemberS); ok {
+		s.PrincipalId = v.Value
+	}
+	if v, ok := item["Version"].(*awsdynamodb.AttributeValueMemberN); ok {
+		s.Version, _ = strconv.Atoi(v.Value)
+	}
+	return s
+}
+
+func formatUnix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func itemKey(id string) map[string]awsdynamodb.AttributeValue {
+	return map[string]awsdynamodb.AttributeValue{
+		"Id": &awsdynamodb.AttributeValueMemberS{Value: id},
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func parseCookie(cookie string) (id string, key []byte, err error) {
+	if cookie == "" {
+		return "", nil, NoSessionError{}
+	}
+	id, encodedKey, ok := strings.Cut(cookie, ".")
+	if !ok || id == "" {
+		return "", nil, MalformedCookieError{}
+	}
+	key, err = base64.RawURLEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != keySize {
+		return "", nil, MalformedCookieError{}
+	}
+	return id, key, nil
+}
+
+func parseUnix(s string) time.Time {
+	i, _ := strconv.ParseInt(s, 10, 64)
+	return time.Unix(i, 0)
+}
+
+type DecryptionError string
+
+func (err DecryptionError) Error() string {
+	return fmt.Sprintf("DecryptionError: couldn't decrypt the refresh token for session %s", string(err))
+}
+
+type ExpiredError string
+
+func (err ExpiredError) Error() string {
+	return fmt.Sprintf("ExpiredError: session %s expired", string(err))
+}
+
+type LeaseError string
+
+func (err LeaseError) Error() string {
+	return fmt.Sprintf("LeaseError: another request is renewing or has renewed session %s", string(err))
+}
+
+type MalformedCookieError struct{} // no details because the cookie contains a key
+
+func (MalformedCookieError) Error() string {
+	return "MalformedCookieError: session cookie is malformed"
+}
+
+type NoSessionError struct{}
+
+func (NoSessionError) Error() string {
+	return "NoSessionError: there's no session cookie; log in to start a session"
+}
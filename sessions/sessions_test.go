@@ -0,0 +1,164 @@
+package sessions
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/src-bin/substrate/awsdynamodb"
+)
+
+func TestCookie(t *testing.T) {
+	s, err := New("user@example.com", "refresh-token", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, key, err := parseCookie(s.Cookie())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != s.Id || !bytes.Equal(key, s.key) {
+		t.Fatalf("actual: %s %x, expected: %s %x", id, key, s.Id, s.key)
+	}
+	if setCookie := s.SetCookie(); !strings.HasPrefix(setCookie, CookieName+"="+s.Cookie()+"; HttpOnly; Max-Age=") {
+		t.Fatal(setCookie)
+	}
+
+	if _, _, err := parseCookie(""); err != (NoSessionError{}) {
+		t.Errorf("parseCookie(\"\"): expected NoSessionError, got %v", err)
+	}
+	for _, cookie := range []string{
+		s.Id,
+		s.Id + ".",
+		s.Id + ".not base64",
+		s.Id + ".c2hvcnQ", // "short"
+		"." + strings.SplitN(s.Cookie(), ".", 2)[1],
+	} {
+		if _, _, err := parseCookie(cookie); err != (MalformedCookieError{}) {
+			t.Errorf("parseCookie(%q): expected MalformedCookieError, got %v", cookie, err)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	s, err := New("user@example.com", "refresh-token", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := s.encrypt([]byte(s.RefreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(ciphertext, []byte(s.RefreshToken)) {
+		t.Fatal("ciphertext contains the refresh token")
+	}
+	plaintext, err := s.decrypt(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != s.RefreshToken {
+		t.Fatalf("actual: %s, expected: %s", plaintext, s.RefreshToken)
+	}
+
+	// The ciphertext is bound to both the key and the session ID.
+	other, err := New("user@example.com", "refresh-token", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.decrypt(ciphertext); err == nil {
+		t.Fatal("decrypted with another session's key")
+	}
+	other.key = s.key
+	if _, err := other.decrypt(ciphertext); err == nil {
+		t.Fatal("decrypted as another session")
+	}
+	if _, err := s.decrypt(ciphertext[:4]); err == nil {
+		t.Fatal("decrypted truncated ciphertext")
+	}
+}
+
+func TestItem(t *testing.T) {
+	s, err := New("user@example.com", "refresh-token", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AccessToken, s.IDToken, s.Version = "access-token", "id-token", 2
+	item, err := s.item()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := fromItem(item); actual.Version != 2 || actual.AccessToken != "" || actual.IDToken != "" {
+		t.Fatalf("fromItem shouldn't know the tokens: %+v", actual)
+	}
+	for name, expected := range map[string]string{
+		"AccessToken":  "access-token",
+		"IDToken":      "id-token",
+		"RefreshToken": "refresh-token",
+	} {
+		v, ok := item[name].(*awsdynamodb.AttributeValueMemberB)
+		if !ok {
+			t.Fatalf("%s: %+v", name, item[name])
+		}
+		if plaintext, err := s.decrypt(v.Value); err != nil || string(plaintext) != expected {
+			t.Errorf("%s: actual: %s %v, expected: %s", name, plaintext, err, expected)
+		}
+	}
+
+	// Sessions that have never been renewed don't store empty tokens.
+	s.AccessToken, s.IDToken = "", ""
+	if item, err = s.item(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := item["IDToken"]; ok {
+		t.Fatalf("%+v", item)
+	}
+}
+
+func TestLifetime(t *testing.T) {
+	t.Setenv(SessionLifetime, "")
+	if actual := Lifetime(); actual != DefaultLifetime {
+		t.Errorf("actual: %v, expected: %v", actual, DefaultLifetime)
+	}
+	t.Setenv(SessionLifetime, "168h")
+	if actual := Lifetime(); actual != 168*time.Hour {
+		t.Errorf("actual: %v, expected: 168h", actual)
+	}
+	t.Setenv(SessionLifetime, "-1h")
+	if actual := Lifetime(); actual != DefaultLifetime {
+		t.Errorf("actual: %v, expected: %v", actual, DefaultLifetime)
+	}
+}
+
+func TestClearCookies(t *testing.T) {
+	setCookies := ClearCookies()
+	for _, name := range []string{"a", "exp", "id", CookieName} {
+		found := false
+		for _, setCookie := range setCookies {
+			found = found || strings.HasPrefix(setCookie, name+"=; ") && strings.Contains(setCookie, "Max-Age=0")
+		}
+		if !found {
+			t.Errorf("ClearCookies doesn't clear the %s cookie: %v", name, setCookies)
+		}
+	}
+}
+
+func TestFromItem(t *testing.T) {
+	s, err := New("user@example.com", "refresh-token", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := fromItem(map[string]awsdynamodb.AttributeValue{
+		"Created":      &awsdynamodb.AttributeValueMemberN{Value: formatUnix(s.Created)},
+		"Expires":      &awsdynamodb.AttributeValueMemberN{Value: formatUnix(s.Expires)},
+		"Id":           &awsdynamodb.AttributeValueMemberS{Value: s.Id},
+		"PrincipalId":  &awsdynamodb.AttributeValueMemberS{Value: s.PrincipalId},
+		"RefreshToken": &awsdynamodb.AttributeValueMemberB{Value: []byte("ciphertext")},
+	})
+	if actual.Id != s.Id || actual.PrincipalId != s.PrincipalId || actual.Created.Unix() != s.Created.Unix() || actual.Expires.Unix() != s.Expires.Unix() {
+		t.Fatalf("actual: %+v, expected: %+v", actual, s)
+	}
+	if actual.RefreshToken != "" || actual.key != nil {
+		t.Fatalf("fromItem shouldn't know the refresh token or key: %+v", actual)
+	}
+}
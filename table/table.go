@@ -1,12 +1,42 @@
 package table
 
 import (
+	"encoding/csv"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 )
 
+// Fcsv writes the given cells (presumed to be in row-major order) to the given
+// io.Writer as CSV, suitable for importing into spreadsheets.
+func Fcsv(w io.Writer, cells [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(cells); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Fmarkdown writes the given cells (presumed to be in row-major order and
+// with rows of equal length) to the given io.Writer as a Markdown table with
+// the first row as its header.
+func Fmarkdown(w io.Writer, cells [][]string) {
+	if len(cells) == 0 {
+		return
+	}
+	for i, row := range cells {
+		escaped := make([]string, len(row))
+		for j, cell := range row {
+			escaped[j] = strings.ReplaceAll(cell, "|", `\|`)
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+		if i == 0 {
+			fmt.Fprint(w, strings.Repeat("| --- ", len(row)), "|\n")
+		}
+	}
+}
+
 // Ftable writes the given cells (presumed to be in row-major order and with
 // rows of equal length) to the given io.Writer in a layout suitable for
 // terminals or plaintext files.
@@ -0,0 +1,38 @@
+package table
+
+import (
+	"bytes"
+	"testing"
+)
+
+var cells = [][]string{
+	{"Domain", "Account Number"},
+	{"www", "123456789012"},
+	{"a|b", "234567890123"},
+}
+
+func TestFcsv(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := Fcsv(b, append(cells, []string{"x,y", `"z"`})); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); s != "Domain,Account Number\nwww,123456789012\na|b,234567890123\n\"x,y\",\"\"\"z\"\"\"\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func TestFmarkdown(t *testing.T) {
+	b := &bytes.Buffer{}
+	Fmarkdown(b, cells)
+	if s := b.String(); s != "| Domain | Account Number |\n| --- | --- |\n| www | 123456789012 |\n| a\\|b | 234567890123 |\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func TestFtable(t *testing.T) {
+	b := &bytes.Buffer{}
+	Ftable(b, cells[:2])
+	if s := b.String(); s != "+--------+----------------+\n| Domain | Account Number |\n+--------+----------------+\n| www    | 123456789012   |\n+--------+----------------+\n" {
+		t.Errorf("%q", s)
+	}
+}
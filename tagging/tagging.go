@@ -20,6 +20,14 @@ const (
 	SubstrateAccountSelectors          = "SubstrateAccountSelectors"
 	SubstrateAssumeRolePolicyFilenames = "SubstrateAssumeRolePolicyFilenames"
 	SubstratePolicyAttachmentFilenames = "SubstratePolicyAttachmentFilenames"
+	SubstratePolicyDigest              = "SubstratePolicyDigest" // SHA-256 of the files named by the previous two tags
+
+	SubstrateMaxSessionDuration  = "SubstrateMaxSessionDuration"  // only used by custom roles
+	SubstratePermissionsBoundary = "SubstratePermissionsBoundary" // only used by custom roles
+	SubstrateTrustedAccounts     = "SubstrateTrustedAccounts"     // only used by custom roles
+
+	SubstratePolicyFilenames = "SubstratePolicyFilenames" // only used by service control policies
+	SubstratePolicyTargets   = "SubstratePolicyTargets"   // only used by service control policies
 
 	SubstrateSpecialAccount = "SubstrateSpecialAccount" // deprecated
 	SubstrateType           = "SubstrateType"
@@ -32,6 +40,9 @@ const (
 	Substrate = "Substrate"
 )
 
+// MaxValueLength is the most characters AWS allows in a tag's value.
+const MaxValueLength = 256
+
 type Map map[string]string
 
 func Merge(maps ...Map) Map {
@@ -15,18 +15,30 @@ import (
 func Apply(dirname string, autoApprove bool) error {
 	ui.Printf("applying Terraform changes in %s", dirname)
 	//log.Print(execdlp(dirname, "aws", "sts", "get-caller-identity"))
+	var err error
 	if autoApprove {
-		return execdlp(dirname, "terraform", "apply", "-auto-approve")
+		err = execdlp(dirname, "terraform", "apply", "-auto-approve")
+	} else {
+		err = execdlp(dirname, "terraform", "apply")
 	}
-	return execdlp(dirname, "terraform", "apply")
+	if err := RemovePlans(dirname); err != nil {
+		ui.PrintWithCaller(err)
+	}
+	return err
 }
 
 func Destroy(dirname string, autoApprove bool) error {
 	ui.Printf("destroying Terraform-managed resources in %s", dirname)
+	var err error
 	if autoApprove {
-		return execdlp(dirname, "terraform", "destroy", "-auto-approve")
+		err = execdlp(dirname, "terraform", "destroy", "-auto-approve")
+	} else {
+		err = execdlp(dirname, "terraform", "destroy")
+	}
+	if err := RemovePlans(dirname); err != nil {
+		ui.PrintWithCaller(err)
 	}
-	return execdlp(dirname, "terraform", "destroy")
+	return err
 }
 
 func Fmt(dirname string) error {
@@ -75,10 +87,16 @@ func InstalledVersion() (string, error) {
 	return memoizedVersion, nil
 }
 
+// Plan plans Terraform changes in dirname and saves the plan in PlanFilename
+// so that it may be summarized later by ShowPlan. It removes any previous plan
+// first so that a failed plan doesn't leave a stale one behind.
 func Plan(dirname string) error {
 	ui.Printf("planning Terraform changes in %s", dirname)
+	if err := RemovePlans(dirname); err != nil {
+		return err
+	}
 	//log.Print(execdlp(dirname, "aws", "sts", "get-caller-identity"))
-	err := execdlp(dirname, "terraform", "plan")
+	err := execdlp(dirname, "terraform", "plan", "-out="+PlanFilename)
 	exitErr, ok := err.(*exec.ExitError)
 	if !ok {
 		return err
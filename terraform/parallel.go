@@ -0,0 +1,157 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// Outcome summarizes how running Terraform in one root module went.
+type Outcome string
+
+const (
+	Changes   Outcome = "changes"
+	Failed    Outcome = "failed"
+	NoChanges Outcome = "no changes"
+	Skipped   Outcome = "skipped" // because another root module failed first
+)
+
+// Result is the Outcome of running Terraform in the root module in Dirname.
+type Result struct {
+	Dirname string
+	Outcome Outcome
+	Err     error `json:",omitempty"`
+}
+
+// Parallel runs `terraform <args>` in every one of dirnames concurrently, at
+// most parallelism at a time, with every line of output prefixed by the root
+// module's directory name. `terraform plan` is given -detailed-exitcode
+// so its results can distinguish Changes from NoChanges. If failFast is true,
+// the first failure interrupts every other running Terraform and skips those
+// that haven't started yet. Results are in the same order as dirnames.
+func Parallel(ctx context.Context, dirnames []string, args []string, parallelism int, failFast bool, w io.Writer) []*Result {
+	if len(args) > 0 && args[0] == "plan" && !contains(args, "-detailed-exitcode") {
+		args = append(append([]string{}, args...), "-detailed-exitcode")
+	}
+	return parallel(ctx, "terraform", dirnames, args, parallelism, failFast, w)
+}
+
+func parallel(ctx context.Context, progname string, dirnames []string, args []string, parallelism int, failFast bool, w io.Writer) []*Result {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*Result, len(dirnames))
+	mu := &sync.Mutex{} // serializes writes to w
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, dirname := range dirnames {
+		results[i] = &Result{Dirname: dirname, Outcome: Skipped}
+		wg.Add(1)
+		go func(r *Result) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+
+			pw := &prefixWriter{mu: mu, prefix: r.Dirname + ": ", w: w}
+			cmd := exec.CommandContext(ctx, progname, args...)
+			cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) } // let Terraform release its state lock
+			cmd.Dir = r.Dirname
+			cmd.Stdout = pw
+			cmd.Stderr = pw
+			err := cmd.Run()
+			pw.Flush()
+
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 && contains(args, "-detailed-exitcode") {
+				r.Outcome = Changes
+			} else if err != nil {
+				r.Outcome, r.Err = Failed, err
+				if failFast {
+					cancel()
+				}
+			} else if pw.changes {
+				r.Outcome = Changes
+			} else {
+				r.Outcome = NoChanges
+			}
+		}(results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// applyCompleteRegexp matches the summary `terraform apply` prints when it
+// finishes, which is how Parallel knows whether an apply changed anything.
+var applyCompleteRegexp = regexp.MustCompile(`Apply complete! Resources: (\d+) added, (\d+) changed, (\d+) destroyed`)
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixWriter writes whole lines to w, each prefixed by prefix, holding
+// incomplete lines back until they're completed or Flush is called so that
+// many of them may share w without interleaving within lines.
+type prefixWriter struct {
+	buf     []byte
+	changes bool // true if an `Apply complete!` line reported any changes
+	mu      *sync.Mutex
+	prefix  string
+	w       io.Writer
+}
+
+func (pw *prefixWriter) Flush() error {
+	if len(pw.buf) == 0 {
+		return nil
+	}
+	line := append(pw.buf, '\n')
+	pw.buf = nil
+	return pw.writeLine(line)
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.buf = append(pw.buf, p...)
+	for {
+		i := bytes.IndexByte(pw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := pw.buf[:i+1]
+		if err := pw.writeLine(line); err != nil {
+			return 0, err
+		}
+		pw.buf = pw.buf[i+1:]
+	}
+	pw.buf = append([]byte{}, pw.buf...) // don't pin what's already written
+	return len(p), nil
+}
+
+func (pw *prefixWriter) writeLine(line []byte) error {
+	if m := applyCompleteRegexp.FindSubmatch(line); m != nil {
+		for _, n := range m[1:] {
+			if i, _ := strconv.Atoi(string(n)); i > 0 {
+				pw.changes = true
+			}
+		}
+	}
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	_, err := fmt.Fprintf(pw.w, "%s%s", pw.prefix, line)
+	return err
+}
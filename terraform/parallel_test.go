@@ -0,0 +1,94 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParallel(t *testing.T) {
+	var dirnames []string
+	for _, script := range []string{
+		"echo ok",
+		"echo changed; exit 2",
+		"echo broken >&2; exit 1",
+		"echo Apply complete! Resources: 0 added, 1 changed, 0 destroyed.",
+	} {
+		dirname := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dirname, "script"), []byte(script+"\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		dirnames = append(dirnames, dirname)
+	}
+	b := &bytes.Buffer{}
+	results := parallel(context.Background(), "sh", dirnames, []string{"script", "-detailed-exitcode"}, len(dirnames), false, b)
+	for i, outcome := range []Outcome{NoChanges, Changes, Failed, Changes} {
+		if results[i].Dirname != dirnames[i] || results[i].Outcome != outcome {
+			t.Errorf("results[%d]: %+v, expected %q", i, results[i], outcome)
+		}
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		dirnames[0] + ": ok",
+		dirnames[1] + ": changed",
+		dirnames[2] + ": broken",
+		dirnames[3] + ": Apply complete! Resources: 0 added, 1 changed, 0 destroyed.",
+	}
+	sort.Strings(expected)
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("output: %q, expected %q", lines, expected)
+	}
+}
+
+func TestParallelFailFast(t *testing.T) {
+	dirnames := make([]string, 10)
+	for i := range dirnames {
+		dirnames[i] = t.TempDir()
+		if err := os.WriteFile(filepath.Join(dirnames[i], "script"), []byte("exit 1\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results := parallel(context.Background(), "sh", dirnames, []string{"script"}, 1, true, &bytes.Buffer{})
+	var failed, skipped int
+	for _, r := range results {
+		switch r.Outcome {
+		case Failed:
+			failed++
+		case Skipped:
+			skipped++
+		default:
+			t.Errorf("%+v", r)
+		}
+	}
+	if failed != 1 || skipped != len(dirnames)-1 {
+		t.Errorf("%d failed, %d skipped", failed, skipped)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	pw := &prefixWriter{mu: &sync.Mutex{}, prefix: "root-modules/foo: ", w: b}
+	for _, s := range []string{"one\ntw", "o\n", "\nthree"} {
+		if _, err := pw.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := b.String(); s != "root-modules/foo: one\nroot-modules/foo: two\nroot-modules/foo: \n" {
+		t.Errorf("before Flush: %q", s)
+	}
+	if err := pw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); !strings.HasSuffix(s, "root-modules/foo: three\n") {
+		t.Errorf("after Flush: %q", s)
+	}
+	if pw.changes {
+		t.Error("changes but there was no Apply complete! line")
+	}
+}
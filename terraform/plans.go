@@ -0,0 +1,155 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/src-bin/substrate/naming"
+)
+
+// PlanFilename is where Plan saves its plan in each root module so that it
+// may be summarized later by ShowPlan.
+const PlanFilename = "substrate.tfplan"
+
+// PlanSummary is a machine-readable summary of a saved plan, suitable for
+// gating merges in CI on e.g. "no destroys in production". Domain,
+// Environment, Quality, and Region are inferred from the root module's
+// directory and Special is set instead of Domain for the deploy and network
+// accounts and the Substrate account, whose root modules are in admin.
+type PlanSummary struct {
+	RootModule                   string
+	Domain, Special              string `json:",omitempty"`
+	Environment, Quality, Region string `json:",omitempty"`
+	Add, Change, Destroy         int
+	Adds, Changes, Destroys      []string // resource addresses
+	Error                        string   `json:",omitempty"`
+}
+
+// NewPlanSummary returns an empty PlanSummary for the root module in dirname
+// with the account and region it belongs to filled in.
+func NewPlanSummary(dirname string) *PlanSummary {
+	s := &PlanSummary{
+		RootModule: dirname,
+		Adds:       []string{},
+		Changes:    []string{},
+		Destroys:   []string{},
+	}
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(dirname)), "/")
+	if len(parts) > 0 && parts[0] == RootModulesDirname {
+		parts = parts[1:]
+	}
+	if len(parts) == 0 {
+		return s
+	}
+	s.Region = parts[len(parts)-1]
+	switch parts[0] {
+	case naming.Deploy:
+		s.Special = parts[0]
+	case naming.Network:
+		s.Special = parts[0]
+		if len(parts) == 4 {
+			s.Environment, s.Quality = parts[1], parts[2]
+		}
+	case naming.Admin:
+		s.Special = naming.Substrate
+		if len(parts) == 3 {
+			s.Quality = parts[1]
+		}
+	default:
+		if len(parts) == 4 {
+			s.Domain, s.Environment, s.Quality = parts[0], parts[1], parts[2]
+		}
+	}
+	return s
+}
+
+// ParsePlanJSON fills in s from the output of `terraform show -json` for a
+// saved plan. Replacing a resource counts as both adding and destroying it,
+// the same as Terraform counts it.
+func (s *PlanSummary) ParsePlanJSON(b []byte) error {
+	var plan struct {
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Change  struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal(b, &plan); err != nil {
+		return err
+	}
+	for _, rc := range plan.ResourceChanges {
+		for _, action := range rc.Change.Actions {
+			switch action {
+			case "create":
+				s.Adds = append(s.Adds, rc.Address)
+			case "update":
+				s.Changes = append(s.Changes, rc.Address)
+			case "delete":
+				s.Destroys = append(s.Destroys, rc.Address)
+			} // "no-op" and "read" don't change anything
+		}
+	}
+	sort.Strings(s.Adds)
+	sort.Strings(s.Changes)
+	sort.Strings(s.Destroys)
+	s.Add, s.Change, s.Destroy = len(s.Adds), len(s.Changes), len(s.Destroys)
+	return nil
+}
+
+// PlanSummaries is the document `substrate terraform --format json plan`
+// prints, with totals across all its root modules for convenience.
+type PlanSummaries struct {
+	Add, Change, Destroy int
+	RootModules          []*PlanSummary
+}
+
+func NewPlanSummaries(summaries []*PlanSummary) *PlanSummaries {
+	doc := &PlanSummaries{RootModules: summaries}
+	for _, s := range summaries {
+		doc.Add += s.Add
+		doc.Change += s.Change
+		doc.Destroy += s.Destroy
+	}
+	return doc
+}
+
+// RemovePlans removes the plan saved in PlanFilename in each of dirnames, if
+// any, because it's stale once Terraform plans or applies again and ShowPlan
+// mustn't report it as current.
+func RemovePlans(dirnames ...string) error {
+	for _, dirname := range dirnames {
+		if err := os.Remove(filepath.Join(dirname, PlanFilename)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShowPlan summarizes the plan saved in PlanFilename in dirname by Plan or
+// `terraform plan -out`.
+func ShowPlan(dirname string) (*PlanSummary, error) {
+	s := NewPlanSummary(dirname)
+	if _, err := os.Stat(filepath.Join(dirname, PlanFilename)); err != nil {
+		return nil, err // clearer than whatever Terraform would say
+	}
+	cmd := exec.Command("terraform", "show", "-json", PlanFilename)
+	cmd.Dir = dirname
+	stdout := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	if err := s.ParsePlanJSON(stdout.Bytes()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,71 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewPlanSummary(t *testing.T) {
+	for dirname, expected := range map[string]*PlanSummary{
+		"root-modules/www/production/default/us-west-2": {Domain: "www", Environment: "production", Quality: "default", Region: "us-west-2"},
+		"root-modules/www/production/default/global":    {Domain: "www", Environment: "production", Quality: "default", Region: "global"},
+		"root-modules/deploy/us-west-2":                 {Special: "deploy", Region: "us-west-2"},
+		"root-modules/network/staging/beta/us-east-1":   {Special: "network", Environment: "staging", Quality: "beta", Region: "us-east-1"},
+		"root-modules/admin/default/global":             {Special: "Substrate", Quality: "default", Region: "global"},
+	} {
+		expected.RootModule = dirname
+		expected.Adds, expected.Changes, expected.Destroys = []string{}, []string{}, []string{}
+		if actual := NewPlanSummary(dirname); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("NewPlanSummary(%q): %+v, expected %+v", dirname, actual, expected)
+		}
+	}
+}
+
+func TestParsePlanJSON(t *testing.T) {
+	s := NewPlanSummary("root-modules/www/production/default/us-west-2")
+	if err := s.ParsePlanJSON([]byte(`{
+		"format_version": "1.2",
+		"resource_changes": [
+			{"address": "module.www.aws_s3_bucket.b", "change": {"actions": ["create"]}},
+			{"address": "module.www.aws_instance.a", "change": {"actions": ["delete", "create"]}},
+			{"address": "module.www.aws_iam_role.r", "change": {"actions": ["update"]}},
+			{"address": "module.www.aws_sqs_queue.q", "change": {"actions": ["delete"]}},
+			{"address": "module.www.aws_vpc.v", "change": {"actions": ["no-op"]}},
+			{"address": "module.www.data.aws_region.current", "change": {"actions": ["read"]}}
+		]
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+	if s.Add != 2 || s.Change != 1 || s.Destroy != 2 {
+		t.Errorf("%d to add, %d to change, %d to destroy", s.Add, s.Change, s.Destroy)
+	}
+	if expected := []string{"module.www.aws_instance.a", "module.www.aws_s3_bucket.b"}; !reflect.DeepEqual(s.Adds, expected) {
+		t.Errorf("Adds: %q, expected %q", s.Adds, expected)
+	}
+	if expected := []string{"module.www.aws_iam_role.r"}; !reflect.DeepEqual(s.Changes, expected) {
+		t.Errorf("Changes: %q, expected %q", s.Changes, expected)
+	}
+	if expected := []string{"module.www.aws_instance.a", "module.www.aws_sqs_queue.q"}; !reflect.DeepEqual(s.Destroys, expected) {
+		t.Errorf("Destroys: %q, expected %q", s.Destroys, expected)
+	}
+
+	doc := NewPlanSummaries([]*PlanSummary{s, NewPlanSummary("root-modules/deploy/global")})
+	if doc.Add != 2 || doc.Change != 1 || doc.Destroy != 2 || len(doc.RootModules) != 2 {
+		t.Errorf("%+v", doc)
+	}
+}
+
+func TestRemovePlans(t *testing.T) {
+	dirname1, dirname2 := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(dirname1, PlanFilename), []byte("stale"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemovePlans(dirname1, dirname2); err != nil { // not an error that dirname2 has no plan
+		t.Fatal(err)
+	}
+	if _, err := ShowPlan(dirname1); !os.IsNotExist(err) {
+		t.Fatalf("expected ShowPlan to find no plan, got %v", err)
+	}
+}
@@ -167,6 +167,7 @@ func EnsureStateManager(ctx context.Context, cfg *awscfg.Config) (*awsiam.Role,
 		cfg,
 		roles.TerraformStateManager,
 		policies.AssumeRolePolicyDocument(&policies.Principal{AWS: terraformPrincipals}),
+		nil,
 	)
 	if err != nil {
 		return nil, ui.StopErr(err)